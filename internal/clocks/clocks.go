@@ -0,0 +1,107 @@
+// Package clocks provides sources of time which can be swapped out to make tests deterministic.
+package clocks
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock is a source of time.
+type Clock interface {
+	Now() time.Time
+	// After returns a channel which receives the current time once d has elapsed,
+	// and a func which must be called to release the wait if the caller stops waiting early.
+	After(d time.Duration) (<-chan time.Time, func())
+}
+
+// Real is the system clock.
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+func (Real) After(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTimer(d)
+	return t.C, func() { t.Stop() }
+}
+
+// Manual is a Clock which only advances when it is told to.
+type Manual struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []manualWaiter
+}
+
+type manualWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewManual returns a Manual clock which is stopped at start.
+func NewManual(start time.Time) *Manual {
+	return &Manual{now: start}
+}
+
+func (mc *Manual) Now() time.Time {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.now
+}
+
+func (mc *Manual) After(d time.Duration) (<-chan time.Time, func()) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- mc.now
+		return ch, func() {}
+	}
+	mc.waiters = append(mc.waiters, manualWaiter{deadline: mc.now.Add(d), ch: ch})
+	return ch, func() { mc.stop(ch) }
+}
+
+// stop removes the waiter which receives on ch, if it has not already been woken.
+func (mc *Manual) stop(ch chan time.Time) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.waiters = slices.DeleteFunc(mc.waiters, func(w manualWaiter) bool {
+		return w.ch == ch
+	})
+}
+
+// Advance moves the clock forward by d, and wakes any waiters whose deadline has passed.
+func (mc *Manual) Advance(d time.Duration) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.setNow(mc.now.Add(d))
+}
+
+// Set moves the clock to t, which must not be before the current time.
+func (mc *Manual) Set(t time.Time) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if t.Before(mc.now) {
+		panic("clocks.Manual: cannot move backwards")
+	}
+	mc.setNow(t)
+}
+
+// Waiters returns the number of calls to After which are waiting for the clock to advance.
+func (mc *Manual) Waiters() int {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return len(mc.waiters)
+}
+
+func (mc *Manual) setNow(t time.Time) {
+	mc.now = t
+	mc.waiters = slices.DeleteFunc(mc.waiters, func(w manualWaiter) bool {
+		if w.deadline.After(t) {
+			return false
+		}
+		w.ch <- t
+		return true
+	})
+}
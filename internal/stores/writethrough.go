@@ -0,0 +1,57 @@
+package stores
+
+import (
+	"context"
+	"errors"
+
+	"myceliumweb.org/mycelium/internal/cadata"
+)
+
+var _ cadata.Store = WriteThrough{}
+
+// WriteThrough is a Store which writes to both Cache and Store,
+// and reads from Cache before Store.
+type WriteThrough struct {
+	Cache *Mem
+	Store cadata.Store
+}
+
+func (s WriteThrough) Post(ctx context.Context, salt *cadata.ID, data []byte) (cadata.ID, error) {
+	id, err := s.Cache.Post(ctx, salt, data)
+	if err != nil {
+		return cadata.ID{}, err
+	}
+	if _, err := s.Store.Post(ctx, salt, data); err != nil {
+		return cadata.ID{}, err
+	}
+	return id, nil
+}
+
+func (s WriteThrough) Get(ctx context.Context, id, salt *cadata.ID, buf []byte) (int, error) {
+	n, err := s.Cache.Get(ctx, id, salt, buf)
+	if !errors.As(err, &cadata.ErrNotFound{}) {
+		return n, err
+	}
+	n, err = s.Store.Get(ctx, id, salt, buf)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.Cache.Post(ctx, salt, buf[:n]); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s WriteThrough) Exists(ctx context.Context, id *cadata.ID) (bool, error) {
+	if yes, err := s.Cache.Exists(ctx, id); err != nil || yes {
+		return yes, err
+	}
+	return s.Store.Exists(ctx, id)
+}
+
+func (s WriteThrough) Delete(ctx context.Context, id *cadata.ID) error {
+	if err := s.Cache.Delete(ctx, id); err != nil {
+		return err
+	}
+	return s.Store.Delete(ctx, id)
+}
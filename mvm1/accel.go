@@ -2,7 +2,6 @@ package mvm1
 
 import (
 	"errors"
-	"math"
 	"math/bits"
 
 	"golang.org/x/exp/maps"
@@ -83,6 +82,28 @@ var defaultAccels = map[Fingerprint]AccelFunc{
 	},
 
 	// B64
+	accKey(myccanon.B64_NOT): func(x []Word) error {
+		x[0], x[1] = ^x[0], ^x[1]
+		return nil
+	},
+	accKey(myccanon.B64_AND): func(x []Word) error {
+		b64BinaryOp(x, func(a, b uint64) uint64 {
+			return a & b
+		})
+		return nil
+	},
+	accKey(myccanon.B64_OR): func(x []Word) error {
+		b64BinaryOp(x, func(a, b uint64) uint64 {
+			return a | b
+		})
+		return nil
+	},
+	accKey(myccanon.B64_XOR): func(x []Word) error {
+		b64BinaryOp(x, func(a, b uint64) uint64 {
+			return a ^ b
+		})
+		return nil
+	},
 	accKey(myccanon.B64_POPCOUNT): func(x []Word) error {
 		y := getUint64(x)
 		y = uint64(bits.OnesCount64(y))
@@ -122,43 +143,6 @@ var defaultAccels = map[Fingerprint]AccelFunc{
 		})
 		return nil
 	},
-
-	accKey(myccanon.Float32_Neg): func(x []Word) error {
-		f := math.Float32frombits(x[0])
-		f = 0 - f
-		x[0] = math.Float32bits(f)
-		return nil
-	},
-	accKey(myccanon.Float32_Recip): func(x []Word) error {
-		f := math.Float32frombits(x[0])
-		f = 1 / f
-		x[0] = math.Float32bits(f)
-		return nil
-	},
-	accKey(myccanon.Float32_Add): func(x []Word) error {
-		float32BinaryOp(x, func(a, b float32) float32 {
-			return a + b
-		})
-		return nil
-	},
-	accKey(myccanon.Float32_Sub): func(x []Word) error {
-		float32BinaryOp(x, func(a, b float32) float32 {
-			return a - b
-		})
-		return nil
-	},
-	accKey(myccanon.Float32_Mul): func(x []Word) error {
-		float32BinaryOp(x, func(a, b float32) float32 {
-			return a * b
-		})
-		return nil
-	},
-	accKey(myccanon.Float32_Div): func(x []Word) error {
-		float32BinaryOp(x, func(a, b float32) float32 {
-			return a / b
-		})
-		return nil
-	},
 }
 
 func getUint64(ws []Word) uint64 {
@@ -176,16 +160,7 @@ func b64BinaryOp(ws []Word, fn func(a, b uint64) uint64) {
 	putUint64(ws, y)
 }
 
-func float32BinaryOp(ws []Word, fn func(a, b float32) float32) {
-	a := math.Float32frombits(ws[0])
-	b := math.Float32frombits(ws[1])
-	o := fn(a, b)
-	ws[0] = math.Float32bits(o)
-}
-
 // accKey is the key used for accelerators
-func accKey(lam *myc.Lambda) (ret Fingerprint) {
-	fp := myc.Fingerprint(lam)
-	bytesToWords(fp[:], ret[:])
-	return ret
+func accKey(lam *myc.Lambda) Fingerprint {
+	return FingerprintOf(lam)
 }
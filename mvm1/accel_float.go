@@ -0,0 +1,244 @@
+package mvm1
+
+import (
+	"math"
+
+	"golang.org/x/exp/maps"
+
+	"myceliumweb.org/mycelium/myccanon"
+)
+
+const (
+	canonicalNaN16 = 0x7e00
+	canonicalNaN32 = 0x7fc0_0000
+	canonicalNaN64 = 0x7ff8_0000_0000_0000
+)
+
+func init() {
+	maps.Copy(defaultAccels, floatAccels)
+}
+
+// floatAccels are the accelerators for the floating point lambdas in myccanon.
+// NaN results are replaced with the canonical NaN, so the results are the same as the pure definitions on every platform.
+var floatAccels = map[Fingerprint]AccelFunc{
+	// Float16
+	accKey(myccanon.Float16_FromFloat32): func(x []Word) error {
+		x[0] = Word(float16FromFloat64(float64(getFloat32(x))))
+		return nil
+	},
+	accKey(myccanon.Float16_FromFloat64): func(x []Word) error {
+		x[0] = Word(float16FromFloat64(getFloat64(x)))
+		return nil
+	},
+
+	// Float32
+	accKey(myccanon.Float32_Neg): func(x []Word) error {
+		x[0] ^= 1 << 31
+		return nil
+	},
+	accKey(myccanon.Float32_Recip): func(x []Word) error {
+		putFloat32(x, 1/getFloat32(x))
+		return nil
+	},
+	accKey(myccanon.Float32_Add): float32BinaryOp(func(a, b float32) float32 { return a + b }),
+	accKey(myccanon.Float32_Sub): float32BinaryOp(func(a, b float32) float32 { return a - b }),
+	accKey(myccanon.Float32_Mul): float32BinaryOp(func(a, b float32) float32 { return a * b }),
+	accKey(myccanon.Float32_Div): float32BinaryOp(func(a, b float32) float32 { return a / b }),
+
+	accKey(myccanon.Float32_Eq): float32Compare(func(a, b float32) bool { return a == b }),
+	accKey(myccanon.Float32_Lt): float32Compare(func(a, b float32) bool { return a < b }),
+	accKey(myccanon.Float32_Le): float32Compare(func(a, b float32) bool { return a <= b }),
+	accKey(myccanon.Float32_Gt): float32Compare(func(a, b float32) bool { return a > b }),
+	accKey(myccanon.Float32_Ge): float32Compare(func(a, b float32) bool { return a >= b }),
+
+	accKey(myccanon.Float32_FromB32S): func(x []Word) error {
+		putFloat32(x, float32(int32(x[0])))
+		return nil
+	},
+	accKey(myccanon.Float32_FromB64S): func(x []Word) error {
+		putFloat32(x, float32(int64(getUint64(x))))
+		return nil
+	},
+	accKey(myccanon.Float32_ToB32S): func(x []Word) error {
+		x[0] = Word(floatToInt(float64(getFloat32(x)), 32))
+		return nil
+	},
+	accKey(myccanon.Float32_ToB64S): func(x []Word) error {
+		putUint64(x, uint64(floatToInt(float64(getFloat32(x)), 64)))
+		return nil
+	},
+	accKey(myccanon.Float32_FromFloat16): func(x []Word) error {
+		putFloat32(x, float32(float16ToFloat64(uint16(x[0]))))
+		return nil
+	},
+	accKey(myccanon.Float32_FromFloat64): func(x []Word) error {
+		putFloat32(x, float32(getFloat64(x)))
+		return nil
+	},
+
+	// Float64
+	accKey(myccanon.Float64_Neg): func(x []Word) error {
+		x[1] ^= 1 << 31
+		return nil
+	},
+	accKey(myccanon.Float64_Recip): func(x []Word) error {
+		putFloat64(x, 1/getFloat64(x))
+		return nil
+	},
+	accKey(myccanon.Float64_Add): float64BinaryOp(func(a, b float64) float64 { return a + b }),
+	accKey(myccanon.Float64_Sub): float64BinaryOp(func(a, b float64) float64 { return a - b }),
+	accKey(myccanon.Float64_Mul): float64BinaryOp(func(a, b float64) float64 { return a * b }),
+	accKey(myccanon.Float64_Div): float64BinaryOp(func(a, b float64) float64 { return a / b }),
+
+	accKey(myccanon.Float64_Eq): float64Compare(func(a, b float64) bool { return a == b }),
+	accKey(myccanon.Float64_Lt): float64Compare(func(a, b float64) bool { return a < b }),
+	accKey(myccanon.Float64_Le): float64Compare(func(a, b float64) bool { return a <= b }),
+	accKey(myccanon.Float64_Gt): float64Compare(func(a, b float64) bool { return a > b }),
+	accKey(myccanon.Float64_Ge): float64Compare(func(a, b float64) bool { return a >= b }),
+
+	accKey(myccanon.Float64_FromB32S): func(x []Word) error {
+		putFloat64(x, float64(int32(x[0])))
+		return nil
+	},
+	accKey(myccanon.Float64_FromB64S): func(x []Word) error {
+		putFloat64(x, float64(int64(getUint64(x))))
+		return nil
+	},
+	accKey(myccanon.Float64_ToB32S): func(x []Word) error {
+		x[0] = Word(floatToInt(getFloat64(x), 32))
+		return nil
+	},
+	accKey(myccanon.Float64_ToB64S): func(x []Word) error {
+		putUint64(x, uint64(floatToInt(getFloat64(x), 64)))
+		return nil
+	},
+	accKey(myccanon.Float64_FromFloat16): func(x []Word) error {
+		putFloat64(x, float16ToFloat64(uint16(x[0])))
+		return nil
+	},
+	accKey(myccanon.Float64_FromFloat32): func(x []Word) error {
+		putFloat64(x, float64(getFloat32(x)))
+		return nil
+	},
+}
+
+func getFloat32(ws []Word) float32 {
+	return math.Float32frombits(ws[0])
+}
+
+func putFloat32(ws []Word, x float32) {
+	if x != x {
+		ws[0] = canonicalNaN32
+		return
+	}
+	ws[0] = math.Float32bits(x)
+}
+
+func getFloat64(ws []Word) float64 {
+	return math.Float64frombits(getUint64(ws))
+}
+
+func putFloat64(ws []Word, x float64) {
+	if x != x {
+		putUint64(ws, canonicalNaN64)
+		return
+	}
+	putUint64(ws, math.Float64bits(x))
+}
+
+func float32BinaryOp(fn func(a, b float32) float32) AccelFunc {
+	return func(ws []Word) error {
+		putFloat32(ws, fn(getFloat32(ws[0:1]), getFloat32(ws[1:2])))
+		return nil
+	}
+}
+
+func float64BinaryOp(fn func(a, b float64) float64) AccelFunc {
+	return func(ws []Word) error {
+		putFloat64(ws, fn(getFloat64(ws[0:2]), getFloat64(ws[2:4])))
+		return nil
+	}
+}
+
+func float32Compare(fn func(a, b float32) bool) AccelFunc {
+	return func(ws []Word) error {
+		ws[0] = boolWord(fn(getFloat32(ws[0:1]), getFloat32(ws[1:2])))
+		return nil
+	}
+}
+
+func float64Compare(fn func(a, b float64) bool) AccelFunc {
+	return func(ws []Word) error {
+		ws[0] = boolWord(fn(getFloat64(ws[0:2]), getFloat64(ws[2:4])))
+		return nil
+	}
+}
+
+func boolWord(x bool) Word {
+	if x {
+		return 1
+	}
+	return 0
+}
+
+// floatToInt converts x to a bits sized two's complement integer.
+// It truncates toward zero, saturates when x is out of range, and returns 0 for NaN.
+func floatToInt(x float64, bits int) int64 {
+	limit := math.Ldexp(1, bits-1)
+	switch {
+	case x != x:
+		return 0
+	case x >= limit:
+		return 1<<(bits-1) - 1
+	case x < -limit:
+		return -1 << (bits - 1)
+	default:
+		return int64(x)
+	}
+}
+
+// float16ToFloat64 converts an IEEE-754 half precision float, which is always exact.
+func float16ToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp, mant := int(h>>10)&0x1f, float64(h&0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	default:
+		return sign * math.Ldexp(1024+mant, exp-25)
+	}
+}
+
+// float16FromFloat64 converts x to the nearest IEEE-754 half precision float, with ties to even.
+func float16FromFloat64(x float64) uint16 {
+	if x != x {
+		return canonicalNaN16
+	}
+	var sign uint16
+	if math.Signbit(x) {
+		sign = 0x8000
+	}
+	x = math.Abs(x)
+	switch {
+	case x >= 65520:
+		// 65504 is the largest finite half, and 65520 is halfway to the next power of 2.
+		return sign | 0x7c00
+	case x < math.Ldexp(1, -14):
+		// subnormal, in units of 2^-24. Rounding up to 1024 produces the smallest normal.
+		return sign | uint16(math.RoundToEven(math.Ldexp(x, 24)))
+	default:
+		_, e := math.Frexp(x)
+		e-- // x is in [2^e, 2^(e+1))
+		mant := math.RoundToEven(math.Ldexp(x, 10-e)) - 1024
+		// rounding up to 1024 carries into the exponent.
+		return sign | (uint16(e+15)<<10 + uint16(mant))
+	}
+}
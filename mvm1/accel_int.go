@@ -0,0 +1,202 @@
+package mvm1
+
+import (
+	"errors"
+	"math/big"
+	"math/bits"
+
+	"myceliumweb.org/mycelium/myccanon"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+// intLambdas are the canonical integer lambdas for a single width
+type intLambdas struct {
+	Bits int
+
+	Shl, Shr, RotL, RotR *myc.Lambda
+	Lt, LtS, Gt, GtS     *myc.Lambda
+	Min, Max             *myc.Lambda
+	Mod, MulWide         *myc.Lambda
+}
+
+func init() {
+	for _, il := range []intLambdas{
+		{
+			Bits: 8,
+			Shl:  myccanon.B8_Shl, Shr: myccanon.B8_Shr, RotL: myccanon.B8_RotL, RotR: myccanon.B8_RotR,
+			Lt: myccanon.B8_Lt, LtS: myccanon.B8_LtS, Gt: myccanon.B8_Gt, GtS: myccanon.B8_GtS,
+			Min: myccanon.B8_Min, Max: myccanon.B8_Max,
+			Mod: myccanon.B8_Mod, MulWide: myccanon.B8_MulWide,
+		},
+		{
+			Bits: 16,
+			Shl:  myccanon.B16_Shl, Shr: myccanon.B16_Shr, RotL: myccanon.B16_RotL, RotR: myccanon.B16_RotR,
+			Lt: myccanon.B16_Lt, LtS: myccanon.B16_LtS, Gt: myccanon.B16_Gt, GtS: myccanon.B16_GtS,
+			Min: myccanon.B16_Min, Max: myccanon.B16_Max,
+			Mod: myccanon.B16_Mod, MulWide: myccanon.B16_MulWide,
+		},
+		{
+			Bits: 32,
+			Shl:  myccanon.B32_Shl, Shr: myccanon.B32_Shr, RotL: myccanon.B32_RotL, RotR: myccanon.B32_RotR,
+			Lt: myccanon.B32_Lt, LtS: myccanon.B32_LtS, Gt: myccanon.B32_Gt, GtS: myccanon.B32_GtS,
+			Min: myccanon.B32_Min, Max: myccanon.B32_Max,
+			Mod: myccanon.B32_Mod, MulWide: myccanon.B32_MulWide,
+		},
+		{
+			Bits: 64,
+			Shl:  myccanon.B64_Shl, Shr: myccanon.B64_Shr, RotL: myccanon.B64_RotL, RotR: myccanon.B64_RotR,
+			Lt: myccanon.B64_Lt, LtS: myccanon.B64_LtS, Gt: myccanon.B64_Gt, GtS: myccanon.B64_GtS,
+			Min: myccanon.B64_Min, Max: myccanon.B64_Max,
+			Mod: myccanon.B64_Mod, MulWide: myccanon.B64_MulWide,
+		},
+		{
+			Bits: 128,
+			Shl:  myccanon.B128_Shl, Shr: myccanon.B128_Shr, RotL: myccanon.B128_RotL, RotR: myccanon.B128_RotR,
+			Lt: myccanon.B128_Lt, LtS: myccanon.B128_LtS, Gt: myccanon.B128_Gt, GtS: myccanon.B128_GtS,
+			Min: myccanon.B128_Min, Max: myccanon.B128_Max,
+			Mod: myccanon.B128_Mod, MulWide: myccanon.B128_MulWide,
+		},
+		{
+			Bits: 256,
+			Shl:  myccanon.B256_Shl, Shr: myccanon.B256_Shr, RotL: myccanon.B256_RotL, RotR: myccanon.B256_RotR,
+			Lt: myccanon.B256_Lt, LtS: myccanon.B256_LtS, Gt: myccanon.B256_Gt, GtS: myccanon.B256_GtS,
+			Min: myccanon.B256_Min, Max: myccanon.B256_Max,
+			Mod: myccanon.B256_Mod, MulWide: myccanon.B256_MulWide,
+		},
+	} {
+		addIntAccels(defaultAccels, il)
+	}
+}
+
+// addIntAccels adds accelerators for il to m.
+// The same implementation, using big.Int, is used for every width.
+func addIntAccels(m map[Fingerprint]AccelFunc, il intLambdas) {
+	n := il.Bits
+	modulus := new(big.Int).Lsh(big.NewInt(1), uint(n))
+	shift := func(fn func(x *big.Int, amt uint32) *big.Int) AccelFunc {
+		return func(ws []Word) error {
+			x := getBig(ws, 0, n)
+			amt := getBits(ws, n, 32)
+			putBig(ws, n, fn(x, uint32(amt)))
+			return nil
+		}
+	}
+	binary := func(outBits int, fn func(a, b *big.Int) (*big.Int, error)) AccelFunc {
+		return func(ws []Word) error {
+			a, b := getBig(ws, 0, n), getBig(ws, n, n)
+			y, err := fn(a, b)
+			if err != nil {
+				return err
+			}
+			putBig(ws, outBits, y)
+			return nil
+		}
+	}
+	compare := func(signed bool, fn func(c int) bool) AccelFunc {
+		return binary(1, func(a, b *big.Int) (*big.Int, error) {
+			if signed {
+				a, b = toSigned(a, n), toSigned(b, n)
+			}
+			if fn(a.Cmp(b)) {
+				return big.NewInt(1), nil
+			}
+			return big.NewInt(0), nil
+		})
+	}
+	rotate := func(x *big.Int, amt uint32) *big.Int {
+		amt %= uint32(n)
+		hi := new(big.Int).Lsh(x, uint(amt))
+		lo := new(big.Int).Rsh(x, uint(n)-uint(amt))
+		return hi.Or(hi, lo).Mod(hi, modulus)
+	}
+	lt := func(c int) bool { return c < 0 }
+	gt := func(c int) bool { return c > 0 }
+
+	m[accKey(il.Shl)] = shift(func(x *big.Int, amt uint32) *big.Int {
+		if amt >= uint32(n) {
+			return new(big.Int)
+		}
+		x.Lsh(x, uint(amt))
+		return x.Mod(x, modulus)
+	})
+	m[accKey(il.Shr)] = shift(func(x *big.Int, amt uint32) *big.Int {
+		if amt >= uint32(n) {
+			return new(big.Int)
+		}
+		return x.Rsh(x, uint(amt))
+	})
+	m[accKey(il.RotL)] = shift(rotate)
+	m[accKey(il.RotR)] = shift(func(x *big.Int, amt uint32) *big.Int {
+		return rotate(x, uint32(n)-amt%uint32(n))
+	})
+	m[accKey(il.Lt)] = compare(false, lt)
+	m[accKey(il.LtS)] = compare(true, lt)
+	m[accKey(il.Gt)] = compare(false, gt)
+	m[accKey(il.GtS)] = compare(true, gt)
+	m[accKey(il.Min)] = binary(n, func(a, b *big.Int) (*big.Int, error) {
+		if a.Cmp(b) < 0 {
+			return a, nil
+		}
+		return b, nil
+	})
+	m[accKey(il.Max)] = binary(n, func(a, b *big.Int) (*big.Int, error) {
+		if a.Cmp(b) < 0 {
+			return b, nil
+		}
+		return a, nil
+	})
+	m[accKey(il.Mod)] = binary(n, func(a, b *big.Int) (*big.Int, error) {
+		if b.Sign() == 0 {
+			return nil, errors.New("divide by 0")
+		}
+		return a.Mod(a, b), nil
+	})
+	m[accKey(il.MulWide)] = binary(2*n, func(a, b *big.Int) (*big.Int, error) {
+		return a.Mul(a, b), nil
+	})
+}
+
+// getBits returns n <= 64 bits starting at bit beg
+func getBits(ws []Word, beg, n int) uint64 {
+	var ret uint64
+	for i := 0; i < n; {
+		w := ws[(beg+i)/WordBits] >> ((beg + i) % WordBits)
+		k := min(WordBits-(beg+i)%WordBits, n-i)
+		ret |= (uint64(w) & (1<<k - 1)) << i
+		i += k
+	}
+	return ret
+}
+
+// getBig returns n bits starting at bit beg, as an unsigned integer
+func getBig(ws []Word, beg, n int) *big.Int {
+	limbs := make([]big.Word, (n+bits.UintSize-1)/bits.UintSize)
+	for i := range limbs {
+		k := min(bits.UintSize, n-i*bits.UintSize)
+		limbs[i] = big.Word(getBits(ws, beg+i*bits.UintSize, k))
+	}
+	return new(big.Int).SetBits(limbs)
+}
+
+// putBig writes the low n bits of x to the start of ws, and zeros the rest of the last word.
+func putBig(ws []Word, n int, x *big.Int) {
+	limbs := x.Bits()
+	for i := 0; i < AlignSize(n)/WordBits; i++ {
+		var w Word
+		if j := i * WordBits / bits.UintSize; j < len(limbs) {
+			w = Word(uint64(limbs[j]) >> (i * WordBits % bits.UintSize))
+		}
+		if rem := n - i*WordBits; rem < WordBits {
+			w &= 1<<rem - 1
+		}
+		ws[i] = w
+	}
+}
+
+// toSigned interprets the n bit unsigned integer x as two's complement
+func toSigned(x *big.Int, n int) *big.Int {
+	if x.Bit(n-1) == 0 {
+		return x
+	}
+	return new(big.Int).Sub(x, new(big.Int).Lsh(big.NewInt(1), uint(n)))
+}
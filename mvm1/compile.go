@@ -167,7 +167,9 @@ func (c *Compiler) compile(ctx context.Context, mc machCtx, x myc.Prog) (*Prog,
 	case spec.Branch:
 		return c.compileBranch(ctx, mc, x.Input(0), x.Input(1), x.Input(2))
 	case spec.Try:
-		panic(x) // TODO
+		// Fault values do not have a type yet, so there is nothing for Try to return.
+		// Faults can only be observed from outside the VM, see VM.Err and VM.ExceededLimit.
+		return nil, fmt.Errorf("mvm1: Try is not supported")
 	}
 
 	// ops in this block evaluate some of their arguments at compile time, but do not modify the machine context
@@ -213,13 +215,61 @@ func (c *Compiler) compile(ctx context.Context, mc machCtx, x myc.Prog) (*Prog,
 		return nil, err
 	}
 	mc.addScratch(-1 * scratch)
+	setNode(prog2.I, uint32(len(x)-1))
 	out = append(out, prog2.I...)
+	value := prog2.Value
+	if value == nil {
+		value = foldConstant(x.Code(), argTypes, argValues)
+	}
 	return &Prog{
-		Type: prog2.Type,
-		I:    out,
+		Type:  prog2.Type,
+		I:     out,
+		Value: value,
 	}, nil
 }
 
+// foldConstant returns the value of building an array or product from constants.
+// It returns nil if the result is not known at compile time.
+// This allows arrays built element by element, like a table for Mux, to be used where constants are required.
+func foldConstant(code spec.Op, argTypes [4]Type, argValues [4][]Word) []Word {
+	switch code {
+	case spec.ArrayUnit, spec.ProductUnit:
+		return argValues[0]
+	case spec.Concat:
+		if argValues[0] == nil || argValues[1] == nil {
+			return nil
+		}
+		leftBits, rightBits := argTypes[0].Size, argTypes[1].Size
+		out := make([]Word, 0, len(argValues[0])+len(argValues[1]))
+		out = append(out, argValues[0]...)
+		out = append(out, argValues[1]...)
+		cutBits(out, leftBits, AlignSize(leftBits))
+		return out[:AlignSize(leftBits+rightBits)/WordBits]
+	default:
+		return nil
+	}
+}
+
+// setNode records the position of a node in the body being compiled
+// on the last instruction in is, if it is a call or a fault.
+// Inputs to a node are always a prefix of the body, so the position of x's root is len(x)-1.
+func setNode(is []I, node uint32) {
+	if len(is) == 0 {
+		return
+	}
+	switch ix := is[len(is)-1].(type) {
+	case applyI:
+		ix.node = node
+		is[len(is)-1] = ix
+	case accelI:
+		ix.node = node
+		is[len(is)-1] = ix
+	case panicI:
+		ix.node = node
+		is[len(is)-1] = ix
+	}
+}
+
 func (c *Compiler) compileTypeOf(ctx context.Context, mc machCtx, a0 myc.Prog) (*Prog, error) {
 	x, err := c.compile(ctx, mc, a0)
 	if err != nil {
@@ -377,11 +427,17 @@ func (c *Compiler) compileBranch(ctx context.Context, mc machCtx, checkE, b0E, b
 	if err != nil {
 		return nil, err
 	}
-	if !b0.Type.Equals(&b1.Type) {
+	// a branch which always faults can take on the type of the other branch.
+	ty := b0.Type
+	switch {
+	case alwaysFaults(b0.I):
+		ty = b1.Type
+	case alwaysFaults(b1.I):
+	case !b0.Type.Equals(&b1.Type):
 		return nil, fmt.Errorf("branch: mismatched types")
 	}
 	return &Prog{
-		Type: b0.Type,
+		Type: ty,
 		I: seq(
 			check.I,
 			mkBranch(b0.I, b1.I),
@@ -389,6 +445,15 @@ func (c *Compiler) compileBranch(ctx context.Context, mc machCtx, checkE, b0E, b
 	}, nil
 }
 
+// alwaysFaults returns true if is ends by unconditionally faulting.
+func alwaysFaults(is []I) bool {
+	if len(is) == 0 {
+		return false
+	}
+	_, ok := is[len(is)-1].(panicI)
+	return ok
+}
+
 func (c *Compiler) compileLet(ctx context.Context, mc machCtx, bindE, bodyE myc.Prog) (*Prog, error) {
 	bind, err := c.compile(ctx, mc, bindE)
 	if err != nil {
@@ -765,6 +830,7 @@ func (c *Compiler) compileSimple(ctx context.Context, mc machCtx, code spec.Op,
 							inputWords:  inputType.SizeWords(),
 							outputWords: outputType.SizeWords(),
 							fn:          fn,
+							fp:          fp,
 						},
 					},
 				}, nil
@@ -1210,7 +1276,7 @@ func (c *Compiler) compileSlot(ctx context.Context, x Type, idx Type) (*Prog, er
 		arrayLen := at.Len()
 		elemSize := elemType.Size
 		return &Prog{
-			Type: Type{Size: elemSize},
+			Type: elemType,
 			I: []I{arrayGetI{
 				len:      arrayLen,
 				elemSize: elemSize,
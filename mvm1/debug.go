@@ -0,0 +1,201 @@
+package mvm1
+
+import (
+	"context"
+	"fmt"
+
+	"myceliumweb.org/mycelium/internal/bitbuf"
+	mycelium "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/spec"
+)
+
+// Frame is a Lambda or Lazy on the VM's call stack.
+type Frame struct {
+	// Fingerprint is the fingerprint of the Lambda or Lazy.
+	Fingerprint Fingerprint
+	// Value is the Lambda or Lazy being evaluated.
+	// Value is nil if the frame is the top level evaluation, or if the type of the frame is not known.
+	Value mycelium.Value
+	// PC is the position of the next instruction in the frame's program.
+	// For frames which are not the current frame, it is the position of the call.
+	PC uint32
+	// Node is the position, in the frame's body, of the Apply or Panic node the frame is executing.
+	// It is 0 if the frame is not executing a call or a fault, or if the position is not known.
+	Node uint32
+}
+
+// Site returns the Site of the node the frame is executing.
+func (fr Frame) Site() Site {
+	return Site{Fingerprint: fr.Fingerprint, Node: fr.Node}
+}
+
+// Site is the position of a node in the body of a Lambda or Lazy.
+type Site struct {
+	Fingerprint Fingerprint
+	Node        uint32
+}
+
+// SourceMap resolves Sites to positions in source code.
+type SourceMap interface {
+	// Lookup returns a description of the source position of site, such as "main.sp:12"
+	Lookup(site Site) (string, bool)
+}
+
+// StackTrace returns the source position of each frame on the call stack, starting with the current frame.
+// After a fault, the first entry is the position of the fault.
+// Frames which have no position in sm are described by their fingerprint.
+func (vm *VM) StackTrace(ctx context.Context, sm SourceMap) []string {
+	var ret []string
+	for _, fr := range vm.Frames(ctx) {
+		if fr.Node != 0 && sm != nil {
+			if pos, ok := sm.Lookup(fr.Site()); ok {
+				ret = append(ret, pos)
+				continue
+			}
+		}
+		if fr.Fingerprint == (Fingerprint{}) {
+			ret = append(ret, "<eval>")
+		} else {
+			ret = append(ret, fmt.Sprintf("%v#%d", fr.Fingerprint, fr.Node))
+		}
+	}
+	return ret
+}
+
+// FingerprintOf returns the Fingerprint of x.
+// It is the same as the Fingerprint computed by the VM for x.
+func FingerprintOf(x mycelium.Value) (ret Fingerprint) {
+	fp := mycelium.Fingerprint(x)
+	bytesToWords(fp[:], ret[:])
+	return ret
+}
+
+// SetBreakpoint causes Run to return immediately after the VM calls the Lambda or Lazy with Fingerprint fp.
+// Lambdas which are replaced by accelerators are never called, so breakpoints on them have no effect.
+func (vm *VM) SetBreakpoint(fp Fingerprint) {
+	if vm.breakpoints == nil {
+		vm.breakpoints = make(map[Fingerprint]struct{})
+	}
+	vm.breakpoints[fp] = struct{}{}
+}
+
+// ClearBreakpoint removes a breakpoint set with SetBreakpoint
+func (vm *VM) ClearBreakpoint(fp Fingerprint) {
+	delete(vm.breakpoints, fp)
+}
+
+// AtBreakpoint returns true if the last call to Run returned because of a breakpoint.
+func (vm *VM) AtBreakpoint() bool {
+	return vm.atBreakpoint
+}
+
+// Step executes a single instruction.
+// Step returns false if the VM has halted.
+func (vm *VM) Step(ctx context.Context) bool {
+	return vm.Run(ctx, 1) > 0
+}
+
+// Halted returns true if the VM will not take any more steps.
+func (vm *VM) Halted() bool {
+	return !vm.isAlive()
+}
+
+// PC returns the position of the next instruction in the current program.
+func (vm *VM) PC() uint32 {
+	return vm.pc
+}
+
+// Frames returns the call stack, starting with the current frame.
+func (vm *VM) Frames(ctx context.Context) []Frame {
+	if vm.ctx == nil {
+		vm.ctx = ctx
+		defer func() { vm.ctx = nil }()
+	}
+	frames := []Frame{vm.frame(ctx, &vm.self, vm.pc)}
+	if vm.pc > 0 {
+		frames[0].Node = nodeAt(vm.prog, vm.pc-1)
+	}
+	for i := len(vm.calls) - 1; i >= 0; i-- {
+		call := &vm.calls[i]
+		fr := vm.frame(ctx, &call.From, call.Pos)
+		var prog []I
+		if call.isLambda() {
+			prog, _ = vm.loadLambda(call.lambdaType(), call.lambda())
+		} else {
+			prog, _ = vm.loadLazy(call.lazy())
+		}
+		fr.Node = nodeAt(prog, call.Pos)
+		frames = append(frames, fr)
+	}
+	return frames
+}
+
+// NextNode returns the position, in the current frame's body, of the next call or fault the VM will execute in that frame.
+// It returns false if the frame has no more calls or faults, or if their positions are not known.
+func (vm *VM) NextNode() (uint32, bool) {
+	for pc := vm.pc; int(pc) < len(vm.prog); pc++ {
+		if node := nodeAt(vm.prog, pc); node != 0 {
+			return node, true
+		}
+	}
+	return 0, false
+}
+
+// nodeAt returns the node recorded on the instruction at pc, or 0.
+func nodeAt(prog []I, pc uint32) uint32 {
+	if int(pc) >= len(prog) {
+		return 0
+	}
+	switch ix := prog[pc].(type) {
+	case applyI:
+		return ix.node
+	case accelI:
+		return ix.node
+	case panicI:
+		return ix.node
+	}
+	return 0
+}
+
+func (vm *VM) frame(ctx context.Context, dv *dynValue, pc uint32) Frame {
+	fr := Frame{PC: pc}
+	if dv.IsZero() {
+		return fr
+	}
+	fr.Fingerprint = dv.Fingerprint()
+	// Lazy frames do not carry their type.
+	if dv.t2.TypeCode() == spec.TC_Lambda {
+		fr.Value = dv.AsMycelium(ctx, vm.store)
+	}
+	return fr
+}
+
+// StackLen returns the number of words on the stack.
+func (vm *VM) StackLen() int {
+	return len(vm.stack)
+}
+
+// PeekValue decodes a value of type ty from the stack.
+// The value ends offset words below the top of the stack.
+func (vm *VM) PeekValue(ctx context.Context, ty mycelium.Type, offset int) (mycelium.Value, error) {
+	size := divCeil(ty.SizeOf(), WordBits)
+	end := len(vm.stack) - offset
+	if offset < 0 || end-size < 0 {
+		return nil, fmt.Errorf("stack has %d words, cannot read %d words at offset %d", len(vm.stack), size, offset)
+	}
+	data := makeBytes(vm.stack[end-size : end])
+	val := ty.Zero()
+	if err := val.Decode(bitbuf.FromBytes(data), func(ref mycelium.Ref) (mycelium.Value, error) {
+		return mycelium.Load(ctx, vm.store, ref)
+	}); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// checkBreakpoint is called when the VM enters target
+func (vm *VM) checkBreakpoint(target *dynValue) {
+	if _, exists := vm.breakpoints[target.Fingerprint()]; exists {
+		vm.atBreakpoint = true
+	}
+}
@@ -0,0 +1,110 @@
+package mvm1
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	mycelium "myceliumweb.org/mycelium/mycmem"
+)
+
+// deadlineCheckInterval is the number of steps between checks of the wall clock.
+const deadlineCheckInterval = 1 << 10
+
+// Limits constrains the resources that a VM can use.
+// The zero value for any field means that there is no limit.
+type Limits struct {
+	// MaxSteps is the maximum number of steps the VM can take since it was last Reset.
+	MaxSteps uint64
+	// MaxStackWords is the maximum number of Words which can be on the stack.
+	MaxStackWords int
+	// MaxPostBytes is the maximum number of bytes that can be posted to the store since the VM was last Reset.
+	MaxPostBytes int64
+	// Deadline is the time after which the VM will fault.
+	Deadline time.Time
+}
+
+// Limit identifies one of the fields in Limits
+type Limit uint8
+
+const (
+	LimitSteps Limit = iota + 1
+	LimitStackWords
+	LimitPostBytes
+	LimitDeadline
+)
+
+func (l Limit) String() string {
+	switch l {
+	case LimitSteps:
+		return "steps"
+	case LimitStackWords:
+		return "stack words"
+	case LimitPostBytes:
+		return "post bytes"
+	case LimitDeadline:
+		return "deadline"
+	default:
+		return fmt.Sprintf("Limit(%d)", uint8(l))
+	}
+}
+
+// ErrLimitExceeded is the error returned by VM.Err when the VM faults because it exceeded its Limits.
+type ErrLimitExceeded struct {
+	Limit Limit
+}
+
+func (e ErrLimitExceeded) Error() string {
+	return fmt.Sprintf("fault: exceeded limit on %v", e.Limit)
+}
+
+// SetLimits sets the limits for the VM.
+// Limits are not cleared by Reset, but the resources counted against them are.
+func (vm *VM) SetLimits(lim Limits) {
+	vm.limits = lim
+}
+
+// Steps returns the number of steps the VM has taken since it was last Reset.
+func (vm *VM) Steps() uint64 {
+	return vm.steps
+}
+
+// checkLimits faults the VM if it has exceeded any of its limits.
+// steps is the number of steps taken during the current call to Run.
+func (vm *VM) checkLimits(steps uint64) bool {
+	lim := &vm.limits
+	switch {
+	case lim.MaxSteps > 0 && vm.steps+steps >= lim.MaxSteps:
+		vm.faultLimit(LimitSteps)
+	case lim.MaxStackWords > 0 && len(vm.stack) > lim.MaxStackWords:
+		vm.faultLimit(LimitStackWords)
+	case lim.MaxPostBytes > 0 && vm.postBytes > lim.MaxPostBytes:
+		vm.faultLimit(LimitPostBytes)
+	case !lim.Deadline.IsZero() && steps%deadlineCheckInterval == 0 && time.Now().After(lim.Deadline):
+		vm.faultLimit(LimitDeadline)
+	default:
+		return true
+	}
+	return false
+}
+
+// faultLimit faults the machine, as if a Panic had occured.
+// The fault value is a String describing the limit, but the limit is only reported by Err and ExceededLimit,
+// so a Panic with the same value is not mistaken for it.
+func (vm *VM) faultLimit(l Limit) {
+	err := ErrLimitExceeded{Limit: l}
+	data, serr := mycelium.SaveRoot(vm.ctx, vm.store, mycelium.NewAnyValue(mycelium.NewString(err.Error())))
+	if serr == nil {
+		vm.panicVal.FromBytes(data)
+	}
+	vm.fail(err)
+}
+
+// ExceededLimit returns the Limit which caused the VM to fault, if it faulted because it exceeded its Limits.
+func (vm *VM) ExceededLimit() (Limit, bool) {
+	var err ErrLimitExceeded
+	if errors.As(vm.err, &err) {
+		return err.Limit, true
+	}
+	return 0, false
+}
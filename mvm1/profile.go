@@ -0,0 +1,127 @@
+package mvm1
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// Profiler is a Tracer which aggregates the steps taken in each call stack.
+// A Profiler can be used with many VMs, but only one at a time.
+// The aggregated profile can be written in the pprof format with WriteProfile.
+type Profiler struct {
+	stack   []Fingerprint
+	last    uint64
+	samples map[string]*profSample
+	order   []string
+}
+
+type profSample struct {
+	stack []Fingerprint
+	steps int64
+	calls int64
+}
+
+// NewProfiler returns an empty Profiler
+func NewProfiler() *Profiler {
+	return &Profiler{samples: make(map[string]*profSample)}
+}
+
+func (p *Profiler) Trace(ev TraceEvent) {
+	if ev.Steps > p.last {
+		p.sample(p.stack).steps += int64(ev.Steps - p.last)
+	}
+	p.last = ev.Steps
+	switch ev.Kind {
+	case EventCall:
+		p.stack = append(p.stack, ev.Fingerprint)
+		p.sample(p.stack).calls++
+	case EventReturn:
+		if len(p.stack) > 0 {
+			p.stack = p.stack[:len(p.stack)-1]
+		}
+	case EventAccel:
+		s := p.sample(append(p.stack[:len(p.stack):len(p.stack)], ev.Fingerprint))
+		s.calls++
+		// the accelerator takes a single step, which is attributed to it.
+		s.steps++
+		p.last++
+	case EventHalt:
+		// The outermost frame never returns.
+		// Reset so the Profiler can be used with another VM.
+		p.stack = p.stack[:0]
+		p.last = 0
+	}
+}
+
+// sample returns the sample for the stack, creating it if it does not exist.
+func (p *Profiler) sample(stack []Fingerprint) *profSample {
+	var sb strings.Builder
+	for _, fp := range stack {
+		for _, w := range fp {
+			sb.Write(binary.LittleEndian.AppendUint32(nil, w))
+		}
+	}
+	k := sb.String()
+	s, exists := p.samples[k]
+	if !exists {
+		s = &profSample{stack: append([]Fingerprint{}, stack...)}
+		p.samples[k] = s
+		p.order = append(p.order, k)
+	}
+	return s
+}
+
+// WriteProfile writes the profile to w as a gzip compressed pprof protocol buffer.
+// The profile has two sample types: "steps" and "calls".
+// name is used to resolve fingerprints to function names; it may return "" for unknown fingerprints.
+func (p *Profiler) WriteProfile(w io.Writer, name func(Fingerprint) string) error {
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "steps", Unit: "count"},
+			{Type: "calls", Unit: "count"},
+		},
+		DefaultSampleType: "steps",
+	}
+	// each function has a single location with the same id.
+	locs := make(map[Fingerprint]*profile.Location)
+	location := func(fp Fingerprint) *profile.Location {
+		if loc, exists := locs[fp]; exists {
+			return loc
+		}
+		n := ""
+		if name != nil {
+			n = name(fp)
+		}
+		if n == "" {
+			if fp == (Fingerprint{}) {
+				n = "<eval>"
+			} else {
+				n = fp.String()
+			}
+		}
+		id := uint64(len(locs) + 1)
+		fn := &profile.Function{ID: id, Name: n, SystemName: fp.String()}
+		loc := &profile.Location{ID: id, Line: []profile.Line{{Function: fn}}}
+		prof.Function = append(prof.Function, fn)
+		prof.Location = append(prof.Location, loc)
+		locs[fp] = loc
+		return loc
+	}
+	for _, k := range p.order {
+		s := p.samples[k]
+		stack := s.stack
+		if len(stack) == 0 {
+			stack = []Fingerprint{{}}
+		}
+		// pprof stacks start with the leaf
+		sample := &profile.Sample{Value: []int64{s.steps, s.calls}}
+		for i := len(stack) - 1; i >= 0; i-- {
+			sample.Location = append(sample.Location, location(stack[i]))
+		}
+		prof.Sample = append(prof.Sample, sample)
+	}
+	return prof.Write(w)
+}
@@ -0,0 +1,406 @@
+package mvm1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"myceliumweb.org/mycelium/internal/cadata"
+	mycelium "myceliumweb.org/mycelium/mycmem"
+)
+
+// recordingChunk is the number of port calls or blobs in each chunk of a Recording
+const recordingChunk = 1 << 8
+
+// PortOp is the kind of operation performed on a port.
+type PortOp uint8
+
+const (
+	PortInput PortOp = iota + 1
+	PortOutput
+	PortInteract
+)
+
+func (op PortOp) String() string {
+	switch op {
+	case PortInput:
+		return "input"
+	case PortOutput:
+		return "output"
+	case PortInteract:
+		return "interact"
+	default:
+		return fmt.Sprintf("PortOp(%d)", uint8(op))
+	}
+}
+
+// PortCall is a single call to a PortBackend, as seen by the VM.
+type PortCall struct {
+	Port Port
+	Op   PortOp
+	// In is the buffer passed to the backend.
+	In []Word
+	// Out is the buffer after the backend returned.
+	Out []Word
+	// Err is the error returned by the backend, if any.
+	Err string
+}
+
+// Blob is a blob read from the VM's store.
+type Blob struct {
+	Salt *cadata.ID
+	Data []byte
+}
+
+// Recorder records every port call made by a VM, and every blob the VM reads from its store.
+// Since ports are the only source of nondeterminism in the VM, the recorded calls
+// are enough to re-execute the VM exactly, using a Replayer.
+type Recorder struct {
+	mu    sync.Mutex
+	calls []PortCall
+	seen  map[cadata.ID]struct{}
+	blobs []Blob
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{seen: make(map[cadata.ID]struct{})}
+}
+
+// Calls returns the port calls recorded so far.
+func (r *Recorder) Calls() []PortCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.calls)
+}
+
+// Blobs returns the distinct blobs read by the VM so far, in the order they were first read.
+func (r *Recorder) Blobs() []Blob {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.blobs)
+}
+
+func (r *Recorder) recordCall(pc PortCall) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, pc)
+}
+
+func (r *Recorder) recordBlob(id cadata.ID, salt *cadata.ID, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.seen[id]; exists {
+		return
+	}
+	r.seen[id] = struct{}{}
+	var salt2 *cadata.ID
+	if salt != nil {
+		s := *salt
+		salt2 = &s
+	}
+	r.blobs = append(r.blobs, Blob{Salt: salt2, Data: slices.Clone(data)})
+}
+
+// SetRecorder sets a Recorder to receive every port call made by the VM, and every blob it reads.
+// Passing nil disables recording.
+func (vm *VM) SetRecorder(r *Recorder) {
+	if rs, ok := vm.store.(*recordingStore); ok {
+		vm.store = rs.Store
+	}
+	vm.recorder = r
+	if r != nil {
+		vm.store = &recordingStore{Store: vm.store, r: r}
+		// compiled functions would not be read from the store again.
+		vm.funcCache.Purge()
+	}
+}
+
+// recordPortIO calls fn and records the call.
+func (vm *VM) recordPortIO(pv Port, op PortOp, buf []Word, fn func([]Word) error) error {
+	pc := PortCall{
+		Port: pv,
+		Op:   op,
+		In:   slices.Clone(buf),
+	}
+	err := fn(buf)
+	pc.Out = slices.Clone(buf)
+	if err != nil {
+		pc.Err = err.Error()
+	}
+	vm.recorder.recordCall(pc)
+	return err
+}
+
+// recordingStore records every blob read from it.
+type recordingStore struct {
+	cadata.Store
+	r *Recorder
+}
+
+func (rs *recordingStore) Get(ctx context.Context, id *cadata.ID, salt *cadata.ID, buf []byte) (int, error) {
+	n, err := rs.Store.Get(ctx, id, salt, buf)
+	if err != nil {
+		return n, err
+	}
+	rs.r.recordBlob(*id, salt, buf[:n])
+	return n, nil
+}
+
+// ErrDivergence is returned by a replayed port when the VM does not make the same port call that was recorded.
+type ErrDivergence struct {
+	// Index is the index of the port call in the recording.
+	Index  int
+	Reason string
+}
+
+func (e ErrDivergence) Error() string {
+	return fmt.Sprintf("replay diverged at port call %d: %s", e.Index, e.Reason)
+}
+
+// Replayer serves port calls from a recording.
+// Each call made by the VM must match the next call in the recording, or the call fails with ErrDivergence.
+type Replayer struct {
+	mu    sync.Mutex
+	calls []PortCall
+	pos   int
+	err   error
+}
+
+func NewReplayer(calls []PortCall) *Replayer {
+	return &Replayer{calls: calls}
+}
+
+// Ports returns all of the distinct ports in the recording.
+func (rp *Replayer) Ports() []Port {
+	var ret []Port
+	for _, pc := range rp.calls {
+		if !slices.Contains(ret, pc.Port) {
+			ret = append(ret, pc.Port)
+		}
+	}
+	return ret
+}
+
+// Install adds a backend to vm for every port in the recording.
+func (rp *Replayer) Install(vm *VM) {
+	for _, pv := range rp.Ports() {
+		vm.PutPort(pv, rp.Backend(pv))
+	}
+}
+
+// Backend returns a PortBackend which serves calls to pv from the recording.
+func (rp *Replayer) Backend(pv Port) PortBackend {
+	return PortBackend{
+		Input: func(ctx context.Context, s cadata.PostExister, buf []Word) error {
+			return rp.replay(pv, PortInput, buf)
+		},
+		Output: func(ctx context.Context, s cadata.Getter, buf []Word) error {
+			return rp.replay(pv, PortOutput, buf)
+		},
+		Interact: func(ctx context.Context, s cadata.Store, buf []Word) error {
+			return rp.replay(pv, PortInteract, buf)
+		},
+	}
+}
+
+// Remaining returns the number of recorded calls which have not been replayed.
+func (rp *Replayer) Remaining() int {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return len(rp.calls) - rp.pos
+}
+
+// Err returns the first divergence, if there has been one.
+func (rp *Replayer) Err() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return rp.err
+}
+
+func (rp *Replayer) replay(pv Port, op PortOp, buf []Word) error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.err != nil {
+		return rp.err
+	}
+	diverge := func(format string, args ...any) error {
+		rp.err = ErrDivergence{Index: rp.pos, Reason: fmt.Sprintf(format, args...)}
+		return rp.err
+	}
+	if rp.pos >= len(rp.calls) {
+		return diverge("unexpected %v on port %v, the recording has ended", op, pv)
+	}
+	pc := rp.calls[rp.pos]
+	switch {
+	case pc.Port != pv:
+		return diverge("port %v, recorded %v", pv, pc.Port)
+	case pc.Op != op:
+		return diverge("%v, recorded %v", op, pc.Op)
+	case len(pc.Out) != len(buf):
+		return diverge("buffer has %d words, recorded %d", len(buf), len(pc.Out))
+	case op != PortInput && !slices.Equal(pc.In, buf):
+		// the contents of the buffer are only the VM's request for outputs and interactions.
+		return diverge("request %v, recorded %v", buf, pc.In)
+	}
+	rp.pos++
+	if pc.Err != "" {
+		return errors.New(pc.Err)
+	}
+	copy(buf, pc.Out)
+	return nil
+}
+
+var (
+	blobType = mycelium.ProductType{
+		// salt, empty if there was no salt
+		wordListType,
+		// data
+		wordListType,
+	}
+	// portCallType is the type used to store a PortCall in a Recording.
+	portCallType = mycelium.ProductType{
+		// port
+		wordListType,
+		// op
+		mycelium.B8Type(),
+		// in
+		wordListType,
+		// out
+		wordListType,
+		// err
+		mycelium.StringType(),
+	}
+	// RecordingType is the type of a Recording, stored as a Value.
+	RecordingType = mycelium.ProductType{
+		// the snapshot of the VM when recording started
+		mycelium.NewRefType(SnapshotType),
+		// blobs, in chunks
+		mycelium.ListOf(mycelium.NewRefType(mycelium.ListOf(blobType))),
+		// calls, in chunks
+		mycelium.ListOf(mycelium.NewRefType(mycelium.ListOf(portCallType))),
+	}
+)
+
+// Recording is the initial state of a VM, every blob it read, and every port call it made while running.
+type Recording struct {
+	Start mycelium.Ref
+	Blobs []Blob
+	Calls []PortCall
+}
+
+// Post writes the recording to dst as a Value of RecordingType, and returns a Ref to it.
+// The blobs for Start must already be in dst.
+func (rec *Recording) Post(ctx context.Context, dst cadata.PostExister) (mycelium.Ref, error) {
+	blobChunks, err := postChunks(ctx, dst, blobType, rec.Blobs, blobToValue)
+	if err != nil {
+		return mycelium.Ref{}, err
+	}
+	callChunks, err := postChunks(ctx, dst, portCallType, rec.Calls, portCallToValue)
+	if err != nil {
+		return mycelium.Ref{}, err
+	}
+	start := rec.Start
+	val := mycelium.Product{
+		&start,
+		mycelium.NewList(mycelium.NewRefType(mycelium.ListOf(blobType)), blobChunks...),
+		mycelium.NewList(mycelium.NewRefType(mycelium.ListOf(portCallType)), callChunks...),
+	}
+	return mycelium.Post(ctx, dst, val)
+}
+
+// LoadRecording reads a Recording written by Recording.Post
+func LoadRecording(ctx context.Context, src cadata.Getter, ref mycelium.Ref) (*Recording, error) {
+	if !mycelium.Equal(ref.ElemType(), RecordingType) {
+		return nil, fmt.Errorf("cannot load recording from %v", ref.ElemType())
+	}
+	val, err := mycelium.Load(ctx, src, ref)
+	if err != nil {
+		return nil, err
+	}
+	prod := val.(mycelium.Product)
+	rec := &Recording{Start: *prod[0].(*mycelium.Ref)}
+	if rec.Blobs, err = loadChunks(ctx, src, prod[1].(*mycelium.List), blobFromValue); err != nil {
+		return nil, err
+	}
+	if rec.Calls, err = loadChunks(ctx, src, prod[2].(*mycelium.List), portCallFromValue); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func postChunks[T any](ctx context.Context, dst cadata.PostExister, elem mycelium.Type, xs []T, fn func(T) mycelium.Value) ([]mycelium.Value, error) {
+	var chunks []mycelium.Value
+	for beg := 0; beg < len(xs); beg += recordingChunk {
+		var vals []mycelium.Value
+		for _, x := range xs[beg:min(beg+recordingChunk, len(xs))] {
+			vals = append(vals, fn(x))
+		}
+		ref, err := mycelium.Post(ctx, dst, mycelium.NewList(elem, vals...))
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, &ref)
+	}
+	return chunks, nil
+}
+
+func loadChunks[T any](ctx context.Context, src cadata.Getter, chunks *mycelium.List, fn func(mycelium.Product) T) ([]T, error) {
+	var ret []T
+	for i := 0; i < chunks.Len(); i++ {
+		chunk, err := mycelium.Load(ctx, src, *chunks.Get(i).(*mycelium.Ref))
+		if err != nil {
+			return nil, err
+		}
+		l := chunk.(*mycelium.List)
+		for j := 0; j < l.Len(); j++ {
+			ret = append(ret, fn(l.Get(j).(mycelium.Product)))
+		}
+	}
+	return ret, nil
+}
+
+func blobToValue(b Blob) mycelium.Value {
+	var salt []byte
+	if b.Salt != nil {
+		salt = b.Salt[:]
+	}
+	return mycelium.Product{
+		mycelium.NewString(string(salt)),
+		mycelium.NewString(string(b.Data)),
+	}
+}
+
+func blobFromValue(x mycelium.Product) Blob {
+	var b Blob
+	if salt := listToBytes(x[0]); len(salt) == cadata.IDSize {
+		b.Salt = (*cadata.ID)(salt)
+	}
+	b.Data = listToBytes(x[1])
+	return b
+}
+
+func portCallToValue(pc PortCall) mycelium.Value {
+	return mycelium.Product{
+		wordsToList(pc.Port[:]),
+		mycelium.NewB8(uint8(pc.Op)),
+		wordsToList(pc.In),
+		wordsToList(pc.Out),
+		mycelium.NewString(pc.Err),
+	}
+}
+
+func portCallFromValue(x mycelium.Product) PortCall {
+	var pc PortCall
+	copy(pc.Port[:], listToWords(x[0]))
+	pc.Op = PortOp(x[1].(*mycelium.B8).AsUint32())
+	pc.In = listToWords(x[2])
+	pc.Out = listToWords(x[3])
+	pc.Err = string(listToBytes(x[4]))
+	return pc
+}
+
+func listToBytes(x mycelium.Value) []byte {
+	return slices.Clone(x.(*mycelium.List).Array().(mycelium.ByteArray).AsBytes())
+}
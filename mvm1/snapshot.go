@@ -0,0 +1,285 @@
+package mvm1
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"myceliumweb.org/mycelium/internal/cadata"
+	mycelium "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/spec"
+)
+
+const (
+	// snapshotStackChunk is the number of stack words in each chunk of a Snapshot
+	snapshotStackChunk = 1 << 16
+	// snapshotCallsChunk is the number of call frames in each chunk of a Snapshot
+	snapshotCallsChunk = 1 << 10
+)
+
+var (
+	// wordListType is the type used to store a []Word in a Snapshot.
+	// The words are stored as little endian bytes, which are much cheaper to encode than a List[B32].
+	wordListType = mycelium.ListOf(mycelium.ByteType())
+
+	wordListRefType = mycelium.NewRefType(wordListType)
+
+	// snapshotFrameType is the type of a call frame in a Snapshot.
+	// (type2, type data, value data, value bits, pos)
+	snapshotFrameType = mycelium.ProductType{
+		mycelium.B32Type(),
+		wordListRefType,
+		wordListRefType,
+		mycelium.B32Type(),
+		mycelium.B32Type(),
+	}
+	// SnapshotType is the type of the Value written by VM.Snapshot
+	// The stack and the calls are split into chunks, so that large machines fit in the store.
+	SnapshotType = mycelium.ProductType{
+		// stack
+		mycelium.ListOf(wordListRefType),
+		// pc
+		mycelium.B32Type(),
+		// self
+		snapshotFrameType,
+		// calls
+		mycelium.ListOf(mycelium.NewRefType(mycelium.ListOf(snapshotFrameType))),
+		// steps
+		mycelium.B64Type(),
+		// progs contains a Ref to the body of every Lazy or Lambda in self or calls.
+		mycelium.ListOf(mycelium.AnyValueType{}),
+	}
+)
+
+// Snapshot writes the state of the machine to dst as a Value of SnapshotType, and returns a Ref to it.
+// The bodies of all the functions on the call stack are included in the snapshot.
+//
+// Any values referenced from the stack are not copied.
+// They must be available in the store given to the VM which calls Restore.
+// Ports are also not included; the same ports must be added to the restored VM.
+func (vm *VM) Snapshot(ctx context.Context, dst cadata.PostExister) (mycelium.Ref, error) {
+	if vm.err != nil {
+		return mycelium.Ref{}, fmt.Errorf("cannot snapshot VM which has faulted: %w", vm.err)
+	}
+	if vm.self.IsZero() && !vm.isEvalProg() {
+		return mycelium.Ref{}, fmt.Errorf("cannot snapshot VM which is not evaluating a Lazy or Lambda")
+	}
+	// frames in deep recursion are mostly the same, so the word lists are shared.
+	wordRefs := map[string]*mycelium.Ref{}
+	toRef := func(ws []Word) (*mycelium.Ref, error) {
+		key := wordsKey(ws)
+		if ref, exists := wordRefs[key]; exists {
+			return ref, nil
+		}
+		ref, err := mycelium.Post(ctx, dst, wordsToList(ws))
+		if err != nil {
+			return nil, err
+		}
+		wordRefs[key] = &ref
+		return &ref, nil
+	}
+	var progs []mycelium.Value
+	seen := map[Ref]struct{}{}
+	frameTo := func(dv *dynValue, pos uint32) (mycelium.Value, error) {
+		if !dv.IsZero() {
+			if _, exists := seen[Ref(dv.valData[:8])]; !exists {
+				seen[Ref(dv.valData[:8])] = struct{}{}
+				prog, err := loadAnyProg(ctx, vm.store, Ref(dv.valData[:8]), ProgType{dv.valData[8]})
+				if err != nil {
+					return nil, err
+				}
+				for ref := range prog.Components() {
+					progs = append(progs, mycelium.NewAnyValue(ref))
+					break
+				}
+			}
+		}
+		typeRef, err := toRef(dv.typeData)
+		if err != nil {
+			return nil, err
+		}
+		valRef, err := toRef(dv.valData)
+		if err != nil {
+			return nil, err
+		}
+		return mycelium.Product{
+			mycelium.NewB32(dv.t2[0]),
+			typeRef,
+			valRef,
+			mycelium.NewB32(dv.valBits),
+			mycelium.NewB32(pos),
+		}, nil
+	}
+	self, err := frameTo(&vm.self, 0)
+	if err != nil {
+		return mycelium.Ref{}, err
+	}
+	calls := make([]mycelium.Value, len(vm.calls))
+	for i := range vm.calls {
+		if calls[i], err = frameTo(&vm.calls[i].From, vm.calls[i].Pos); err != nil {
+			return mycelium.Ref{}, err
+		}
+	}
+	var stackChunks []mycelium.Value
+	for beg := 0; beg < len(vm.stack); beg += snapshotStackChunk {
+		ref, err := toRef(vm.stack[beg:min(beg+snapshotStackChunk, len(vm.stack))])
+		if err != nil {
+			return mycelium.Ref{}, err
+		}
+		stackChunks = append(stackChunks, ref)
+	}
+	var callChunks []mycelium.Value
+	for beg := 0; beg < len(calls); beg += snapshotCallsChunk {
+		ref, err := mycelium.Post(ctx, dst, mycelium.NewList(snapshotFrameType, calls[beg:min(beg+snapshotCallsChunk, len(calls))]...))
+		if err != nil {
+			return mycelium.Ref{}, err
+		}
+		callChunks = append(callChunks, &ref)
+	}
+	snap := mycelium.Product{
+		mycelium.NewList(wordListRefType, stackChunks...),
+		mycelium.NewB32(vm.pc),
+		self,
+		mycelium.NewList(mycelium.NewRefType(mycelium.ListOf(snapshotFrameType)), callChunks...),
+		mycelium.NewB64(vm.steps),
+		mycelium.NewList(mycelium.AnyValueType{}, progs...),
+	}
+	return mycelium.Post(ctx, dst, snap)
+}
+
+// Restore replaces the state of the machine with a snapshot written by Snapshot.
+// The functions in the snapshot are copied from src into the VM's store.
+// If Restore returns an error, the machine is left Reset.
+func (vm *VM) Restore(ctx context.Context, src cadata.Getter, ref mycelium.Ref) (retErr error) {
+	if !mycelium.Equal(ref.ElemType(), SnapshotType) {
+		return fmt.Errorf("cannot restore from %v", ref.ElemType())
+	}
+	val, err := mycelium.Load(ctx, src, ref)
+	if err != nil {
+		return err
+	}
+	if err := val.PullInto(ctx, vm.store, src); err != nil {
+		return err
+	}
+	snap := val.(mycelium.Product)
+	wordLists := map[cadata.ID][]Word{}
+	loadWords := func(x mycelium.Value) ([]Word, error) {
+		ref := x.(*mycelium.Ref)
+		if ws, exists := wordLists[ref.Data()]; exists {
+			return slices.Clone(ws), nil
+		}
+		l, err := mycelium.Load(ctx, vm.store, *ref)
+		if err != nil {
+			return nil, err
+		}
+		ws := listToWords(l)
+		wordLists[ref.Data()] = ws
+		return slices.Clone(ws), nil
+	}
+	frameFrom := func(x mycelium.Value) (dynValue, uint32, error) {
+		p := x.(mycelium.Product)
+		typeData, err := loadWords(p[1])
+		if err != nil {
+			return dynValue{}, 0, err
+		}
+		valData, err := loadWords(p[2])
+		if err != nil {
+			return dynValue{}, 0, err
+		}
+		dv := dynValue{
+			t2:       Type2{Word(*p[0].(*mycelium.B32))},
+			typeData: typeData,
+			valData:  valData,
+			valBits:  int(*p[3].(*mycelium.B32)),
+		}
+		return dv, uint32(*p[4].(*mycelium.B32)), nil
+	}
+	callChunkCache := map[cadata.ID]*mycelium.List{}
+	loadCallChunk := func(x mycelium.Value) (*mycelium.List, error) {
+		ref := x.(*mycelium.Ref)
+		if chunk, exists := callChunkCache[ref.Data()]; exists {
+			return chunk, nil
+		}
+		chunk, err := mycelium.Load(ctx, vm.store, *ref)
+		if err != nil {
+			return nil, err
+		}
+		callChunkCache[ref.Data()] = chunk.(*mycelium.List)
+		return chunk.(*mycelium.List), nil
+	}
+	vm.Reset()
+	defer func() {
+		if retErr != nil {
+			vm.Reset()
+		}
+	}()
+	stackChunks := snap[0].(*mycelium.List)
+	vm.stack = []Word{}
+	for i := 0; i < stackChunks.Len(); i++ {
+		ws, err := loadWords(stackChunks.Get(i))
+		if err != nil {
+			return err
+		}
+		vm.stack = append(vm.stack, ws...)
+	}
+	vm.pc = uint32(*snap[1].(*mycelium.B32))
+	if vm.self, _, err = frameFrom(snap[2]); err != nil {
+		return err
+	}
+	callChunks := snap[3].(*mycelium.List)
+	for i := 0; i < callChunks.Len(); i++ {
+		chunk, err := loadCallChunk(callChunks.Get(i))
+		if err != nil {
+			return err
+		}
+		for j := 0; j < chunk.Len(); j++ {
+			from, pos, err := frameFrom(chunk.Get(j))
+			if err != nil {
+				return err
+			}
+			vm.calls = append(vm.calls, Call{From: from, Pos: pos})
+		}
+	}
+	vm.steps = uint64(*snap[4].(*mycelium.B64))
+
+	vm.ctx = ctx
+	defer func() { vm.ctx = nil }()
+	switch {
+	case vm.self.IsZero():
+		vm.prog = []I{evalI{}}
+	case vm.self.t2.TypeCode() == spec.TC_Lambda:
+		vm.prog, err = vm.loadLambda(LambdaType(vm.self.typeData), vm.self.Lambda())
+	case vm.self.t2.TypeCode() == spec.TC_Lazy:
+		vm.prog, err = vm.loadLazy(vm.self.Lazy())
+	default:
+		err = fmt.Errorf("snapshot has invalid self %v", vm.self.t2.TypeCode())
+	}
+	return err
+}
+
+// isEvalProg returns true if the program was set by SetEval
+func (vm *VM) isEvalProg() bool {
+	return len(vm.prog) == 1 && vm.prog[0] == evalI{}
+}
+
+func wordsToList(ws []Word) *mycelium.List {
+	return mycelium.NewString(wordsKey(ws))
+}
+
+func wordsKey(ws []Word) string {
+	buf := make([]byte, 0, 4*len(ws))
+	for _, w := range ws {
+		buf = binary.LittleEndian.AppendUint32(buf, w)
+	}
+	return string(buf)
+}
+
+func listToWords(x mycelium.Value) []Word {
+	data := x.(*mycelium.List).Array().(mycelium.ByteArray).AsBytes()
+	ret := make([]Word, len(data)/4)
+	for i := range ret {
+		ret[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+	return ret
+}
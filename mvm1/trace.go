@@ -0,0 +1,59 @@
+package mvm1
+
+import "fmt"
+
+// EventKind is the kind of a TraceEvent
+type EventKind uint8
+
+const (
+	// EventCall happens when the VM enters a Lambda or Lazy.
+	EventCall EventKind = iota + 1
+	// EventReturn happens when the VM returns from a Lambda or Lazy.
+	EventReturn
+	// EventAccel happens when the VM evaluates an accelerator in place of a Lambda.
+	EventAccel
+	// EventHalt happens once, when the VM stops running because it has finished or faulted.
+	EventHalt
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventCall:
+		return "call"
+	case EventReturn:
+		return "return"
+	case EventAccel:
+		return "accel"
+	case EventHalt:
+		return "halt"
+	default:
+		return fmt.Sprintf("EventKind(%d)", uint8(k))
+	}
+}
+
+// TraceEvent is emitted by the VM to its Tracer.
+type TraceEvent struct {
+	Kind EventKind
+	// Fingerprint is the Lambda or Lazy being entered or returned from, or the Lambda
+	// which was accelerated.
+	// It is zero for EventHalt.
+	Fingerprint Fingerprint
+	// Steps is the total number of steps the VM had taken when the event happened.
+	Steps uint64
+}
+
+// Tracer receives events from a VM.
+// Trace is called synchronously from Run, so it should be fast.
+type Tracer interface {
+	Trace(TraceEvent)
+}
+
+// SetTracer sets a Tracer to receive events from the VM.
+// Passing nil disables tracing.
+func (vm *VM) SetTracer(t Tracer) {
+	vm.tracer = t
+}
+
+func (vm *VM) trace(kind EventKind, fp Fingerprint) {
+	vm.tracer.Trace(TraceEvent{Kind: kind, Fingerprint: fp, Steps: vm.curStep})
+}
@@ -288,6 +288,10 @@ func (dv *dynValue) Clone() dynValue {
 	}
 }
 
+func (dv *dynValue) Fingerprint() Fingerprint {
+	return fingerprint(dv.t2, dv.typeData, dv.valData, dv.valBits)
+}
+
 func (dv *dynValue) Lambda() Lambda {
 	if dv.t2.TypeCode() != spec.TC_Lambda {
 		panic("not a lambda")
@@ -32,6 +32,18 @@ type VM struct {
 	stack []Word
 	steps uint64
 
+	limits    Limits
+	postBytes int64
+
+	breakpoints  map[Fingerprint]struct{}
+	atBreakpoint bool
+
+	tracer   Tracer
+	recorder *Recorder
+	// curStep is the step count at the start of the current instruction.
+	// It is only maintained when there is a tracer.
+	curStep uint64
+
 	err      error
 	panicVal AnyValue
 	ctx      context.Context
@@ -60,27 +72,49 @@ func (vm *VM) Reset() {
 	vm.calls = vm.calls[:0]
 	vm.self = dynValue{}
 	vm.err = nil
+	vm.panicVal = AnyValue{}
 	vm.pc = 0
 	vm.prog = nil
+	vm.steps = 0
+	vm.postBytes = 0
 }
 
 // Run executes the VM for a maximum of maxSteps.
 // The number of steps taken is returned.
 // If Run returns 0, then nothing happened and the machine has halted.
+// If the VM exceeds its Limits, then it faults with ErrLimitExceeded.
+// If the VM calls a function with a breakpoint, then Run returns immediately after the call.
 func (vm *VM) Run(ctx context.Context, maxSteps uint64) (steps uint64) {
 	vm.ctx = ctx
 	defer func() { vm.ctx = nil }()
 	defer func() { vm.steps += steps }()
+	vm.atBreakpoint = false
 
 	for i := uint64(0); i < maxSteps; i++ {
 		if !vm.isAlive() {
 			return i
 		}
+		if vm.tracer != nil {
+			vm.curStep = vm.steps + i
+		}
+		if !vm.checkLimits(i) {
+			if vm.tracer != nil {
+				vm.trace(EventHalt, Fingerprint{})
+			}
+			return i
+		}
 		ix := vm.prog[vm.pc]
 		vm.pc++
 		// it is important to adjust the program counter before the instruction so
 		// that the instruction can override it.
 		vm.step(ix)
+		if vm.tracer != nil && !vm.isAlive() {
+			vm.curStep = vm.steps + i + 1
+			vm.trace(EventHalt, Fingerprint{})
+		}
+		if vm.atBreakpoint {
+			return i + 1
+		}
 	}
 	return maxSteps
 }
@@ -156,6 +190,8 @@ func (vm *VM) step(ix I) {
 	case portInteractI:
 		vm.portInteract(ix.consumeWords, ix.produceWords)
 
+	case arrayGetI:
+		vm.arrayGet(ix)
 	case listGetI:
 		vm.listGet(ix)
 	case anyTypeFromI:
@@ -357,14 +393,7 @@ func (vm *VM) popList() (ret List) {
 }
 
 func (vm *VM) mux(table []Word, elemSize int) {
-	idx := vm.pop()
-
-	// TODO: don't copy the whole table to the stack, just pick from the table and copy that to the stack.
-	for i := range table {
-		vm.push(table[i])
-	}
-	vm.push(idx)
-
+	// The program which produced the table has already left it on the stack, below the index.
 	arrayLen := len(table) * WordBits / elemSize
 	vm.arrayGet(arrayGetI{elemSize: elemSize, len: arrayLen})
 }
@@ -500,7 +529,7 @@ func (vm *VM) slice(ix sliceI) {
 
 	ws := vm.stack[len(vm.stack)-inputWords:]
 	cutBits(ws, 0, int(ix.beg))
-	zeroBits(ws, ix.end, len(ws)*WordBits)
+	zeroBits(ws, outSize, len(ws)*WordBits)
 	vm.stack = vm.stack[:len(vm.stack)-inputWords+outWords]
 }
 
@@ -607,6 +636,7 @@ func (vm *VM) post(ix postI) {
 		vm.fail(err)
 		return
 	}
+	vm.postBytes += int64(divCeil(ix.inputBits, 8))
 	vm.discard(words)
 	vm.pushRef(ref)
 }
@@ -636,10 +666,19 @@ func (vm *VM) call(target dynValue, fn []I) {
 	vm.self.Set(target)
 	vm.prog = fn
 	vm.pc = 0
+	if vm.tracer != nil {
+		vm.trace(EventCall, vm.self.Fingerprint())
+	}
+	if len(vm.breakpoints) > 0 {
+		vm.checkBreakpoint(&vm.self)
+	}
 }
 
 // ret returns from a call
 func (vm *VM) ret(ix retI) {
+	if vm.tracer != nil {
+		vm.trace(EventReturn, vm.self.Fingerprint())
+	}
 	call := vm.calls[len(vm.calls)-1]
 	var prog []I
 	if call.isLambda() {
@@ -697,15 +736,19 @@ func (vm *VM) apply(ix applyI) {
 }
 
 func (vm *VM) applyAccel(ix accelI) {
+	if vm.tracer != nil {
+		vm.trace(EventAccel, ix.fp)
+	}
 	for i := ix.inputWords; i < ix.outputWords; i++ {
 		vm.push(0)
 	}
-	stack := vm.stack[len(vm.stack)-max(ix.inputWords, ix.outputWords):]
+	n := max(ix.inputWords, ix.outputWords)
+	stack := vm.stack[len(vm.stack)-n:]
 	if err := ix.fn(stack); err != nil {
 		vm.fail(fmt.Errorf("during accelerator %w", err))
 		return
 	}
-	vm.stack = vm.stack[:len(vm.stack)-ix.inputWords+ix.outputWords]
+	vm.stack = vm.stack[:len(vm.stack)-n+ix.outputWords]
 }
 
 // arrayGet (Array[T], Size) => T
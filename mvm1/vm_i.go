@@ -55,6 +55,8 @@ type evalI struct{ baseI }
 type applyI struct {
 	lambdaType              LambdaType
 	inputWords, outputWords int
+	// node is the position of the Apply node in the body being compiled.
+	node uint32
 	baseI
 }
 
@@ -73,11 +75,18 @@ type jumpI struct {
 	baseI
 }
 
-type panicI struct{ baseI }
+type panicI struct {
+	// node is the position of the Panic node in the body being compiled.
+	node uint32
+	baseI
+}
 
 type accelI struct {
 	inputWords, outputWords int
 	fn                      AccelFunc
+	fp                      Fingerprint
+	// node is the position of the Apply node in the body being compiled.
+	node uint32
 	baseI
 }
 
@@ -59,14 +59,14 @@ func (vm *VM) popPort() (ret Port) {
 
 // portOutput pops a port off the stack, and consumes consumeWords from the stack
 func (vm *VM) portOutput(consumeWords int) {
-	vm.portIO(consumeWords, 0, func(p PortBackend, buf []Word) error {
+	vm.portIO(PortOutput, consumeWords, 0, func(p PortBackend, buf []Word) error {
 		return p.Output(vm.ctx, vm.store, buf)
 	})
 }
 
 // portInput pops a port off the stack, and produces produceWords onto the stack
 func (vm *VM) portInput(produceWords int) {
-	vm.portIO(0, produceWords, func(p PortBackend, buf []Word) error {
+	vm.portIO(PortInput, 0, produceWords, func(p PortBackend, buf []Word) error {
 		return p.Input(vm.ctx, vm.store, buf)
 	})
 }
@@ -74,12 +74,12 @@ func (vm *VM) portInput(produceWords int) {
 // portInteract pops a port off of the top of the stack,
 // consumes consumeWords from the stack, and produces produceWords onto the stack
 func (vm *VM) portInteract(consumeWords, produceWords int) {
-	vm.portIO(consumeWords, produceWords, func(p PortBackend, buf []Word) error {
+	vm.portIO(PortInteract, consumeWords, produceWords, func(p PortBackend, buf []Word) error {
 		return p.Interact(vm.ctx, vm.store, buf)
 	})
 }
 
-func (vm *VM) portIO(consumeWords, produceWords int, fn func(PortBackend, []Word) error) {
+func (vm *VM) portIO(op PortOp, consumeWords, produceWords int, fn func(PortBackend, []Word) error) {
 	pv := vm.popPort()
 	backend, exists := vm.ports[pv]
 	if !exists {
@@ -92,7 +92,15 @@ func (vm *VM) portIO(consumeWords, produceWords int, fn func(PortBackend, []Word
 		vm.stack = append(vm.stack, 13)
 	}
 	buf := vm.stack[pos-consumeWords:]
-	if err := fn(backend, buf); err != nil {
+	var err error
+	if vm.recorder != nil {
+		err = vm.recordPortIO(pv, op, buf, func(buf []Word) error {
+			return fn(backend, buf)
+		})
+	} else {
+		err = fn(backend, buf)
+	}
+	if err != nil {
 		vm.fail(fmt.Errorf("port op failed: %w", err))
 		return
 	}
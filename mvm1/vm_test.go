@@ -3,9 +3,16 @@ package mvm1
 import (
 	"bytes"
 	"context"
+	"flag"
 	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/pprof/profile"
 	"github.com/stretchr/testify/require"
 
 	"myceliumweb.org/mycelium/internal/cadata"
@@ -14,6 +21,7 @@ import (
 	"myceliumweb.org/mycelium/mycexpr"
 	myc "myceliumweb.org/mycelium/mycmem"
 	"myceliumweb.org/mycelium/myctests"
+	"myceliumweb.org/mycelium/spec"
 )
 
 func TestVM(t *testing.T) {
@@ -87,6 +95,32 @@ func TestVM(t *testing.T) {
 			},
 			End: []Word{7},
 		},
+		{
+			// the table has already been pushed, by the program which produced it.
+			Name: "Mux [0 1] 1 => 1",
+			Setup: func(t testing.TB, vm *VM) {
+				vm.push(2)
+				vm.push(1)
+			},
+			Prog: []I{
+				muxI{table: []Word{2}, elemSize: 1},
+			},
+			End: []Word{1},
+		},
+		{
+			// the accelerator produces more words than it consumes.
+			Name: "Accel 1 => 2",
+			Setup: func(t testing.TB, vm *VM) {
+				vm.push(5)
+			},
+			Prog: []I{
+				accelI{inputWords: 1, outputWords: 2, fn: func(x []Word) error {
+					x[1] = 2 * x[0]
+					return nil
+				}},
+			},
+			End: []Word{5, 10},
+		},
 		{
 			Name: "Branch 0",
 			Setup: func(t testing.TB, vm *VM) {
@@ -401,6 +435,157 @@ func TestAccelerators(t *testing.T) {
 	}
 }
 
+func TestIntAccels(t *testing.T) {
+	t.Parallel()
+	src := testutil.NewStore(t)
+	wideVecs := make(map[string][]myctests.EvalVec)
+	for i, tc := range myctests.IntVecs() {
+		if _, exists := wideIntLambdas[tc.Name]; exists {
+			wideVecs[tc.Name] = append(wideVecs[tc.Name], tc)
+		}
+		t.Run(fmt.Sprintf("%d/%s", i, tc.Name), func(t *testing.T) {
+			t.Parallel()
+			accelSets := []map[Fingerprint]AccelFunc{DefaultAccels()}
+			if _, exists := wideIntLambdas[tc.Name]; !exists {
+				accelSets = append(accelSets, nil)
+			}
+			for _, accels := range accelSets {
+				checkAccelVec(t, src, tc, accels)
+			}
+		})
+	}
+	for _, name := range slices.Sorted(maps.Keys(wideIntLambdas)) {
+		t.Run("pure/"+name, func(t *testing.T) {
+			t.Parallel()
+			if strings.HasPrefix(name, "B256_") && !*checkB256 {
+				t.Skip("pass -b256 to check the pure B256 definitions, which take minutes")
+			}
+			// The VM is shared between vectors, so the definition is only compiled once.
+			vm := New(100, testutil.NewStore(t), pureAccels(name))
+			for _, tc := range wideVecs[name] {
+				checkVec(t, vm, src, tc)
+			}
+		})
+	}
+}
+
+func TestFloatAccels(t *testing.T) {
+	t.Parallel()
+	src := testutil.NewStore(t)
+	// The pure float definitions run on top of the integer accelerators.
+	intOnly := DefaultAccels()
+	for k := range floatAccels {
+		delete(intOnly, k)
+	}
+	// The VMs are shared between vectors, so each lambda is only compiled once.
+	vms := []*VM{
+		New(100, testutil.NewStore(t), intOnly),
+		New(100, testutil.NewStore(t), DefaultAccels()),
+	}
+	for i, tc := range myctests.FloatVecs() {
+		t.Run(fmt.Sprintf("%d/%s", i, tc.Name), func(t *testing.T) {
+			for _, vm := range vms {
+				checkVec(t, vm, src, tc)
+			}
+		})
+	}
+}
+
+// checkAccelVec evaluates tc using accels, and checks the result.
+func checkAccelVec(t *testing.T, src cadata.Getter, tc myctests.EvalVec, accels map[Fingerprint]AccelFunc) {
+	s := testutil.NewStore(t)
+	checkVec(t, New(100, s, accels), src, tc)
+}
+
+// checkVec evaluates tc on vm, and checks the result.
+// The VM is reset first, so it can be reused to share compiled lambdas between vectors.
+func checkVec(t *testing.T, vm *VM, src cadata.Getter, tc myctests.EvalVec) {
+	ctx := testutil.Context(t)
+	s := vm.store
+	vm.Reset()
+	laz, err := mycexpr.BuildLazy(myc.AnyValueType{}, func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.AnyValueFrom(tc.I)
+	})
+	require.NoError(t, err)
+	require.NoError(t, vm.ImportLazy(ctx, src, laz))
+	vm.SetEval()
+	steps := vm.Run(ctx, 1e9)
+	require.NoError(t, vm.Err())
+	actual, err := vm.ExportAnyValue(ctx, s)
+	require.NoError(t, err)
+	actualBytes := make([]byte, len(actual)*4)
+	wordsToBytes(actual[:], actualBytes)
+	expectedBytes, err := myc.SaveRoot(ctx, s, myc.NewAnyValue(tc.O))
+	require.NoError(t, err)
+	if !bytes.Equal(expectedBytes, actualBytes) {
+		actual, err := myc.LoadRoot(ctx, s, actualBytes)
+		require.NoError(t, err)
+		t.Errorf("accels=%d steps=%d HAVE: %v WANT: %v", len(vm.accels), steps, actual, tc.O)
+	}
+}
+
+var checkB256 = flag.Bool("b256", false, "check the pure definitions of B256_Mod and B256_MulWide in TestIntAccels")
+
+// wideIntLambdas are the lambdas whose pure definitions take too long to evaluate without any accelerators.
+// The pure definitions of Mod and MulWide take time super-linear in the width,
+// so the wider ones are evaluated on top of the accelerated narrower lambdas they are built from.
+var wideIntLambdas = map[string]*myc.Lambda{
+	"B64_Mod":      myccanon.B64_Mod,
+	"B64_MulWide":  myccanon.B64_MulWide,
+	"B128_Mod":     myccanon.B128_Mod,
+	"B128_MulWide": myccanon.B128_MulWide,
+	"B256_Mod":     myccanon.B256_Mod,
+	"B256_MulWide": myccanon.B256_MulWide,
+}
+
+// pureAccels returns the default accelerators, without the accelerator for the named lambda in wideIntLambdas.
+func pureAccels(name string) map[Fingerprint]AccelFunc {
+	lam := wideIntLambdas[name]
+	accels := DefaultAccels()
+	delete(accels, FingerprintOf(lam))
+	return accels
+}
+
+// TestUnaccelerated runs the definitions of accelerated Lambdas without their accelerators.
+func TestUnaccelerated(t *testing.T) {
+	type testCase struct {
+		Lam *myc.Lambda
+		I   *mycexpr.Expr
+		O   []Word
+	}
+	eb := mycexpr.EB{}
+	tcs := []testCase{
+		{Lam: myccanon.NOT, I: eb.Bit(0), O: []Word{1}},
+		{Lam: myccanon.NOT, I: eb.Bit(1), O: []Word{0}},
+		{Lam: myccanon.AND, I: eb.Product(eb.Bit(1), eb.Bit(1)), O: []Word{1}},
+		{Lam: myccanon.AND, I: eb.Product(eb.Bit(1), eb.Bit(0)), O: []Word{0}},
+		{Lam: myccanon.OR, I: eb.Product(eb.Bit(0), eb.Bit(1)), O: []Word{1}},
+		{Lam: myccanon.OR, I: eb.Product(eb.Bit(0), eb.Bit(0)), O: []Word{0}},
+		{Lam: myccanon.XOR, I: eb.Product(eb.Bit(1), eb.Bit(1)), O: []Word{0}},
+		{Lam: myccanon.XOR, I: eb.Product(eb.Bit(1), eb.Bit(0)), O: []Word{1}},
+		{Lam: myccanon.B32_Sub, I: eb.Product(eb.B32(7), eb.B32(5)), O: []Word{2}},
+		{Lam: myccanon.B32_Sub, I: eb.Product(eb.B32(0), eb.B32(1)), O: []Word{0xffff_ffff}},
+		{Lam: myccanon.B32_Neg, I: eb.B32(1), O: []Word{0xffff_ffff}},
+		{Lam: myccanon.B32_Neg, I: eb.B32(0), O: []Word{0}},
+	}
+	for i, tc := range tcs {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			ctx := testutil.Context(t)
+			s := testutil.NewStore(t)
+			vm := New(0, s, nil)
+			laz, err := mycexpr.BuildLazy(myc.AnyValueType{}, func(eb mycexpr.EB) *mycexpr.Expr {
+				return eb.Apply(eb.Lit(tc.Lam), tc.I)
+			})
+			require.NoError(t, err)
+			require.NoError(t, vm.ImportLazy(ctx, s, laz))
+			vm.SetEval()
+			vm.Run(ctx, 1e6)
+			require.NoError(t, vm.Err())
+			require.Equal(t, tc.O, vm.DumpStack(nil))
+		})
+	}
+}
+
 func TestFingerprint(t *testing.T) {
 	s := testutil.NewStore(t)
 	vals := myctests.InterestingValues(s)
@@ -430,3 +615,290 @@ func encodeWords(t testing.TB, x myc.Value) []Word {
 	bytesToWords(data, words)
 	return words
 }
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+	// forever is a lambda which calls itself forever
+	forever := func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.Apply(
+			eb.Lambda(myc.B32Type(), myc.B32Type(), func(eb mycexpr.EB) *mycexpr.Expr {
+				return eb.Apply(eb.Self(), eb.P(0))
+			}),
+			eb.B32(1),
+		)
+	}
+	tcs := []struct {
+		Name   string
+		Limits Limits
+		Want   Limit
+	}{
+		{Name: "Steps", Limits: Limits{MaxSteps: 1000}, Want: LimitSteps},
+		{Name: "StackWords", Limits: Limits{MaxStackWords: 100}, Want: LimitStackWords},
+		{Name: "Deadline", Limits: Limits{Deadline: time.Now().Add(10 * time.Millisecond)}, Want: LimitDeadline},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctx := testutil.Context(t)
+			s := testutil.NewStore(t)
+			vm := New(100, s, DefaultAccels())
+			vm.SetLimits(tc.Limits)
+			laz, err := mycexpr.BuildLazy(myc.B32Type(), forever)
+			require.NoError(t, err)
+			require.NoError(t, vm.ImportLazy(ctx, s, laz))
+			vm.SetEval()
+			vm.Run(ctx, math.MaxUint64)
+			require.ErrorIs(t, vm.Err(), ErrLimitExceeded{Limit: tc.Want})
+			require.Equal(t, myc.NewString(ErrLimitExceeded{Limit: tc.Want}.Error()), vm.GetFault())
+			l, ok := vm.ExceededLimit()
+			require.True(t, ok)
+			require.Equal(t, tc.Want, l)
+		})
+	}
+
+	// a Panic with the same fault value is not a limit fault.
+	ctx := testutil.Context(t)
+	s := testutil.NewStore(t)
+	vm := New(100, s, DefaultAccels())
+	msg := ErrLimitExceeded{Limit: LimitSteps}.Error()
+	laz, err := mycexpr.BuildLazy(myc.B32Type(), func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.Fault(eb.String(msg))
+	})
+	require.NoError(t, err)
+	require.NoError(t, vm.ImportLazy(ctx, s, laz))
+	vm.SetEval()
+	vm.Run(ctx, math.MaxUint64)
+	require.Error(t, vm.Err())
+	require.NotErrorIs(t, vm.Err(), ErrLimitExceeded{Limit: LimitSteps})
+	require.Equal(t, myc.NewString(msg), vm.GetFault())
+	_, ok := vm.ExceededLimit()
+	require.False(t, ok)
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	t.Parallel()
+	src := testutil.NewStore(t)
+	tcs := myctests.EvalVecs(src)
+	tcs = append(tcs, myctests.EvalVecs2(src)...)
+	for i, tc := range tcs {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			ctx := testutil.Context(t)
+			s := testutil.NewStore(t)
+			laz, err := mycexpr.BuildLazy(myc.AnyValueType{}, func(eb mycexpr.EB) *mycexpr.Expr {
+				return eb.AnyValueFrom(tc.I)
+			})
+			require.NoError(t, err)
+
+			vm1 := New(100, s, DefaultAccels())
+			require.NoError(t, vm1.ImportLazy(ctx, src, laz))
+			vm1.SetEval()
+			// a VM which has not taken any steps has no self, and can still be snapshotted.
+			_, err = vm1.Snapshot(ctx, s)
+			require.NoError(t, err)
+			vm1.Run(ctx, 10)
+			if !vm1.isAlive() {
+				t.SkipNow()
+			}
+			ref, err := vm1.Snapshot(ctx, s)
+			require.NoError(t, err)
+
+			vm2 := New(100, s, DefaultAccels())
+			require.NoError(t, vm2.Restore(ctx, s, ref))
+			require.Equal(t, vm1.stack, vm2.stack)
+			require.Equal(t, vm1.Steps(), vm2.Steps())
+
+			vm1.Run(ctx, 1e4)
+			vm2.Run(ctx, 1e4)
+			require.NoError(t, vm1.Err())
+			require.NoError(t, vm2.Err())
+			require.Equal(t, vm1.stack, vm2.stack)
+			require.Equal(t, vm1.Steps(), vm2.Steps())
+		})
+	}
+}
+
+func TestBreakpoint(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := testutil.NewStore(t)
+	dup, err := mycexpr.BuildLambda(myc.B32Type(), myc.ProductType{myc.B32Type(), myc.B32Type()}, func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.Product(eb.P(0), eb.P(0))
+	})
+	require.NoError(t, err)
+	laz, err := mycexpr.BuildLazy(myc.AnyValueType{}, func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.AnyValueFrom(eb.Apply(eb.Lit(dup), eb.B32(7)))
+	})
+	require.NoError(t, err)
+
+	vm := New(100, s, DefaultAccels())
+	vm.SetBreakpoint(FingerprintOf(dup))
+	require.NoError(t, vm.ImportLazy(ctx, s, laz))
+	vm.SetEval()
+	vm.Run(ctx, math.MaxUint64)
+	require.NoError(t, vm.Err())
+	require.True(t, vm.AtBreakpoint())
+	require.False(t, vm.Halted())
+
+	frames := vm.Frames(ctx)
+	require.Len(t, frames, 2)
+	require.Equal(t, FingerprintOf(dup), frames[0].Fingerprint)
+	require.True(t, myc.Equal(dup, frames[0].Value))
+	arg, err := vm.PeekValue(ctx, myc.B32Type(), 0)
+	require.NoError(t, err)
+	require.Equal(t, myc.NewB32(7), arg)
+
+	vm.ClearBreakpoint(FingerprintOf(dup))
+	vm.Run(ctx, math.MaxUint64)
+	require.False(t, vm.AtBreakpoint())
+	require.True(t, vm.Halted())
+	require.NoError(t, vm.Err())
+	av, err := vm.ExportAnyValue(ctx, s)
+	require.NoError(t, err)
+	out, err := myc.LoadRoot(ctx, s, av.AsBytes())
+	require.NoError(t, err)
+	require.Equal(t, myc.Product{myc.NewB32(7), myc.NewB32(7)}, out.Unwrap())
+}
+
+func TestNextNode(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := testutil.NewStore(t)
+	dup, err := mycexpr.BuildLambda(myc.B32Type(), myc.ProductType{myc.B32Type(), myc.B32Type()}, func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.Product(eb.P(0), eb.P(0))
+	})
+	require.NoError(t, err)
+	outer, err := mycexpr.BuildLambda(myc.B32Type(), myc.ProductType{myc.B32Type(), myc.B32Type()}, func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.Apply(eb.Lit(dup), eb.P(0))
+	})
+	require.NoError(t, err)
+	laz, err := mycexpr.BuildLazy(myc.AnyValueType{}, func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.AnyValueFrom(eb.Apply(eb.Lit(outer), eb.B32(7)))
+	})
+	require.NoError(t, err)
+
+	vm := New(100, s, DefaultAccels())
+	vm.SetBreakpoint(FingerprintOf(outer))
+	vm.SetBreakpoint(FingerprintOf(dup))
+	require.NoError(t, vm.ImportLazy(ctx, s, laz))
+	vm.SetEval()
+	vm.Run(ctx, math.MaxUint64)
+	require.True(t, vm.AtBreakpoint())
+	// outer is about to call dup.
+	node, ok := vm.NextNode()
+	require.True(t, ok)
+	body := outer.Body().Prog()
+	require.Less(t, int(node), len(body))
+	require.Equal(t, spec.Apply, body[node].Code())
+
+	// dup makes no calls.
+	vm.Run(ctx, math.MaxUint64)
+	require.True(t, vm.AtBreakpoint())
+	require.Equal(t, FingerprintOf(dup), vm.Frames(ctx)[0].Fingerprint)
+	_, ok = vm.NextNode()
+	require.False(t, ok)
+}
+
+type traceLog []TraceEvent
+
+func (tl *traceLog) Trace(ev TraceEvent) { *tl = append(*tl, ev) }
+
+func TestTrace(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := testutil.NewStore(t)
+	dup, err := mycexpr.BuildLambda(myc.B32Type(), myc.ProductType{myc.B32Type(), myc.B32Type()}, func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.Product(eb.P(0), eb.P(0))
+	})
+	require.NoError(t, err)
+	laz, err := mycexpr.BuildLazy(myc.AnyValueType{}, func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.AnyValueFrom(eb.Apply(eb.Lit(dup), eb.B32(7)))
+	})
+	require.NoError(t, err)
+
+	var log traceLog
+	prof := NewProfiler()
+	vm := New(100, s, DefaultAccels())
+	vm.SetTracer(multiTracer{&log, prof})
+	require.NoError(t, vm.ImportLazy(ctx, s, laz))
+	vm.SetEval()
+	vm.Run(ctx, math.MaxUint64)
+	require.NoError(t, vm.Err())
+
+	var calls, rets int
+	for _, ev := range log {
+		switch ev.Kind {
+		case EventCall:
+			calls++
+		case EventReturn:
+			rets++
+			require.Equal(t, FingerprintOf(dup), ev.Fingerprint)
+		}
+		require.LessOrEqual(t, ev.Steps, vm.Steps())
+	}
+	require.Equal(t, 2, calls)
+	require.Equal(t, 1, rets)
+	require.Equal(t, EventHalt, log[len(log)-1].Kind)
+	require.Equal(t, vm.Steps(), log[len(log)-1].Steps)
+
+	var buf bytes.Buffer
+	require.NoError(t, prof.WriteProfile(&buf, func(fp Fingerprint) string {
+		if fp == FingerprintOf(dup) {
+			return "dup"
+		}
+		return ""
+	}))
+	pp, err := profile.Parse(&buf)
+	require.NoError(t, err)
+	require.NoError(t, pp.CheckValid())
+	require.Equal(t, "steps", pp.DefaultSampleType)
+	require.Equal(t, []string{"steps", "calls"}, []string{pp.SampleType[0].Type, pp.SampleType[1].Type})
+	var steps, dupCalls int64
+	for _, s := range pp.Sample {
+		steps += s.Value[0]
+		if s.Location[0].Line[0].Function.Name == "dup" {
+			dupCalls += s.Value[1]
+		}
+	}
+	require.Equal(t, int64(vm.Steps()), steps)
+	require.Equal(t, int64(1), dupCalls)
+}
+
+type multiTracer []Tracer
+
+func (mt multiTracer) Trace(ev TraceEvent) {
+	for _, t := range mt {
+		t.Trace(ev)
+	}
+}
+
+func TestFaultFrames(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := testutil.NewStore(t)
+	fail, err := mycexpr.BuildLambda(myc.B32Type(), myc.B32Type(), func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.Fault(eb.P(0))
+	})
+	require.NoError(t, err)
+	laz, err := mycexpr.BuildLazy(myc.AnyValueType{}, func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.AnyValueFrom(eb.Apply(eb.Lit(fail), eb.B32(7)))
+	})
+	require.NoError(t, err)
+
+	vm := New(100, s, DefaultAccels())
+	require.NoError(t, vm.ImportLazy(ctx, s, laz))
+	vm.SetEval()
+	vm.Run(ctx, math.MaxUint64)
+	require.Error(t, vm.Err())
+
+	frames := vm.Frames(ctx)
+	require.Len(t, frames, 2)
+	require.Equal(t, FingerprintOf(fail), frames[0].Fingerprint)
+	body := fail.Body().Prog()
+	require.Equal(t, spec.Panic, body[frames[0].Node].Code())
+	require.NotZero(t, frames[1].Node)
+
+	trace := vm.StackTrace(ctx, testSourceMap{frames[0].Site(): "fail.sp:3"})
+	require.Equal(t, []string{"fail.sp:3", fmt.Sprintf("%v#%d", frames[1].Fingerprint, frames[1].Node)}, trace)
+}
+
+type testSourceMap map[Site]string
+
+func (sm testSourceMap) Lookup(site Site) (string, bool) {
+	pos, ok := sm[site]
+	return pos, ok
+}
@@ -141,14 +141,12 @@ func reduceArray(beg, end int, arr, fn *Expr) *Expr {
 
 func negNBit(n int, x *Expr) *Expr {
 	// ~x + 1
-	return addNBit(n,
-		arrayMap(n, x, eb.Lit(NOT)),
-		eb.Concat(eb.Bit(1), mkZeros(n-1)),
-	)
+	return addCarryNBit(n, bitArrayMap(n, x, eb.Lit(NOT)), mkZeros(n), eb.Bit(1))
 }
 
 func subNBit(n int, a, b *Expr) *Expr {
-	return eb.Fault(eb.String(fmt.Sprintf("must accelerate b%dSub", n)))
+	// a + ~b + 1
+	return addCarryNBit(n, a, bitArrayMap(n, b, eb.Lit(NOT)), eb.Bit(1))
 }
 
 func mulNBit(n int, a, b *Expr) *Expr {
@@ -160,8 +158,12 @@ func divNBit(n int, a, b *Expr) *Expr {
 }
 
 func addNBit(n int, a, b *Expr) *Expr {
+	return addCarryNBit(n, a, b, eb.Bit(0))
+}
+
+// addCarryNBit adds a and b, and the carry bit
+func addCarryNBit(n int, a, b, carry *Expr) *Expr {
 	out := make([]*Expr, n)
-	carry := eb.Bit(0)
 	for i := 0; i < n; i++ {
 		ai := eb.Slot(a, eb.B32(uint32(i)))
 		bi := eb.Slot(b, eb.B32(uint32(i)))
@@ -4,12 +4,30 @@ import (
 	myc "myceliumweb.org/mycelium/mycmem"
 )
 
+// Floats are IEEE-754 binary floating point numbers.
+// They are stored as a product of the mantissa, the biased exponent, and the sign,
+// which when laid out least significant bit first is the IEEE-754 bit pattern.
+//
+// Arithmetic rounds to nearest, ties to even, and supports subnormals, infinities and NaN.
+// Every lambda other than Neg which produces a NaN produces the canonical quiet NaN:
+// all exponent bits set, only the top mantissa bit set, and a sign of 0.
+// Comparisons involving NaN are false, and -0 is equal to +0.
+// Conversions to integers truncate toward zero, saturate when out of range, and produce 0 for NaN.
+// Conversions from integers interpret the input as two's complement.
+//
+// Every lambda has a definition in pure MVM, which is slow, and the VM is expected to accelerate them.
+
 var (
 	Float16 = myc.ProductType{
-		myc.ArrayOf(myc.BitType{}, 12), // mantissa
-		myc.ArrayOf(myc.BitType{}, 4),  // exp
+		myc.ArrayOf(myc.BitType{}, 10), // mantissa
+		myc.ArrayOf(myc.BitType{}, 5),  // exp
 		myc.BitType{},                  // sign
 	}
+
+	f16 = newFloatFormat(Float16, 10, 5)
+
+	Float16_FromFloat32 = convertFloat(f32, f16)
+	Float16_FromFloat64 = convertFloat(f64, f16)
 )
 
 var (
@@ -19,27 +37,626 @@ var (
 		myc.BitType{},                  // sign
 	}
 
-	Float32_Neg   = lambda(Float32, Float32, func(eb EB) *Expr { return eb.Fault(eb.String("must accelerate f32_neg")) })
-	Float32_Recip = lambda(Float32, Float32, func(eb EB) *Expr { return eb.Fault(eb.String("must accelerate f32_recip")) })
+	f32 = newFloatFormat(Float32, 23, 8)
+
+	Float32_Neg   = f32.neg
+	Float32_Recip = lambda(Float32, Float32, func(eb EB) *Expr { return call(Float32_Div, eb.Lit(f32.one()), eb.P(0)) })
 
-	Float32_Add = lambda(ProductType{Float32, Float32}, Float32, func(eb EB) *Expr { return eb.Fault(eb.String("must accelerate f32_add")) })
-	Float32_Sub = lambda(ProductType{Float32, Float32}, Float32, func(eb EB) *Expr { return eb.Fault(eb.String("must accelerate f32_sub")) })
-	Float32_Mul = lambda(ProductType{Float32, Float32}, Float32, func(eb EB) *Expr { return eb.Fault(eb.String("must accelerate f32_mul")) })
-	Float32_Div = lambda(ProductType{Float32, Float32}, Float32, func(eb EB) *Expr { return eb.Fault(eb.String("must accelerate f32_div")) })
+	Float32_Add = f32.mkAdd()
+	Float32_Sub = f32.mkSub(Float32_Add)
+	Float32_Mul = f32.mkMul()
+	Float32_Div = f32.mkDiv()
+
+	Float32_Eq = f32.mkEq()
+	Float32_Lt = f32.mkLt()
+	Float32_Le = f32.mkLe(Float32_Lt, Float32_Eq)
+	Float32_Gt = flipFloat(Float32, Float32_Lt)
+	Float32_Ge = flipFloat(Float32, Float32_Le)
+
+	Float32_FromB32S = f32.mkFromInt(32)
+	Float32_FromB64S = f32.mkFromInt(64)
+	Float32_ToB32S   = f32.mkToInt(32)
+	Float32_ToB64S   = f32.mkToInt(64)
+
+	Float32_FromFloat16 = convertFloat(f16, f32)
+	Float32_FromFloat64 = convertFloat(f64, f32)
 )
 
 var (
 	Float64 = myc.ProductType{
-		myc.ArrayOf(myc.BitType{}, 53), // mantissa
-		myc.ArrayOf(myc.BitType{}, 10), // exp
+		myc.ArrayOf(myc.BitType{}, 52), // mantissa
+		myc.ArrayOf(myc.BitType{}, 11), // exp
 		myc.BitType{},                  // sign
 	}
 
-	Float64_Neg   = lambda(Float64, Float64, func(eb EB) *Expr { return eb.Fault(eb.String("must accelerate f64_neg")) })
-	Float64_Recip = lambda(Float64, Float64, func(eb EB) *Expr { return eb.Fault(eb.String("must accelerate f64_recip")) })
+	f64 = newFloatFormat(Float64, 52, 11)
+
+	Float64_Neg   = f64.neg
+	Float64_Recip = lambda(Float64, Float64, func(eb EB) *Expr { return call(Float64_Div, eb.Lit(f64.one()), eb.P(0)) })
 
-	Float64_Add = lambda(ProductType{Float64, Float64}, Float64, func(eb EB) *Expr { return eb.Fault(eb.String("must accelerate f64_add")) })
-	Float64_Sub = lambda(ProductType{Float64, Float64}, Float64, func(eb EB) *Expr { return eb.Fault(eb.String("must accelerate f64_sub")) })
-	Float64_Mul = lambda(ProductType{Float64, Float64}, Float64, func(eb EB) *Expr { return eb.Fault(eb.String("must accelerate f64_mul")) })
-	Float64_Div = lambda(ProductType{Float64, Float64}, Float64, func(eb EB) *Expr { return eb.Fault(eb.String("must accelerate f64_div")) })
+	Float64_Add = f64.mkAdd()
+	Float64_Sub = f64.mkSub(Float64_Add)
+	Float64_Mul = f64.mkMul()
+	Float64_Div = f64.mkDiv()
+
+	Float64_Eq = f64.mkEq()
+	Float64_Lt = f64.mkLt()
+	Float64_Le = f64.mkLe(Float64_Lt, Float64_Eq)
+	Float64_Gt = flipFloat(Float64, Float64_Lt)
+	Float64_Ge = flipFloat(Float64, Float64_Le)
+
+	Float64_FromB32S = f64.mkFromInt(32)
+	Float64_FromB64S = f64.mkFromInt(64)
+	Float64_ToB32S   = f64.mkToInt(32)
+	Float64_ToB64S   = f64.mkToInt(64)
+
+	Float64_FromFloat16 = convertFloat(f16, f64)
+	Float64_FromFloat32 = convertFloat(f32, f64)
 )
+
+// floatFormat is an IEEE-754 binary format, and the lambdas shared by its operations.
+//
+// Internally a finite float is unpacked into a sign, a B32 exponent, and an integer significand,
+// such that the magnitude is sig * 2^exp.
+// The significand is held in an integer the size of the format, which leaves room for guard bits.
+type floatFormat struct {
+	ty        Type
+	mant, exp int
+	// wide is the size of the significand, which is the size of the format.
+	wide int
+	ops  intOps
+
+	isNaN, isInf, isZero *myc.Lambda
+	neg                  *myc.Lambda
+	parts                *myc.Lambda
+	normalize            *myc.Lambda
+	round                *myc.Lambda
+}
+
+func newFloatFormat(ty Type, mant, exp int) *floatFormat {
+	wide := mant + exp + 1
+	f := &floatFormat{
+		ty:   ty,
+		mant: mant,
+		exp:  exp,
+		wide: wide,
+		ops:  intOpsOf(wide),
+	}
+	f.isNaN = lambda(ty, myc.BitType{}, func(eb EB) *Expr {
+		return and(eb.Equal(eb.Field(eb.P(0), 1), mkOnes(exp)), not(eb.Equal(eb.Field(eb.P(0), 0), mkZeros(mant))))
+	})
+	f.isInf = lambda(ty, myc.BitType{}, func(eb EB) *Expr {
+		return and(eb.Equal(eb.Field(eb.P(0), 1), mkOnes(exp)), eb.Equal(eb.Field(eb.P(0), 0), mkZeros(mant)))
+	})
+	f.isZero = lambda(ty, myc.BitType{}, func(eb EB) *Expr {
+		return and(eb.Equal(eb.Field(eb.P(0), 1), mkZeros(exp)), eb.Equal(eb.Field(eb.P(0), 0), mkZeros(mant)))
+	})
+	f.neg = lambda(ty, ty, func(eb EB) *Expr {
+		return eb.Product(eb.Field(eb.P(0), 0), eb.Field(eb.P(0), 1), not(eb.Field(eb.P(0), 2)))
+	})
+	f.parts = f.mkParts()
+	f.normalize = normalizer(wide)
+	f.round = f.mkRound()
+	return f
+}
+
+func (f *floatFormat) bits() int {
+	return f.mant + f.exp + 1
+}
+
+func (f *floatFormat) bias() int {
+	return 1<<(f.exp-1) - 1
+}
+
+func (f *floatFormat) wideType() Type {
+	return myc.BitArrayType(f.wide)
+}
+
+// partsType is the type of an unpacked float: the sign, exponent and significand
+func (f *floatFormat) partsType() Type {
+	return partsType(f.wide)
+}
+
+// partsType is the type of unpacked parts with an n bit significand
+func partsType(n int) Type {
+	return myc.ProductType{myc.BitType{}, B32, myc.BitArrayType(n)}
+}
+
+// one returns the value 1.0
+func (f *floatFormat) one() myc.Value {
+	return f.value(0, uint64(f.bias()), 0)
+}
+
+// value returns a float from the fields of its bit pattern
+func (f *floatFormat) value(mant, exp uint64, sign int) myc.Value {
+	return myc.Product{bitsValue(f.mant, mant), bitsValue(f.exp, exp), myc.NewBit(sign)}
+}
+
+// nan returns the canonical NaN
+func (f *floatFormat) nan() *Expr {
+	return eb.Lit(f.value(1<<(f.mant-1), 1<<f.exp-1, 0))
+}
+
+func (f *floatFormat) inf(sign *Expr) *Expr {
+	return eb.Product(mkZeros(f.mant), mkOnes(f.exp), sign)
+}
+
+func (f *floatFormat) zero(sign *Expr) *Expr {
+	return eb.Product(mkZeros(f.mant), mkZeros(f.exp), sign)
+}
+
+// magnitude returns the bits of x without the sign, as an unsigned integer the size of the format.
+func (f *floatFormat) magnitude(x *Expr) *Expr {
+	return eb.Concat(eb.Concat(eb.Field(x, 0), eb.Field(x, 1)), eb.BitArray(0))
+}
+
+// mkParts unpacks a finite float.
+func (f *floatFormat) mkParts() *myc.Lambda {
+	return lambda(f.ty, f.partsType(), func(eb EB) *Expr {
+		mant, exp := eb.Field(eb.P(0), 0), eb.Field(eb.P(0), 1)
+		subnormal := eb.Equal(exp, mkZeros(f.exp))
+		sig := eb.Concat(eb.Concat(mant, eb.ArrayUnit(not(subnormal))), mkZeros(f.wide-f.mant-1))
+		// subnormals have the same exponent as the smallest normal numbers.
+		e := eb.Concat(eb.If(subnormal, bitsConst(f.exp, 1), exp), mkZeros(32-f.exp))
+		return eb.Product(eb.Field(eb.P(0), 2), call(B32_Add, e, b32Const(-(f.bias()+f.mant))), sig)
+	})
+}
+
+// normalizer returns a lambda which shifts the n bit significand of parts left until its top bit is set,
+// adjusting the exponent to compensate.
+func normalizer(n int) *myc.Lambda {
+	pt := partsType(n)
+	return lambda(pt, pt, func(eb EB) *Expr {
+		x := eb.P(0)
+		for s := n / 2; s > 0; s /= 2 {
+			stage := lambda(pt, pt, func(eb EB) *Expr {
+				sign, e, sig := eb.Arg(0, 0), eb.Arg(0, 1), eb.Arg(0, 2)
+				return eb.If(eb.Equal(eb.Section(sig, n-s, n), mkZeros(s)),
+					eb.Product(sign, call(B32_Sub, e, b32Const(s)), eb.Concat(mkZeros(s), eb.Section(sig, 0, n-s))),
+					eb.P(0),
+				)
+			})
+			x = eb.Apply(litOf(stage), x)
+		}
+		return x
+	})
+}
+
+// mkNarrow rounds parts with an n bit significand, which is wider than the format's.
+// The top bits of the normalized significand are kept, and the rest are folded into a sticky bit.
+func (f *floatFormat) mkNarrow(n int) *myc.Lambda {
+	cut := n - f.wide
+	narrow := lambda(partsType(n), f.ty, func(eb EB) *Expr {
+		sign, e, sig := eb.Arg(0, 0), eb.Arg(0, 1), eb.Arg(0, 2)
+		sticky := not(eb.Equal(eb.Section(sig, 0, cut), mkZeros(cut)))
+		top := eb.Section(sig, cut, n)
+		top = eb.Concat(eb.ArrayUnit(or(eb.Slot(top, eb.B32(0)), sticky)), eb.Section(top, 1, f.wide))
+		return call(f.round, sign, call(B32_Add, e, b32Const(cut)), top)
+	})
+	return lambda(partsType(n), f.ty, func(eb EB) *Expr {
+		return eb.Apply(litOf(narrow), eb.Apply(litOf(normalizer(n)), eb.P(0)))
+	})
+}
+
+// mkRound packs parts into the nearest float.
+func (f *floatFormat) mkRound() *myc.Lambda {
+	// the normalized significand has its top bit set, so the magnitude is 1.xxx * 2^(exp+wide-1)
+	pack := lambda(f.partsType(), f.ty, func(eb EB) *Expr {
+		sign, e, sig := eb.Arg(0, 0), eb.Arg(0, 1), eb.Arg(0, 2)
+		return call(f.mkPackBiased(), sign, call(B32_Add, e, b32Const(f.wide-1+f.bias())), sig)
+	})
+	return lambda(f.partsType(), f.ty, func(eb EB) *Expr {
+		return eb.If(eb.Equal(eb.Arg(0, 2), mkZeros(f.wide)),
+			f.zero(eb.Arg(0, 0)),
+			eb.Apply(litOf(pack), eb.Apply(litOf(f.normalize), eb.P(0))),
+		)
+	})
+}
+
+// mkPackBiased packs a sign, biased exponent, and normalized significand.
+func (f *floatFormat) mkPackBiased() *myc.Lambda {
+	return lambda(f.partsType(), f.ty, func(eb EB) *Expr {
+		sign, be, sig := eb.Arg(0, 0), eb.Arg(0, 1), eb.Arg(0, 2)
+		overflow := not(call(B32_LtS, be, b32Const(1<<f.exp-1)))
+		subnormal := call(B32_LtS, be, b32Const(1))
+		// The exponent field is one less than the biased exponent, because the implicit bit of the
+		// significand is added to it. This also carries into the exponent when rounding up.
+		ef := eb.If(subnormal, b32Const(0), call(B32_Sub, be, b32Const(1)))
+		shift := eb.If(subnormal,
+			eb.If(call(B32_LtS, be, b32Const(-(f.mant+1))),
+				// everything is shifted out, but the significand still contributes to the sticky bit.
+				b32Const(f.wide+1),
+				call(B32_Sub, b32Const(f.wide-f.mant), be),
+			),
+			b32Const(f.wide-f.mant-1),
+		)
+		return eb.If(overflow, f.inf(sign), call(f.mkPackShifted(), sign, ef, shift, sig))
+	})
+}
+
+// mkPackShifted shifts the significand into place, and rounds to nearest even.
+func (f *floatFormat) mkPackShifted() *myc.Lambda {
+	add := lambda(myc.ProductType{myc.BitType{}, B32, f.wideType(), myc.BitType{}, myc.BitType{}}, f.ty, func(eb EB) *Expr {
+		sign, ef, k, half, sticky := eb.Arg(0, 0), eb.Arg(0, 1), eb.Arg(0, 2), eb.Arg(0, 3), eb.Arg(0, 4)
+		up := and(half, or(sticky, eb.Slot(k, eb.B32(0))))
+		mag := call(f.ops.add,
+			eb.Concat(eb.Concat(mkZeros(f.mant), eb.Section(ef, 0, f.exp)), eb.BitArray(0)),
+			eb.Concat(eb.Section(k, 0, f.mant+1), mkZeros(f.exp)),
+		)
+		mag = call(f.ops.add, mag, eb.Concat(eb.ArrayUnit(up), mkZeros(f.wide-1)))
+		return eb.Product(eb.Section(mag, 0, f.mant), eb.Section(mag, f.mant, f.mant+f.exp), sign)
+	})
+	return lambda(myc.ProductType{myc.BitType{}, B32, B32, f.wideType()}, f.ty, func(eb EB) *Expr {
+		sign, ef, s, sig := eb.Arg(0, 0), eb.Arg(0, 1), eb.Arg(0, 2), eb.Arg(0, 3)
+		k := call(f.ops.shr, sig, s)
+		half := eb.Slot(call(f.ops.shr, sig, call(B32_Sub, s, b32Const(1))), eb.B32(0))
+		sticky := not(eb.Equal(call(f.ops.shl, sig, call(B32_Sub, b32Const(f.wide+1), s)), mkZeros(f.wide)))
+		return call(add, sign, ef, k, half, sticky)
+	})
+}
+
+func (f *floatFormat) mkAdd() *myc.Lambda {
+	// guard is the number of bits the significands are shifted left before aligning them
+	guard := f.wide - f.mant - 3
+	final := lambda(myc.ProductType{myc.BitType{}, myc.BitType{}, B32, f.wideType(), f.wideType(), myc.BitType{}}, f.ty, func(eb EB) *Expr {
+		sx, sy, e, x, shifted, sticky := eb.Arg(0, 0), eb.Arg(0, 1), eb.Arg(0, 2), eb.Arg(0, 3), eb.Arg(0, 4), eb.Arg(0, 5)
+		y := eb.Concat(eb.ArrayUnit(or(eb.Slot(shifted, eb.B32(0)), sticky)), eb.Section(shifted, 1, f.wide))
+		diff := lambda(myc.ProductType{myc.BitType{}, B32, f.wideType()}, f.ty, func(eb EB) *Expr {
+			// exact cancellation produces +0
+			return eb.If(eb.Equal(eb.Arg(0, 2), mkZeros(f.wide)), f.zero(eb.Bit(0)), eb.Apply(litOf(f.round), eb.P(0)))
+		})
+		return eb.If(eb.Equal(sx, sy),
+			call(f.round, sx, e, call(f.ops.add, x, y)),
+			call(diff, sx, e, call(f.ops.sub, x, y)),
+		)
+	})
+	align := lambda(myc.ProductType{f.partsType(), f.partsType(), B32}, f.ty, func(eb EB) *Expr {
+		px, py, d := eb.Arg(0, 0), eb.Arg(0, 1), eb.Arg(0, 2)
+		x := shlConst(f.wide, eb.Field(px, 2), guard)
+		y := shlConst(f.wide, eb.Field(py, 2), guard)
+		shifted := call(f.ops.shr, y, d)
+		sticky := not(eb.Equal(call(f.ops.shl, y, call(B32_Sub, b32Const(f.wide), d)), mkZeros(f.wide)))
+		e := call(B32_Sub, eb.Field(px, 1), b32Const(guard))
+		return call(final, eb.Field(px, 0), eb.Field(py, 0), e, x, shifted, sticky)
+	})
+	// ordered adds x and y, where |x| >= |y|
+	ordered := lambda(myc.ProductType{f.partsType(), f.partsType()}, f.ty, func(eb EB) *Expr {
+		px, py := eb.Arg(0, 0), eb.Arg(0, 1)
+		d := call(B32_Min, call(B32_Sub, eb.Field(px, 1), eb.Field(py, 1)), b32Const(f.wide))
+		return call(align, px, py, d)
+	})
+	finite := lambda(myc.ProductType{f.ty, f.ty}, f.ty, func(eb EB) *Expr {
+		a, b := eb.Arg(0, 0), eb.Arg(0, 1)
+		swap := call(f.ops.lt, f.magnitude(a), f.magnitude(b))
+		return eb.Apply(litOf(lambda(myc.ProductType{f.ty, f.ty}, f.ty, func(eb EB) *Expr {
+			return call(ordered, call(f.parts, eb.Arg(0, 0)), call(f.parts, eb.Arg(0, 1)))
+		})), eb.If(swap, eb.Product(b, a), eb.Product(a, b)))
+	})
+	return lambda(myc.ProductType{f.ty, f.ty}, f.ty, func(eb EB) *Expr {
+		a, b := eb.Arg(0, 0), eb.Arg(0, 1)
+		infA, infB := call(f.isInf, a), call(f.isInf, b)
+		nan := or(
+			or(call(f.isNaN, a), call(f.isNaN, b)),
+			and(and(infA, infB), not(eb.Equal(eb.Field(a, 2), eb.Field(b, 2)))),
+		)
+		return eb.If(nan, f.nan(),
+			eb.If(infA, a,
+				eb.If(infB, b, call(finite, a, b)),
+			),
+		)
+	})
+}
+
+func (f *floatFormat) mkSub(add *myc.Lambda) *myc.Lambda {
+	return lambda(myc.ProductType{f.ty, f.ty}, f.ty, func(eb EB) *Expr {
+		return call(add, eb.Arg(0, 0), call(f.neg, eb.Arg(0, 1)))
+	})
+}
+
+func (f *floatFormat) mkMul() *myc.Lambda {
+	finite := lambda(myc.ProductType{myc.BitType{}, f.partsType(), f.partsType()}, f.ty, func(eb EB) *Expr {
+		pa, pb := eb.Arg(0, 1), eb.Arg(0, 2)
+		sig := call(f.ops.mulWide, eb.Field(pa, 2), eb.Field(pb, 2))
+		return call(f.mkNarrow(2*f.wide), eb.Arg(0, 0), call(B32_Add, eb.Field(pa, 1), eb.Field(pb, 1)), sig)
+	})
+	return lambda(myc.ProductType{f.ty, f.ty}, f.ty, func(eb EB) *Expr {
+		a, b := eb.Arg(0, 0), eb.Arg(0, 1)
+		infA, infB := call(f.isInf, a), call(f.isInf, b)
+		nan := or(
+			or(call(f.isNaN, a), call(f.isNaN, b)),
+			or(and(infA, call(f.isZero, b)), and(call(f.isZero, a), infB)),
+		)
+		sign := xor(eb.Field(a, 2), eb.Field(b, 2))
+		return eb.If(nan, f.nan(),
+			eb.If(or(infA, infB), f.inf(sign),
+				call(finite, sign, call(f.parts, a), call(f.parts, b)),
+			),
+		)
+	})
+}
+
+func (f *floatFormat) mkDiv() *myc.Lambda {
+	// steps is the number of quotient bits computed after the first.
+	// That is enough for the significand, a guard bit, and a round bit.
+	steps := f.mant + 3
+	wt := f.wideType()
+	stateType := myc.ProductType{wt, wt, wt}
+	// step does one round of restoring division on the remainder, quotient, and divisor.
+	stepSub := lambda(myc.ProductType{wt, wt, wt, myc.BitType{}}, stateType, func(eb EB) *Expr {
+		r, q, d, ge := eb.Arg(0, 0), eb.Arg(0, 1), eb.Arg(0, 2), eb.Arg(0, 3)
+		r2 := eb.If(ge, call(f.ops.sub, r, d), r)
+		return eb.Product(
+			shlConst(f.wide, r2, 1),
+			eb.Concat(eb.ArrayUnit(ge), eb.Section(q, 0, f.wide-1)),
+			d,
+		)
+	})
+	step := lambda(stateType, stateType, func(eb EB) *Expr {
+		r, q, d := eb.Arg(0, 0), eb.Arg(0, 1), eb.Arg(0, 2)
+		return call(stepSub, r, q, d, not(call(f.ops.lt, r, d)))
+	})
+	finish := lambda(myc.ProductType{myc.BitType{}, B32, stateType}, f.ty, func(eb EB) *Expr {
+		r, q := eb.Field(eb.Arg(0, 2), 0), eb.Field(eb.Arg(0, 2), 1)
+		sticky := not(eb.Equal(r, mkZeros(f.wide)))
+		sig := eb.Concat(eb.ArrayUnit(sticky), eb.Section(q, 0, f.wide-1))
+		return call(f.round, eb.Arg(0, 0), call(B32_Sub, eb.Arg(0, 1), b32Const(steps+1)), sig)
+	})
+	// normal divides 2 normalized significands, which are first shifted right by 1 so the remainder can be shifted left.
+	normal := lambda(myc.ProductType{myc.BitType{}, f.partsType(), f.partsType()}, f.ty, func(eb EB) *Expr {
+		pa, pb := eb.Arg(0, 1), eb.Arg(0, 2)
+		a := shrConst(f.wide, eb.Field(pa, 2), 1)
+		b := shrConst(f.wide, eb.Field(pb, 2), 1)
+		x := eb.Product(a, mkZeros(f.wide), b)
+		for range steps + 1 {
+			x = eb.Apply(litOf(step), x)
+		}
+		return call(finish, eb.Arg(0, 0), call(B32_Sub, eb.Field(pa, 1), eb.Field(pb, 1)), x)
+	})
+	finite := lambda(myc.ProductType{myc.BitType{}, f.partsType(), f.partsType()}, f.ty, func(eb EB) *Expr {
+		return call(normal, eb.Arg(0, 0), call(f.normalize, eb.Arg(0, 1)), call(f.normalize, eb.Arg(0, 2)))
+	})
+	return lambda(myc.ProductType{f.ty, f.ty}, f.ty, func(eb EB) *Expr {
+		a, b := eb.Arg(0, 0), eb.Arg(0, 1)
+		infA, infB := call(f.isInf, a), call(f.isInf, b)
+		zeroA, zeroB := call(f.isZero, a), call(f.isZero, b)
+		nan := or(
+			or(call(f.isNaN, a), call(f.isNaN, b)),
+			or(and(zeroA, zeroB), and(infA, infB)),
+		)
+		sign := xor(eb.Field(a, 2), eb.Field(b, 2))
+		return eb.If(nan, f.nan(),
+			eb.If(or(infA, zeroB), f.inf(sign),
+				eb.If(or(zeroA, infB), f.zero(sign),
+					call(finite, sign, call(f.parts, a), call(f.parts, b)),
+				),
+			),
+		)
+	})
+}
+
+func (f *floatFormat) mkEq() *myc.Lambda {
+	return lambda(myc.ProductType{f.ty, f.ty}, myc.BitType{}, func(eb EB) *Expr {
+		a, b := eb.Arg(0, 0), eb.Arg(0, 1)
+		return eb.If(or(call(f.isNaN, a), call(f.isNaN, b)),
+			eb.Bit(0),
+			or(and(call(f.isZero, a), call(f.isZero, b)), eb.Equal(a, b)),
+		)
+	})
+}
+
+func (f *floatFormat) mkLt() *myc.Lambda {
+	// key maps floats to unsigned integers with the same order
+	key := lambda(f.ty, myc.BitArrayType(f.bits()), func(eb EB) *Expr {
+		x := eb.P(0)
+		bits := eb.Concat(eb.Concat(eb.Field(x, 0), eb.Field(x, 1)), eb.ArrayUnit(eb.Field(x, 2)))
+		return eb.If(eb.Field(x, 2),
+			bitArrayMap(f.bits(), bits, eb.Lit(NOT)),
+			eb.Concat(eb.Concat(eb.Field(x, 0), eb.Field(x, 1)), eb.BitArray(1)),
+		)
+	})
+	return lambda(myc.ProductType{f.ty, f.ty}, myc.BitType{}, func(eb EB) *Expr {
+		a, b := eb.Arg(0, 0), eb.Arg(0, 1)
+		unordered := or(
+			or(call(f.isNaN, a), call(f.isNaN, b)),
+			and(call(f.isZero, a), call(f.isZero, b)),
+		)
+		return eb.If(unordered, eb.Bit(0), call(f.ops.lt, call(key, a), call(key, b)))
+	})
+}
+
+func (f *floatFormat) mkLe(lt, eq *myc.Lambda) *myc.Lambda {
+	return lambda(myc.ProductType{f.ty, f.ty}, myc.BitType{}, func(eb EB) *Expr {
+		return or(eb.Apply(litOf(lt), eb.P(0)), eb.Apply(litOf(eq), eb.P(0)))
+	})
+}
+
+func flipFloat(ty Type, cmp *myc.Lambda) *myc.Lambda {
+	return lambda(myc.ProductType{ty, ty}, myc.BitType{}, func(eb EB) *Expr {
+		return flip(cmp, eb.Arg(0, 0), eb.Arg(0, 1))
+	})
+}
+
+// mkFromInt converts an n bit two's complement integer to a float
+func (f *floatFormat) mkFromInt(n int) *myc.Lambda {
+	return lambda(myc.BitArrayType(n), f.ty, func(eb EB) *Expr {
+		sign := eb.Slot(eb.P(0), eb.B32(uint32(n-1)))
+		sig := call(condNeg(n), sign, eb.P(0))
+		switch {
+		case n < f.wide:
+			sig = eb.Concat(sig, mkZeros(f.wide-n))
+		case n > f.wide:
+			return call(f.mkNarrow(n), sign, b32Const(0), sig)
+		}
+		return call(f.round, sign, b32Const(0), sig)
+	})
+}
+
+// mkToInt converts a float to an n bit two's complement integer
+func (f *floatFormat) mkToInt(n int) *myc.Lambda {
+	ty := myc.BitArrayType(n)
+	// the significand is shifted in an integer wide enough for the result
+	w := max(n, f.wide)
+	ops := intOpsOf(w)
+	finite := lambda(f.partsType(), ty, func(eb EB) *Expr {
+		sign, e, sig := eb.Arg(0, 0), eb.Arg(0, 1), eb.Arg(0, 2)
+		if w > f.wide {
+			sig = eb.Concat(sig, mkZeros(w-f.wide))
+		}
+		mag := eb.If(call(B32_LtS, e, b32Const(0)),
+			call(ops.shr, sig, call(B32_Sub, b32Const(0), e)),
+			call(ops.shl, sig, e),
+		)
+		return call(condNeg(n), sign, eb.Section(mag, 0, n))
+	})
+	// limit is the magnitude of 2^(n-1)
+	limit := bitsConst(f.bits(), uint64(f.bias()+n-1)<<f.mant)
+	return lambda(f.ty, ty, func(eb EB) *Expr {
+		x := eb.P(0)
+		return eb.If(call(f.isNaN, x), mkZeros(n),
+			eb.If(call(f.ops.lt, f.magnitude(x), limit),
+				call(finite, call(f.parts, x)),
+				eb.If(eb.Field(x, 2), bitsConst(n, 1<<(n-1)), bitArrayMap(n, bitsConst(n, 1<<(n-1)), eb.Lit(NOT))),
+			),
+		)
+	})
+}
+
+// convertFloat converts floats from one format to another
+func convertFloat(src, dst *floatFormat) *myc.Lambda {
+	finite := lambda(src.partsType(), dst.ty, func(eb EB) *Expr {
+		sign, e, sig := eb.Arg(0, 0), eb.Arg(0, 1), eb.Arg(0, 2)
+		if src.wide > dst.wide {
+			return call(dst.mkNarrow(src.wide), sign, e, sig)
+		}
+		if src.wide < dst.wide {
+			sig = eb.Concat(sig, mkZeros(dst.wide-src.wide))
+		}
+		return call(dst.round, sign, e, sig)
+	})
+	return lambda(src.ty, dst.ty, func(eb EB) *Expr {
+		x := eb.P(0)
+		return eb.If(call(src.isNaN, x), dst.nan(),
+			eb.If(call(src.isInf, x), dst.inf(eb.Field(x, 2)),
+				call(finite, call(src.parts, x)),
+			),
+		)
+	})
+}
+
+// condNeg returns a lambda which negates an n bit integer if a bit is set
+func condNeg(n int) *myc.Lambda {
+	ty := myc.BitArrayType(n)
+	return lambda(myc.ProductType{myc.BitType{}, ty}, ty, func(eb EB) *Expr {
+		return eb.If(eb.Arg(0, 0), call(intOpsOf(n).neg, eb.Arg(0, 1)), eb.Arg(0, 1))
+	})
+}
+
+// intOps are the integer lambdas used on significands and results of a given width.
+// Most of them are canonical lambdas, which the VM accelerates.
+type intOps struct {
+	add, sub, neg *myc.Lambda
+	shl, shr      *myc.Lambda
+	lt            *myc.Lambda
+	mulWide       *myc.Lambda
+}
+
+func intOpsOf(n int) intOps {
+	switch n {
+	case 16:
+		ty := myc.BitArrayType(16)
+		return intOps{
+			add: adder(16),
+			sub: lambda(myc.ProductType{ty, ty}, ty, func(eb EB) *Expr { return subNBit(16, eb.Arg(0, 0), eb.Arg(0, 1)) }),
+			neg: lambda(ty, ty, func(eb EB) *Expr { return negNBit(16, eb.P(0)) }),
+			shl: B16_Shl, shr: B16_Shr,
+			lt:      B16_Lt,
+			mulWide: B16_MulWide,
+		}
+	case 32:
+		return intOps{
+			add: B32_Add, sub: B32_Sub, neg: B32_Neg,
+			shl: B32_Shl, shr: B32_Shr,
+			lt:      B32_Lt,
+			mulWide: B32_MulWide,
+		}
+	case 64:
+		return intOps{
+			add: B64_Add, sub: B64_Sub, neg: B64_Neg,
+			shl: B64_Shl, shr: B64_Shr,
+			lt:      B64_Lt,
+			mulWide: B64_MulWide,
+		}
+	default:
+		panic(n)
+	}
+}
+
+// shlConst shifts the n bit array x left by a constant
+func shlConst(n int, x *Expr, s int) *Expr {
+	return eb.Concat(mkZeros(s), eb.Section(x, 0, n-s))
+}
+
+// shrConst shifts the n bit array x right by a constant
+func shrConst(n int, x *Expr, s int) *Expr {
+	return eb.Concat(eb.Section(x, s, n), mkZeros(s))
+}
+
+// lits holds literal expressions for lambdas, so that each lambda is encoded once in a body.
+var lits = map[*myc.Lambda]*Expr{}
+
+func litOf(la *myc.Lambda) *Expr {
+	e, exists := lits[la]
+	if !exists {
+		e = eb.Lit(la)
+		lits[la] = e
+	}
+	return e
+}
+
+// call applies la to args, which are passed in a product if there is more than one.
+func call(la *myc.Lambda, args ...*Expr) *Expr {
+	if len(args) == 1 {
+		return eb.Apply(litOf(la), args[0])
+	}
+	return eb.Apply(litOf(la), eb.Product(args...))
+}
+
+func not(x *Expr) *Expr {
+	return eb.Apply(eb.Lit(NOT), x)
+}
+
+func and(a, b *Expr) *Expr {
+	return eb.Apply(eb.Lit(AND), eb.Product(a, b))
+}
+
+func or(a, b *Expr) *Expr {
+	return eb.Apply(eb.Lit(OR), eb.Product(a, b))
+}
+
+func xor(a, b *Expr) *Expr {
+	return eb.Apply(eb.Lit(XOR), eb.Product(a, b))
+}
+
+// b32Const returns x as a two's complement B32
+func b32Const(x int) *Expr {
+	return eb.B32(uint32(int32(x)))
+}
+
+// bitsConst returns the low n bits of x as a bit array
+func bitsConst(n int, x uint64) *Expr {
+	return eb.Lit(bitsValue(n, x))
+}
+
+func bitsValue(n int, x uint64) myc.Value {
+	bs := make([]myc.Bit, n)
+	for i := range bs {
+		if i < 64 {
+			bs[i] = myc.Bit(x >> i & 1)
+		}
+	}
+	return myc.NewBitArray(bs...)
+}
+
+func mkOnes(n int) *Expr {
+	return bitsConst(n, ^uint64(0))
+}
@@ -0,0 +1,300 @@
+package myccanon
+
+import (
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+// The integer lambdas below treat an N bit array as an unsigned integer, least significant bit first.
+// Lambdas with an S suffix interpret their inputs as two's complement signed integers.
+//
+// Shifts and rotates take the amount as a B32.
+// Shifting by N or more bits produces 0; rotating uses the amount mod N.
+// Mod faults if the divisor is 0.
+// MulWide returns the full 2N bit product.
+//
+// Every lambda has a definition in pure MVM, which is slow, and the VM is expected to accelerate them.
+
+var (
+	B8   = myc.B8Type()
+	B16  = myc.B16Type()
+	B128 = myc.BitArrayType(128)
+	B256 = myc.BitArrayType(256)
+	B512 = myc.BitArrayType(512)
+)
+
+var (
+	B8_Shl  = lambda(myc.ProductType{B8, B32}, B8, func(eb EB) *Expr { return shlNBit(8, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B8_Shr  = lambda(myc.ProductType{B8, B32}, B8, func(eb EB) *Expr { return shrNBit(8, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B8_RotL = lambda(myc.ProductType{B8, B32}, B8, func(eb EB) *Expr { return rotlNBit(8, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B8_RotR = lambda(myc.ProductType{B8, B32}, B8, func(eb EB) *Expr { return rotrNBit(8, eb.Arg(0, 0), eb.Arg(0, 1)) })
+
+	B8_Lt  = lambda(myc.ProductType{B8, B8}, myc.BitType{}, func(eb EB) *Expr { return ltNBit(8, eb.Arg(0, 0), eb.Arg(0, 1), false) })
+	B8_LtS = lambda(myc.ProductType{B8, B8}, myc.BitType{}, func(eb EB) *Expr { return ltNBit(8, eb.Arg(0, 0), eb.Arg(0, 1), true) })
+	B8_Gt  = lambda(myc.ProductType{B8, B8}, myc.BitType{}, func(eb EB) *Expr { return flip(B8_Lt, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B8_GtS = lambda(myc.ProductType{B8, B8}, myc.BitType{}, func(eb EB) *Expr { return flip(B8_LtS, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B8_Min = lambda(myc.ProductType{B8, B8}, B8, func(eb EB) *Expr { return pick(B8_Lt, eb.Arg(0, 0), eb.Arg(0, 1), false) })
+	B8_Max = lambda(myc.ProductType{B8, B8}, B8, func(eb EB) *Expr { return pick(B8_Lt, eb.Arg(0, 0), eb.Arg(0, 1), true) })
+
+	B8_Mod     = lambda(myc.ProductType{B8, B8}, B8, func(eb EB) *Expr { return modNBit(eb, 8, B8_Lt) })
+	B8_MulWide = lambda(myc.ProductType{B8, B8}, B16, func(eb EB) *Expr { return mulWideNBit(8, eb.Arg(0, 0), eb.Arg(0, 1)) })
+)
+
+var (
+	B16_Shl  = lambda(myc.ProductType{B16, B32}, B16, func(eb EB) *Expr { return shlNBit(16, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B16_Shr  = lambda(myc.ProductType{B16, B32}, B16, func(eb EB) *Expr { return shrNBit(16, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B16_RotL = lambda(myc.ProductType{B16, B32}, B16, func(eb EB) *Expr { return rotlNBit(16, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B16_RotR = lambda(myc.ProductType{B16, B32}, B16, func(eb EB) *Expr { return rotrNBit(16, eb.Arg(0, 0), eb.Arg(0, 1)) })
+
+	B16_Lt  = lambda(myc.ProductType{B16, B16}, myc.BitType{}, func(eb EB) *Expr { return ltNBit(16, eb.Arg(0, 0), eb.Arg(0, 1), false) })
+	B16_LtS = lambda(myc.ProductType{B16, B16}, myc.BitType{}, func(eb EB) *Expr { return ltNBit(16, eb.Arg(0, 0), eb.Arg(0, 1), true) })
+	B16_Gt  = lambda(myc.ProductType{B16, B16}, myc.BitType{}, func(eb EB) *Expr { return flip(B16_Lt, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B16_GtS = lambda(myc.ProductType{B16, B16}, myc.BitType{}, func(eb EB) *Expr { return flip(B16_LtS, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B16_Min = lambda(myc.ProductType{B16, B16}, B16, func(eb EB) *Expr { return pick(B16_Lt, eb.Arg(0, 0), eb.Arg(0, 1), false) })
+	B16_Max = lambda(myc.ProductType{B16, B16}, B16, func(eb EB) *Expr { return pick(B16_Lt, eb.Arg(0, 0), eb.Arg(0, 1), true) })
+
+	B16_Mod     = lambda(myc.ProductType{B16, B16}, B16, func(eb EB) *Expr { return modNBit(eb, 16, B16_Lt) })
+	B16_MulWide = lambda(myc.ProductType{B16, B16}, B32, func(eb EB) *Expr { return mulWideHalves(16, B8_MulWide, eb.Arg(0, 0), eb.Arg(0, 1)) })
+)
+
+var (
+	B32_Shl  = lambda(myc.ProductType{B32, B32}, B32, func(eb EB) *Expr { return shlNBit(32, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B32_Shr  = lambda(myc.ProductType{B32, B32}, B32, func(eb EB) *Expr { return shrNBit(32, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B32_RotL = lambda(myc.ProductType{B32, B32}, B32, func(eb EB) *Expr { return rotlNBit(32, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B32_RotR = lambda(myc.ProductType{B32, B32}, B32, func(eb EB) *Expr { return rotrNBit(32, eb.Arg(0, 0), eb.Arg(0, 1)) })
+
+	B32_Lt  = lambda(myc.ProductType{B32, B32}, myc.BitType{}, func(eb EB) *Expr { return ltNBit(32, eb.Arg(0, 0), eb.Arg(0, 1), false) })
+	B32_LtS = lambda(myc.ProductType{B32, B32}, myc.BitType{}, func(eb EB) *Expr { return ltNBit(32, eb.Arg(0, 0), eb.Arg(0, 1), true) })
+	B32_Gt  = lambda(myc.ProductType{B32, B32}, myc.BitType{}, func(eb EB) *Expr { return flip(B32_Lt, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B32_GtS = lambda(myc.ProductType{B32, B32}, myc.BitType{}, func(eb EB) *Expr { return flip(B32_LtS, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B32_Min = lambda(myc.ProductType{B32, B32}, B32, func(eb EB) *Expr { return pick(B32_Lt, eb.Arg(0, 0), eb.Arg(0, 1), false) })
+	B32_Max = lambda(myc.ProductType{B32, B32}, B32, func(eb EB) *Expr { return pick(B32_Lt, eb.Arg(0, 0), eb.Arg(0, 1), true) })
+
+	B32_Mod     = lambda(myc.ProductType{B32, B32}, B32, func(eb EB) *Expr { return modNBit(eb, 32, B32_Lt) })
+	B32_MulWide = lambda(myc.ProductType{B32, B32}, B64, func(eb EB) *Expr { return mulWideHalves(32, B16_MulWide, eb.Arg(0, 0), eb.Arg(0, 1)) })
+)
+
+var (
+	B64_Shl  = lambda(myc.ProductType{B64, B32}, B64, func(eb EB) *Expr { return shlNBit(64, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B64_Shr  = lambda(myc.ProductType{B64, B32}, B64, func(eb EB) *Expr { return shrNBit(64, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B64_RotL = lambda(myc.ProductType{B64, B32}, B64, func(eb EB) *Expr { return rotlNBit(64, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B64_RotR = lambda(myc.ProductType{B64, B32}, B64, func(eb EB) *Expr { return rotrNBit(64, eb.Arg(0, 0), eb.Arg(0, 1)) })
+
+	B64_Lt  = lambda(myc.ProductType{B64, B64}, myc.BitType{}, func(eb EB) *Expr { return ltNBit(64, eb.Arg(0, 0), eb.Arg(0, 1), false) })
+	B64_LtS = lambda(myc.ProductType{B64, B64}, myc.BitType{}, func(eb EB) *Expr { return ltNBit(64, eb.Arg(0, 0), eb.Arg(0, 1), true) })
+	B64_Gt  = lambda(myc.ProductType{B64, B64}, myc.BitType{}, func(eb EB) *Expr { return flip(B64_Lt, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B64_GtS = lambda(myc.ProductType{B64, B64}, myc.BitType{}, func(eb EB) *Expr { return flip(B64_LtS, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B64_Min = lambda(myc.ProductType{B64, B64}, B64, func(eb EB) *Expr { return pick(B64_Lt, eb.Arg(0, 0), eb.Arg(0, 1), false) })
+	B64_Max = lambda(myc.ProductType{B64, B64}, B64, func(eb EB) *Expr { return pick(B64_Lt, eb.Arg(0, 0), eb.Arg(0, 1), true) })
+
+	B64_Mod     = lambda(myc.ProductType{B64, B64}, B64, func(eb EB) *Expr { return modNBit(eb, 64, B64_Lt) })
+	B64_MulWide = lambda(myc.ProductType{B64, B64}, B128, func(eb EB) *Expr { return mulWideHalves(64, B32_MulWide, eb.Arg(0, 0), eb.Arg(0, 1)) })
+)
+
+var (
+	B128_Shl  = lambda(myc.ProductType{B128, B32}, B128, func(eb EB) *Expr { return shlNBit(128, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B128_Shr  = lambda(myc.ProductType{B128, B32}, B128, func(eb EB) *Expr { return shrNBit(128, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B128_RotL = lambda(myc.ProductType{B128, B32}, B128, func(eb EB) *Expr { return rotlNBit(128, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B128_RotR = lambda(myc.ProductType{B128, B32}, B128, func(eb EB) *Expr { return rotrNBit(128, eb.Arg(0, 0), eb.Arg(0, 1)) })
+
+	B128_Lt  = lambda(myc.ProductType{B128, B128}, myc.BitType{}, func(eb EB) *Expr { return ltNBit(128, eb.Arg(0, 0), eb.Arg(0, 1), false) })
+	B128_LtS = lambda(myc.ProductType{B128, B128}, myc.BitType{}, func(eb EB) *Expr { return ltNBit(128, eb.Arg(0, 0), eb.Arg(0, 1), true) })
+	B128_Gt  = lambda(myc.ProductType{B128, B128}, myc.BitType{}, func(eb EB) *Expr { return flip(B128_Lt, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B128_GtS = lambda(myc.ProductType{B128, B128}, myc.BitType{}, func(eb EB) *Expr { return flip(B128_LtS, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B128_Min = lambda(myc.ProductType{B128, B128}, B128, func(eb EB) *Expr { return pick(B128_Lt, eb.Arg(0, 0), eb.Arg(0, 1), false) })
+	B128_Max = lambda(myc.ProductType{B128, B128}, B128, func(eb EB) *Expr { return pick(B128_Lt, eb.Arg(0, 0), eb.Arg(0, 1), true) })
+
+	B128_Mod     = lambda(myc.ProductType{B128, B128}, B128, func(eb EB) *Expr { return modNBit(eb, 128, B128_Lt) })
+	B128_MulWide = lambda(myc.ProductType{B128, B128}, B256, func(eb EB) *Expr { return mulWideHalves(128, B64_MulWide, eb.Arg(0, 0), eb.Arg(0, 1)) })
+)
+
+var (
+	B256_Shl  = lambda(myc.ProductType{B256, B32}, B256, func(eb EB) *Expr { return shlNBit(256, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B256_Shr  = lambda(myc.ProductType{B256, B32}, B256, func(eb EB) *Expr { return shrNBit(256, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B256_RotL = lambda(myc.ProductType{B256, B32}, B256, func(eb EB) *Expr { return rotlNBit(256, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B256_RotR = lambda(myc.ProductType{B256, B32}, B256, func(eb EB) *Expr { return rotrNBit(256, eb.Arg(0, 0), eb.Arg(0, 1)) })
+
+	B256_Lt  = lambda(myc.ProductType{B256, B256}, myc.BitType{}, func(eb EB) *Expr { return ltNBit(256, eb.Arg(0, 0), eb.Arg(0, 1), false) })
+	B256_LtS = lambda(myc.ProductType{B256, B256}, myc.BitType{}, func(eb EB) *Expr { return ltNBit(256, eb.Arg(0, 0), eb.Arg(0, 1), true) })
+	B256_Gt  = lambda(myc.ProductType{B256, B256}, myc.BitType{}, func(eb EB) *Expr { return flip(B256_Lt, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B256_GtS = lambda(myc.ProductType{B256, B256}, myc.BitType{}, func(eb EB) *Expr { return flip(B256_LtS, eb.Arg(0, 0), eb.Arg(0, 1)) })
+	B256_Min = lambda(myc.ProductType{B256, B256}, B256, func(eb EB) *Expr { return pick(B256_Lt, eb.Arg(0, 0), eb.Arg(0, 1), false) })
+	B256_Max = lambda(myc.ProductType{B256, B256}, B256, func(eb EB) *Expr { return pick(B256_Lt, eb.Arg(0, 0), eb.Arg(0, 1), true) })
+
+	B256_Mod     = lambda(myc.ProductType{B256, B256}, B256, func(eb EB) *Expr { return modNBit(eb, 256, B256_Lt) })
+	B256_MulWide = lambda(myc.ProductType{B256, B256}, B512, func(eb EB) *Expr { return mulWideHalves(256, B128_MulWide, eb.Arg(0, 0), eb.Arg(0, 1)) })
+)
+
+func shlNBit(n int, x, amt *Expr) *Expr {
+	x = eb.If(shiftsOut(n, amt), mkZeros(n), x)
+	return shiftStages(n, x, amt, func(v *Expr, s int) *Expr {
+		return eb.Concat(mkZeros(s), eb.Section(v, 0, n-s))
+	})
+}
+
+func shrNBit(n int, x, amt *Expr) *Expr {
+	x = eb.If(shiftsOut(n, amt), mkZeros(n), x)
+	return shiftStages(n, x, amt, func(v *Expr, s int) *Expr {
+		return eb.Concat(eb.Section(v, s, n), mkZeros(s))
+	})
+}
+
+func rotlNBit(n int, x, amt *Expr) *Expr {
+	return shiftStages(n, x, amt, func(v *Expr, s int) *Expr {
+		return eb.Concat(eb.Section(v, n-s, n), eb.Section(v, 0, n-s))
+	})
+}
+
+func rotrNBit(n int, x, amt *Expr) *Expr {
+	return shiftStages(n, x, amt, func(v *Expr, s int) *Expr {
+		return eb.Concat(eb.Section(v, s, n), eb.Section(v, 0, s))
+	})
+}
+
+// shiftStages is a barrel shifter.
+// For each of the low log2(n) bits of amt, it applies a lambda which calls shift(v, s) if that bit is set.
+// s is the power of 2 corresponding to the bit.
+func shiftStages(n int, x, amt *Expr, shift func(v *Expr, s int) *Expr) *Expr {
+	ty := myc.BitArrayType(n)
+	for i := 0; 1<<i < n; i++ {
+		stage := lambda(myc.ProductType{ty, myc.BitType{}}, ty, func(eb EB) *Expr {
+			return eb.If(eb.Arg(0, 1), shift(eb.Arg(0, 0), 1<<i), eb.Arg(0, 0))
+		})
+		x = eb.Apply(eb.Lit(stage), eb.Product(x, eb.Slot(amt, eb.B32(uint32(i)))))
+	}
+	return x
+}
+
+// shiftsOut returns 1 if amt >= n
+func shiftsOut(n int, amt *Expr) *Expr {
+	var k int
+	for 1<<k < n {
+		k++
+	}
+	return reduceArray(k, 32, amt, eb.Lit(OR))
+}
+
+var (
+	// ltTable is indexed by (a, b, lt), and returns lt if a == b, otherwise a < b.
+	ltTable = myc.NewBitArray(0, 0, 1, 0, 1, 0, 1, 1)
+	// ltSignTable is the same as ltTable, but for the sign bit.
+	ltSignTable = myc.NewBitArray(0, 1, 0, 0, 1, 1, 0, 1)
+)
+
+// ltNBit compares a and b starting from the least significant bit.
+func ltNBit(n int, a, b *Expr, signed bool) *Expr {
+	lt := eb.Bit(0)
+	for i := 0; i < n; i++ {
+		table := ltTable
+		if signed && i == n-1 {
+			table = ltSignTable
+		}
+		idx := eb.B32(uint32(i))
+		lt = eb.Mux(eb.Lit(table), eb.Product(eb.Slot(a, idx), eb.Slot(b, idx), lt))
+	}
+	return lt
+}
+
+// flip calls the comparison lt with the arguments swapped
+func flip(lt *myc.Lambda, a, b *Expr) *Expr {
+	return eb.Apply(eb.Lit(lt), eb.Product(b, a))
+}
+
+// pick returns the lesser of a and b according to lt, or the greater if max is set.
+func pick(lt *myc.Lambda, a, b *Expr, max bool) *Expr {
+	if max {
+		return eb.If(eb.Apply(eb.Lit(lt), eb.Product(a, b)), b, a)
+	}
+	return eb.If(eb.Apply(eb.Lit(lt), eb.Product(a, b)), a, b)
+}
+
+// modNBit is the body of a lambda taking 2 n bit arrays.
+// It performs restoring division, one bit of the dividend at a time, keeping only the remainder.
+func modNBit(eb EB, n int, lt *myc.Lambda) *Expr {
+	ty := myc.BitArrayType(n)
+	// step takes the remainder, the next bit of the dividend, the divisor, and the complement of the divisor.
+	step := lambda(myc.ProductType{ty, myc.BitType{}, ty, ty}, ty, func(eb EB) *Expr {
+		shifted := eb.Concat(eb.ArrayUnit(eb.Arg(0, 1)), eb.Section(eb.Arg(0, 0), 0, n-1))
+		return eb.Let(shifted, func(eb EB) *Expr {
+			// if a bit was shifted out then the remainder is larger than the divisor.
+			carry := eb.Slot(eb.Arg(1, 0), eb.B32(uint32(n-1)))
+			ge := eb.Apply(eb.Lit(NOT), eb.Apply(eb.Lit(lt), eb.Product(eb.P(0), eb.Arg(1, 2))))
+			return eb.If(
+				eb.Apply(eb.Lit(OR), eb.Product(carry, ge)),
+				addCarryNBit(n, eb.P(0), eb.Arg(1, 3), eb.Bit(1)),
+				eb.P(0),
+			)
+		})
+	})
+	return eb.If(
+		eb.Equal(eb.Arg(0, 1), mkZeros(n)),
+		eb.Fault(eb.String("divide by 0")),
+		eb.Let(bitArrayMap(n, eb.Arg(0, 1), eb.Lit(NOT)), func(eb EB) *Expr {
+			stepLit := eb.Lit(step)
+			r := mkZeros(n)
+			for i := n - 1; i >= 0; i-- {
+				ai := eb.Slot(eb.Arg(1, 0), eb.B32(uint32(i)))
+				r = eb.Apply(stepLit, eb.Product(r, ai, eb.Arg(1, 1), eb.P(0)))
+			}
+			return r
+		}),
+	)
+}
+
+// mulWideNBit is long multiplication, producing 2n bits.
+func mulWideNBit(n int, a, b *Expr) *Expr {
+	terms := make([]*Expr, n)
+	for i := range terms {
+		shifted := eb.Concat(a, mkZeros(n))
+		if i > 0 {
+			shifted = eb.Concat(mkZeros(i), eb.Concat(a, mkZeros(n-i)))
+		}
+		terms[i] = eb.If(eb.Slot(b, eb.B32(uint32(i))), shifted, mkZeros(2*n))
+	}
+	return sumTree(adder(2*n), terms)
+}
+
+// mulWideHalves multiplies each half of a and b using half, and adds the 4 partial products.
+func mulWideHalves(n int, half *myc.Lambda, a, b *Expr) *Expr {
+	h := n / 2
+	// share the literal so that half is only encoded once
+	halfLit := eb.Lit(half)
+	mul := func(x, y *Expr) *Expr {
+		return eb.Apply(halfLit, eb.Product(x, y))
+	}
+	mid := func(x *Expr) *Expr {
+		return eb.Concat(mkZeros(h), eb.Concat(x, mkZeros(h)))
+	}
+	aLo, aHi := eb.Section(a, 0, h), eb.Section(a, h, n)
+	bLo, bHi := eb.Section(b, 0, h), eb.Section(b, h, n)
+	return sumTree(adder(2*n), []*Expr{
+		eb.Concat(mul(aLo, bLo), mul(aHi, bHi)),
+		mid(mul(aLo, bHi)),
+		mid(mul(aHi, bLo)),
+	})
+}
+
+// sumTree adds xs using add, which is called on each pair.
+func sumTree(add *myc.Lambda, xs []*Expr) *Expr {
+	if len(xs) == 0 {
+		panic("sumTree on 0 elements")
+	}
+	return sumTreeLit(eb.Lit(add), xs)
+}
+
+func sumTreeLit(add *Expr, xs []*Expr) *Expr {
+	if len(xs) == 1 {
+		return xs[0]
+	}
+	left := sumTreeLit(add, xs[:len(xs)/2])
+	right := sumTreeLit(add, xs[len(xs)/2:])
+	return eb.Apply(add, eb.Product(left, right))
+}
+
+// adder returns a lambda which adds 2 n bit arrays.
+// Applying a lambda ensures that the arguments are only evaluated once.
+func adder(n int) *myc.Lambda {
+	ty := myc.BitArrayType(n)
+	return lambda(myc.ProductType{ty, ty}, ty, func(eb EB) *Expr {
+		return addNBit(n, eb.Arg(0, 0), eb.Arg(0, 1))
+	})
+}
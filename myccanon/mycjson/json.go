@@ -46,6 +46,10 @@ type JSONNull struct{}
 
 func (JSONNull) isJSON() {}
 
+func (JSONNull) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
 type JSONBool bool
 
 func (JSONBool) isJSON() {}
@@ -58,6 +62,10 @@ type JSONNumber json.Number
 
 func (JSONNumber) isJSON() {}
 
+func (n JSONNumber) MarshalJSON() ([]byte, error) {
+	return json.Marshal(json.Number(n))
+}
+
 type JSONMap map[JSONString]JSON
 
 func (JSONMap) isJSON() {}
@@ -41,3 +41,14 @@ func TestJSONPull(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, node1, node2)
 }
+
+func TestJSONMarshal(t *testing.T) {
+	x := NewJSON(map[string]any{
+		"a": nil,
+		"b": json.Number("100"),
+		"c": []any{"x", true},
+	})
+	data, err := json.Marshal(x)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"a": null, "b": 100, "c": ["x", true]}`, string(data))
+}
@@ -0,0 +1,63 @@
+package myccmd
+
+import (
+	"archive/zip"
+	"os"
+
+	"go.brendoncarroll.net/star"
+)
+
+var exportCmd = star.Command{
+	Metadata: star.Metadata{
+		Short: "write every pod in the system to an archive",
+		Tags:  []string{"pod"},
+	},
+	Flags: []star.IParam{DBParam, KeyParam},
+	Pos:   []star.IParam{outFileParam},
+	F: func(c star.Context) error {
+		sys := NewSystem(c)
+		f := outFileParam.Load(c)
+		if err := sys.Export(c, f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	},
+}
+
+var importCmd = star.Command{
+	Metadata: star.Metadata{
+		Short: "create pods from an archive written by export",
+		Tags:  []string{"pod"},
+	},
+	Flags: []star.IParam{DBParam, KeyParam},
+	Pos:   []star.IParam{fileParam},
+	F: func(c star.Context) error {
+		sys := NewSystem(c)
+		f := fileParam.Load(c)
+		defer f.Close()
+		finfo, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(f, finfo.Size())
+		if err != nil {
+			return err
+		}
+		pods, err := sys.Import(c, zr)
+		if err != nil {
+			return err
+		}
+		for _, pod := range pods {
+			c.Printf("imported pod %d\n", pod.ID())
+		}
+		return nil
+	},
+}
+
+var outFileParam = star.Param[*os.File]{
+	Name: "out",
+	Parse: func(x string) (*os.File, error) {
+		return os.Create(x)
+	},
+}
@@ -0,0 +1,46 @@
+package myccmd
+
+import (
+	"go.brendoncarroll.net/star"
+
+	"myceliumweb.org/mycelium/mycss"
+)
+
+var gc = star.Command{
+	Metadata: star.Metadata{
+		Short: "remove data which is not reachable from a pod's namespace",
+		Tags:  []string{"pod"},
+	},
+	Flags: []star.IParam{DBParam, KeyParam},
+	Pos:   []star.IParam{podIDsParam},
+	F: func(c star.Context) error {
+		sys := NewSystem(c)
+		var pods []*mycss.Pod
+		if pids := podIDsParam.LoadAll(c); len(pids) > 0 {
+			for _, pid := range pids {
+				pod, err := sys.Get(c, pid)
+				if err != nil {
+					return err
+				}
+				pods = append(pods, pod)
+			}
+		} else {
+			var err error
+			if pods, err = sys.List(c); err != nil {
+				return err
+			}
+		}
+		c.Printf("POD\tREACHABLE\tREMOVED\tRECLAIMED\n")
+		var total int64
+		for _, pod := range pods {
+			stats, err := pod.GC(c)
+			if err != nil {
+				return err
+			}
+			c.Printf("%v\t%d\t%d\t%d bytes\n", pod.ID(), stats.Reachable, stats.Removed, stats.BytesReclaimed)
+			total += stats.BytesReclaimed
+		}
+		c.Printf("reclaimed %d bytes\n", total)
+		return nil
+	},
+}
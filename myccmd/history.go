@@ -0,0 +1,145 @@
+package myccmd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.brendoncarroll.net/star"
+
+	"myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/mycss"
+)
+
+var history = star.NewDir(star.Metadata{
+	Short: "inspect and prune the history of a pod's namespace",
+	Tags:  []string{"pod"},
+}, map[star.Symbol]star.Command{
+	"list":  historyList,
+	"prune": historyPrune,
+})
+
+var historyList = star.Command{
+	Metadata: star.Metadata{
+		Short: "list the changes to a key in a pod's namespace",
+		Tags:  []string{"pod"},
+	},
+	Flags: []star.IParam{DBParam, KeyParam},
+	Pos:   []star.IParam{PodIDParam, nsKeyParam},
+	F: func(c star.Context) error {
+		sys := NewSystem(c)
+		pod, err := sys.Get(c, PodIDParam.Load(c))
+		if err != nil {
+			return err
+		}
+		ents, err := pod.History(c, nsKeyParam.Load(c))
+		if err != nil {
+			return err
+		}
+		c.Printf("VERSION\tPROC\tTIME\tVALUE\n")
+		for _, ent := range ents {
+			val := "<deleted>"
+			if ent.Value != nil {
+				val = mycmem.Pretty(ent.Value)
+			}
+			c.Printf("%d\t%d\t%v\t%s\n", ent.Version, ent.ProcID, ent.CreatedAt, val)
+		}
+		return nil
+	},
+}
+
+var historyPrune = star.Command{
+	Metadata: star.Metadata{
+		Short: "forget old versions of a pod's namespace, so GC can remove the values only they reference",
+		Tags:  []string{"pod"},
+	},
+	Flags: []star.IParam{DBParam, KeyParam, keepParam, beforeParam},
+	Pos:   []star.IParam{PodIDParam},
+	F: func(c star.Context) error {
+		keep, hasKeep := keepParam.LoadOpt(c)
+		before, hasBefore := beforeParam.LoadOpt(c)
+		if hasKeep == hasBefore {
+			return fmt.Errorf("exactly one of --%s or --%s is required", keepParam.Name, beforeParam.Name)
+		}
+		sys := NewSystem(c)
+		pod, err := sys.Get(c, PodIDParam.Load(c))
+		if err != nil {
+			return err
+		}
+		if hasBefore {
+			err = pod.PruneHistoryBefore(c, before)
+		} else {
+			if keep == 0 {
+				return fmt.Errorf("--%s must be at least 1", keepParam.Name)
+			}
+			var ver mycss.NSVersion
+			if ver, err = pod.NSVersion(c); err != nil {
+				return err
+			}
+			if ver >= mycss.NSVersion(keep) {
+				err = pod.PruneHistory(c, ver-mycss.NSVersion(keep)+1)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		c.Printf("pruned history of pod %d\n", pod.ID())
+		return nil
+	},
+}
+
+var rollback = star.Command{
+	Metadata: star.Metadata{
+		Short: "set a pod's namespace to a previous version",
+		Tags:  []string{"pod"},
+	},
+	Flags: []star.IParam{DBParam, KeyParam},
+	Pos:   []star.IParam{PodIDParam, nsVersionParam},
+	F: func(c star.Context) error {
+		sys := NewSystem(c)
+		pod, err := sys.Get(c, PodIDParam.Load(c))
+		if err != nil {
+			return err
+		}
+		ver := nsVersionParam.Load(c)
+		if err := pod.Rollback(c, ver); err != nil {
+			return err
+		}
+		c.Printf("rolled back pod %d to version %d\n", pod.ID(), ver)
+		return nil
+	},
+}
+
+var nsKeyParam = star.Param[string]{
+	Name:  "key",
+	Parse: star.ParseString,
+}
+
+// keepParam is the number of versions to keep, including the current version.
+var keepParam = star.Param[uint64]{
+	Name:     "keep",
+	Repeated: true,
+	Parse: func(x string) (uint64, error) {
+		return strconv.ParseUint(x, 10, 64)
+	},
+}
+
+// beforeParam is either an RFC 3339 time, or a duration before now.
+var beforeParam = star.Param[time.Time]{
+	Name:     "before",
+	Repeated: true,
+	Parse: func(x string) (time.Time, error) {
+		if d, err := time.ParseDuration(x); err == nil {
+			return time.Now().Add(-d), nil
+		}
+		return time.Parse(time.RFC3339, x)
+	},
+}
+
+var nsVersionParam = star.Param[mycss.NSVersion]{
+	Name: "version",
+	Parse: func(x string) (mycss.NSVersion, error) {
+		n, err := strconv.ParseUint(x, 10, 64)
+		return mycss.NSVersion(n), err
+	},
+}
@@ -13,10 +13,9 @@ var invokeJSON = star.Command{
 	Metadata: star.Metadata{
 		Short: "call invokeJSON method in a pod with JSON data",
 	},
-	Flags: []star.IParam{DBParam, PodIDParam},
+	Flags: []star.IParam{DBParam, KeyParam, PodIDParam},
 	F: func(c star.Context) error {
-		db := DBParam.Load(c)
-		sys := mycss.NewSystem(db)
+		sys := NewSystem(c)
 		ctx := c.Context
 		sys.Get(ctx, PodIDParam.Load(c))
 		pod, err := sys.Create(ctx)
@@ -0,0 +1,86 @@
+package myccmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jmoiron/sqlx"
+	"go.brendoncarroll.net/star"
+
+	"myceliumweb.org/mycelium/mycss"
+)
+
+const (
+	// passphraseEnv is the environment variable holding the passphrase for the System's master key.
+	passphraseEnv = "MYC_PASSPHRASE"
+	// newPassphraseEnv is the environment variable holding the passphrase for the new master key during rekey.
+	newPassphraseEnv = "MYC_NEW_PASSPHRASE"
+)
+
+// KeyParam is a file holding the master key used to encrypt pod secrets.
+// If it is not given, the key is derived from $MYC_PASSPHRASE if that is set.
+//
+// The flag is optional because it is Repeated, not because it has a Default:
+// star only fills in the Default of one missing flag per command.
+var KeyParam = star.Param[mycss.MasterKey]{
+	Name:     "key-file",
+	Repeated: true,
+	Parse:    mycss.ReadKeyFile,
+}
+
+var newKeyParam = star.Param[mycss.MasterKey]{
+	Name:     "new-key-file",
+	Repeated: true,
+	Parse:    mycss.ReadKeyFile,
+}
+
+// loadMasterKey returns the last key file given for p, or the key derived from the passphrase in env.
+// It returns false if neither is given.
+func loadMasterKey(c star.Context, p star.Param[mycss.MasterKey], env string) (mycss.MasterKey, bool) {
+	if mk, ok := p.LoadOpt(c); ok {
+		return mk, true
+	}
+	if pass, ok := os.LookupEnv(env); ok {
+		return mycss.PassphraseKey([]byte(pass)), true
+	}
+	return mycss.MasterKey{}, false
+}
+
+// NewSystem returns a System for the database and master key given on the command line.
+// The command must include DBParam and KeyParam in its flags.
+// A key is required to create pods in a new database, unless the database is in memory.
+func NewSystem(c star.Context) *mycss.System {
+	db := DBParam.Load(c)
+	mk, _ := loadMasterKey(c, KeyParam, passphraseEnv)
+	sys := mycss.NewSystemWithKey(db, mk)
+	sys.SetRequireKey(!isMemoryDB(db))
+	return sys
+}
+
+// isMemoryDB returns true if db is an in-memory database, which is gone when the command exits.
+func isMemoryDB(db *sqlx.DB) bool {
+	var file string
+	if err := db.Get(&file, `SELECT file FROM pragma_database_list WHERE name = 'main'`); err != nil {
+		return false
+	}
+	return file == ""
+}
+
+var rekey = star.Command{
+	Metadata: star.Metadata{
+		Short: "change the master key, and re-encrypt every pod's secret with it",
+	},
+	Flags: []star.IParam{DBParam, KeyParam, newKeyParam},
+	F: func(c star.Context) error {
+		next, ok := loadMasterKey(c, newKeyParam, newPassphraseEnv)
+		if !ok {
+			return fmt.Errorf("rekey requires a new key, from --%s or $%s", newKeyParam.Name, newPassphraseEnv)
+		}
+		sys := NewSystem(c)
+		if err := sys.Rekey(c, next); err != nil {
+			return err
+		}
+		c.Printf("master key changed\n")
+		return nil
+	},
+}
@@ -0,0 +1,93 @@
+package myccmd
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.brendoncarroll.net/star"
+
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/mycss"
+)
+
+func TestCLI(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "myc.db")
+	key := filepath.Join(dir, "key")
+	require.NoError(t, os.WriteFile(key, bytes.Repeat([]byte{1}, 32), 0o600))
+
+	_, err := execCmd(t, Root(), "create", "--db", db)
+	require.ErrorAs(t, err, &mycss.ErrNoKey{})
+	runCmd(t, Root(), "create", "--db", db, "--key-file", key)
+	require.Equal(t, "ID\n1\n", runCmd(t, Root(), "list", "--db", db, "--key-file", key))
+	// rekey requires a new key.
+	t.Setenv(newPassphraseEnv, "")
+	require.NoError(t, os.Unsetenv(newPassphraseEnv))
+	_, err = execCmd(t, Root(), "rekey", "--db", db, "--key-file", key)
+	require.ErrorContains(t, err, "new-key-file")
+	require.Equal(t, "ID\n1\n", runCmd(t, Root(), "list", "--db", db, "--key-file", key))
+
+	_, err = execCmd(t, Root(), "history", "prune", "--db", db, "--key-file", key, "1")
+	require.ErrorContains(t, err, "exactly one")
+	runCmd(t, Root(), "history", "prune", "--db", db, "--key-file", key, "--keep", "1", "1")
+	runCmd(t, Root(), "history", "prune", "--db", db, "--key-file", key, "--before", "1h", "1")
+	runCmd(t, Root(), "history", "list", "--db", db, "--key-file", key, "1", "x")
+	runCmd(t, Root(), "gc")
+}
+
+// TestOptionalFlags checks that each command can be run with only its required arguments.
+func TestOptionalFlags(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "ns.zip")
+	require.NoError(t, os.WriteFile(f, nil, 0o644))
+	tcs := []struct {
+		Name string
+		Cmd  star.Command
+		Args []string
+	}{
+		{Name: "create", Cmd: create},
+		{Name: "list", Cmd: list},
+		{Name: "gc", Cmd: gc},
+		{Name: "history-prune", Cmd: historyPrune, Args: []string{"1"}},
+		{Name: "rekey", Cmd: rekey},
+		{Name: "reset", Cmd: reset, Args: []string{"--f", f, "1"}},
+		{Name: "run", Cmd: run, Args: []string{"--f", f}},
+		{Name: "serve", Cmd: serve},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			var called bool
+			cmd := tc.Cmd
+			cmd.F = func(star.Context) error {
+				called = true
+				return nil
+			}
+			runCmd(t, cmd, tc.Args...)
+			require.True(t, called)
+		})
+	}
+}
+
+// runCmd runs cmd with args, and returns what it printed.
+func runCmd(t testing.TB, cmd star.Command, args ...string) string {
+	out, err := execCmd(t, cmd, args...)
+	require.NoError(t, err)
+	return out
+}
+
+func execCmd(t testing.TB, cmd star.Command, args ...string) (string, error) {
+	ctx := testutil.Context(t)
+	var stdout, stderr bytes.Buffer
+	outw, errw := bufio.NewWriter(&stdout), bufio.NewWriter(&stderr)
+	err := star.Run(ctx, cmd, map[string]string{}, "myc", args, bufio.NewReader(strings.NewReader("")), outw, errw)
+	outw.Flush()
+	errw.Flush()
+	if err != nil {
+		t.Log(stderr.String())
+	}
+	return stdout.String(), err
+}
@@ -3,10 +3,12 @@ package myccmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"go.brendoncarroll.net/star"
+	"myceliumweb.org/mycelium/mycnet"
 	"myceliumweb.org/mycelium/mycss"
 )
 
@@ -15,10 +17,9 @@ var create = star.Command{
 		Short: "create a pod",
 		Tags:  []string{"pod"},
 	},
-	Flags: []star.IParam{DBParam},
+	Flags: []star.IParam{DBParam, KeyParam},
 	F: func(c star.Context) error {
-		db := DBParam.Load(c)
-		sys := mycss.NewSystem(db)
+		sys := NewSystem(c)
 		pod, err := sys.Create(c)
 		if err != nil {
 			return err
@@ -33,11 +34,10 @@ var drop = star.Command{
 		Short: "remove a pod and its data from the system",
 		Tags:  []string{"pod"},
 	},
-	Flags: []star.IParam{DBParam},
+	Flags: []star.IParam{DBParam, KeyParam},
 	Pos:   []star.IParam{PodIDParam},
 	F: func(c star.Context) error {
-		db := DBParam.Load(c)
-		sys := mycss.NewSystem(db)
+		sys := NewSystem(c)
 		return sys.Drop(c, PodIDParam.Load(c))
 	},
 }
@@ -47,10 +47,9 @@ var list = star.Command{
 		Short: "list the pods in a system",
 		Tags:  []string{"pod"},
 	},
-	Flags: []star.IParam{DBParam},
+	Flags: []star.IParam{DBParam, KeyParam},
 	F: func(c star.Context) error {
-		db := DBParam.Load(c)
-		sys := mycss.NewSystem(db)
+		sys := NewSystem(c)
 		pods, err := sys.List(c)
 		if err != nil {
 			return err
@@ -68,13 +67,12 @@ var reset = star.Command{
 		Short: "reset",
 		Tags:  []string{"pods"},
 	},
-	Flags: []star.IParam{DBParam, fileParam,
-		NetNodeParam, CellParam, ConsoleParam,
+	Flags: []star.IParam{DBParam, KeyParam, fileParam,
+		NetNodeParam, CellParam, ConsoleParam, WatchParam, TimerParam, MailboxParam, FSParam, AutostartParam, RecordParam,
 	},
 	Pos: []star.IParam{PodIDParam},
 	F: func(c star.Context) error {
-		db := DBParam.Load(c)
-		sys := mycss.NewSystem(db)
+		sys := NewSystem(c)
 		pod, err := sys.Get(c, PodIDParam.Load(c))
 		if err != nil {
 			return err
@@ -103,8 +101,11 @@ var fileParam = star.Param[*os.File]{
 type NetNodeSpec struct {
 	Path     string
 	KeyIndex uint32
+	Limits   mycnet.Limits
 }
 
+// NetNodeParam configures a network device.
+// The format is path:keyIndex[:depth=n][:blobs=n][:bits=n]
 var NetNodeParam = star.Param[NetNodeSpec]{
 	Name:     "net",
 	Repeated: true,
@@ -117,10 +118,94 @@ var NetNodeParam = star.Param[NetNodeSpec]{
 		if err != nil {
 			return NetNodeSpec{}, err
 		}
-		return NetNodeSpec{
+		ret := NetNodeSpec{
 			Path:     parts[0],
 			KeyIndex: uint32(idx),
-		}, nil
+		}
+		for _, part := range parts[2:] {
+			k, v, _ := strings.Cut(part, "=")
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return NetNodeSpec{}, fmt.Errorf("could not parse network node spec from %q: invalid limit %q", x, part)
+			}
+			switch k {
+			case "depth":
+				ret.Limits.MaxDepth = n
+			case "blobs":
+				ret.Limits.MaxBlobs = n
+			case "bits":
+				ret.Limits.MaxBits = n
+			default:
+				return NetNodeSpec{}, fmt.Errorf("could not parse network node spec from %q: unknown option %q", x, k)
+			}
+		}
+		return ret, nil
+	},
+}
+
+type MailboxSpec struct {
+	Path string
+	Spec mycss.MailboxSpec
+}
+
+// MailboxParam configures a mailbox device.
+// The format is path[:send=id,id...][:accept=id,id...]
+var MailboxParam = star.Param[MailboxSpec]{
+	Name:     "mailbox",
+	Repeated: true,
+	Parse: func(x string) (MailboxSpec, error) {
+		parts := strings.Split(x, ":")
+		ret := MailboxSpec{Path: parts[0]}
+		for _, part := range parts[1:] {
+			k, v, _ := strings.Cut(part, "=")
+			var ids []mycss.PodID
+			for _, s := range strings.Split(v, ",") {
+				id, err := ParsePodID(s)
+				if err != nil {
+					return MailboxSpec{}, fmt.Errorf("could not parse mailbox spec from %q: %w", x, err)
+				}
+				ids = append(ids, id)
+			}
+			switch k {
+			case "send":
+				ret.Spec.SendTo = append(ret.Spec.SendTo, ids...)
+			case "accept":
+				ret.Spec.AcceptFrom = append(ret.Spec.AcceptFrom, ids...)
+			default:
+				return MailboxSpec{}, fmt.Errorf("could not parse mailbox spec from %q: unknown option %q", x, k)
+			}
+		}
+		return ret, nil
+	},
+}
+
+type FSSpec struct {
+	Path string
+	Spec mycss.FSSpec
+}
+
+// FSParam configures a filesystem device.
+// The format is path:hostdir[:ro]
+var FSParam = star.Param[FSSpec]{
+	Name:     "fs",
+	Repeated: true,
+	Parse: func(x string) (FSSpec, error) {
+		parts := strings.Split(x, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return FSSpec{}, fmt.Errorf("could not parse fs spec from %q", x)
+		}
+		root, err := filepath.Abs(parts[1])
+		if err != nil {
+			return FSSpec{}, err
+		}
+		ret := FSSpec{Path: parts[0], Spec: mycss.FSSpec{Root: root}}
+		if len(parts) == 3 {
+			if parts[2] != "ro" {
+				return FSSpec{}, fmt.Errorf("could not parse fs spec from %q: unknown option %q", x, parts[2])
+			}
+			ret.Spec.ReadOnly = true
+		}
+		return ret, nil
 	},
 }
 
@@ -130,6 +215,30 @@ var CellParam = star.Param[string]{
 	Parse:    star.ParseString,
 }
 
+var AutostartParam = star.Param[bool]{
+	Name:     "autostart",
+	Repeated: true,
+	Parse:    strconv.ParseBool,
+}
+
+var RecordParam = star.Param[bool]{
+	Name:     "record",
+	Repeated: true,
+	Parse:    strconv.ParseBool,
+}
+
+var WatchParam = star.Param[string]{
+	Name:     "watch",
+	Repeated: true,
+	Parse:    star.ParseString,
+}
+
+var TimerParam = star.Param[string]{
+	Name:     "timer",
+	Repeated: true,
+	Parse:    star.ParseString,
+}
+
 var ConsoleParam = star.Param[string]{
 	Name:     "console",
 	Repeated: true,
@@ -144,10 +253,29 @@ func BuildPodConfig(c star.Context) mycss.PodConfig {
 	for _, k := range CellParam.LoadAll(c) {
 		devs[k] = mycss.DevCell()
 	}
+	for _, k := range WatchParam.LoadAll(c) {
+		devs[k] = mycss.DevWatch()
+	}
+	for _, k := range TimerParam.LoadAll(c) {
+		devs[k] = mycss.DevTimer()
+	}
 	for _, spec := range NetNodeParam.LoadAll(c) {
-		devs[spec.Path] = mycss.DevNetwork(spec.KeyIndex)
+		ds := mycss.DevNetwork(spec.KeyIndex)
+		ds.Network.Limits = spec.Limits
+		devs[spec.Path] = ds
+	}
+	for _, spec := range MailboxParam.LoadAll(c) {
+		devs[spec.Path] = mycss.DevMailbox(spec.Spec)
+	}
+	for _, spec := range FSParam.LoadAll(c) {
+		devs[spec.Path] = mycss.DevFS(spec.Spec)
 	}
+	// a boolean flag which is not given is false.
+	autostart, _ := AutostartParam.LoadOpt(c)
+	record, _ := RecordParam.LoadOpt(c)
 	return mycss.PodConfig{
-		Devices: devs,
+		Autostart: autostart,
+		Record:    record,
+		Devices:   devs,
 	}
 }
@@ -0,0 +1,74 @@
+package myccmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.brendoncarroll.net/star"
+
+	"myceliumweb.org/mycelium/mycss"
+)
+
+var replay = star.Command{
+	Metadata: star.Metadata{
+		Short: "re-execute a process's recorded runs, with port I/O served from the recording",
+		Tags:  []string{"pod"},
+	},
+	Flags: []star.IParam{DBParam, KeyParam, runNumParam},
+	Pos:   []star.IParam{PodIDParam, procIDParam},
+	F: func(c star.Context) error {
+		sys := NewSystem(c)
+		pod, err := sys.Get(c, PodIDParam.Load(c))
+		if err != nil {
+			return err
+		}
+		procID := procIDParam.Load(c)
+		recs, err := pod.Recordings(c, procID)
+		if err != nil {
+			return err
+		}
+		if n, ok := runNumParam.LoadOpt(c); ok {
+			recs = filterRuns(recs, n)
+		}
+		if len(recs) == 0 {
+			return fmt.Errorf("no recordings of process %d in pod %d", procID, pod.ID())
+		}
+		c.Printf("RUN\tSTEPS\tRESULT\n")
+		var failed int
+		for _, rec := range recs {
+			result := "ok"
+			if err := pod.Replay(c, procID, rec.Run); err != nil {
+				result = err.Error()
+				failed++
+			}
+			c.Printf("%d\t%d\t%s\n", rec.Run, rec.Steps, result)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d runs did not replay", failed, len(recs))
+		}
+		return nil
+	},
+}
+
+func filterRuns(recs []mycss.RecordingInfo, run int) []mycss.RecordingInfo {
+	var ret []mycss.RecordingInfo
+	for _, rec := range recs {
+		if rec.Run == run {
+			ret = append(ret, rec)
+		}
+	}
+	return ret
+}
+
+var procIDParam = star.Param[mycss.ProcID]{
+	Name: "proc",
+	Parse: func(x string) (mycss.ProcID, error) {
+		n, err := strconv.ParseUint(x, 10, 64)
+		return mycss.ProcID(n), err
+	},
+}
+
+var runNumParam = star.Param[int]{
+	Name:  "run",
+	Parse: strconv.Atoi,
+}
@@ -28,6 +28,15 @@ var root = star.NewDir(star.Metadata{
 	"list":   list,
 	"drop":   drop,
 	"reset":  reset,
+	"gc":     gc,
+
+	"history":  history,
+	"rollback": rollback,
+	"rekey":    rekey,
+	"replay":   replay,
+
+	"export": exportCmd,
+	"import": importCmd,
 
 	"status": status,
 	"zip":    zipCmd,
@@ -61,9 +70,12 @@ var DBParam = star.Param[*sqlx.DB]{
 	},
 }
 
+// defaultListenAddr is the address to listen on if ListenerParam is not given.
+const defaultListenAddr = "127.0.0.1:6666"
+
 var ListenerParam = star.Param[net.Listener]{
-	Name:    "l",
-	Default: star.Ptr("127.0.0.1:6666"),
+	Name:     "l",
+	Repeated: true,
 	Parse: func(x string) (net.Listener, error) {
 		return net.Listen("tcp", x)
 	},
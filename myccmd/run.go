@@ -1,6 +1,9 @@
 package myccmd
 
 import (
+	"net"
+	"strconv"
+
 	"go.brendoncarroll.net/star"
 	"golang.org/x/sync/errgroup"
 
@@ -12,12 +15,11 @@ var run = star.Command{
 	Metadata: star.Metadata{
 		Short: "run an executable namespace in a new pod",
 	},
-	Flags: []star.IParam{DBParam, fileParam,
-		NetNodeParam, CellParam, ConsoleParam,
+	Flags: []star.IParam{DBParam, KeyParam, fileParam,
+		NetNodeParam, CellParam, ConsoleParam, WatchParam, TimerParam, MailboxParam, FSParam, AutostartParam, RecordParam,
 	},
 	F: func(c star.Context) error {
-		db := DBParam.Load(c)
-		sys := mycss.NewSystem(db)
+		sys := NewSystem(c)
 		ctx := c.Context
 		pod, err := sys.Create(ctx)
 		if err != nil {
@@ -34,14 +36,13 @@ var run = star.Command{
 
 var runPods = star.Command{
 	Metadata: star.Metadata{
-		Short: "run existing pods",
+		Short: "run existing pods, resuming any interrupted processes",
 	},
 	Pos:   []star.IParam{podIDsParam},
-	Flags: []star.IParam{DBParam},
+	Flags: []star.IParam{DBParam, KeyParam},
 	F: func(c star.Context) error {
 		// setup system
-		db := DBParam.Load(c)
-		sys := mycss.NewSystem(db)
+		sys := NewSystem(c)
 
 		var pods []*mycss.Pod
 		for _, pid := range podIDsParam.LoadAll(c) {
@@ -55,7 +56,19 @@ var runPods = star.Command{
 		for _, pod := range pods {
 			pod := pod
 			eg.Go(func() error {
-				return mycss.Main(ctx, pod)
+				procIDs, err := pod.Interrupted(ctx)
+				if err != nil {
+					return err
+				}
+				if len(procIDs) == 0 {
+					return mycss.Main(ctx, pod)
+				}
+				for _, procID := range procIDs {
+					if err := mycss.Resume(ctx, pod, procID); err != nil {
+						return err
+					}
+				}
+				return nil
 			})
 		}
 		return eg.Wait()
@@ -66,21 +79,38 @@ var serve = star.Command{
 	Metadata: star.Metadata{
 		Short: "run all the pods in this system, and serve the HTTP UI",
 	},
-	Flags: []star.IParam{DBParam, ListenerParam},
+	Flags: []star.IParam{DBParam, KeyParam, ListenerParam, maxRequestsParam},
 	F: func(c star.Context) error {
 		// setup system
-		db := DBParam.Load(c)
-		sys := mycss.NewSystem(db)
+		sys := NewSystem(c)
 		// setup listener
-		lis := ListenerParam.Load(c)
+		lis, ok := ListenerParam.LoadOpt(c)
+		if !ok {
+			var err error
+			if lis, err = net.Listen("tcp", defaultListenAddr); err != nil {
+				return err
+			}
+		}
+		srv := mychui.New(sys)
+		if n, ok := maxRequestsParam.LoadOpt(c); ok {
+			srv.SetMaxConcurrentRequests(n)
+		}
 
 		eg, ctx := errgroup.WithContext(c.Context)
 		eg.Go(func() error { return sys.Run(ctx) })
-		eg.Go(func() error { return mychui.Serve(ctx, lis, sys) })
+		eg.Go(func() error { return srv.Serve(ctx, lis) })
 		return eg.Wait()
 	},
 }
 
+// maxRequestsParam is the number of HTTP requests each pod can handle at once.
+// If it is not given, the limit is mychui.DefaultMaxConcurrentRequests.
+var maxRequestsParam = star.Param[int]{
+	Name:     "max-requests",
+	Repeated: true,
+	Parse:    strconv.Atoi,
+}
+
 var podIDsParam = star.Param[mycss.PodID]{
 	Name:     "pid",
 	Repeated: true,
@@ -373,6 +373,11 @@ func (eb EB) Field(x *Expr, i int) *Expr {
 	return newExpr(spec.Field, x, eb.B32(uint32(i)))
 }
 
+// MakeSum creates a value of the SumType ty, from x, which must be of the type of the variant tag.
+func (eb EB) MakeSum(ty *Expr, tag int, x *Expr) *Expr {
+	return newExpr(spec.MakeSum, ty, eb.B32(uint32(tag)), x)
+}
+
 func (EB) Slot(x *Expr, idx *Expr) *Expr {
 	return newExpr(spec.Slot, x, idx)
 }
@@ -130,10 +130,34 @@ func (e *AnyProg) String() string {
 // - ValidateBody(_, 0) is called by NewLazy
 // - ValidateBody(_, 1) is called by NewLambda
 func ValidateBody(body *AnyProg, level uint32, hasSelf bool) error {
-	return validateBody(body.prog, level, hasSelf)
+	v := bodyValidator{done: make(map[validateKey]struct{})}
+	return v.validate(body.prog, level, hasSelf)
 }
 
-func validateBody(body Prog, level uint32, hasSelf bool) error {
+// bodyValidator remembers which nodes have been validated, so that shared nodes are only checked once per context.
+type bodyValidator struct {
+	done map[validateKey]struct{}
+}
+
+type validateKey struct {
+	pos     int
+	level   uint32
+	hasSelf bool
+}
+
+func (v *bodyValidator) validate(body Prog, level uint32, hasSelf bool) error {
+	k := validateKey{pos: len(body) - 1, level: level, hasSelf: hasSelf}
+	if _, exists := v.done[k]; exists {
+		return nil
+	}
+	if err := v.validateNode(body, level, hasSelf); err != nil {
+		return err
+	}
+	v.done[k] = struct{}{}
+	return nil
+}
+
+func (v *bodyValidator) validateNode(body Prog, level uint32, hasSelf bool) error {
 	node := body.Root()
 	switch {
 	case node.IsLiteral():
@@ -149,26 +173,26 @@ func validateBody(body Prog, level uint32, hasSelf bool) error {
 		}
 		return nil
 	case node.IsCode(spec.Lambda):
-		if err := validateBody(body.Input(0), level, true); err != nil {
+		if err := v.validate(body.Input(0), level, true); err != nil {
 			return err
 		}
-		if err := validateBody(body.Input(1), level, hasSelf); err != nil {
+		if err := v.validate(body.Input(1), level, hasSelf); err != nil {
 			return err
 		}
-		if err := validateBody(body.Input(2), level+1, hasSelf); err != nil {
+		if err := v.validate(body.Input(2), level+1, hasSelf); err != nil {
 			return err
 		}
 		return nil
 	case node.IsCode(spec.Let):
-		if err := validateBody(body.Input(0), level, hasSelf); err != nil {
+		if err := v.validate(body.Input(0), level, hasSelf); err != nil {
 			return err
 		}
-		return validateBody(body.Input(1), level+1, hasSelf)
+		return v.validate(body.Input(1), level+1, hasSelf)
 	case node.IsCode(spec.Fractal):
-		return validateBody(body.Input(0), level, true)
+		return v.validate(body.Input(0), level, true)
 	default:
 		for arg := range body.Inputs() {
-			if err := validateBody(arg, level, hasSelf); err != nil {
+			if err := v.validate(arg, level, hasSelf); err != nil {
 				return err
 			}
 		}
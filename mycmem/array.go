@@ -58,6 +58,9 @@ func (t *ArrayType) SizeOf() int {
 
 func (at *ArrayType) Zero() Value {
 	elem := at.elemAT.Unwrap()
+	if reflect.DeepEqual(elem, ByteType()) {
+		return ByteArray{d: make([]byte, at.Len())}
+	}
 	var vals []Value
 	for i := 0; i < at.Len(); i++ {
 		vals = append(vals, elem.Zero())
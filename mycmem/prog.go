@@ -563,73 +563,82 @@ type ProgCtx struct {
 
 // Closure closes over x and appends the new Prog to out
 // Any Params less than level will not be bound, and >= level will be replaced using pctx.
+// Parts of x which have nothing to bind are copied unchanged, including any nodes they share,
+// so closing over a Prog does not change the encoding, or the Fingerprint, of the Lambdas nested in it.
 func Closure(out Prog, pctx ProgCtx, level uint32, bindSelf bool, x Prog) (Prog, error) {
-	appendOp := func(out Prog, op spec.Op, idxs ...int) Prog {
-		offsets := slices2.Map(idxs, func(absPos int) uint32 {
-			return uint32(len(out) - absPos)
-		})
-		return append(out, OpNode(op, offsets...))
+	c := closer{pctx: pctx, memo: make(map[closerKey]bool)}
+	return c.closure(out, level, bindSelf, x)
+}
+
+type closerKey struct {
+	// pos is the position of the node in the input
+	pos      int
+	level    uint32
+	bindSelf bool
+}
+
+type closer struct {
+	pctx ProgCtx
+	// memo records whether a node in the input needs closing, in each context it is visited.
+	memo map[closerKey]bool
+}
+
+func (c *closer) closure(out Prog, level uint32, bindSelf bool, x Prog) (Prog, error) {
+	if !c.needsClosure(x, level, bindSelf) {
+		return copyProg(out, x, make(map[int]int)), nil
 	}
 	node := x.Root()
 	switch {
-	case node.IsLiteral():
-		return append(out, node), nil
 	case node.IsSelf():
-		if !bindSelf {
-			return append(out, node), nil
-		}
-		self, err := pctx.Self()
+		self, err := c.pctx.Self()
 		if err != nil {
 			return nil, err
 		}
 		return append(out, self), nil
 	case node.IsParam():
-		if node.Param() < level {
-			return append(out, node), nil
-		}
 		// TODO: double-check the math for this index
-		val, err := pctx.Lookup(node.Param() - level + 1)
+		val, err := c.pctx.Lookup(node.Param() - level + 1)
 		if err != nil {
 			return nil, err
 		}
 		return append(out, val), nil
 	case node.IsCode(spec.Let):
-		out, err := Closure(out, pctx, level, bindSelf, x.Input(0))
+		out, err := c.closure(out, level, bindSelf, x.Input(0))
 		if err != nil {
 			return nil, err
 		}
 		valueIdx := len(out) - 1
-		out, err = Closure(out, pctx, level+1, bindSelf, x.Input(1))
+		out, err = c.closure(out, level+1, bindSelf, x.Input(1))
 		if err != nil {
 			return nil, err
 		}
 		bodyIdx := len(out) - 1
 		return appendOp(out, spec.Let, valueIdx, bodyIdx), nil
 	case node.IsCode(spec.Lambda):
-		out, err := Closure(out, pctx, level, bindSelf, x.Input(0))
+		out, err := c.closure(out, level, bindSelf, x.Input(0))
 		if err != nil {
 			return nil, err
 		}
 		inTypeIdx := len(out) - 1
-		out, err = Closure(out, pctx, level, bindSelf, x.Input(1))
+		out, err = c.closure(out, level, bindSelf, x.Input(1))
 		if err != nil {
 			return nil, err
 		}
 		outTypeIdx := len(out) - 1
-		out, err = Closure(out, pctx, level+1, false, x.Input(2))
+		out, err = c.closure(out, level+1, false, x.Input(2))
 		if err != nil {
 			return nil, err
 		}
 		bodyIdx := len(out) - 1
 		return appendOp(out, spec.Lambda, inTypeIdx, outTypeIdx, bodyIdx), nil
 	case node.IsCode(spec.Lazy):
-		out, err := Closure(out, pctx, level, bindSelf, x.Input(0))
+		out, err := c.closure(out, level, bindSelf, x.Input(0))
 		if err != nil {
 			return nil, err
 		}
 		return appendOp(out, spec.Lazy, len(out)-1), nil
 	case node.IsCode(spec.Fractal):
-		out, err := Closure(out, pctx, level, false, x.Input(0))
+		out, err := c.closure(out, level, false, x.Input(0))
 		if err != nil {
 			return nil, err
 		}
@@ -638,7 +647,7 @@ func Closure(out Prog, pctx ProgCtx, level uint32, bindSelf bool, x Prog) (Prog,
 		var idxs []int
 		for i := 0; i < x.NumInputs(); i++ {
 			var err error
-			out, err = Closure(out, pctx, level, bindSelf, x.Input(i))
+			out, err = c.closure(out, level, bindSelf, x.Input(i))
 			if err != nil {
 				return nil, err
 			}
@@ -648,6 +657,69 @@ func Closure(out Prog, pctx ProgCtx, level uint32, bindSelf bool, x Prog) (Prog,
 	}
 }
 
+// needsClosure returns true if x contains a Param >= level, or a Self when bindSelf is true.
+func (c *closer) needsClosure(x Prog, level uint32, bindSelf bool) bool {
+	k := closerKey{pos: len(x) - 1, level: level, bindSelf: bindSelf}
+	if yes, exists := c.memo[k]; exists {
+		return yes
+	}
+	var yes bool
+	node := x.Root()
+	switch {
+	case node.IsLiteral():
+	case node.IsSelf():
+		yes = bindSelf
+	case node.IsParam():
+		yes = node.Param() >= level
+	case node.IsCode(spec.Let):
+		yes = c.needsClosure(x.Input(0), level, bindSelf) ||
+			c.needsClosure(x.Input(1), level+1, bindSelf)
+	case node.IsCode(spec.Lambda):
+		yes = c.needsClosure(x.Input(0), level, bindSelf) ||
+			c.needsClosure(x.Input(1), level, bindSelf) ||
+			c.needsClosure(x.Input(2), level+1, false)
+	case node.IsCode(spec.Fractal):
+		yes = c.needsClosure(x.Input(0), level, false)
+	default:
+		for i := 0; i < x.NumInputs(); i++ {
+			if c.needsClosure(x.Input(i), level, bindSelf) {
+				yes = true
+				break
+			}
+		}
+	}
+	c.memo[k] = yes
+	return yes
+}
+
+// copyProg appends the nodes reachable from the root of x to out, and returns out.
+// Nodes which are shared in x are also shared in out; done maps their positions in x to their positions in out.
+func copyProg(out Prog, x Prog, done map[int]int) Prog {
+	var idxs []int
+	for i := 0; i < x.NumInputs(); i++ {
+		in := x.Input(i)
+		idx, exists := done[len(in)-1]
+		if !exists {
+			out = copyProg(out, in, done)
+			idx = len(out) - 1
+			done[len(in)-1] = idx
+		}
+		idxs = append(idxs, idx)
+	}
+	if len(idxs) == 0 {
+		return append(out, x.Root())
+	}
+	return appendOp(out, x.Code(), idxs...)
+}
+
+// appendOp appends a node which takes the nodes at idxs in out as inputs.
+func appendOp(out Prog, op spec.Op, idxs ...int) Prog {
+	offsets := slices2.Map(idxs, func(absPos int) uint32 {
+		return uint32(len(out) - absPos)
+	})
+	return append(out, OpNode(op, offsets...))
+}
+
 func varintLen(x uint32) int {
 	var buf [binary.MaxVarintLen64]byte
 	return binary.PutUvarint(buf[:], uint64(x))
@@ -81,3 +81,63 @@ func TestEncodeProg(t *testing.T) {
 		})
 	}
 }
+
+func TestClosure(t *testing.T) {
+	five := Literal(NewB32(5))
+	pctx := ProgCtx{
+		Lookup: func(i uint32) (Node, error) {
+			if i != 1 {
+				return Node{}, fmt.Errorf("unexpected param %d", i)
+			}
+			return five, nil
+		},
+		Self: func() (Node, error) {
+			return Node{}, fmt.Errorf("no self")
+		},
+	}
+	type testCase struct {
+		Name string
+		I, O Prog
+	}
+	tcs := []testCase{
+		{
+			Name: "NothingToBind",
+			I:    Prog{Param(0), OpNode(spec.Equal, 1, 1)},
+			O:    Prog{Param(0), OpNode(spec.Equal, 1, 1)},
+		},
+		{
+			Name: "Bind",
+			I:    Prog{Param(1), Param(0), OpNode(spec.Equal, 2, 1)},
+			O:    Prog{five, Param(0), OpNode(spec.Equal, 2, 1)},
+		},
+		{
+			// the shared node does not need closing, so it stays shared
+			Name: "BindShared",
+			I:    Prog{Param(0), OpNode(spec.Equal, 1, 1), Param(1), OpNode(spec.Equal, 2, 1)},
+			O:    Prog{Param(0), OpNode(spec.Equal, 1, 1), five, OpNode(spec.Equal, 2, 1)},
+		},
+		{
+			// nodes which need closing are closed once for each time they are used
+			Name: "BindSharedParam",
+			I:    Prog{Param(1), OpNode(spec.Equal, 1, 1)},
+			O:    Prog{five, five, OpNode(spec.Equal, 2, 1)},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			out, err := Closure(nil, pctx, 1, false, tc.I)
+			require.NoError(t, err)
+			require.Equal(t, tc.O, out)
+		})
+	}
+}
+
+func TestValidateBodyShared(t *testing.T) {
+	// each node uses the one before it twice, so there are 2^40 paths through the body.
+	prog := Prog{Param(0)}
+	for i := 0; i < 40; i++ {
+		prog = append(prog, OpNode(spec.Equal, 1, 1))
+	}
+	require.NoError(t, ValidateBody(NewAnyProg(prog), 1, false))
+	require.Error(t, ValidateBody(NewAnyProg(prog), 0, false))
+}
@@ -121,6 +121,15 @@ func (r *Ref) ElemType() Type {
 	return r.elem
 }
 
+// Salt returns the salt that the Value r refers to is stored with.
+func (r *Ref) Salt() *cadata.ID {
+	ty := r.elem
+	if ft, ok := ty.(*FractalType); ok {
+		ty = ft.Expanded()
+	}
+	return saltForValueOfType(ty)
+}
+
 func (r *Ref) retype(ty Type) *Ref {
 	r2 := *r
 	r2.elem = ty
@@ -174,8 +183,9 @@ func Base64Encoding() *base64.Encoding {
 	return base64.NewEncoding(cadata.Base64Alphabet).WithPadding(base64.NoPadding)
 }
 
-// listRefs returns all the references contained in Value.
-func forEachRef(v Value, fn func(ref *Ref) error) error {
+// ForEachRef calls fn for each Ref contained in v.
+// Refs are not followed, so only the Refs which can be reached without loading anything are visited.
+func ForEachRef(v Value, fn func(ref *Ref) error) error {
 	worklist := []Value{v}
 	for len(worklist) > 0 {
 		l := len(worklist)
@@ -215,7 +225,7 @@ func Post(ctx context.Context, s cadata.PostExister, v Value) (Ref, error) {
 	}
 
 	// check that all the deps exist.
-	if err := forEachRef(v, func(ref *Ref) error {
+	if err := ForEachRef(v, func(ref *Ref) error {
 		if exists, err := s.Exists(ctx, &ref.cid); err != nil {
 			return err
 		} else if !exists && !reflect.DeepEqual(v, ref) {
@@ -247,14 +257,44 @@ func Post(ctx context.Context, s cadata.PostExister, v Value) (Ref, error) {
 
 // Load loads a Mycelium value from storage.
 func Load(ctx context.Context, src cadata.Getter, ref Ref) (Value, error) {
+	l := loader{ctx: ctx, src: src}
+	return l.load(ref)
+}
+
+// loader loads values from a store.
+// Bodies often refer to the same Progs and Lambdas many times, so those are only decoded once.
+type loader struct {
+	ctx   context.Context
+	src   cadata.Getter
+	cache map[loadKey]Value
+}
+
+type loadKey struct {
+	cid  cadata.ID
+	size int
+}
+
+func (l *loader) load(ref Ref) (Value, error) {
 	ty := ref.ElemType()
 	if tt, ok := ty.(*FractalType); ok {
 		ty = tt.expanded
 	}
+	var cacheable bool
+	switch ty.(type) {
+	case *ProgType, *LambdaType:
+		// Lambdas are salted with their type, and Progs are only distinguished by their size.
+		cacheable = true
+	}
+	key := loadKey{cid: ref.cid, size: ty.SizeOf()}
+	if cacheable {
+		if val, exists := l.cache[key]; exists {
+			return val, nil
+		}
+	}
 	salt := saltForValueOfType(ty)
 	buf := acquireBuffer()
 	defer releaseBuffer(buf)
-	n, err := src.Get(ctx, &ref.cid, salt, buf[:])
+	n, err := l.src.Get(l.ctx, &ref.cid, salt, buf[:])
 	if err != nil {
 		return nil, err
 	}
@@ -263,13 +303,16 @@ func Load(ctx context.Context, src cadata.Getter, ref Ref) (Value, error) {
 	}
 	data := buf[:n]
 	bb := bitbuf.FromBytes(data).Slice(0, ty.SizeOf())
-	load := func(ref Ref) (Value, error) {
-		return Load(ctx, src, ref)
-	}
 	val := ty.Zero()
-	if err := val.Decode(bb, load); err != nil {
+	if err := val.Decode(bb, l.load); err != nil {
 		return nil, err
 	}
+	if cacheable {
+		if l.cache == nil {
+			l.cache = make(map[loadKey]Value)
+		}
+		l.cache[key] = val
+	}
 	return val, nil
 }
 
@@ -1,6 +1,7 @@
 package mycmem_test
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"testing"
@@ -10,6 +11,7 @@ import (
 
 	"myceliumweb.org/mycelium/internal/cadata"
 	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/mycexpr"
 	myc "myceliumweb.org/mycelium/mycmem"
 	"myceliumweb.org/mycelium/myctests"
 )
@@ -85,6 +87,48 @@ func TestPostLoad(t *testing.T) {
 	}
 }
 
+func TestLoadShared(t *testing.T) {
+	t.Parallel()
+	ctx := testutil.Context(t)
+	s := testutil.NewStore(t)
+	// each Lambda refers to the one before it twice.
+	const depth = 12
+	lam, err := mycexpr.BuildLambda(myc.ProductType{}, myc.B32Type(), func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.B32(1)
+	})
+	require.NoError(t, err)
+	for i := 0; i < depth; i++ {
+		prev := lam
+		lam, err = mycexpr.BuildLambda(myc.ProductType{}, myc.B32Type(), func(eb mycexpr.EB) *mycexpr.Expr {
+			return eb.Field(eb.Product(
+				eb.Apply(eb.Lit(prev), eb.Product()),
+				eb.Apply(eb.Lit(prev), eb.Product()),
+			), 0)
+		})
+		require.NoError(t, err)
+	}
+	ref, err := myc.Post(ctx, s, lam)
+	require.NoError(t, err)
+	require.NoError(t, lam.PullInto(ctx, s, s))
+
+	cs := &countingGetter{Getter: s}
+	actual, err := myc.Load(ctx, cs, ref)
+	require.NoError(t, err)
+	require.True(t, myc.Equal(lam, actual))
+	// shared Lambdas are only loaded once, rather than once for every path to them.
+	require.Less(t, cs.n, 20*depth)
+}
+
+type countingGetter struct {
+	cadata.Getter
+	n int
+}
+
+func (cg *countingGetter) Get(ctx context.Context, id *cadata.ID, salt *cadata.ID, buf []byte) (int, error) {
+	cg.n++
+	return cg.Getter.Get(ctx, id, salt, buf)
+}
+
 func TestPull(t *testing.T) {
 	t.Parallel()
 	src := testutil.NewStore(t)
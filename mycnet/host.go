@@ -5,13 +5,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"time"
 
 	"myceliumweb.org/mycelium"
 	"myceliumweb.org/mycelium/internal/cadata"
 	"myceliumweb.org/mycelium/internal/stores"
 	"myceliumweb.org/mycelium/mycbytes"
-	myc "myceliumweb.org/mycelium/mycmem"
 )
 
 // Host is a Client and Server
@@ -57,24 +55,26 @@ func (h *Host[T]) LocalAddr() Addr[T] {
 }
 
 func (h *Host[T]) TellAnyVal(ctx context.Context, dst Addr[T], msg Artifact) error {
-	cleanup := h.repo.Pin(msg)
-	time.AfterFunc(time.Minute, cleanup)
+	// the release is dropped, dst has until the pin expires to pull the message.
+	if _, err := h.repo.Pin(ctx, dst.Peer.ID(), msg, DefaultPinTTL); err != nil {
+		return err
+	}
 	return h.client.tellAnyVal(ctx, dst, msg.Root)
 }
 
 func (h *Host[T]) AskAnyVal(ctx context.Context, dst Addr[T], req Artifact) (*Artifact, error) {
-	cleanupReq := h.repo.Pin(req)
-	defer cleanupReq()
+	release, err := h.repo.Pin(ctx, dst.Peer.ID(), req, DefaultPinTTL)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	resp, err := h.client.askAnyVal(ctx, dst, req.Root)
 	if err != nil {
 		return nil, err
 	}
 	return &Artifact{
-		Store: &remoteStore[T]{
-			raddr: dst,
-			tp:    h.tp,
-		},
-		Root: *resp,
+		Store: newRemoteStore(h.tp, dst),
+		Root:  *resp,
 	}, nil
 }
 
@@ -104,6 +104,40 @@ func (c client[T]) blobPull(ctx context.Context, dst Addr[T], id *cadata.ID, sal
 	return copy(buf[:], resp.Body()), nil
 }
 
+// blobWant pulls the blobs for ids, calling fn for each one which the remote has, in order.
+// ids are requested in batches, and any which were not included in a response are asked for again.
+func (c client[T]) blobWant(ctx context.Context, dst Addr[T], ids []cadata.ID, fn func(id cadata.ID, data []byte) error) error {
+	var req, resp Message
+	for len(ids) > 0 {
+		n := req.SetBlobWant(ids)
+		if err := c.tp.Ask(ctx, dst, &req, &resp); err != nil {
+			return err
+		}
+		if resp.Type() != MT_BLOB_BATCH {
+			return fmt.Errorf("response to blob want must be blob batch. HAVE: %v", resp.Type())
+		}
+		var served int
+		if err := resp.ForEachBlob(func(id cadata.ID, data []byte) error {
+			if served >= n || id != ids[served] {
+				return fmt.Errorf("blob batch contains unexpected ID %v", id)
+			}
+			served++
+			if data == nil {
+				return nil
+			}
+			return fn(id, data)
+		}); err != nil {
+			return err
+		}
+		if served == 0 {
+			// the first blob was too large to fit in a batch, and can only be pulled on its own.
+			served = 1
+		}
+		ids = ids[served:]
+	}
+	return nil
+}
+
 func (c client[T]) tellAnyVal(ctx context.Context, dst Addr[T], av mycbytes.AnyValue) error {
 	var msg Message
 	msg.SetAnyValTell(av[:])
@@ -124,7 +158,7 @@ func (c client[T]) askAnyVal(ctx context.Context, dst Addr[T], av mycbytes.AnyVa
 }
 
 func (c client[T]) RemoteStore(raddr Addr[T]) cadata.Getter {
-	return &remoteStore[T]{raddr: raddr, tp: c.tp}
+	return newRemoteStore(c.tp, raddr)
 }
 
 type server[T comparable] struct {
@@ -146,7 +180,7 @@ func (s *server[T]) Handle(ctx context.Context, from Addr[T], req, res *Message)
 func (s *server[T]) handleTell(ctx context.Context, from Addr[T], req *Message) error {
 	switch req.Type() {
 	case MT_ANYVAL_TELL:
-		af, err := newArtifact(req.Body(), &remoteStore[T]{tp: s.tp, raddr: from})
+		af, err := newArtifact(req.Body(), newRemoteStore(s.tp, from))
 		if err != nil {
 			return err
 		}
@@ -174,8 +208,14 @@ func (s *server[T]) handleAsk(ctx context.Context, from Addr[T], req, resp *Mess
 		}
 		resp.SetLen(n)
 		return nil
+	case MT_BLOB_WANT:
+		ids, err := req.AsIDs()
+		if err != nil {
+			return err
+		}
+		return resp.setBlobBatch(ctx, s.repo.Open(from.Peer.ID()), ids)
 	case MT_ANYVAL_ASK:
-		rs := &remoteStore[T]{tp: s.tp, raddr: from}
+		rs := newRemoteStore(s.tp, from)
 		rv, err := newArtifact(req.Body(), rs)
 		if err != nil {
 			return err
@@ -184,8 +224,9 @@ func (s *server[T]) handleAsk(ctx context.Context, from Addr[T], req, resp *Mess
 		if err != nil {
 			return err
 		}
-		cleanup := s.repo.Pin(*reply)
-		time.AfterFunc(time.Minute, cleanup)
+		if _, err := s.repo.Pin(ctx, from.Peer.ID(), *reply, DefaultPinTTL); err != nil {
+			return err
+		}
 		resp.SetAnyValReply(reply.Root[:])
 		return nil
 	default:
@@ -193,15 +234,37 @@ func (s *server[T]) handleAsk(ctx context.Context, from Addr[T], req, resp *Mess
 	}
 }
 
-// RemoteStore implements a cadata.Getter using the BlobPull/Push protocol messages.
+// remoteStore implements a cadata.Getter using the BlobPull/Push protocol messages.
+// Blobs are cached once they have been pulled or prefetched, so each is only transferred once.
 type remoteStore[T comparable] struct {
 	tp    Transport[T]
 	raddr Addr[T]
+	cache *stores.Mem
+}
+
+func newRemoteStore[T comparable](tp Transport[T], raddr Addr[T]) *remoteStore[T] {
+	return &remoteStore[T]{
+		tp:    tp,
+		raddr: raddr,
+		cache: stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes),
+	}
 }
 
 func (s *remoteStore[T]) Get(ctx context.Context, id *cadata.ID, salt *cadata.ID, buf []byte) (int, error) {
+	if n, err := s.cache.Get(ctx, id, salt, buf); err == nil {
+		return n, nil
+	} else if !errors.As(err, &cadata.ErrNotFound{}) {
+		return 0, err
+	}
 	c := client[T]{tp: s.tp}
-	return c.blobPull(ctx, s.raddr, id, salt, buf)
+	n, err := c.blobPull(ctx, s.raddr, id, salt, buf)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.cache.Post(ctx, salt, buf[:n]); err != nil {
+		return 0, err
+	}
+	return n, nil
 }
 
 func (s *remoteStore[T]) Hash(salt *cadata.ID, x []byte) cadata.ID {
@@ -211,30 +274,3 @@ func (s *remoteStore[T]) Hash(salt *cadata.ID, x []byte) cadata.ID {
 func (s *remoteStore[T]) MaxSize() int {
 	return mycelium.MaxSizeBytes
 }
-
-// repo stores artifacts
-type repo struct {
-	s cadata.Store
-}
-
-func newRepo() *repo {
-	return &repo{
-		s: stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes),
-	}
-}
-
-func (r *repo) Pin(x Artifact) func() {
-	ctx := context.Background()
-	av, err := myc.LoadRoot(ctx, x.Store, x.Root[:])
-	if err != nil {
-		panic(err)
-	}
-	if err := av.PullInto(ctx, r.s, x.Store); err != nil {
-		panic(err)
-	}
-	return func() {}
-}
-
-func (r *repo) Open(peer PeerID) cadata.Getter {
-	return r.s
-}
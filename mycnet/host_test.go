@@ -0,0 +1,129 @@
+package mycnet
+
+import (
+	"context"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cloudflare/circl/sign/ed25519"
+	"github.com/stretchr/testify/require"
+	"go.brendoncarroll.net/p2p/p2ptest"
+
+	"myceliumweb.org/mycelium"
+	"myceliumweb.org/mycelium/internal/cadata"
+	"myceliumweb.org/mycelium/internal/stores"
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/mycbytes"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+func TestPullInto(t *testing.T) {
+	ctx := testutil.Context(t)
+	const n = 1000
+	af, list := newRefList(t, n)
+	h1, ct := newCountingHost(t, 1)
+	h2 := newHost(t, 2, nil, func(QUICAddr, Artifact) (*Artifact, error) {
+		return &af, nil
+	})
+
+	resp, err := h1.AskAnyVal(ctx, h2.LocalAddr(), ArtifactFromMemory(myc.NewAnyValue(myc.NewB32(0))))
+	require.NoError(t, err)
+	before := ct.asks.Load()
+	dst := stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes)
+	av, err := resp.PullInto(ctx, dst, Limits{})
+	require.NoError(t, err)
+	require.Equal(t, list, av.Unwrap())
+	// pulling one blob at a time would take more than n asks.
+	require.Less(t, ct.asks.Load()-before, int64(n/10))
+
+	// everything must be in dst.
+	for i := 0; i < n; i++ {
+		x, err := myc.Load(ctx, dst, *list.Get(i).(*myc.Ref))
+		require.NoError(t, err)
+		require.Equal(t, myc.NewB64(uint64(i)), x)
+	}
+}
+
+func BenchmarkPullList(b *testing.B) {
+	const n = 1000
+	af, _ := newRefList(b, n)
+	h1, ct := newCountingHost(b, 1)
+	h2 := newHost(b, 2, nil, func(QUICAddr, Artifact) (*Artifact, error) {
+		return &af, nil
+	})
+	req := ArtifactFromMemory(myc.NewAnyValue(myc.NewB32(0)))
+
+	b.Run("Pull", func(b *testing.B) {
+		benchPull(b, ct, func(ctx context.Context, dst cadata.PostExister) error {
+			resp, err := h1.AskAnyVal(ctx, h2.LocalAddr(), req)
+			if err != nil {
+				return err
+			}
+			av, err := myc.LoadRoot(ctx, resp.Store, resp.Root[:])
+			if err != nil {
+				return err
+			}
+			return av.PullInto(ctx, dst, resp.Store)
+		})
+	})
+	b.Run("Want", func(b *testing.B) {
+		benchPull(b, ct, func(ctx context.Context, dst cadata.PostExister) error {
+			resp, err := h1.AskAnyVal(ctx, h2.LocalAddr(), req)
+			if err != nil {
+				return err
+			}
+			_, err = resp.PullInto(ctx, dst, Limits{})
+			return err
+		})
+	})
+}
+
+func benchPull(b *testing.B, ct *countingTransport[netip.AddrPort], fn func(ctx context.Context, dst cadata.PostExister) error) {
+	ctx := testutil.Context(b)
+	before := ct.asks.Load()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dst := stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes)
+		if err := fn(ctx, dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(ct.asks.Load()-before)/float64(b.N), "asks/op")
+}
+
+// newRefList returns an Artifact containing a List of n Refs, each to a different B64.
+func newRefList(t testing.TB, n int) (Artifact, *myc.List) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes)
+	refs := make([]myc.Value, n)
+	for i := range refs {
+		ref, err := myc.Post(ctx, s, myc.NewB64(uint64(i)))
+		require.NoError(t, err)
+		refs[i] = &ref
+	}
+	list := myc.NewList(myc.NewRefType(myc.B64Type()), refs...)
+	av := myc.NewAnyValue(list)
+	require.NoError(t, av.PullInto(ctx, s, s))
+	return Artifact{
+		Root:  mycbytes.AnyValue(myc.MarshalAppend(nil, av)),
+		Store: s,
+	}, list
+}
+
+func newCountingHost(t testing.TB, i int) (*Host[netip.AddrPort], *countingTransport[netip.AddrPort]) {
+	priv := ed25519.PrivateKey(p2ptest.NewTestKey(t, i))
+	ct := &countingTransport[netip.AddrPort]{Transport: NewQUIC(priv, testutil.NewPacketConn(t))}
+	return newHostOn(t, ct, nil, nil), ct
+}
+
+// countingTransport counts the asks made through a Transport.
+type countingTransport[T comparable] struct {
+	Transport[T]
+	asks atomic.Int64
+}
+
+func (ct *countingTransport[T]) Ask(ctx context.Context, raddr Addr[T], req, res *Message) error {
+	ct.asks.Add(1)
+	return ct.Transport.Ask(ctx, raddr, req, res)
+}
@@ -0,0 +1,175 @@
+package mycnet
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"myceliumweb.org/mycelium/internal/cadata"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+// Limits are constraints on the Values received from other peers.
+// The zero value for any field means that there is no limit.
+type Limits struct {
+	// MaxDepth is the maximum number of Refs which can be followed from the root.
+	// This includes the Refs used internally by Lists and types.
+	MaxDepth int `json:",omitempty"`
+	// MaxBlobs is the maximum number of distinct blobs which can be reachable from the root.
+	MaxBlobs int `json:",omitempty"`
+	// MaxBits is the maximum total size of the blobs reachable from the root.
+	MaxBits int `json:",omitempty"`
+}
+
+// DefaultLimits are reasonable limits for Values received from untrusted peers.
+var DefaultLimits = Limits{
+	MaxDepth: 1 << 8,
+	MaxBlobs: 1 << 16,
+	MaxBits:  1 << 30,
+}
+
+// Limit identifies one of the fields in Limits
+type Limit uint8
+
+const (
+	LimitDepth Limit = iota + 1
+	LimitBlobs
+	LimitBits
+)
+
+func (l Limit) String() string {
+	switch l {
+	case LimitDepth:
+		return "depth"
+	case LimitBlobs:
+		return "blobs"
+	case LimitBits:
+		return "bits"
+	default:
+		return fmt.Sprintf("Limit(%d)", uint8(l))
+	}
+}
+
+// ErrLimitExceeded is returned when a Value from another peer exceeds its Limits.
+type ErrLimitExceeded struct {
+	Limit Limit
+}
+
+func (e ErrLimitExceeded) Error() string {
+	return fmt.Sprintf("mycnet: value exceeds limit on %v", e.Limit)
+}
+
+// traverse loads every Value reachable from the AnyValue root, one level of Refs at a time,
+// and returns ErrLimitExceeded as soon as it is clear that they exceed lim.
+// If s can fetch blobs in batches, all of the blobs in a level are fetched at once,
+// so the number of round trips depends on the depth of the value, rather than the number of blobs.
+func traverse(ctx context.Context, s cadata.Getter, root []byte, lim Limits) error {
+	b := &budget{lim: lim, seen: make(map[cadata.ID]struct{})}
+	bs := &budgetStore{Getter: s, b: b}
+	bf, _ := s.(batchFetcher)
+	av, err := myc.LoadRoot(ctx, bs, root)
+	if err != nil {
+		return err
+	}
+	seen := make(map[cadata.ID]struct{})
+	level := newRefs(av, seen)
+	for depth := 1; len(level) > 0; depth++ {
+		if lim.MaxDepth > 0 && depth > lim.MaxDepth {
+			return ErrLimitExceeded{Limit: LimitDepth}
+		}
+		if err := b.reserve(level); err != nil {
+			return err
+		}
+		if bf != nil {
+			if err := bf.fetchAll(ctx, level, b); err != nil {
+				return err
+			}
+		}
+		var next []*myc.Ref
+		for _, ref := range level {
+			val, err := myc.Load(ctx, bs, *ref)
+			if err != nil {
+				return err
+			}
+			next = append(next, newRefs(val, seen)...)
+		}
+		level = next
+	}
+	return nil
+}
+
+// newRefs returns the Refs in x which are not in seen, and adds them to seen.
+func newRefs(x myc.Value, seen map[cadata.ID]struct{}) (ret []*myc.Ref) {
+	myc.ForEachRef(x, func(ref *myc.Ref) error {
+		id := cadata.ID(ref.Data())
+		if _, exists := seen[id]; !exists {
+			seen[id] = struct{}{}
+			ret = append(ret, ref)
+		}
+		return nil
+	})
+	return ret
+}
+
+// budget counts the distinct blobs loaded during a traversal against Limits.
+type budget struct {
+	lim Limits
+
+	mu   sync.Mutex
+	seen map[cadata.ID]struct{}
+	bits int
+}
+
+// reserve checks that loading refs would not exceed the limit on blobs, before anything is fetched.
+func (b *budget) reserve(refs []*myc.Ref) error {
+	if b.lim.MaxBlobs <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(b.seen)
+	for _, ref := range refs {
+		if _, exists := b.seen[cadata.ID(ref.Data())]; !exists {
+			n++
+		}
+	}
+	if n > b.lim.MaxBlobs {
+		return ErrLimitExceeded{Limit: LimitBlobs}
+	}
+	return nil
+}
+
+// spend counts the blob id, of n bytes, if it has not been counted already.
+func (b *budget) spend(id cadata.ID, n int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, exists := b.seen[id]; exists {
+		return nil
+	}
+	b.seen[id] = struct{}{}
+	b.bits += n * 8
+	switch {
+	case b.lim.MaxBlobs > 0 && len(b.seen) > b.lim.MaxBlobs:
+		return ErrLimitExceeded{Limit: LimitBlobs}
+	case b.lim.MaxBits > 0 && b.bits > b.lim.MaxBits:
+		return ErrLimitExceeded{Limit: LimitBits}
+	}
+	return nil
+}
+
+// budgetStore counts every blob it gets against a budget.
+type budgetStore struct {
+	cadata.Getter
+	b *budget
+}
+
+func (s *budgetStore) Get(ctx context.Context, id *cadata.ID, salt *cadata.ID, buf []byte) (int, error) {
+	n, err := s.Getter.Get(ctx, id, salt, buf)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.b.spend(*id, n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
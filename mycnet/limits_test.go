@@ -0,0 +1,75 @@
+package mycnet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium"
+	"myceliumweb.org/mycelium/internal/stores"
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/mycbytes"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+func TestSlurpLimits(t *testing.T) {
+	ctx := testutil.Context(t)
+	list, _ := newRefList(t, 100)
+	chain := newRefChain(t, 10)
+
+	tcs := []struct {
+		Artifact Artifact
+		Limits   Limits
+		Err      error
+	}{
+		{Artifact: list, Limits: Limits{}},
+		{Artifact: list, Limits: DefaultLimits},
+		{Artifact: list, Limits: Limits{MaxBlobs: 50}, Err: ErrLimitExceeded{Limit: LimitBlobs}},
+		{Artifact: list, Limits: Limits{MaxBits: 64 * 50}, Err: ErrLimitExceeded{Limit: LimitBits}},
+		{Artifact: chain, Limits: Limits{MaxDepth: 20}},
+		{Artifact: chain, Limits: Limits{MaxDepth: 5}, Err: ErrLimitExceeded{Limit: LimitDepth}},
+	}
+	for i, tc := range tcs {
+		_, err := tc.Artifact.Slurp(ctx, tc.Limits)
+		if tc.Err != nil {
+			require.ErrorIs(t, err, tc.Err, "case %d", i)
+		} else {
+			require.NoError(t, err, "case %d", i)
+		}
+	}
+}
+
+func TestPullIntoLimits(t *testing.T) {
+	ctx := testutil.Context(t)
+	n := NewMemNet()
+	af, _ := newRefList(t, 100)
+	h1 := newMemHost(t, n, 1, nil, nil)
+	h2 := newMemHost(t, n, 2, nil, func(MemAddr, Artifact) (*Artifact, error) {
+		return &af, nil
+	})
+
+	resp, err := h1.AskAnyVal(ctx, h2.LocalAddr(), ArtifactFromMemory(myc.NewAnyValue(myc.NewB32(0))))
+	require.NoError(t, err)
+	dst := stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes)
+	_, err = resp.PullInto(ctx, dst, Limits{MaxBlobs: 10})
+	require.ErrorIs(t, err, ErrLimitExceeded{Limit: LimitBlobs})
+	require.Equal(t, 0, dst.Len())
+}
+
+// newRefChain returns an Artifact containing a Ref to a Ref..., n deep.
+func newRefChain(t testing.TB, n int) Artifact {
+	ctx := testutil.Context(t)
+	s := stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes)
+	var x myc.Value = myc.NewB64(0)
+	for i := 0; i < n; i++ {
+		ref, err := myc.Post(ctx, s, x)
+		require.NoError(t, err)
+		x = &ref
+	}
+	av := myc.NewAnyValue(x)
+	require.NoError(t, av.PullInto(ctx, s, s))
+	return Artifact{
+		Root:  mycbytes.AnyValue(myc.MarshalAppend(nil, av)),
+		Store: s,
+	}
+}
@@ -0,0 +1,176 @@
+package mycnet
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cloudflare/circl/sign"
+	"go.brendoncarroll.net/stdctx/logctx"
+	"go.uber.org/zap"
+
+	"myceliumweb.org/mycelium/mycnet/mycpki"
+)
+
+type MemAddr = Addr[string]
+
+// MemNet is an in-process network of MemTransports, which are addressed by name.
+type MemNet struct {
+	mu    sync.RWMutex
+	nodes map[string]*MemTransport
+}
+
+func NewMemNet() *MemNet {
+	return &MemNet{nodes: make(map[string]*MemTransport)}
+}
+
+// NewTransport adds a Transport called name to the network.
+// Its Peer is derived from privateKey.
+func (n *MemNet) NewTransport(name string, privateKey sign.PrivateKey) *MemTransport {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, exists := n.nodes[name]; exists {
+		panic(fmt.Sprintf("memnet: %q is already in use", name))
+	}
+	mt := &MemTransport{
+		n:          n,
+		privateKey: privateKey,
+		laddr: MemAddr{
+			Peer:     NewPeer(mycpki.VerifierFromPublicKey(privateKey.Public().(sign.PublicKey))),
+			Location: name,
+		},
+		ready: make(chan struct{}),
+	}
+	n.nodes[name] = mt
+	return mt
+}
+
+// lookup returns the transport at raddr, checking that it belongs to the expected peer.
+func (n *MemNet) lookup(raddr MemAddr) (*MemTransport, error) {
+	n.mu.RLock()
+	mt := n.nodes[raddr.Location]
+	n.mu.RUnlock()
+	if mt == nil {
+		return nil, fmt.Errorf("memnet: no transport at %q", raddr.Location)
+	}
+	if mt.laddr.Peer.ID() != raddr.Peer.ID() {
+		return nil, fmt.Errorf("memnet: wrong peer at %q", raddr.Location)
+	}
+	return mt, nil
+}
+
+var _ Transport[string] = &MemTransport{}
+
+// MemTransport implements Transport on a MemNet.
+// Messages are copied between transports, and handled in their own goroutines, as they would be over a real network.
+type MemTransport struct {
+	n          *MemNet
+	privateKey sign.PrivateKey
+	laddr      MemAddr
+
+	mu    sync.Mutex
+	ctx   context.Context
+	serve MsgHandler[string]
+	// ready is closed when the transport starts being served.
+	ready chan struct{}
+}
+
+func (mt *MemTransport) PrivateKey() sign.PrivateKey {
+	return mt.privateKey
+}
+
+func (mt *MemTransport) LocalAddr() MemAddr {
+	return mt.laddr
+}
+
+// Tell delivers msg to raddr, and returns without waiting for it to be handled.
+func (mt *MemTransport) Tell(ctx context.Context, raddr MemAddr, msg *Message) error {
+	dst, err := mt.n.lookup(raddr)
+	if err != nil {
+		return err
+	}
+	sctx, h, err := dst.handler(ctx)
+	if err != nil {
+		return err
+	}
+	msg2 := cloneMessage(msg)
+	go func() {
+		if err := h(sctx, mt.laddr, msg2, nil); err != nil {
+			logctx.Error(sctx, "handling tell", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Ask delivers req to raddr, and waits for the response.
+func (mt *MemTransport) Ask(ctx context.Context, raddr MemAddr, req, resp *Message) error {
+	dst, err := mt.n.lookup(raddr)
+	if err != nil {
+		return err
+	}
+	sctx, h, err := dst.handler(ctx)
+	if err != nil {
+		return err
+	}
+	req2 := cloneMessage(req)
+	res := new(Message)
+	done := make(chan error, 1)
+	go func() {
+		done <- h(sctx, mt.laddr, req2, res)
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("memnet: handling ask: %w", err)
+		}
+	}
+	if res.Type() == MT_INVALID {
+		return fmt.Errorf("handler did not set response message type")
+	}
+	resp.copyFrom(res)
+	return nil
+}
+
+// Serve handles messages sent to the transport with fn, until ctx is cancelled.
+func (mt *MemTransport) Serve(ctx context.Context, fn MsgHandler[string]) error {
+	mt.mu.Lock()
+	if mt.serve != nil {
+		mt.mu.Unlock()
+		return fmt.Errorf("memnet: %q is already being served", mt.laddr.Location)
+	}
+	mt.ctx, mt.serve = ctx, fn
+	close(mt.ready)
+	mt.mu.Unlock()
+
+	<-ctx.Done()
+	mt.mu.Lock()
+	mt.ctx, mt.serve = nil, nil
+	mt.ready = make(chan struct{})
+	mt.mu.Unlock()
+	return ctx.Err()
+}
+
+// handler waits until the transport is being served, and returns the context and handler passed to Serve.
+func (mt *MemTransport) handler(ctx context.Context) (context.Context, MsgHandler[string], error) {
+	for {
+		mt.mu.Lock()
+		sctx, h, ready := mt.ctx, mt.serve, mt.ready
+		mt.mu.Unlock()
+		if h != nil {
+			return sctx, h, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, fmt.Errorf("memnet: waiting for %q to be served: %w", mt.laddr.Location, ctx.Err())
+		case <-ready:
+		}
+	}
+}
+
+func cloneMessage(x *Message) *Message {
+	m := new(Message)
+	m.copyFrom(x)
+	return m
+}
@@ -0,0 +1,67 @@
+package mycnet
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cloudflare/circl/sign/ed25519"
+	"github.com/stretchr/testify/require"
+	"go.brendoncarroll.net/p2p/p2ptest"
+
+	"myceliumweb.org/mycelium"
+	"myceliumweb.org/mycelium/internal/stores"
+	"myceliumweb.org/mycelium/internal/testutil"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+func TestMemNetTell(t *testing.T) {
+	ctx := testutil.Context(t)
+	n := NewMemNet()
+	inbox := make(chan Artifact, 1)
+	h1 := newMemHost(t, n, 1, nil, nil)
+	h2 := newMemHost(t, n, 2, func(from MemAddr, x Artifact) error {
+		require.Equal(t, h1.LocalAddr(), from)
+		inbox <- x
+		return nil
+	}, nil)
+
+	in := myc.NewB32(13)
+	require.NoError(t, h1.TellAnyVal(ctx, h2.LocalAddr(), ArtifactFromMemory(myc.NewAnyValue(in))))
+	x := <-inbox
+	av, err := x.Slurp(ctx, Limits{})
+	require.NoError(t, err)
+	require.Equal(t, in, av.Unwrap())
+}
+
+func TestMemNetAsk(t *testing.T) {
+	ctx := testutil.Context(t)
+	n := NewMemNet()
+	af, list := newRefList(t, 100)
+	h1 := newMemHost(t, n, 1, nil, nil)
+	h2 := newMemHost(t, n, 2, nil, func(MemAddr, Artifact) (*Artifact, error) {
+		return &af, nil
+	})
+
+	resp, err := h1.AskAnyVal(ctx, h2.LocalAddr(), ArtifactFromMemory(myc.NewAnyValue(myc.NewB32(0))))
+	require.NoError(t, err)
+	av, err := resp.PullInto(ctx, stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes), Limits{})
+	require.NoError(t, err)
+	require.Equal(t, list, av.Unwrap())
+}
+
+func TestMemNetWrongPeer(t *testing.T) {
+	ctx := testutil.Context(t)
+	n := NewMemNet()
+	h1 := newMemHost(t, n, 1, nil, nil)
+	h2 := newMemHost(t, n, 2, nil, nil)
+
+	raddr := h2.LocalAddr()
+	raddr.Peer = h1.LocalAddr().Peer
+	require.Error(t, h1.TellAnyVal(ctx, raddr, ArtifactFromMemory(myc.NewAnyValue(myc.NewB32(0)))))
+}
+
+func newMemHost(t testing.TB, n *MemNet, i int, onTell TellHandler[string], onAsk AskHandler[string]) *Host[string] {
+	priv := ed25519.PrivateKey(p2ptest.NewTestKey(t, i))
+	mt := n.NewTransport(fmt.Sprintf("node-%d", i), priv)
+	return newHostOn(t, mt, onTell, onAsk)
+}
@@ -24,6 +24,22 @@ const (
 	MT_ANYVAL_TELL
 	MT_ANYVAL_ASK
 	MT_ANYVAL_REPLY
+
+	// MT_BLOB_WANT asks for many blobs at once.  The body is a list of IDs.
+	MT_BLOB_WANT
+	// MT_BLOB_BATCH is the response to MT_BLOB_WANT.
+	// The body is a sequence of entries, for a prefix of the wanted IDs, in the same order.
+	// Each entry is the ID, a 4 byte length, and the blob.
+	MT_BLOB_BATCH
+)
+
+const (
+	// MaxWantIDs is the most IDs that can be in a single MT_BLOB_WANT message.
+	MaxWantIDs = 4096
+
+	batchEntryHeader = cadata.IDSize + 4
+	// batchNotFound is used as the length of an entry for a blob which does not exist.
+	batchNotFound = ^uint32(0)
 )
 
 type Message struct {
@@ -80,6 +96,89 @@ func (m *Message) SetBlobNotFound(id cadata.ID) {
 	m.setBody(id[:])
 }
 
+// SetBlobWant sets the message to a want for ids.
+// At most MaxWantIDs are added, and the number added is returned.
+func (m *Message) SetBlobWant(ids []cadata.ID) int {
+	m.setType(MT_BLOB_WANT)
+	ids = ids[:min(len(ids), MaxWantIDs)]
+	for i, id := range ids {
+		copy(m.buf[i*cadata.IDSize:], id[:])
+	}
+	m.SetLen(len(ids) * cadata.IDSize)
+	return len(ids)
+}
+
+// AsIDs returns the IDs in an MT_BLOB_WANT message.
+func (m *Message) AsIDs() ([]cadata.ID, error) {
+	body := m.Body()
+	if len(body)%cadata.IDSize != 0 || len(body) > MaxWantIDs*cadata.IDSize {
+		return nil, fmt.Errorf("message is wrong size to be a list of IDs, len=%d", len(body))
+	}
+	ids := make([]cadata.ID, len(body)/cadata.IDSize)
+	for i := range ids {
+		ids[i] = cadata.IDFromBytes(body[i*cadata.IDSize:])
+	}
+	return ids, nil
+}
+
+// setBlobBatch fills the message with as many of the blobs wanted by ids as will fit, in order.
+// It stops at the first blob which does not fit, so the response is always for a prefix of ids.
+func (m *Message) setBlobBatch(ctx context.Context, s cadata.Getter, ids []cadata.ID) error {
+	m.setType(MT_BLOB_BATCH)
+	var n int
+	for _, id := range ids {
+		ent := m.buf[n:]
+		if len(ent) <= batchEntryHeader {
+			break
+		}
+		copy(ent, id[:])
+		l, err := s.Get(ctx, &id, nil, ent[batchEntryHeader:])
+		if errors.As(err, &cadata.ErrNotFound{}) {
+			binary.BigEndian.PutUint32(ent[cadata.IDSize:], batchNotFound)
+			n += batchEntryHeader
+			continue
+		} else if err != nil {
+			return err
+		}
+		if l == len(ent[batchEntryHeader:]) {
+			// the blob may have been truncated.
+			break
+		}
+		binary.BigEndian.PutUint32(ent[cadata.IDSize:], uint32(l))
+		n += batchEntryHeader + l
+	}
+	m.SetLen(n)
+	return nil
+}
+
+// ForEachBlob calls fn for each entry in an MT_BLOB_BATCH message.
+// data is nil if the blob was not found.
+func (m *Message) ForEachBlob(fn func(id cadata.ID, data []byte) error) error {
+	if m.Type() != MT_BLOB_BATCH {
+		return fmt.Errorf("%v message type does not contain blobs", m.Type())
+	}
+	body := m.Body()
+	for len(body) > 0 {
+		if len(body) < batchEntryHeader {
+			return fmt.Errorf("short blob batch entry, len=%d", len(body))
+		}
+		id := cadata.IDFromBytes(body[:cadata.IDSize])
+		l := binary.BigEndian.Uint32(body[cadata.IDSize:])
+		body = body[batchEntryHeader:]
+		var data []byte
+		if l != batchNotFound {
+			if int(l) > len(body) {
+				return fmt.Errorf("blob batch entry is longer than message, len=%d", l)
+			}
+			data, body = body[:l], body[l:]
+		}
+		if err := fn(id, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *Message) SetAnyValTell(data []byte) {
 	m.setType(MT_ANYVAL_TELL)
 	m.setBody(data)
@@ -120,6 +219,11 @@ func (m *Message) String() string {
 	return fmt.Sprintf("Message{type=%v, n=%v, %q}", m.Type(), m.Len(), data)
 }
 
+func (m *Message) copyFrom(x *Message) {
+	m.header = x.header
+	copy(m.buf[:], x.Body())
+}
+
 func (m *Message) setType(x MessageType) {
 	m.header &= low24
 	m.header |= uint32(x) << 24
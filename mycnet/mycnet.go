@@ -20,13 +20,6 @@ func (a Addr[T]) String() string {
 	return fmt.Sprintf("%v@%v", a.Peer, a.Location)
 }
 
-// Limits are contraints on values
-type Limits struct {
-	MaxDepth int
-	MaxBlobs int
-	MaxBits  int
-}
-
 // Handler is called to handle message.
 // Tell interactions will pass nil for res.
 // Ask interactions will pass non-nil for req and res.
@@ -70,6 +63,24 @@ func newArtifact(root []byte, s cadata.Getter) (Artifact, error) {
 	return Artifact{Root: mycbytes.AnyValue(root), Store: s}, nil
 }
 
+// Slurp loads the Artifact's root, after checking that everything reachable from it is within lim.
+// If the Artifact came from another peer, everything reachable is fetched in batches, and cached in its Store.
 func (af *Artifact) Slurp(ctx context.Context, lim Limits) (*myc.AnyValue, error) {
+	if err := traverse(ctx, af.Store, af.Root[:], lim); err != nil {
+		return nil, err
+	}
 	return myc.LoadRoot(ctx, af.Store, af.Root[:])
 }
+
+// PullInto copies the Artifact's root, and every Value reachable from it, into dst.
+// Nothing is copied unless everything reachable is within lim.
+func (af *Artifact) PullInto(ctx context.Context, dst cadata.PostExister, lim Limits) (*myc.AnyValue, error) {
+	av, err := af.Slurp(ctx, lim)
+	if err != nil {
+		return nil, err
+	}
+	if err := av.PullInto(ctx, dst, af.Store); err != nil {
+		return nil, err
+	}
+	return av, nil
+}
@@ -0,0 +1,63 @@
+package mycnet
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+
+	"myceliumweb.org/mycelium"
+	"myceliumweb.org/mycelium/internal/cadata"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+const (
+	// wantBatchSize is the number of IDs in each want sent during a prefetch.
+	wantBatchSize = 256
+	// prefetchParallel is the number of wants in flight at once during a prefetch.
+	prefetchParallel = 4
+)
+
+// batchFetcher is implemented by stores which can fetch many blobs at once, before they are needed.
+type batchFetcher interface {
+	// fetchAll fetches the blobs for refs, counting each one against b.
+	fetchAll(ctx context.Context, refs []*myc.Ref, b *budget) error
+}
+
+var _ batchFetcher = &remoteStore[struct{}]{}
+
+// fetchAll adds the blobs for refs to the cache, using parallel batched wants.
+func (s *remoteStore[T]) fetchAll(ctx context.Context, refs []*myc.Ref, b *budget) error {
+	salts := make(map[cadata.ID]*cadata.ID, len(refs))
+	var ids []cadata.ID
+	for _, ref := range refs {
+		id := cadata.ID(ref.Data())
+		if yes, err := s.cache.Exists(ctx, &id); err != nil {
+			return err
+		} else if yes {
+			continue
+		}
+		salts[id] = ref.Salt()
+		ids = append(ids, id)
+	}
+	c := client[T]{tp: s.tp}
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(prefetchParallel)
+	for len(ids) > 0 {
+		batch := ids[:min(len(ids), wantBatchSize)]
+		ids = ids[len(batch):]
+		eg.Go(func() error {
+			return c.blobWant(ctx, s.raddr, batch, func(id cadata.ID, data []byte) error {
+				salt := salts[id]
+				if err := cadata.Check(mycelium.Hash, &id, salt, data); err != nil {
+					return err
+				}
+				if err := b.spend(id, len(data)); err != nil {
+					return err
+				}
+				_, err := s.cache.Post(ctx, salt, data)
+				return err
+			})
+		})
+	}
+	return eg.Wait()
+}
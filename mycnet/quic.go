@@ -8,6 +8,7 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/netip"
 	"sync"
@@ -76,7 +77,7 @@ func (qt *QUICTransport) Tell(ctx context.Context, raddr QUICAddr, msg *Message)
 	if err != nil {
 		return err
 	}
-	s, err := conn.OpenUniStream()
+	s, err := conn.OpenUniStreamSync(ctx)
 	if err != nil {
 		return err
 	}
@@ -92,18 +93,19 @@ func (qt *QUICTransport) Ask(ctx context.Context, raddr QUICAddr, req, resp *Mes
 	if err != nil {
 		return err
 	}
-	s, err := conn.OpenStream()
+	s, err := conn.OpenStreamSync(ctx)
 	if err != nil {
 		return err
 	}
-	defer s.Close()
+	defer s.CancelRead(0)
 	if _, err := req.WriteTo(s); err != nil {
+		s.CancelWrite(0)
 		return err
 	}
-	if _, err := resp.ReadFrom(s); err != nil {
+	if err := s.Close(); err != nil {
 		return err
 	}
-	return nil
+	return readMsg(s, resp)
 }
 
 // Send sends a datagram
@@ -276,7 +278,7 @@ func (qt *QUICTransport) handleConn(ctx context.Context, conn quic.Connection, h
 func (qt *QUICTransport) handleStream(ctx context.Context, raddr QUICAddr, s quic.Stream, h QUICHandler) error {
 	defer s.Close()
 	var req Message
-	if _, err := req.ReadFrom(s); err != nil {
+	if err := readMsg(s, &req); err != nil {
 		return err
 	}
 	var res Message
@@ -292,7 +294,7 @@ func (qt *QUICTransport) handleStream(ctx context.Context, raddr QUICAddr, s qui
 
 func (qt *QUICTransport) handleUniStream(ctx context.Context, raddr QUICAddr, s quic.ReceiveStream, h QUICHandler) error {
 	var msg Message
-	if _, err := msg.ReadFrom(s); err != nil {
+	if err := readMsg(s, &msg); err != nil {
 		return err
 	}
 	if err := h(ctx, raddr, &msg, nil); err != nil {
@@ -301,6 +303,22 @@ func (qt *QUICTransport) handleUniStream(ctx context.Context, raddr QUICAddr, s
 	return nil
 }
 
+// readMsg reads msg from r, which must end after it.
+// Streams are only released once they have been read to the end,
+// so a peer which does not read to the end will run out of streams.
+func readMsg(r io.Reader, msg *Message) error {
+	if _, err := msg.ReadFrom(r); err != nil {
+		return err
+	}
+	var extra [1]byte
+	if _, err := io.ReadFull(r, extra[:]); err == nil {
+		return fmt.Errorf("stream continues after %v", msg)
+	} else if !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
 // makeDialTlsConfig is called to create a tls.Config for outbound connections
 func (qt *QUICTransport) makeDialTlsConfig(dst PeerID) *tls.Config {
 	cfg := qt.makeTlsConfig()
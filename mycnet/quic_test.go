@@ -64,29 +64,69 @@ func TestBlobPull(t *testing.T) {
 	ctx := testutil.Context(t)
 	h1 := newHost(t, 1, nil, nil)
 	h2 := newHost(t, 2, nil, nil)
+	h3 := newHost(t, 3, nil, nil)
 
-	var salt *cadata.ID
-	data := []byte("hello world")
-	targetID, err := h2.repo.s.Post(ctx, salt, data)
+	af, list := newRefList(t, 1)
+	_, err := h2.repo.Pin(ctx, h1.LocalAddr().Peer.ID(), af, DefaultPinTTL)
 	require.NoError(t, err)
+	target := list.Get(0).(*myc.Ref)
+	targetID := cadata.ID(target.Data())
 
 	// success
 	buf := make([]byte, 1024)
-	n, err := h1.client.blobPull(ctx, h2.tp.LocalAddr(), &targetID, salt, buf)
+	n, err := h1.client.blobPull(ctx, h2.tp.LocalAddr(), &targetID, target.Salt(), buf)
 	require.NoError(t, err)
-	require.Equal(t, len(data), n)
+	require.NoError(t, cadata.Check(mycelium.Hash, &targetID, target.Salt(), buf[:n]))
+
+	// not pinned for h3
+	_, err = h3.client.blobPull(ctx, h2.tp.LocalAddr(), &targetID, target.Salt(), buf)
+	require.ErrorAs(t, err, &cadata.ErrNotFound{})
 
 	// not found case
+	var salt *cadata.ID
 	nfID := mycelium.Hash(salt, []byte("does not exist"))
 	_, err = h1.client.blobPull(ctx, h2.tp.LocalAddr(), &nfID, salt, make([]byte, 100))
 	require.ErrorAs(t, err, &cadata.ErrNotFound{})
 }
 
-func newHost(t testing.TB, i int, onTell TellHandler[netip.AddrPort], onAsk AskHandler[netip.AddrPort]) *Host[netip.AddrPort] {
+func TestBlobWant(t *testing.T) {
 	ctx := testutil.Context(t)
+	h1 := newHost(t, 1, nil, nil)
+	h2 := newHost(t, 2, nil, nil)
+
+	af, list := newRefList(t, 10)
+	_, err := h2.repo.Pin(ctx, h1.LocalAddr().Peer.ID(), af, DefaultPinTTL)
+	require.NoError(t, err)
+	salts := make(map[cadata.ID]*cadata.ID)
+	var ids []cadata.ID
+	for i := 0; i < list.Len(); i++ {
+		ref := list.Get(i).(*myc.Ref)
+		id := cadata.ID(ref.Data())
+		salts[id] = ref.Salt()
+		ids = append(ids, id)
+	}
+	nfID := mycelium.Hash(nil, []byte("does not exist"))
+	ids = append(ids, nfID)
+
+	got := make(map[cadata.ID][]byte)
+	require.NoError(t, h1.client.blobWant(ctx, h2.tp.LocalAddr(), ids, func(id cadata.ID, data []byte) error {
+		require.NoError(t, cadata.Check(mycelium.Hash, &id, salts[id], data))
+		got[id] = append([]byte{}, data...)
+		return nil
+	}))
+	require.Len(t, got, 10)
+	require.NotContains(t, got, nfID)
+}
+
+func newHost(t testing.TB, i int, onTell TellHandler[netip.AddrPort], onAsk AskHandler[netip.AddrPort]) *Host[netip.AddrPort] {
 	priv := ed25519.PrivateKey(p2ptest.NewTestKey(t, i))
 	qt := NewQUIC(priv, testutil.NewPacketConn(t))
-	h := NewHost(qt, onTell, onAsk)
+	return newHostOn(t, qt, onTell, onAsk)
+}
+
+func newHostOn[T comparable](t testing.TB, tp Transport[T], onTell TellHandler[T], onAsk AskHandler[T]) *Host[T] {
+	ctx := testutil.Context(t)
+	h := NewHost(tp, onTell, onAsk)
 	go h.Run(ctx)
 	return h
 }
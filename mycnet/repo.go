@@ -0,0 +1,161 @@
+package mycnet
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"myceliumweb.org/mycelium"
+	"myceliumweb.org/mycelium/internal/cadata"
+	"myceliumweb.org/mycelium/internal/stores"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+// DefaultPinTTL is how long an Artifact sent to a peer stays available for the peer to pull.
+const DefaultPinTTL = time.Minute
+
+// repo stores the blobs of Artifacts which have been sent to other peers.
+// Artifacts are pinned for the peer they were sent to,
+// and a peer can only pull blobs reachable from an Artifact pinned for it.
+// Blobs are reference counted by the pins which reach them, and are evicted when the last one is released or expires.
+type repo struct {
+	now func() time.Time
+
+	mu    sync.Mutex
+	blobs map[cadata.ID]*repoBlob
+	peers map[PeerID]map[cadata.ID]int
+	pins  map[*pin]struct{}
+}
+
+type repoBlob struct {
+	data []byte
+	refs int
+}
+
+// pin is a reference from a peer to every blob reachable from an Artifact.
+type pin struct {
+	peer      PeerID
+	ids       []cadata.ID
+	expiresAt time.Time
+}
+
+func newRepo() *repo {
+	return &repo{
+		now: time.Now,
+
+		blobs: make(map[cadata.ID]*repoBlob),
+		peers: make(map[PeerID]map[cadata.ID]int),
+		pins:  make(map[*pin]struct{}),
+	}
+}
+
+// Pin makes everything reachable from x available to peer,
+// until the returned function is called, or ttl has elapsed.
+// Expired pins are released lazily, the next time the repo is used.
+func (r *repo) Pin(ctx context.Context, peer PeerID, x Artifact, ttl time.Duration) (func(), error) {
+	tmp := stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes)
+	av, err := myc.LoadRoot(ctx, x.Store, x.Root[:])
+	if err != nil {
+		return nil, err
+	}
+	if err := av.PullInto(ctx, tmp, x.Store); err != nil {
+		return nil, err
+	}
+	p := &pin{
+		peer:      peer,
+		ids:       tmp.All(),
+		expiresAt: r.now().Add(ttl),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire()
+	if r.peers[peer] == nil {
+		r.peers[peer] = make(map[cadata.ID]int)
+	}
+	buf := make([]byte, mycelium.MaxSizeBytes)
+	for _, id := range p.ids {
+		b := r.blobs[id]
+		if b == nil {
+			n, err := tmp.Get(ctx, &id, nil, buf)
+			if err != nil {
+				return nil, err
+			}
+			b = &repoBlob{data: append([]byte{}, buf[:n]...)}
+			r.blobs[id] = b
+		}
+		b.refs++
+		r.peers[peer][id]++
+	}
+	r.pins[p] = struct{}{}
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.unpin(p)
+	}, nil
+}
+
+// Open returns a store containing the blobs which peer is allowed to pull.
+func (r *repo) Open(peer PeerID) cadata.Getter {
+	return &peerStore{r: r, peer: peer}
+}
+
+// Len returns the number of blobs in the repo.
+func (r *repo) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire()
+	return len(r.blobs)
+}
+
+func (r *repo) get(peer PeerID, id cadata.ID, buf []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire()
+	if r.peers[peer][id] == 0 {
+		return 0, cadata.ErrNotFound{Key: &id}
+	}
+	return copy(buf, r.blobs[id].data), nil
+}
+
+// unpin releases p's references, and evicts any blobs which are no longer referenced.
+// It is a no-op if p has already been released.
+func (r *repo) unpin(p *pin) {
+	if _, exists := r.pins[p]; !exists {
+		return
+	}
+	delete(r.pins, p)
+	refs := r.peers[p.peer]
+	for _, id := range p.ids {
+		if refs[id]--; refs[id] == 0 {
+			delete(refs, id)
+		}
+		b := r.blobs[id]
+		if b.refs--; b.refs == 0 {
+			delete(r.blobs, id)
+		}
+	}
+	if len(refs) == 0 {
+		delete(r.peers, p.peer)
+	}
+}
+
+// expire releases every pin which has expired.
+func (r *repo) expire() {
+	now := r.now()
+	for p := range r.pins {
+		if !now.Before(p.expiresAt) {
+			r.unpin(p)
+		}
+	}
+}
+
+// peerStore is the view of a repo for a single peer.
+type peerStore struct {
+	r    *repo
+	peer PeerID
+}
+
+func (s *peerStore) Get(ctx context.Context, id *cadata.ID, salt *cadata.ID, buf []byte) (int, error) {
+	return s.r.get(s.peer, *id, buf)
+}
@@ -0,0 +1,50 @@
+package mycnet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium/internal/cadata"
+	"myceliumweb.org/mycelium/internal/testutil"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+func TestRepoPin(t *testing.T) {
+	ctx := testutil.Context(t)
+	now := time.Now()
+	r := newRepo()
+	r.now = func() time.Time { return now }
+	af, list := newRefList(t, 3)
+	ref := list.Get(0).(*myc.Ref)
+	id := cadata.ID(ref.Data())
+	peerA, peerB := PeerID{1}, PeerID{2}
+
+	get := func(peer PeerID) error {
+		_, err := r.Open(peer).Get(ctx, &id, ref.Salt(), make([]byte, 100))
+		return err
+	}
+
+	releaseA, err := r.Pin(ctx, peerA, af, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, get(peerA))
+	require.ErrorAs(t, get(peerB), &cadata.ErrNotFound{})
+
+	_, err = r.Pin(ctx, peerB, af, 2*time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, get(peerB))
+	n := r.Len()
+
+	// releasing A's pin does not evict blobs B's pin still refers to.
+	releaseA()
+	releaseA()
+	require.ErrorAs(t, get(peerA), &cadata.ErrNotFound{})
+	require.NoError(t, get(peerB))
+	require.Equal(t, n, r.Len())
+
+	// B's pin expires
+	now = now.Add(2 * time.Minute)
+	require.ErrorAs(t, get(peerB), &cadata.ErrNotFound{})
+	require.Equal(t, 0, r.Len())
+}
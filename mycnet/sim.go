@@ -0,0 +1,294 @@
+package mycnet
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"go.brendoncarroll.net/stdctx/logctx"
+	"go.uber.org/zap"
+
+	"myceliumweb.org/mycelium/internal/clocks"
+)
+
+var (
+	// ErrDropped is returned by a simulated Ask when the request or response is dropped.
+	ErrDropped = errors.New("mycnet: message dropped by simulator")
+	// ErrPartitioned is returned by a simulated Transport when the destination is on the other side of a partition.
+	ErrPartitioned = errors.New("mycnet: destination is partitioned")
+)
+
+// SimConfig configures the faults injected by a Sim.
+type SimConfig struct {
+	// Seed seeds the random number generator, which makes every decision about delays and drops.
+	Seed uint64
+	// Latency is added to every message.
+	Latency time.Duration
+	// Jitter is the most random delay added to a message, on top of Latency.
+	// Tells are delivered in the background, so jitter can reorder them.
+	Jitter time.Duration
+	// DropRate is the probability that a message is dropped, between 0 and 1.
+	DropRate float64
+	// Clock times the delays. If it is nil, the system clock is used.
+	// A manual clock (mycss.ManualClock) makes delivery depend only on how far the clock is advanced.
+	Clock clocks.Clock
+}
+
+// Sim simulates a faulty network, by wrapping Transports.
+// Each direction of each link has its own random number generator, derived from the seed,
+// so the same sequence of messages over a link always experiences the same faults,
+// no matter how messages on other links are interleaved.
+// Tells between Transports wrapped by the same Sim are delivered one at a time,
+// in the order of their delivery times, and then the order they were sent.
+type Sim[T comparable] struct {
+	cfg   SimConfig
+	clock clocks.Clock
+
+	mu         sync.Mutex
+	rngs       map[[2]T]*rand.Rand
+	partitions map[[2]T]struct{}
+	nodes      map[T]*simTransport[T]
+	// queue holds the Tells waiting to be delivered, sorted by delivery time.
+	queue   []simDelivery
+	seq     uint64
+	pumping bool
+	wake    chan struct{}
+}
+
+func NewSim[T comparable](cfg SimConfig) *Sim[T] {
+	clock := cfg.Clock
+	if clock == nil {
+		clock = clocks.Real{}
+	}
+	return &Sim[T]{
+		cfg:        cfg,
+		clock:      clock,
+		rngs:       make(map[[2]T]*rand.Rand),
+		partitions: make(map[[2]T]struct{}),
+		nodes:      make(map[T]*simTransport[T]),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Wrap returns a Transport which sends messages through tp, subject to the Sim's faults.
+func (s *Sim[T]) Wrap(tp Transport[T]) Transport[T] {
+	st := &simTransport[T]{sim: s, inner: tp}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodes[tp.LocalAddr().Location] = st
+	return st
+}
+
+// Partition prevents messages between a and b, in both directions.
+func (s *Sim[T]) Partition(a, b T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partitions[[2]T{a, b}] = struct{}{}
+	s.partitions[[2]T{b, a}] = struct{}{}
+}
+
+// Heal undoes Partition.
+func (s *Sim[T]) Heal(a, b T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.partitions, [2]T{a, b})
+	delete(s.partitions, [2]T{b, a})
+}
+
+// HealAll removes every partition.
+func (s *Sim[T]) HealAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.partitions)
+}
+
+// simFate is what happens to a single message.
+type simFate struct {
+	delay   time.Duration
+	dropped bool
+	err     error
+}
+
+// decide determines the fate of a message from src to dst.
+func (s *Sim[T]) decide(src, dst T) simFate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.partitions[[2]T{src, dst}]; exists {
+		return simFate{err: ErrPartitioned}
+	}
+	rng := s.rngs[[2]T{src, dst}]
+	if rng == nil {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%v\x00%v", src, dst)
+		rng = rand.New(rand.NewPCG(s.cfg.Seed, h.Sum64()))
+		s.rngs[[2]T{src, dst}] = rng
+	}
+	f := simFate{delay: s.cfg.Latency}
+	if s.cfg.Jitter > 0 {
+		f.delay += time.Duration(rng.Int64N(int64(s.cfg.Jitter)))
+	}
+	f.dropped = rng.Float64() < s.cfg.DropRate
+	return f
+}
+
+// simDelivery is a Tell waiting in the Sim's queue.
+type simDelivery struct {
+	at      time.Time
+	seq     uint64
+	deliver func()
+}
+
+// schedule queues deliver to be called once d has elapsed.
+func (s *Sim[T]) schedule(d time.Duration, deliver func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	x := simDelivery{at: s.clock.Now().Add(d), seq: s.seq, deliver: deliver}
+	s.seq++
+	i, _ := slices.BinarySearchFunc(s.queue, x, func(a, b simDelivery) int {
+		if c := a.at.Compare(b.at); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.seq, b.seq)
+	})
+	s.queue = slices.Insert(s.queue, i, x)
+	if !s.pumping {
+		s.pumping = true
+		go s.pump()
+		return
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pump delivers queued Tells in order, as their delivery times pass.
+// It returns when the queue is empty.
+func (s *Sim[T]) pump() {
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.pumping = false
+			s.mu.Unlock()
+			return
+		}
+		next := s.queue[0]
+		now := s.clock.Now()
+		if !next.at.After(now) {
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+			next.deliver()
+			continue
+		}
+		s.mu.Unlock()
+		// wait for the first delivery, or for an earlier one to be scheduled.
+		ch, stop := s.clock.After(next.at.Sub(now))
+		select {
+		case <-ch:
+		case <-s.wake:
+		}
+		stop()
+	}
+}
+
+// handler returns the handler serving the Transport wrapped at loc, and the context it is served with.
+func (s *Sim[T]) handler(loc T) (context.Context, MsgHandler[T]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st := s.nodes[loc]; st != nil && st.serveFn != nil {
+		return st.serveCtx, st.serveFn
+	}
+	return nil, nil
+}
+
+type simTransport[T comparable] struct {
+	sim   *Sim[T]
+	inner Transport[T]
+
+	// serveCtx and serveFn are set while the Transport is being served, and are protected by sim.mu.
+	serveCtx context.Context
+	serveFn  MsgHandler[T]
+}
+
+// Tell sends msg in the background, after the message's delay.
+// Dropped messages are not reported to the sender.
+// If the destination was wrapped by the same Sim, the message is handled by the Sim's delivery loop,
+// otherwise it is sent with the inner Transport.
+func (st *simTransport[T]) Tell(ctx context.Context, raddr Addr[T], msg *Message) error {
+	f := st.sim.decide(st.LocalAddr().Location, raddr.Location)
+	if f.err != nil {
+		return f.err
+	}
+	if f.dropped {
+		return nil
+	}
+	msg2 := cloneMessage(msg)
+	ctx = context.WithoutCancel(ctx)
+	st.sim.schedule(f.delay, func() {
+		var err error
+		if sctx, fn := st.sim.handler(raddr.Location); fn != nil {
+			err = fn(sctx, st.LocalAddr(), msg2, nil)
+		} else {
+			err = st.inner.Tell(ctx, raddr, msg2)
+		}
+		if err != nil {
+			logctx.Error(ctx, "simulated tell", zap.Error(err))
+		}
+	})
+	return nil
+}
+
+// Ask waits for the delay of both the request and the response.
+func (st *simTransport[T]) Ask(ctx context.Context, raddr Addr[T], req, resp *Message) error {
+	laddr := st.LocalAddr()
+	reqFate := st.sim.decide(laddr.Location, raddr.Location)
+	if err := reqFate.wait(ctx, st.sim.clock); err != nil {
+		return err
+	}
+	if err := st.inner.Ask(ctx, raddr, req, resp); err != nil {
+		return err
+	}
+	return st.sim.decide(raddr.Location, laddr.Location).wait(ctx, st.sim.clock)
+}
+
+func (st *simTransport[T]) Serve(ctx context.Context, fn MsgHandler[T]) error {
+	st.sim.mu.Lock()
+	st.serveCtx, st.serveFn = ctx, fn
+	st.sim.mu.Unlock()
+	defer func() {
+		st.sim.mu.Lock()
+		st.serveCtx, st.serveFn = nil, nil
+		st.sim.mu.Unlock()
+	}()
+	return st.inner.Serve(ctx, fn)
+}
+
+func (st *simTransport[T]) LocalAddr() Addr[T] {
+	return st.inner.LocalAddr()
+}
+
+// wait blocks for the message's delay on clock, and then returns an error if it was not delivered.
+func (f simFate) wait(ctx context.Context, clock clocks.Clock) error {
+	if f.err != nil {
+		return f.err
+	}
+	if f.delay > 0 {
+		ch, stop := clock.After(f.delay)
+		defer stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ch:
+		}
+	}
+	if f.dropped {
+		return ErrDropped
+	}
+	return nil
+}
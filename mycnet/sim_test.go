@@ -0,0 +1,130 @@
+package mycnet
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/circl/sign/ed25519"
+	"github.com/stretchr/testify/require"
+	"go.brendoncarroll.net/p2p/p2ptest"
+
+	"myceliumweb.org/mycelium/internal/clocks"
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/mycbytes"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+func TestSimPartition(t *testing.T) {
+	ctx := testutil.Context(t)
+	sim := NewSim[string](SimConfig{Seed: 1})
+	h1, h2 := newSimHosts(t, sim)
+	req := ArtifactFromMemory(myc.NewAnyValue(myc.NewB32(0)))
+
+	sim.Partition(h1.LocalAddr().Location, h2.LocalAddr().Location)
+	_, err := h1.AskAnyVal(ctx, h2.LocalAddr(), req)
+	require.ErrorIs(t, err, ErrPartitioned)
+	require.ErrorIs(t, h1.TellAnyVal(ctx, h2.LocalAddr(), req), ErrPartitioned)
+
+	sim.Heal(h1.LocalAddr().Location, h2.LocalAddr().Location)
+	_, err = h1.AskAnyVal(ctx, h2.LocalAddr(), req)
+	require.NoError(t, err)
+}
+
+func TestSimDrop(t *testing.T) {
+	ctx := testutil.Context(t)
+	sim := NewSim[string](SimConfig{Seed: 1, DropRate: 1})
+	h1, h2 := newSimHosts(t, sim)
+	_, err := h1.AskAnyVal(ctx, h2.LocalAddr(), ArtifactFromMemory(myc.NewAnyValue(myc.NewB32(0))))
+	require.ErrorIs(t, err, ErrDropped)
+}
+
+func TestSimLatency(t *testing.T) {
+	ctx := testutil.Context(t)
+	const latency = 20 * time.Millisecond
+	sim := NewSim[string](SimConfig{Seed: 1, Latency: latency})
+	h1, h2 := newSimHosts(t, sim)
+	start := time.Now()
+	_, err := h1.AskAnyVal(ctx, h2.LocalAddr(), ArtifactFromMemory(myc.NewAnyValue(myc.NewB32(0))))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 2*latency)
+}
+
+func TestSimDeterministic(t *testing.T) {
+	fates := func(seed uint64) (ret []simFate) {
+		sim := NewSim[string](SimConfig{Seed: seed, Jitter: time.Second, DropRate: 0.5})
+		for i := 0; i < 100; i++ {
+			ret = append(ret, sim.decide("a", "b"))
+		}
+		return ret
+	}
+	require.Equal(t, fates(1), fates(1))
+	require.NotEqual(t, fates(1), fates(2))
+}
+
+func TestSimOrder(t *testing.T) {
+	const n = 20
+	var sent []mycbytes.AnyValue
+	for i := 0; i < n; i++ {
+		sent = append(sent, ArtifactFromMemory(myc.NewAnyValue(myc.NewB32(uint32(i)))).Root)
+	}
+	deliver := func(seed uint64) []mycbytes.AnyValue {
+		ctx := testutil.Context(t)
+		clk := clocks.NewManual(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+		sim := NewSim[string](SimConfig{Seed: seed, Latency: time.Second, Jitter: time.Second, Clock: clk})
+		var mu sync.Mutex
+		var got []mycbytes.AnyValue
+		h1, h2 := newSimHostsTell(t, sim, func(_ MemAddr, af Artifact) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, af.Root)
+			return nil
+		})
+		// wait for h2 to be served, so Tells are handled by the Sim.
+		require.Eventually(t, func() bool {
+			_, fn := sim.handler(h2.LocalAddr().Location)
+			return fn != nil
+		}, 5*time.Second, time.Millisecond)
+		for i := range sent {
+			require.NoError(t, h1.TellAnyVal(ctx, h2.LocalAddr(), ArtifactFromMemory(myc.NewAnyValue(myc.NewB32(uint32(i))))))
+		}
+		clk.Advance(time.Second - time.Nanosecond)
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		require.Empty(t, got, "delivered before the latency had elapsed")
+		mu.Unlock()
+		clk.Advance(time.Second)
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(got) == n
+		}, 5*time.Second, time.Millisecond)
+		return got
+	}
+	out := deliver(1)
+	require.ElementsMatch(t, sent, out)
+	require.NotEqual(t, sent, out, "jitter should reorder tells")
+	require.Equal(t, out, deliver(1))
+	require.NotEqual(t, out, deliver(2))
+}
+
+// newSimHosts creates 2 hosts, which communicate through sim.
+func newSimHosts(t testing.TB, sim *Sim[string]) (*Host[string], *Host[string]) {
+	return newSimHostsTell(t, sim, nil)
+}
+
+// newSimHostsTell creates 2 hosts, which communicate through sim, and handle tells with onTell.
+func newSimHostsTell(t testing.TB, sim *Sim[string], onTell TellHandler[string]) (*Host[string], *Host[string]) {
+	n := NewMemNet()
+	var hosts [2]*Host[string]
+	for i := range hosts {
+		priv := ed25519.PrivateKey(p2ptest.NewTestKey(t, i))
+		mt := n.NewTransport(fmt.Sprintf("node-%d", i), priv)
+		hosts[i] = newHostOn(t, sim.Wrap(mt), onTell, func(MemAddr, Artifact) (*Artifact, error) {
+			ret := ArtifactFromMemory(myc.NewAnyValue(myc.NewB32(1)))
+			return &ret, nil
+		})
+	}
+	return hosts[0], hosts[1]
+}
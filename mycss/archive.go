@@ -0,0 +1,219 @@
+package mycss
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"myceliumweb.org/mycelium/internal/cadata"
+	"myceliumweb.org/mycelium/internal/stores"
+	"myceliumweb.org/mycelium/myccanon"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/mycss/internal/dbutil"
+	"myceliumweb.org/mycelium/mycss/internal/sqlstores"
+	"myceliumweb.org/mycelium/myczip"
+)
+
+// archivePodsName is the name of the zip entry holding the pod metadata in a System archive.
+const archivePodsName = "pods.json"
+
+// archivePod is the metadata stored for each pod in a System archive.
+// The pod's namespace is stored at the same index in the archive's root List.
+type archivePod struct {
+	ID        PodID     `json:"id"`
+	Config    PodConfig `json:"config"`
+	Secret    []byte    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Export writes every pod in the System to w as a myczip archive.
+// The archive contains each pod's namespace, config, and encrypted secret, along with all the blobs
+// reachable from the namespaces.
+// All the pods are read in a single transaction, so the archive is a consistent snapshot of the System.
+// Device ports are not part of a pod's stored namespace, and are not exported.
+func (s *System) Export(ctx context.Context, w io.Writer) error {
+	return dbutil.DoTx(ctx, s.db, func(tx *sqlx.Tx) error {
+		var rows []struct {
+			ID        PodID             `db:"id"`
+			StoreID   sqlstores.StoreID `db:"store_id"`
+			Secret    []byte            `db:"secret"`
+			Config    []byte            `db:"config"`
+			CreatedAt time.Time         `db:"created_at"`
+		}
+		if err := tx.SelectContext(ctx, &rows, `SELECT id, store_id, secret, config, created_at FROM pods ORDER BY id`); err != nil {
+			return err
+		}
+		var metas []archivePod
+		var nsVals []Value
+		var srcs stores.Union
+		for _, row := range rows {
+			var cfg PodConfig
+			if err := json.Unmarshal(row.Config, &cfg); err != nil {
+				return err
+			}
+			pod := s.txPod(row.ID, row.StoreID, cfg)
+			ns := myccanon.Namespace{}
+			if err := pod.nsAll(tx, ns); err != nil {
+				return err
+			}
+			metas = append(metas, archivePod{
+				ID:        row.ID,
+				Config:    cfg,
+				Secret:    row.Secret,
+				CreatedAt: row.CreatedAt,
+			})
+			nsVals = append(nsVals, ns.ToMycelium())
+			srcs = append(srcs, pod.newTxStore(tx))
+		}
+
+		zw := zip.NewWriter(w)
+		metaW, err := zw.Create(archivePodsName)
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(metaW).Encode(metas); err != nil {
+			return err
+		}
+		root := myc.NewList(myccanon.NS_Type, nsVals...)
+		if err := myczip.Save(ctx, srcs, root, zw); err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+}
+
+// Import creates a new pod for each pod in an archive produced by Export.
+// Pods are assigned new IDs in this System, but keep their secrets, so network identities are preserved.
+// The secrets in the archive are sealed with the exporting System's MasterKey, so this System must use the same key,
+// otherwise Import returns ErrArchiveKey.
+// Either all the pods are created, or none of them are.
+// The new pods are returned in the order they appear in the archive.
+func (s *System) Import(ctx context.Context, zr *zip.Reader) ([]*Pod, error) {
+	metas, err := readArchivePods(zr)
+	if err != nil {
+		return nil, err
+	}
+	root, src, err := myczip.Load(zr)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := root.(*myc.List)
+	if !ok || list.Len() != len(metas) {
+		return nil, fmt.Errorf("archive root does not match pod metadata")
+	}
+	nss := make([]myccanon.Namespace, len(metas))
+	for i, meta := range metas {
+		nss[i] = myccanon.Namespace{}
+		if err := nss[i].FromMycelium(list.Get(i)); err != nil {
+			return nil, err
+		}
+		if err := meta.Config.Validate(); err != nil {
+			return nil, fmt.Errorf("importing pod %d: %w", meta.ID, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, err := s.unlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, meta := range metas {
+		if _, err := openSecret(key, meta.Secret); err != nil {
+			if errors.As(err, &ErrWrongKey{}) {
+				return nil, ErrArchiveKey{PodID: meta.ID}
+			}
+			return nil, fmt.Errorf("importing pod %d: %w", meta.ID, err)
+		}
+	}
+	s.stale = true
+	pids, err := dbutil.DoTx1(ctx, s.db, func(tx *sqlx.Tx) ([]PodID, error) {
+		var pids []PodID
+		for i, meta := range metas {
+			pid, err := s.importPod(ctx, tx, src, meta, nss[i])
+			if err != nil {
+				return nil, fmt.Errorf("importing pod %d: %w", meta.ID, err)
+			}
+			pids = append(pids, pid)
+		}
+		return pids, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var ret []*Pod
+	for _, pid := range pids {
+		pod, err := s.openPod(ctx, pid, s.podEnv())
+		if err != nil {
+			return nil, err
+		}
+		s.pods[pod.ID()] = pod
+		ret = append(ret, pod)
+	}
+	return ret, nil
+}
+
+// importPod creates a pod in tx with the namespace ns, and the config and secret from meta.
+func (s *System) importPod(ctx context.Context, tx *sqlx.Tx, src cadata.Getter, meta archivePod, ns myccanon.Namespace) (PodID, error) {
+	cfgData, err := json.Marshal(meta.Config)
+	if err != nil {
+		return 0, err
+	}
+	sid, err := sqlstores.CreateStore(tx)
+	if err != nil {
+		return 0, err
+	}
+	var pid PodID
+	if err := tx.GetContext(ctx, &pid, `INSERT INTO pods (store_id, secret, config, created_at) VALUES (?, ?, ?, ?) RETURNING id`,
+		sid, meta.Secret, cfgData, meta.CreatedAt); err != nil {
+		return 0, err
+	}
+	pod := s.txPod(pid, sid, meta.Config)
+	dst := pod.newTxStore(tx)
+	for _, v := range ns {
+		if err := v.PullInto(ctx, dst, src); err != nil {
+			return 0, err
+		}
+		if err := pod.checkDepth(ctx, dst, v, meta.Config.MaxDepth); err != nil {
+			return 0, err
+		}
+	}
+	if err := pod.nsApply(ctx, tx, 0, ns, slices.Sorted(maps.Keys(ns))); err != nil {
+		return 0, err
+	}
+	return pid, nil
+}
+
+// txPod returns a Pod for reading and writing a pod's rows in a transaction, without opening it.
+// It has no processes or devices, and must not be used outside of the transaction.
+func (s *System) txPod(pid PodID, sid sqlstores.StoreID, cfg PodConfig) *Pod {
+	p := &Pod{
+		id:      pid,
+		env:     s.podEnv(),
+		cfg:     cfg,
+		storeID: sid,
+	}
+	p.gcPostsDone.L = &p.gcMarkMu
+	return p
+}
+
+func readArchivePods(zr *zip.Reader) ([]archivePod, error) {
+	f, err := zr.Open(archivePodsName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var metas []archivePod
+	if err := json.NewDecoder(f).Decode(&metas); err != nil {
+		return nil, err
+	}
+	return metas, nil
+}
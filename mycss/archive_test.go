@@ -0,0 +1,128 @@
+package mycss
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/myccanon"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/myctests"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys1 := newTestSys(t)
+	s := testutil.NewStore(t)
+
+	ns := myccanon.Namespace{}
+	for i, v := range myctests.InterestingValues(s) {
+		ns[fmt.Sprintf("v%d", i)] = v
+	}
+	cfg := PodConfig{
+		Devices: map[string]DeviceSpec{
+			"cell0": DevCell(),
+		},
+	}
+	ns["cell0"] = myc.NewB32(7)
+	p1, err := sys1.Create(ctx)
+	require.NoError(t, err)
+	reset(t, p1, s, ns, cfg)
+	p2, err := sys1.Create(ctx)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, sys1.Export(ctx, &buf))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	sys2 := newTestSys(t)
+	pods, err := sys2.Import(ctx, zr)
+	require.NoError(t, err)
+	require.Len(t, pods, 2)
+
+	for i, pair := range [][2]*Pod{{p1, pods[0]}, {p2, pods[1]}} {
+		src, dst := pair[0], pair[1]
+		require.Equal(t, *src.secret, *dst.secret, "pod %d", i)
+		require.Equal(t, src.Config(), dst.Config(), "pod %d", i)
+		ns1, ns2 := myccanon.Namespace{}, myccanon.Namespace{}
+		require.NoError(t, src.nsAll(sys1.db, ns1))
+		require.NoError(t, dst.nsAll(sys2.db, ns2))
+		require.Equal(t, len(ns1), len(ns2))
+		for k, v1 := range ns1 {
+			require.True(t, myc.Equal(v1, ns2[k]), "pod %d key %q", i, k)
+		}
+	}
+	// the imported pod can be read back through the System
+	p, err := sys2.Get(ctx, pods[0].ID())
+	require.NoError(t, err)
+	require.Equal(t, myc.NewB32(7), cellLoad(t, p, s, "cell0"))
+}
+
+func TestImportAtomic(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys1 := newTestSys(t)
+	s := testutil.NewStore(t)
+	_, err := sys1.Create(ctx)
+	require.NoError(t, err)
+	p2, err := sys1.Create(ctx)
+	require.NoError(t, err)
+	require.NoError(t, p2.Put(ctx, s, "x", myc.NewString("hello world")))
+	var buf bytes.Buffer
+	require.NoError(t, sys1.Export(ctx, &buf))
+
+	// the second pod cannot be imported, because its namespace does not fit in its quota.
+	zr := rewriteArchivePods(t, buf.Bytes(), func(metas []archivePod) {
+		metas[1].Config.MaxStorageBytes = 1
+	})
+	sys2 := newTestSys(t)
+	_, err = sys2.Import(ctx, zr)
+	require.Error(t, err)
+	pods, err := sys2.List(ctx)
+	require.NoError(t, err)
+	require.Empty(t, pods)
+
+	// the secrets are sealed with sys1's key.
+	sys3 := NewSystemWithKey(newTestSys(t).db, RawKey([32]byte{1}))
+	zr, err = zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	_, err = sys3.Import(ctx, zr)
+	require.ErrorIs(t, err, ErrArchiveKey{PodID: 1})
+	pods, err = sys3.List(ctx)
+	require.NoError(t, err)
+	require.Empty(t, pods)
+}
+
+// rewriteArchivePods returns the archive in data, with its pod metadata changed by fn.
+func rewriteArchivePods(t testing.TB, data []byte, fn func([]archivePod)) *zip.Reader {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	metas, err := readArchivePods(zr)
+	require.NoError(t, err)
+	fn(metas)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		w, err := zw.Create(f.Name)
+		require.NoError(t, err)
+		if f.Name == archivePodsName {
+			require.NoError(t, json.NewEncoder(w).Encode(metas))
+			continue
+		}
+		r, err := f.Open()
+		require.NoError(t, err)
+		_, err = io.Copy(w, r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+	}
+	require.NoError(t, zw.Close())
+	zr, err = zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	return zr
+}
@@ -0,0 +1,43 @@
+package mycss
+
+import (
+	"io"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"myceliumweb.org/mycelium/internal/clocks"
+)
+
+// Clock is a source of time for the devices in a Pod.
+type Clock = clocks.Clock
+
+// RealClock is the system clock.
+type RealClock = clocks.Real
+
+// ManualClock is a Clock which only advances when it is told to.
+// It is used to make the devices in a Pod deterministic.
+type ManualClock = clocks.Manual
+
+// NewManualClock returns a ManualClock which is stopped at start.
+func NewManualClock(start time.Time) *ManualClock {
+	return clocks.NewManual(start)
+}
+
+// SeededEntropy returns a deterministic source of random bytes, which produces the same bytes for the same seed.
+// It must not be used to generate secrets outside of tests.
+func SeededEntropy(seed [32]byte) io.Reader {
+	return &lockedReader{r: rand.NewChaCha8(seed)}
+}
+
+// lockedReader makes a Reader safe to use from multiple processes.
+type lockedReader struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func (lr *lockedReader) Read(buf []byte) (int, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.r.Read(buf)
+}
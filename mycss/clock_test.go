@@ -0,0 +1,88 @@
+package mycss
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/myccanon"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+func TestDeterministic(t *testing.T) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := func() myc.Value {
+		sys := newTestSys(t)
+		sys.SetClock(NewManualClock(start))
+		sys.SetEntropy(SeededEntropy([32]byte{1, 2, 3}))
+		ctx := testutil.Context(t)
+		p, err := sys.Create(ctx)
+		require.NoError(t, err)
+		s := testutil.NewStore(t)
+		reset(t, p, s, myccanon.Namespace{}, PodConfig{
+			Devices: map[string]DeviceSpec{
+				"clock0":  DevWallClock(),
+				"random0": DevRandom(),
+			},
+		})
+		return eval(t, p, s, func(eb EB) *Expr {
+			return eb.Product(
+				CurrentTimeExpr(GetWallClock(eb.P(0), "clock0")),
+				GenRandomExpr(GetRandom(eb.P(0), "random0"), 256),
+				// ports are generated from the entropy source as well.
+				myccanon.NSGetExpr(eb.P(0), "random0"),
+			)
+		})
+	}
+	out1, out2 := run(), run()
+	require.Equal(t, out1, out2)
+	require.Equal(t, start.Unix(), decodeTAI64N(out1.(myc.Product)[0]).GoTime().Unix())
+}
+
+func TestManualClockTimer(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	clk := NewManualClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	sys.SetClock(clk)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	reset(t, p, s, myccanon.Namespace{}, PodConfig{
+		Devices: map[string]DeviceSpec{
+			"timer0": DevTimer(),
+		},
+	})
+	start := clk.Now()
+	done := make(chan myc.Value, 1)
+	go func() {
+		done <- eval(t, p, s, func(eb EB) *Expr {
+			return SleepForExpr(GetTimer(eb.P(0), "timer0"), time.Hour)
+		})
+	}()
+	require.Eventually(t, func() bool { return clk.Waiters() == 1 }, 5*time.Second, time.Millisecond)
+	clk.Advance(time.Hour - time.Second)
+	select {
+	case <-done:
+		t.Fatal("sleep ended before the clock reached the deadline")
+	case <-time.After(50 * time.Millisecond):
+	}
+	clk.Advance(time.Second)
+	out := <-done
+	require.Equal(t, start.Add(time.Hour), decodeTAI64N(out).GoTime())
+}
+
+func TestManualClockStop(t *testing.T) {
+	clk := NewManualClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	ch1, stop1 := clk.After(time.Second)
+	_, stop2 := clk.After(time.Second)
+	require.Equal(t, 2, clk.Waiters())
+	stop2()
+	require.Equal(t, 1, clk.Waiters())
+	clk.Advance(time.Second)
+	require.Equal(t, 0, clk.Waiters())
+	require.Equal(t, clk.Now(), <-ch1)
+	// stopping after the deadline does nothing.
+	stop1()
+}
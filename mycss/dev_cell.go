@@ -70,18 +70,52 @@ func (c *cell) CAS(ctx context.Context, prev, next *myc.AnyValue) (*myc.AnyValue
 	if c.pid == 0 {
 		panic("cell: pid not set")
 	}
+	var swapped bool
 	val, err := dbutil.DoTx1(ctx, c.p.env.DB, func(tx *sqlx.Tx) (myc.Value, error) {
 		if err := c.p.checkProcAlive(tx, c.pid); err != nil {
 			return nil, err
 		}
-		return c.p.nsCAS(ctx, tx, c.k, prev.Unwrap(), next.Unwrap())
+		val, ok, err := c.p.nsCAS(ctx, tx, c.pid, c.k, prev.Unwrap(), next.Unwrap())
+		swapped = ok
+		return val, err
 	})
 	if err != nil {
 		return nil, err
 	}
+	if swapped && !myc.Equal(prev, next) {
+		c.p.publish(c.k)
+	}
 	return myc.NewAnyValue(val), nil
 }
 
+// Put unconditionally sets the cell to next, pulling any data it references from src.
+func (c *cell) Put(ctx context.Context, src cadata.Getter, next *myc.AnyValue) error {
+	if c.pid == 0 {
+		panic("cell: pid not set")
+	}
+	if err := dbutil.DoTx(ctx, c.p.env.DB, func(tx *sqlx.Tx) error {
+		if err := c.p.checkProcAlive(tx, c.pid); err != nil {
+			return err
+		}
+		dst := c.p.newTxStore(tx)
+		if err := next.PullInto(ctx, dst, src); err != nil {
+			return err
+		}
+		if err := c.p.checkDepth(ctx, dst, next.Unwrap(), c.p.config().MaxDepth); err != nil {
+			return err
+		}
+		w, err := c.p.beginNSWrite(tx, c.pid)
+		if err != nil {
+			return err
+		}
+		return c.p.nsPut(ctx, tx, w, c.k, next.Unwrap())
+	}); err != nil {
+		return err
+	}
+	c.p.publish(c.k)
+	return nil
+}
+
 func (c *cell) PortType() *myc.PortType {
 	return DEV_CELL_Type
 }
@@ -109,6 +143,16 @@ func (c *cell) Port() mvm1.PortBackend {
 			}
 			return bytesToWords(data, buf[:spec.AnyValueBits/mvm1.WordBits])
 		},
+		Output: func(ctx context.Context, src cadata.Getter, buf []mvm1.Word) error {
+			av := &myc.AnyValue{}
+			load := func(ref myc.Ref) (myc.Value, error) {
+				return myc.Load(ctx, src, ref)
+			}
+			if err := av.Decode(bitbuf.FromBytes(wordsToBytes(buf)).Slice(0, spec.AnyValueBits), load); err != nil {
+				return err
+			}
+			return c.Put(ctx, src, av)
+		},
 		Input: func(ctx context.Context, dst cadata.PostExister, buf []mvm1.Word) error {
 			av, err := c.Load(ctx)
 			if err != nil {
@@ -38,13 +38,12 @@ func CurrentTimeExpr(clk *Expr) *Expr {
 }
 
 type wallClockDev struct {
+	clock Clock
 }
 
 func (cs wallClockDev) portInput(ctx context.Context, _ cadata.PostExister, buf []mvm1.Word) error {
-	ts := tai64.Now()
-	buf[0] = uint32(ts.Seconds)
-	buf[1] = uint32(ts.Nanoseconds)
-	return nil
+	ts := tai64.FromGoTime(cs.clock.Now())
+	return bytesToWords(myc.MarshalAppend(nil, encodeTAI64N(ts)), buf[:TAI64N.SizeOf()/mvm1.WordBits])
 }
 
 func (cs wallClockDev) PortType() *myc.PortType {
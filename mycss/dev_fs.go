@@ -0,0 +1,330 @@
+package mycss
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.brendoncarroll.net/tai64"
+
+	"myceliumweb.org/mycelium"
+	"myceliumweb.org/mycelium/internal/bitbuf"
+	"myceliumweb.org/mycelium/internal/cadata"
+	"myceliumweb.org/mycelium/internal/stores"
+	"myceliumweb.org/mycelium/mvm1"
+	"myceliumweb.org/mycelium/myccanon"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+var (
+	// DEV_FS_Info describes a file or directory
+	DEV_FS_Info = myc.ProductType{
+		// Name
+		myc.StringType(),
+		// Size in bytes
+		myc.B64Type(),
+		// IsDir
+		myc.BitType{},
+		// ModTime
+		TAI64N,
+	}
+	DEV_FS_Req = myc.SumType{
+		// List, the path of a directory
+		myc.StringType(),
+		// Stat, the path of a file or directory
+		myc.StringType(),
+		// Read, the path of a file
+		myc.StringType(),
+		// Write, the path of a file and its contents
+		myc.ProductType{myc.StringType(), myc.ListOf(myc.ByteType())},
+	}
+	DEV_FS_Resp = myc.SumType{
+		// List
+		myc.ListOf(DEV_FS_Info),
+		// Stat
+		DEV_FS_Info,
+		// Read
+		myc.ListOf(myc.ByteType()),
+		// Write
+		myc.ProductType{},
+	}
+	DEV_FS_Type = myc.NewPortType(
+		myc.Bottom(),
+		myc.Bottom(),
+		DEV_FS_Req,
+		DEV_FS_Resp,
+	)
+)
+
+// FSSpec grants a pod access to a directory on the host.
+type FSSpec struct {
+	// Root is the directory on the host.  The pod cannot access anything outside of it.
+	Root string
+	// ReadOnly prevents the pod from writing files.
+	ReadOnly bool `json:",omitempty"`
+	// MaxFileBytes is the largest file the pod can read or write.
+	// Zero, or anything larger than mycelium.MaxSizeBytes, means mycelium.MaxSizeBytes.
+	MaxFileBytes int64 `json:",omitempty"`
+}
+
+func (s *FSSpec) Validate() error {
+	if !filepath.IsAbs(s.Root) {
+		return fmt.Errorf("fs root must be an absolute path, have %q", s.Root)
+	}
+	if s.MaxFileBytes < 0 {
+		return fmt.Errorf("fs: negative MaxFileBytes")
+	}
+	return nil
+}
+
+func (s *FSSpec) maxFileBytes() int64 {
+	if s.MaxFileBytes == 0 || s.MaxFileBytes > mycelium.MaxSizeBytes {
+		return mycelium.MaxSizeBytes
+	}
+	return s.MaxFileBytes
+}
+
+// ErrFSReadOnly is returned when a pod writes to a read-only fs device.
+var ErrFSReadOnly = errors.New("fs: device is read-only")
+
+func GetFS(ns *Expr, k string) *Expr {
+	eb := EB{}
+	return eb.AnyValueTo(
+		myccanon.NSGetExpr(ns, k),
+		DEV_FS_Type,
+	)
+}
+
+// FSListExpr evaluates to information about every entry in the directory at p.
+func FSListExpr(fsys *Expr, p string) *Expr {
+	return fsExpr(fsys, 0, myc.NewString(p))
+}
+
+// FSStatExpr evaluates to information about the file or directory at p.
+func FSStatExpr(fsys *Expr, p string) *Expr {
+	return fsExpr(fsys, 1, myc.NewString(p))
+}
+
+// FSReadExpr evaluates to the contents of the file at p.
+func FSReadExpr(fsys *Expr, p string) *Expr {
+	return fsExpr(fsys, 2, myc.NewString(p))
+}
+
+// FSWriteExpr replaces the contents of the file at p with data, creating it if necessary.
+func FSWriteExpr(fsys *Expr, p string, data []byte) *Expr {
+	return fsExpr(fsys, 3, myc.Product{myc.NewString(p), newBytes(data)})
+}
+
+// fsExpr makes a request to fsys, and evaluates to the matching variant of the response.
+func fsExpr(fsys *Expr, tag int, x myc.Value) *Expr {
+	eb := EB{}
+	req, err := DEV_FS_Req.New(tag, x)
+	if err != nil {
+		panic(err)
+	}
+	return eb.Field(eb.Interact(fsys, eb.Lit(req)), tag)
+}
+
+// fsDev gives a process access to the files in a directory on the host.
+// Every path is relative to the root, and is resolved with an os.Root,
+// so neither ".." nor symlinks can be used to escape it.
+type fsDev struct {
+	spec FSSpec
+}
+
+func newFS(spec FSSpec) *fsDev {
+	return &fsDev{spec: spec}
+}
+
+// List returns information about the entries in the directory at p, sorted by name.
+func (fd *fsDev) List(p string) ([]fs.FileInfo, error) {
+	r, err := os.OpenRoot(fd.spec.Root)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	f, err := r.Open(fsPath(p))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ents, err := f.ReadDir(-1)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(ents, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	infos := make([]fs.FileInfo, 0, len(ents))
+	for _, ent := range ents {
+		info, err := ent.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (fd *fsDev) Stat(p string) (fs.FileInfo, error) {
+	r, err := os.OpenRoot(fd.spec.Root)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return r.Stat(fsPath(p))
+}
+
+func (fd *fsDev) Read(p string) ([]byte, error) {
+	r, err := os.OpenRoot(fd.spec.Root)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	f, err := r.Open(fsPath(p))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	limit := fd.spec.maxFileBytes()
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("fs: %q is larger than the limit of %d bytes", p, limit)
+	}
+	return data, nil
+}
+
+func (fd *fsDev) Write(p string, data []byte) error {
+	if fd.spec.ReadOnly {
+		return ErrFSReadOnly
+	}
+	if limit := fd.spec.maxFileBytes(); int64(len(data)) > limit {
+		return fmt.Errorf("fs: cannot write %d bytes to %q, limit is %d bytes", len(data), p, limit)
+	}
+	r, err := os.OpenRoot(fd.spec.Root)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	// the data is written to a temporary file next to the destination, and renamed into place,
+	// so readers never see a partially written file.
+	name := fsPath(p)
+	tmp := filepath.Join(filepath.Dir(name), "."+filepath.Base(name)+".tmp-"+rand.Text())
+	f, err := r.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = r.Rename(tmp, name)
+	}
+	if err != nil {
+		r.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (fd *fsDev) PortType() *myc.PortType {
+	return DEV_FS_Type
+}
+
+func (fd *fsDev) Port() mvm1.PortBackend {
+	return mvm1.PortBackend{
+		Interact: fd.portInteract,
+	}
+}
+
+func (fd *fsDev) portInteract(ctx context.Context, s cadata.Store, buf []mvm1.Word) error {
+	req := DEV_FS_Req.Zero().(*myc.Sum)
+	load := func(ref myc.Ref) (myc.Value, error) {
+		return myc.Load(ctx, s, ref)
+	}
+	if err := req.Decode(bitbuf.FromBytes(wordsToBytes(buf)).Slice(0, DEV_FS_Req.SizeOf()), load); err != nil {
+		return err
+	}
+	var resp myc.Value
+	switch req.Tag() {
+	case 0: // List
+		infos, err := fd.List(myccanon.AsString(req.Unwrap()))
+		if err != nil {
+			return err
+		}
+		vals := make([]myc.Value, len(infos))
+		for i, info := range infos {
+			vals[i] = fsInfoTo(info)
+		}
+		resp = myc.NewList(DEV_FS_Info, vals...)
+	case 1: // Stat
+		info, err := fd.Stat(myccanon.AsString(req.Unwrap()))
+		if err != nil {
+			return err
+		}
+		resp = fsInfoTo(info)
+	case 2: // Read
+		data, err := fd.Read(myccanon.AsString(req.Unwrap()))
+		if err != nil {
+			return err
+		}
+		resp = newBytes(data)
+	case 3: // Write
+		x := req.Unwrap().(myc.Product)
+		data := x[1].(*myc.List).Array().(myc.ByteArray).AsBytes()
+		if err := fd.Write(myccanon.AsString(x[0]), data); err != nil {
+			return err
+		}
+		resp = myc.Product{}
+	default:
+		panic(req)
+	}
+	if err := resp.PullInto(ctx, s, stores.Union{}); err != nil {
+		return err
+	}
+	respSum, err := DEV_FS_Resp.New(req.Tag(), resp)
+	if err != nil {
+		return err
+	}
+	data := myc.MarshalAppend(nil, respSum)
+	return bytesToWords(data, buf[:(len(data)+mvm1.WordBytes-1)/mvm1.WordBytes])
+}
+
+// fsPath turns a path from a pod into a path relative to the root.
+// Paths are always slash separated, and a leading slash refers to the root.
+func fsPath(p string) string {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		return "."
+	}
+	return filepath.FromSlash(p)
+}
+
+func fsInfoTo(info fs.FileInfo) myc.Product {
+	var isDir uint
+	if info.IsDir() {
+		isDir = 1
+	}
+	return myc.Product{
+		myc.NewString(info.Name()),
+		myc.NewB64(info.Size()),
+		myc.NewBit(isDir),
+		encodeTAI64N(tai64.FromGoTime(info.ModTime())),
+	}
+}
+
+func newBytes(data []byte) *myc.List {
+	return myc.NewString(string(data))
+}
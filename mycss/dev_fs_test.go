@@ -0,0 +1,111 @@
+package mycss
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/myccanon"
+	"myceliumweb.org/mycelium/mycexpr"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+func TestFS(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+	reset(t, p, s, myccanon.Namespace{}, PodConfig{
+		Devices: map[string]DeviceSpec{
+			"fs": DevFS(FSSpec{Root: dir}),
+		},
+	})
+
+	out := eval(t, p, s, func(eb EB) *Expr {
+		return FSReadExpr(GetFS(eb.P(0), "fs"), "a.txt")
+	})
+	require.Equal(t, "hello", myccanon.AsString(out))
+
+	eval(t, p, s, func(eb EB) *Expr {
+		return FSWriteExpr(GetFS(eb.P(0), "fs"), "/sub/b.txt", []byte("world"))
+	})
+	data, err := os.ReadFile(filepath.Join(dir, "sub", "b.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "world", string(data))
+
+	out = eval(t, p, s, func(eb EB) *Expr {
+		return FSStatExpr(GetFS(eb.P(0), "fs"), "sub/b.txt")
+	})
+	info := out.(myc.Product)
+	require.Equal(t, "b.txt", myccanon.AsString(info[0]))
+	require.Equal(t, myc.NewB64(5), info[1])
+	require.Equal(t, myc.NewBit(0), info[2])
+
+	// a write which fails leaves no temporary file behind.
+	fd := newFS(FSSpec{Root: dir})
+	require.Error(t, fd.Write("sub", []byte("not a directory")))
+	require.NoError(t, fd.Write("a.txt", []byte("hi")))
+	data, err = os.ReadFile(filepath.Join(dir, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "hi", string(data))
+
+	out = eval(t, p, s, func(eb EB) *Expr {
+		return FSListExpr(GetFS(eb.P(0), "fs"), "/")
+	})
+	ents := out.(*myc.List)
+	require.Equal(t, 2, ents.Len())
+	require.Equal(t, "a.txt", myccanon.AsString(ents.Get(0).(myc.Product)[0]))
+	require.Equal(t, myc.NewBit(1), ents.Get(1).(myc.Product)[2])
+}
+
+func TestFSSandbox(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "root")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(parent, "secret.txt"), []byte("secret"), 0o644))
+	require.NoError(t, os.Symlink(filepath.Join(parent, "secret.txt"), filepath.Join(dir, "link.txt")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "big.txt"), make([]byte, 100), 0o644))
+	reset(t, p, s, myccanon.Namespace{}, PodConfig{
+		Devices: map[string]DeviceSpec{
+			"fs": DevFS(FSSpec{Root: dir, ReadOnly: true, MaxFileBytes: 10}),
+		},
+	})
+	tryEval := func(fn func(eb EB) *Expr) error {
+		_, err := Eval(ctx, p, s, s, func(env myc.Value) *myc.Lazy {
+			laz, err := mycexpr.BuildLazy(myc.Bottom(), func(eb EB) *Expr {
+				return eb.LetVal(env, fn)
+			})
+			require.NoError(t, err)
+			return laz
+		})
+		return err
+	}
+	for _, p := range []string{"../secret.txt", "link.txt", "big.txt"} {
+		err := tryEval(func(eb EB) *Expr {
+			return FSReadExpr(GetFS(eb.P(0), "fs"), p)
+		})
+		require.Error(t, err, p)
+	}
+	err = tryEval(func(eb EB) *Expr {
+		return FSWriteExpr(GetFS(eb.P(0), "fs"), "new.txt", []byte("x"))
+	})
+	require.ErrorIs(t, err, ErrFSReadOnly)
+	require.NoFileExists(t, filepath.Join(dir, "new.txt"))
+
+	// the root must be absolute.
+	require.Error(t, p.Reset(ctx, s, myccanon.Namespace{}, PodConfig{
+		Devices: map[string]DeviceSpec{"fs": DevFS(FSSpec{Root: "relative"})},
+	}))
+}
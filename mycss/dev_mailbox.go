@@ -0,0 +1,243 @@
+package mycss
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+
+	"myceliumweb.org/mycelium/internal/bitbuf"
+	"myceliumweb.org/mycelium/internal/cadata"
+	"myceliumweb.org/mycelium/mvm1"
+	"myceliumweb.org/mycelium/myccanon"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/mycss/internal/dbutil"
+	"myceliumweb.org/mycelium/spec"
+)
+
+var (
+	// DEV_MAILBOX_Msg is a message between pods, containing the pod ID to/from and a payload
+	DEV_MAILBOX_Msg = myc.ProductType{
+		// PodID
+		myc.B64Type(),
+		// Payload
+		myc.AnyValueType{},
+	}
+	DEV_MAILBOX_Type = myc.NewPortType(
+		DEV_MAILBOX_Msg, // send a message to another pod
+		DEV_MAILBOX_Msg, // wait for a message from another pod
+		myc.Bottom(),    // never request
+		myc.Bottom(),    // never respond
+	)
+)
+
+// MailboxSpec configures a pod's mailbox, and which pods it can exchange messages with.
+// Pods are addressed by PodID; pods in a System do not have names, so there is nothing else to resolve.
+type MailboxSpec struct {
+	// SendTo is the list of pods that messages can be sent to.
+	SendTo []PodID `json:",omitempty"`
+	// AcceptFrom is the list of pods that messages are accepted from.
+	AcceptFrom []PodID `json:",omitempty"`
+}
+
+// ErrMailboxDenied is returned when a message is sent to or from a pod which is not allowed by a MailboxSpec.
+type ErrMailboxDenied struct {
+	From, To PodID
+}
+
+func (e ErrMailboxDenied) Error() string {
+	return fmt.Sprintf("mailbox: pod %d is not allowed to send to pod %d", e.From, e.To)
+}
+
+func GetMailbox(ns *Expr, k string) *Expr {
+	eb := EB{}
+	return eb.AnyValueTo(
+		myccanon.NSGetExpr(ns, k),
+		DEV_MAILBOX_Type,
+	)
+}
+
+// MailboxSendExpr sends payload, which must evaluate to an AnyValue, to the pod dst.
+func MailboxSendExpr(mb *Expr, dst PodID, payload *Expr) *Expr {
+	eb := EB{}
+	return eb.Output(mb, eb.Product(eb.B64(uint64(dst)), payload))
+}
+
+// MailboxRecvExpr blocks until there is a message in the pod's mailbox,
+// and evaluates to the sending pod's ID and the payload.
+func MailboxRecvExpr(mb *Expr) *Expr {
+	eb := EB{}
+	return eb.Input(mb)
+}
+
+// mailboxDev lets a process send messages to the other pods in the System, and receive messages from its pod's inbox.
+// Messages are queued in the database until they are received, so they are not lost if the receiving pod is not running.
+type mailboxDev struct {
+	p    *Pod
+	pid  ProcID
+	spec MailboxSpec
+}
+
+func newMailbox(p *Pod, procID ProcID, spec MailboxSpec) *mailboxDev {
+	return &mailboxDev{p: p, pid: procID, spec: spec}
+}
+
+// Send delivers the payload to the mailbox of the pod dst, pulling it from src into dst's store.
+func (mb *mailboxDev) Send(ctx context.Context, src cadata.Getter, dst PodID, payload *myc.AnyValue) error {
+	if !slices.Contains(mb.spec.SendTo, dst) {
+		return ErrMailboxDenied{From: mb.p.id, To: dst}
+	}
+	dstPod, err := mb.p.env.Pods(ctx, dst)
+	if err != nil {
+		return err
+	}
+	return dstPod.deliver(ctx, mb.p.id, src, payload)
+}
+
+// Recv blocks until there is a message in the inbox, and then removes it.
+// The payload is pulled into dst.
+func (mb *mailboxDev) Recv(ctx context.Context, dst cadata.PostExister) (PodID, *myc.AnyValue, error) {
+	p := mb.p
+	done := p.procDone(mb.pid)
+	for {
+		// get the channel before checking the database, so a delivery in between is not missed.
+		notify := p.inboxNotify()
+		var row struct {
+			ID      int64  `db:"id"`
+			FromPod PodID  `db:"from_pod"`
+			Root    []byte `db:"root"`
+		}
+		err := dbutil.DoTx(ctx, p.env.DB, func(tx *sqlx.Tx) error {
+			if err := p.checkProcAlive(tx, mb.pid); err != nil {
+				return ErrProcStopped
+			}
+			return tx.GetContext(ctx, &row, `SELECT id, from_pod, root FROM pod_mailbox
+				WHERE pod_id = ?
+				ORDER BY id LIMIT 1`, p.id)
+		})
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			select {
+			case <-ctx.Done():
+				return 0, nil, ctx.Err()
+			case <-done:
+				return 0, nil, ErrProcStopped
+			case <-notify:
+			}
+			continue
+		case err != nil:
+			return 0, nil, err
+		}
+
+		src := p.newStore()
+		av, err := myc.LoadRoot(ctx, src, row.Root)
+		if err != nil {
+			return 0, nil, err
+		}
+		if err := av.PullInto(ctx, dst, src); err != nil {
+			return 0, nil, err
+		}
+		// another process may have received the message first.
+		res, err := p.env.DB.ExecContext(ctx, `DELETE FROM pod_mailbox WHERE id = ?`, row.ID)
+		if err != nil {
+			return 0, nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return 0, nil, err
+		} else if n == 0 {
+			continue
+		}
+		return row.FromPod, av, nil
+	}
+}
+
+func (mb *mailboxDev) PortType() *myc.PortType {
+	return DEV_MAILBOX_Type
+}
+
+func (mb *mailboxDev) Port() mvm1.PortBackend {
+	return mvm1.PortBackend{
+		Output: func(ctx context.Context, s cadata.Getter, buf []mvm1.Word) error {
+			msg := DEV_MAILBOX_Msg.Zero().(myc.Product)
+			load := func(ref myc.Ref) (myc.Value, error) {
+				return myc.Load(ctx, s, ref)
+			}
+			if err := msg.Decode(bitbuf.FromBytes(wordsToBytes(buf)).Slice(0, DEV_MAILBOX_Msg.SizeOf()), load); err != nil {
+				return err
+			}
+			return mb.Send(ctx, s, PodID(*msg[0].(*myc.B64)), msg[1].(*myc.AnyValue))
+		},
+		Input: func(ctx context.Context, s cadata.PostExister, buf []mvm1.Word) error {
+			from, av, err := mb.Recv(ctx, s)
+			if err != nil {
+				return err
+			}
+			msg := myc.Product{myc.NewB64(from), av}
+			return bytesToWords(myc.MarshalAppend(nil, msg), buf[:(64+spec.AnyValueBits)/mvm1.WordBits])
+		},
+	}
+}
+
+// deliver adds a message from the pod from to the Pod's inbox.
+func (p *Pod) deliver(ctx context.Context, from PodID, src cadata.Getter, payload *myc.AnyValue) error {
+	cfg := p.config()
+	mbs := cfg.getMailbox()
+	if mbs == nil || !slices.Contains(mbs.AcceptFrom, from) {
+		return ErrMailboxDenied{From: from, To: p.id}
+	}
+	if err := dbutil.DoTx(ctx, p.env.DB, func(tx *sqlx.Tx) error {
+		dst := p.newTxStore(tx)
+		if err := payload.PullInto(ctx, dst, src); err != nil {
+			return err
+		}
+		root, err := myc.SaveRoot(ctx, dst, payload)
+		if err != nil {
+			return err
+		}
+		if err := p.gcBarrier(ctx, dst, payload); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO pod_mailbox (pod_id, from_pod, root) VALUES (?, ?, ?)`, p.id, from, root)
+		return err
+	}); err != nil {
+		return err
+	}
+	p.inboxMu.Lock()
+	defer p.inboxMu.Unlock()
+	if p.inboxCh != nil {
+		close(p.inboxCh)
+		p.inboxCh = nil
+	}
+	return nil
+}
+
+// inboxNotify returns a channel which is closed when the next message is delivered to the Pod.
+func (p *Pod) inboxNotify() <-chan struct{} {
+	p.inboxMu.Lock()
+	defer p.inboxMu.Unlock()
+	if p.inboxCh == nil {
+		p.inboxCh = make(chan struct{})
+	}
+	return p.inboxCh
+}
+
+// InboxLen returns the number of messages waiting in the Pod's mailbox.
+func (p *Pod) InboxLen(ctx context.Context) (int, error) {
+	var n int
+	if err := p.env.DB.GetContext(ctx, &n, `SELECT count(*) FROM pod_mailbox WHERE pod_id = ?`, p.id); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// inboxRoots returns the roots of the messages waiting in the Pod's mailbox.
+func (p *Pod) inboxRoots(ctx context.Context, r dbutil.Reader) ([][]byte, error) {
+	var roots [][]byte
+	if err := r.Select(&roots, `SELECT root FROM pod_mailbox WHERE pod_id = ?`, p.id); err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
@@ -0,0 +1,113 @@
+package mycss
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/myccanon"
+	"myceliumweb.org/mycelium/mycexpr"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+func TestMailbox(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	a, err := sys.Create(ctx)
+	require.NoError(t, err)
+	b, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	reset(t, a, s, myccanon.Namespace{}, PodConfig{
+		Devices: map[string]DeviceSpec{
+			"mailbox": DevMailbox(MailboxSpec{SendTo: []PodID{b.ID()}}),
+		},
+	})
+	reset(t, b, s, myccanon.Namespace{}, PodConfig{
+		Devices: map[string]DeviceSpec{
+			"mailbox": DevMailbox(MailboxSpec{AcceptFrom: []PodID{a.ID()}}),
+		},
+	})
+
+	// the message is queued until b receives it.
+	payload := myc.NewAnyValue(myc.NewString("hello"))
+	eval(t, a, s, func(eb EB) *Expr {
+		return MailboxSendExpr(GetMailbox(eb.P(0), "mailbox"), b.ID(), eb.Lit(payload))
+	})
+	n, err := b.InboxLen(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	// the payload is kept by GC while it is waiting.
+	_, err = b.GC(ctx)
+	require.NoError(t, err)
+
+	out := eval(t, b, s, func(eb EB) *Expr {
+		return MailboxRecvExpr(GetMailbox(eb.P(0), "mailbox"))
+	})
+	msg := out.(myc.Product)
+	require.Equal(t, myc.NewB64(a.ID()), msg[0])
+	require.True(t, myc.Equal(payload, msg[1]))
+	n, err = b.InboxLen(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// b blocks until a message arrives.
+	done := make(chan myc.Value, 1)
+	go func() {
+		done <- eval(t, b, s, func(eb EB) *Expr {
+			return MailboxRecvExpr(GetMailbox(eb.P(0), "mailbox"))
+		})
+	}()
+	require.Eventually(t, func() bool { return b.ProcCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+	eval(t, a, s, func(eb EB) *Expr {
+		return MailboxSendExpr(GetMailbox(eb.P(0), "mailbox"), b.ID(), eb.Lit(payload))
+	})
+	select {
+	case out := <-done:
+		require.True(t, myc.Equal(payload, out.(myc.Product)[1]))
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not received")
+	}
+}
+
+func TestMailboxDenied(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	a, err := sys.Create(ctx)
+	require.NoError(t, err)
+	b, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	for _, p := range []*Pod{a, b} {
+		reset(t, p, s, myccanon.Namespace{}, PodConfig{
+			Devices: map[string]DeviceSpec{
+				"mailbox": DevMailbox(MailboxSpec{SendTo: []PodID{a.ID(), b.ID()}}),
+			},
+		})
+	}
+	send := func(from, to *Pod) error {
+		_, err := Eval(ctx, from, s, s, func(env myc.Value) *myc.Lazy {
+			laz, err := mycexpr.BuildLazy(myc.Bottom(), func(eb EB) *Expr {
+				return eb.LetVal(env, func(eb EB) *Expr {
+					return MailboxSendExpr(GetMailbox(eb.P(0), "mailbox"), to.ID(), eb.Lit(myc.NewAnyValue(myc.NewB32(1))))
+				})
+			})
+			require.NoError(t, err)
+			return laz
+		})
+		return err
+	}
+	// b does not accept messages from a.
+	require.ErrorAs(t, send(a, b), &ErrMailboxDenied{})
+
+	reset(t, b, s, myccanon.Namespace{}, PodConfig{
+		Devices: map[string]DeviceSpec{
+			"mailbox": DevMailbox(MailboxSpec{AcceptFrom: []PodID{a.ID()}}),
+		},
+	})
+	require.NoError(t, send(a, b))
+	// b is not allowed to send to a.
+	require.ErrorAs(t, send(b, a), &ErrMailboxDenied{})
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"net"
 	"net/netip"
+	"sync"
+	"time"
 
 	"github.com/cloudflare/circl/sign"
 	"github.com/cloudflare/circl/sign/ed25519"
@@ -50,6 +52,12 @@ var (
 		// Payload
 		myc.AnyValueType{},
 	}
+	// DEV_NET_IncomingAsk is an ask from another node, containing a handle to reply with, and the message.
+	DEV_NET_IncomingAsk = myc.ProductType{
+		// Reply handle
+		myc.B64Type(),
+		DEV_NET_Message,
+	}
 	DEV_NET_NodeReq = myc.SumType{
 		// Receive
 		myc.ProductType{},
@@ -59,6 +67,12 @@ var (
 		myc.AnyValueType{},
 		// Verify
 		myc.ProductType{myc.AnyValueType{}, myc.AnyValueType{}},
+		// Ask
+		DEV_NET_Message,
+		// AcceptAsk
+		myc.ProductType{},
+		// Reply, with the handle from an IncomingAsk
+		myc.ProductType{myc.B64Type(), myc.AnyValueType{}},
 	}
 	DEV_NET_NodeResp = myc.SumType{
 		// Recv
@@ -69,9 +83,21 @@ var (
 		mycpki.SigType(),
 		// Verify
 		myc.BitType{},
+		// Ask, the reply
+		myc.AnyValueType{},
+		// AcceptAsk
+		DEV_NET_IncomingAsk,
+		// Reply
+		myc.ProductType{},
 	}
 )
 
+// DefaultAskTimeout is how long a network device waits for the reply to an ask, if its NetworkSpec does not say.
+const DefaultAskTimeout = 30 * time.Second
+
+// ErrAskTimeout is returned when an ask is not replied to in time.
+var ErrAskTimeout = errors.New("network: timed out waiting for reply")
+
 func GetNetwork(ns *Expr, k string) *Expr {
 	return EB{}.AnyValueTo(
 		myccanon.NSGetExpr(ns, k),
@@ -106,17 +132,77 @@ func ReceiveExpr(nodeSvc *Expr) *Expr {
 	return eb.Interact(nodeSvc, eb.Lit(req))
 }
 
+// AskExpr sends payload to dst, and waits for the reply.
+func AskExpr(nodeSvc *Expr, dst myc.Product, payload *myc.AnyValue) *Expr {
+	eb := EB{}
+	req, err := DEV_NET_NodeReq.New(4, myc.Product{dst, payload})
+	if err != nil {
+		panic(err)
+	}
+	return eb.Interact(nodeSvc, eb.Lit(req))
+}
+
+// AcceptAskExpr waits for an ask from another node.
+// The ask must be answered with ReplyExpr, using the handle in the response.
+func AcceptAskExpr(nodeSvc *Expr) *Expr {
+	eb := EB{}
+	req, err := DEV_NET_NodeReq.New(5, myc.Product{})
+	if err != nil {
+		panic(err)
+	}
+	return eb.Interact(nodeSvc, eb.Lit(req))
+}
+
+// ReplyExpr answers the ask with the reply handle handle, which must evaluate to a B64, with payload, which must evaluate to an AnyValue.
+func ReplyExpr(nodeSvc *Expr, handle, payload *Expr) *Expr {
+	eb := EB{}
+	return eb.Interact(nodeSvc, eb.MakeSum(eb.Lit(DEV_NET_NodeReq), 6, eb.Product(handle, payload)))
+}
+
 type nodeDev struct {
-	bgCtx context.Context
-	cf    context.CancelFunc
-	qt    *mycnet.QUICTransport
-	host  *mycnet.Host[netip.AddrPort]
-	ab    *AddressBook
+	bgCtx      context.Context
+	cf         context.CancelFunc
+	qt         *mycnet.QUICTransport
+	host       *mycnet.Host[netip.AddrPort]
+	ab         *AddressBook
+	lim        mycnet.Limits
+	askTimeout time.Duration
 
-	incomingTells chan myc.Product
+	incomingTells chan incomingMsg
+	incomingAsks  chan *incomingAsk
+
+	mu        sync.Mutex
+	nextReply uint64
+	// pending holds the asks which have been accepted but not replied to, by reply handle.
+	pending map[uint64]*incomingAsk
+}
+
+// incomingMsg is a message from another node, which has been checked against the device's Limits.
+type incomingMsg struct {
+	from mycnet.QUICAddr
+	av   *myc.AnyValue
+	src  cadata.Getter
 }
 
-func newNetworkNode(bgCtx context.Context, s cadata.Store, loc *AddressBook, secret *[32]byte, i uint32) (*nodeDev, error) {
+// pullInto returns the message as a DEV_NET_Message, with the payload pulled into dst.
+func (m incomingMsg) pullInto(ctx context.Context, dst cadata.PostExister) (myc.Product, error) {
+	if err := m.av.PullInto(ctx, dst, m.src); err != nil {
+		return nil, err
+	}
+	return myc.Product{addrTo(m.from), m.av}, nil
+}
+
+type incomingAsk struct {
+	incomingMsg
+	reply chan mycnet.Artifact
+	// handle is set when the ask is accepted.
+	handle uint64
+	// timedOut is set when handleAsk gives up on the ask.
+	// It and handle are protected by the nodeDev's mu.
+	timedOut bool
+}
+
+func newNetworkNode(bgCtx context.Context, s cadata.Store, loc *AddressBook, secret *[32]byte, i uint32, spec NetworkSpec) (*nodeDev, error) {
 	_, privKey, err := deriveEd25519(secret, uint64(i))
 	if err != nil {
 		return nil, err
@@ -135,21 +221,25 @@ func newNetworkNode(bgCtx context.Context, s cadata.Store, loc *AddressBook, sec
 		cf:            cf,
 		qt:            qt,
 		ab:            loc,
-		incomingTells: make(chan myc.Product, 1), // len must be > 0
+		lim:           spec.limits(),
+		askTimeout:    spec.askTimeout(),
+		incomingTells: make(chan incomingMsg, 1), // len must be > 0
+		incomingAsks:  make(chan *incomingAsk),
+		pending:       make(map[uint64]*incomingAsk),
 	}
 	nsvc.host = mycnet.NewHost(qt,
 		func(from mycnet.Addr[netip.AddrPort], msg mycnet.Artifact) error {
-			av, err := msg.Slurp(ctx, mycnet.Limits{})
+			av, err := msg.Slurp(ctx, nsvc.lim)
 			if err != nil {
 				return err
 			}
 			select {
-			case nsvc.incomingTells <- myc.Product{addrTo(from), av}:
+			case nsvc.incomingTells <- incomingMsg{from: from, av: av, src: msg.Store}:
 				return nil
 			default:
 				return errors.New("dropping tell")
 			}
-		}, nil)
+		}, nsvc.handleAsk)
 	go func() {
 		if err := nsvc.run(ctx); err != nil {
 			logctx.Error(ctx, "serving network node", zap.Error(err))
@@ -158,6 +248,38 @@ func newNetworkNode(bgCtx context.Context, s cadata.Store, loc *AddressBook, sec
 	return nsvc, nil
 }
 
+// handleAsk waits for a process to accept the ask and reply to it.
+// It gives up if that has not happened within the device's ask timeout.
+func (svc *nodeDev) handleAsk(from mycnet.QUICAddr, req mycnet.Artifact) (*mycnet.Artifact, error) {
+	ctx, cf := context.WithTimeout(svc.bgCtx, svc.askTimeout)
+	defer cf()
+	av, err := req.Slurp(ctx, svc.lim)
+	if err != nil {
+		return nil, err
+	}
+	ia := &incomingAsk{
+		incomingMsg: incomingMsg{from: from, av: av, src: req.Store},
+		reply:       make(chan mycnet.Artifact, 1),
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ErrAskTimeout
+	case svc.incomingAsks <- ia:
+	}
+	select {
+	case <-ctx.Done():
+		svc.mu.Lock()
+		ia.timedOut = true
+		if ia.handle != 0 {
+			delete(svc.pending, ia.handle)
+		}
+		svc.mu.Unlock()
+		return nil, ErrAskTimeout
+	case reply := <-ia.reply:
+		return &reply, nil
+	}
+}
+
 func (svc *nodeDev) run(ctx context.Context) error {
 	err := svc.host.Run(ctx)
 	if errors.Is(err, svc.bgCtx.Err()) {
@@ -189,13 +311,99 @@ func (svc *nodeDev) tell(ctx context.Context, s cadata.Getter, msg myc.Product)
 	return myc.Product{}, nil
 }
 
-func (svc *nodeDev) recv(ctx context.Context, _ cadata.PostExister, _ myc.Product) (myc.Product, error) {
+func (svc *nodeDev) recv(ctx context.Context, dst cadata.PostExister, _ myc.Product) (myc.Product, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	case msg := <-svc.incomingTells:
-		return msg, nil
+		return msg.pullInto(ctx, dst)
+	}
+}
+
+// ask sends an ask to another node, and returns the reply, pulled into dst.
+func (svc *nodeDev) ask(ctx context.Context, s cadata.Store, msg myc.Product) (*myc.AnyValue, error) {
+	raddr, err := addrFrom(msg[0])
+	if err != nil {
+		return nil, err
+	}
+	av, ok := msg[1].(*myc.AnyValue)
+	if !ok {
+		return nil, fmt.Errorf("can only ask AnyValue")
 	}
+	ctx, cf := context.WithTimeout(ctx, svc.askTimeout)
+	defer cf()
+	af := mycnet.Artifact{
+		Store: s,
+		Root:  mycbytes.AnyValue(mycmem.MarshalAppend(nil, av)),
+	}
+	resp, err := svc.host.AskAnyVal(ctx, raddr, af)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, ErrAskTimeout
+	} else if err != nil {
+		return nil, err
+	}
+	return resp.PullInto(ctx, s, svc.lim)
+}
+
+// acceptAsk waits for an ask from another node, and returns it with a handle to reply with.
+func (svc *nodeDev) acceptAsk(ctx context.Context, dst cadata.PostExister) (myc.Product, error) {
+	for {
+		var ia *incomingAsk
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case ia = <-svc.incomingAsks:
+		}
+		handle, ok := svc.register(ia)
+		if !ok {
+			continue
+		}
+		msg, err := ia.pullInto(ctx, dst)
+		if err != nil {
+			svc.mu.Lock()
+			delete(svc.pending, handle)
+			svc.mu.Unlock()
+			return nil, err
+		}
+		return myc.Product{myc.NewB64(handle), msg}, nil
+	}
+}
+
+// register gives ia a reply handle, and adds it to the pending asks.
+// It returns false if handleAsk has already given up on ia.
+func (svc *nodeDev) register(ia *incomingAsk) (uint64, bool) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if ia.timedOut {
+		return 0, false
+	}
+	svc.nextReply++
+	ia.handle = svc.nextReply
+	svc.pending[ia.handle] = ia
+	return ia.handle, true
+}
+
+// reply answers the ask with the handle in x, with the payload in x.
+func (svc *nodeDev) reply(ctx context.Context, src cadata.Getter, x myc.Product) (myc.Product, error) {
+	handle := uint64(*x[0].(*myc.B64))
+	av := x[1].(*myc.AnyValue)
+	svc.mu.Lock()
+	ia, exists := svc.pending[handle]
+	delete(svc.pending, handle)
+	svc.mu.Unlock()
+	if !exists {
+		return nil, fmt.Errorf("network: no pending ask with reply handle %d, it may have timed out", handle)
+	}
+	// copy the reply out of the process's store, which may be gone by the time it is pulled.
+	s := stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes)
+	if err := av.PullInto(ctx, s, src); err != nil {
+		return nil, err
+	}
+	ia.reply <- mycnet.Artifact{
+		Store: s,
+		Root:  mycbytes.AnyValue(mycmem.MarshalAppend(nil, av)),
+	}
+	return myc.Product{}, nil
 }
 
 func (svc *nodeDev) sign(ctx context.Context, x *myc.AnyValue) (myc.Product, error) {
@@ -251,6 +459,12 @@ func (svc *nodeDev) portInteract(ctx context.Context, s cadata.Store, buf []mvm1
 		resp, err = svc.sign(ctx, req.Unwrap().(*myc.AnyValue))
 	case 3: // Verify
 		resp, err = svc.verify(ctx, req.Unwrap().(myc.Product))
+	case 4: // Ask
+		resp, err = svc.ask(ctx, s, req.Unwrap().(myc.Product))
+	case 5: // AcceptAsk
+		resp, err = svc.acceptAsk(ctx, s)
+	case 6: // Reply
+		resp, err = svc.reply(ctx, s, req.Unwrap().(myc.Product))
 	default:
 		panic(req)
 	}
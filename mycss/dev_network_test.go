@@ -0,0 +1,123 @@
+package mycss
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium/internal/stores"
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/myccanon"
+	"myceliumweb.org/mycelium/mycexpr"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/mycnet"
+)
+
+func TestNetworkAsk(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	a, err := sys.Create(ctx)
+	require.NoError(t, err)
+	b, err := sys.Create(ctx)
+	require.NoError(t, err)
+	sa, sb := testutil.NewStore(t), testutil.NewStore(t)
+	cfg := PodConfig{
+		Devices: map[string]DeviceSpec{"net0": DevNetwork(0)},
+	}
+	reset(t, a, sa, myccanon.Namespace{}, cfg)
+	reset(t, b, sb, myccanon.Namespace{}, cfg)
+	addrB := eval(t, b, sb, func(eb EB) *Expr {
+		return LocalAddrExpr(GetNetwork(eb.P(0), "net0"))
+	})
+
+	// b replies to one ask with the payload it was sent.
+	served := make(chan myc.Value, 1)
+	go func() {
+		served <- eval(t, b, sb, func(eb EB) *Expr {
+			return eb.Let(eb.Field(AcceptAskExpr(GetNetwork(eb.P(0), "net0")), 5), func(eb EB) *Expr {
+				return ReplyExpr(GetNetwork(eb.P(1), "net0"), eb.Field(eb.P(0), 0), eb.Field(eb.Field(eb.P(0), 1), 1))
+			})
+		})
+	}()
+	payload := myc.NewAnyValue(myc.NewString("ping"))
+	require.NoError(t, payload.PullInto(ctx, sa, stores.Union{}))
+	out := eval(t, a, sa, func(eb EB) *Expr {
+		return eb.Field(AskExpr(GetNetwork(eb.P(0), "net0"), addrB.(myc.Product), payload), 4)
+	})
+	require.True(t, myc.Equal(payload, out))
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case <-served:
+	}
+}
+
+func TestNetworkAskTimeout(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	a, err := sys.Create(ctx)
+	require.NoError(t, err)
+	b, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	cfg := PodConfig{
+		Devices: map[string]DeviceSpec{
+			"net0": {Network: &NetworkSpec{AskTimeout: 100 * time.Millisecond}},
+		},
+	}
+	reset(t, a, s, myccanon.Namespace{}, cfg)
+	reset(t, b, s, myccanon.Namespace{}, cfg)
+	addrB := eval(t, b, s, func(eb EB) *Expr {
+		return LocalAddrExpr(GetNetwork(eb.P(0), "net0"))
+	})
+
+	// nothing accepts the ask on b.
+	start := time.Now()
+	_, err = Eval(ctx, a, s, s, func(env myc.Value) *myc.Lazy {
+		laz, err := mycexpr.BuildLazy(myc.Bottom(), func(eb mycexpr.EB) *mycexpr.Expr {
+			return eb.LetVal(env, func(eb EB) *Expr {
+				return AskExpr(GetNetwork(eb.P(0), "net0"), addrB.(myc.Product), myc.NewAnyValue(myc.NewB32(1)))
+			})
+		})
+		require.NoError(t, err)
+		return laz
+	})
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+// TestNetworkAskTimeoutRace checks that an ask which times out after it is received by acceptAsk,
+// but before it is given a reply handle, is not left pending.
+func TestNetworkAskTimeoutRace(t *testing.T) {
+	ctx := testutil.Context(t)
+	svc := &nodeDev{
+		bgCtx:        ctx,
+		askTimeout:   10 * time.Millisecond,
+		incomingAsks: make(chan *incomingAsk),
+		pending:      make(map[uint64]*incomingAsk),
+	}
+	errs := make(chan error, 1)
+	go func() {
+		_, err := svc.handleAsk(mycnet.QUICAddr{}, mycnet.ArtifactFromMemory(myc.NewAnyValue(myc.NewB32(1))))
+		errs <- err
+	}()
+	ia := <-svc.incomingAsks
+	require.ErrorIs(t, <-errs, ErrAskTimeout)
+	_, ok := svc.register(ia)
+	require.False(t, ok)
+	require.Empty(t, svc.pending)
+
+	// an ask which times out after it is registered is removed from pending.
+	go func() {
+		_, err := svc.handleAsk(mycnet.QUICAddr{}, mycnet.ArtifactFromMemory(myc.NewAnyValue(myc.NewB32(2))))
+		errs <- err
+	}()
+	ia = <-svc.incomingAsks
+	_, ok = svc.register(ia)
+	require.True(t, ok)
+	require.ErrorIs(t, <-errs, ErrAskTimeout)
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	require.Empty(t, svc.pending)
+}
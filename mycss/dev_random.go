@@ -2,8 +2,8 @@ package mycss
 
 import (
 	"context"
-	"crypto/rand"
 	"fmt"
+	"io"
 
 	"myceliumweb.org/mycelium"
 
@@ -35,7 +35,9 @@ func GenRandomExpr(dev *Expr, n uint32) *Expr {
 	return eb.Interact(dev, eb.B32(n))
 }
 
-type randomDev struct{}
+type randomDev struct {
+	entropy io.Reader
+}
 
 func (rs randomDev) portInteract(ctx context.Context, s cadata.Store, buf []mvm1.Word) error {
 	size := int(buf[0])
@@ -48,7 +50,7 @@ func (rs randomDev) portInteract(ctx context.Context, s cadata.Store, buf []mvm1
 		return fmt.Errorf("random: currently only multiples of 8 are supported")
 	}
 	randData := make([]byte, byteSize)
-	if _, err := rand.Read(randData); err != nil {
+	if _, err := io.ReadFull(rs.entropy, randData); err != nil {
 		return err
 	}
 	// we manually craft a list here to avoid manifesting individual bits
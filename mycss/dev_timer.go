@@ -0,0 +1,214 @@
+package mycss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.brendoncarroll.net/tai64"
+
+	"myceliumweb.org/mycelium/internal/bitbuf"
+	"myceliumweb.org/mycelium/internal/cadata"
+	"myceliumweb.org/mycelium/mvm1"
+	"myceliumweb.org/mycelium/myccanon"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/mycss/internal/dbutil"
+)
+
+var (
+	DEV_TIMER_Req = myc.SumType{
+		// SleepUntil, a deadline
+		TAI64N,
+		// SleepFor, a duration in nanoseconds
+		myc.B64Type(),
+	}
+	DEV_TIMER_Type = myc.NewPortType(
+		myc.B64Type(), // set the ticker period in nanoseconds, 0 stops the ticker
+		TAI64N,        // wait for the next tick
+		DEV_TIMER_Req,
+		TAI64N, // the time when the sleep ended
+	)
+)
+
+// ErrProcStopped is returned by a device which was blocked when its process was stopped.
+var ErrProcStopped = errors.New("process has been stopped")
+
+func GetTimer(ns *Expr, k string) *Expr {
+	eb := EB{}
+	return eb.AnyValueTo(
+		myccanon.NSGetExpr(ns, k),
+		DEV_TIMER_Type,
+	)
+}
+
+// SleepUntilExpr blocks until deadline has passed, and evaluates to the current time.
+func SleepUntilExpr(timer *Expr, deadline time.Time) *Expr {
+	eb := EB{}
+	req, err := DEV_TIMER_Req.New(0, encodeTAI64N(tai64.FromGoTime(deadline)))
+	if err != nil {
+		panic(err)
+	}
+	return eb.Interact(timer, eb.Lit(req))
+}
+
+// SleepForExpr blocks for d, and evaluates to the current time.
+func SleepForExpr(timer *Expr, d time.Duration) *Expr {
+	eb := EB{}
+	req, err := DEV_TIMER_Req.New(1, myc.NewB64(d))
+	if err != nil {
+		panic(err)
+	}
+	return eb.Interact(timer, eb.Lit(req))
+}
+
+// SetTickerExpr starts the timer's ticker with period, or stops it if period is 0.
+func SetTickerExpr(timer *Expr, period time.Duration) *Expr {
+	eb := EB{}
+	return eb.Output(timer, eb.B64(uint64(period)))
+}
+
+// TickExpr blocks until the timer's next tick, and evaluates to the time of the tick.
+func TickExpr(timer *Expr) *Expr {
+	eb := EB{}
+	return eb.Input(timer)
+}
+
+// timerDev lets a process sleep without busy waiting.
+// The ticker is part of the process's in-memory state, and is not restored when a process is resumed.
+type timerDev struct {
+	p   *Pod
+	pid ProcID
+
+	mu     sync.Mutex
+	period time.Duration
+	next   time.Time
+}
+
+func newTimer(p *Pod, procID ProcID) *timerDev {
+	return &timerDev{p: p, pid: procID}
+}
+
+// SleepUntil blocks until deadline, the process is stopped, or ctx is cancelled.
+func (td *timerDev) SleepUntil(ctx context.Context, deadline time.Time) (time.Time, error) {
+	clk := td.p.env.Clock
+	d := deadline.Sub(clk.Now())
+	if d <= 0 {
+		return clk.Now(), nil
+	}
+	// get the done channel before checking the database, so a stop in between is not missed.
+	done := td.p.procDone(td.pid)
+	if err := dbutil.DoTx(ctx, td.p.env.DB, func(tx *sqlx.Tx) error {
+		return td.p.checkProcAlive(tx, td.pid)
+	}); err != nil {
+		return time.Time{}, ErrProcStopped
+	}
+	wake, stop := clk.After(d)
+	defer stop()
+	select {
+	case <-ctx.Done():
+		return time.Time{}, ctx.Err()
+	case <-done:
+		return time.Time{}, ErrProcStopped
+	case now := <-wake:
+		return now, nil
+	}
+}
+
+// SetTicker sets the period of the ticker.
+// The first tick is one period from now.  If period is 0, the ticker is stopped.
+func (td *timerDev) SetTicker(period time.Duration) error {
+	if period < 0 {
+		return fmt.Errorf("timer: negative ticker period %v", period)
+	}
+	td.mu.Lock()
+	defer td.mu.Unlock()
+	td.period = period
+	td.next = td.p.env.Clock.Now().Add(period)
+	return nil
+}
+
+// Tick blocks until the next tick of the ticker.
+// Like time.Ticker, ticks which are missed because the process was busy are dropped.
+func (td *timerDev) Tick(ctx context.Context) (time.Time, error) {
+	td.mu.Lock()
+	period, next := td.period, td.next
+	if period == 0 {
+		td.mu.Unlock()
+		return time.Time{}, fmt.Errorf("timer: ticker is not running")
+	}
+	if now := td.p.env.Clock.Now(); !next.After(now) {
+		// skip over any missed ticks
+		next = next.Add(now.Sub(next).Truncate(period) + period)
+	}
+	td.next = next
+	td.mu.Unlock()
+	if _, err := td.SleepUntil(ctx, next); err != nil {
+		return time.Time{}, err
+	}
+	return next, nil
+}
+
+func (td *timerDev) PortType() *myc.PortType {
+	return DEV_TIMER_Type
+}
+
+func (td *timerDev) Port() mvm1.PortBackend {
+	return mvm1.PortBackend{
+		Output: func(ctx context.Context, _ cadata.Getter, buf []mvm1.Word) error {
+			period := bitbuf.FromBytes(wordsToBytes(buf)).Get64(0)
+			return td.SetTicker(time.Duration(period))
+		},
+		Input: func(ctx context.Context, _ cadata.PostExister, buf []mvm1.Word) error {
+			t, err := td.Tick(ctx)
+			if err != nil {
+				return err
+			}
+			return bytesToWords(myc.MarshalAppend(nil, encodeTAI64N(tai64.FromGoTime(t))), buf[:TAI64N.SizeOf()/mvm1.WordBits])
+		},
+		Interact: func(ctx context.Context, s cadata.Store, buf []mvm1.Word) error {
+			req := DEV_TIMER_Req.Zero().(*myc.Sum)
+			load := func(ref myc.Ref) (myc.Value, error) {
+				return myc.Load(ctx, s, ref)
+			}
+			if err := req.Decode(bitbuf.FromBytes(wordsToBytes(buf)).Slice(0, DEV_TIMER_Req.SizeOf()), load); err != nil {
+				return err
+			}
+			var deadline time.Time
+			switch req.Tag() {
+			case 0: // SleepUntil
+				deadline = decodeTAI64N(req.Unwrap()).GoTime()
+			case 1: // SleepFor
+				bb := bitbuf.New(64)
+				req.Unwrap().Encode(bb)
+				deadline = td.p.env.Clock.Now().Add(sleepDuration(bb.Get64(0)))
+			default:
+				panic(req)
+			}
+			now, err := td.SleepUntil(ctx, deadline)
+			if err != nil {
+				return err
+			}
+			return bytesToWords(myc.MarshalAppend(nil, encodeTAI64N(tai64.FromGoTime(now))), buf[:TAI64N.SizeOf()/mvm1.WordBits])
+		},
+	}
+}
+
+// sleepDuration converts a SleepFor duration in nanoseconds to a time.Duration.
+// Durations which do not fit in a time.Duration are saturated, so they sleep for as long as possible.
+func sleepDuration(ns uint64) time.Duration {
+	return time.Duration(min(ns, math.MaxInt64))
+}
+
+func encodeTAI64N(ts tai64.TAI64N) myc.Product {
+	return myc.Product{myc.NewB64(ts.Seconds), myc.NewB32(ts.Nanoseconds)}
+}
+
+func decodeTAI64N(x myc.Value) tai64.TAI64N {
+	bb := bitbuf.New(TAI64N.SizeOf())
+	x.Encode(bb)
+	return tai64.TAI64N{Seconds: bb.Get64(0), Nanoseconds: bb.Get32(64)}
+}
@@ -0,0 +1,135 @@
+package mycss
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/myccanon"
+	"myceliumweb.org/mycelium/mycexpr"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+func TestTimerSleep(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	reset(t, p, s, myccanon.Namespace{}, PodConfig{
+		Devices: map[string]DeviceSpec{
+			"timer0": DevTimer(),
+		},
+	})
+
+	start := time.Now()
+	out := eval(t, p, s, func(eb EB) *Expr {
+		return SleepForExpr(GetTimer(eb.P(0), "timer0"), 100*time.Millisecond)
+	})
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	woke := decodeTAI64N(out).GoTime()
+	require.False(t, woke.Before(start.Add(100*time.Millisecond)))
+
+	deadline := time.Now().Add(100 * time.Millisecond)
+	out = eval(t, p, s, func(eb EB) *Expr {
+		return SleepUntilExpr(GetTimer(eb.P(0), "timer0"), deadline)
+	})
+	require.False(t, decodeTAI64N(out).GoTime().Before(deadline))
+	require.False(t, time.Now().Before(deadline))
+}
+
+func TestTimerStop(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	cfg := PodConfig{
+		Devices: map[string]DeviceSpec{
+			"timer0": DevTimer(),
+		},
+	}
+	reset(t, p, s, myccanon.Namespace{}, cfg)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := Eval(ctx, p, s, s, func(env myc.Value) *myc.Lazy {
+			laz, err := mycexpr.BuildLazy(myc.Bottom(), func(eb EB) *Expr {
+				return eb.LetVal(env, func(eb EB) *Expr {
+					return SleepForExpr(GetTimer(eb.P(0), "timer0"), time.Hour)
+				})
+			})
+			if err != nil {
+				panic(err)
+			}
+			return laz
+		})
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return p.ProcCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+	// dropping the pod stops all its processes.
+	require.NoError(t, sys.Drop(ctx, p.ID()))
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, ErrProcStopped)
+	case <-time.After(5 * time.Second):
+		t.Fatal("sleep was not cancelled by process stop")
+	}
+}
+
+func TestTimerTicker(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	reset(t, p, s, myccanon.Namespace{}, PodConfig{
+		Devices: map[string]DeviceSpec{
+			"timer0": DevTimer(),
+		},
+	})
+
+	const period = 50 * time.Millisecond
+	out := eval(t, p, s, func(eb EB) *Expr {
+		timer := GetTimer(eb.P(0), "timer0")
+		return eb.Product(
+			SetTickerExpr(timer, period),
+			TickExpr(timer),
+			TickExpr(timer),
+			// miss some ticks
+			SleepForExpr(timer, 3*period+period/2),
+			TickExpr(timer),
+		)
+	})
+	ticks := out.(myc.Product)
+	t1 := decodeTAI64N(ticks[1]).GoTime()
+	t2 := decodeTAI64N(ticks[2]).GoTime()
+	t3 := decodeTAI64N(ticks[4]).GoTime()
+	// ticks stay aligned to the period, and missed ticks are dropped.
+	require.GreaterOrEqual(t, t2.Sub(t1), period)
+	require.Zero(t, t2.Sub(t1)%period)
+	require.GreaterOrEqual(t, t3.Sub(t2), 4*period)
+	require.Zero(t, t3.Sub(t2)%period)
+
+	// ticking without a ticker is an error
+	_, err = Eval(ctx, p, s, s, func(env myc.Value) *myc.Lazy {
+		laz, err := mycexpr.BuildLazy(myc.Bottom(), func(eb EB) *Expr {
+			return eb.LetVal(env, func(eb EB) *Expr {
+				return TickExpr(GetTimer(eb.P(0), "timer0"))
+			})
+		})
+		require.NoError(t, err)
+		return laz
+	})
+	require.Error(t, err)
+}
+
+func TestSleepDuration(t *testing.T) {
+	require.Equal(t, time.Second, sleepDuration(uint64(time.Second)))
+	require.Equal(t, time.Duration(math.MaxInt64), sleepDuration(math.MaxInt64))
+	require.Equal(t, time.Duration(math.MaxInt64), sleepDuration(math.MaxInt64+1))
+	require.Equal(t, time.Duration(math.MaxInt64), sleepDuration(math.MaxUint64))
+}
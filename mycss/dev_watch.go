@@ -0,0 +1,122 @@
+package mycss
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+
+	"myceliumweb.org/mycelium/internal/bitbuf"
+	"myceliumweb.org/mycelium/internal/cadata"
+	"myceliumweb.org/mycelium/mvm1"
+	"myceliumweb.org/mycelium/myccanon"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/mycss/internal/dbutil"
+	"myceliumweb.org/mycelium/spec"
+)
+
+var (
+	// DEV_WATCH_Req is a cell key, and the last value of the cell seen by the caller.
+	DEV_WATCH_Req = myc.ProductType{
+		myc.StringType(),
+		myc.AnyValueType{},
+	}
+	DEV_WATCH_Type = myc.NewPortType(
+		myc.Bottom(),
+		myc.Bottom(),
+		DEV_WATCH_Req,
+		myc.AnyValueType{}, // the current value of the cell
+	)
+)
+
+func GetWatch(env *Expr, k string) *Expr {
+	return EB{}.AnyValueTo(myccanon.NSGetExpr(env, k), DEV_WATCH_Type)
+}
+
+// WatchExpr blocks until the cell at k no longer holds prev, and evaluates to its current value.
+func WatchExpr(watch *Expr, k string, prev *Expr) *Expr {
+	eb := EB{}
+	return eb.Interact(watch, eb.Product(eb.Lit(myc.NewString(k)), prev))
+}
+
+// watchDev lets a process block until one of the pod's cells changes.
+type watchDev struct {
+	p   *Pod
+	pid ProcID
+}
+
+func newWatch(p *Pod, procID ProcID) *watchDev {
+	return &watchDev{p: p, pid: procID}
+}
+
+// Watch blocks until the cell at k holds a value other than prev, and then returns that value.
+// If the process is stopped while it is waiting, Watch returns ErrProcStopped.
+func (w *watchDev) Watch(ctx context.Context, k string, prev Value) (Value, error) {
+	cfg := w.p.config()
+	if !slices.Contains(cfg.getCells(), k) {
+		return nil, fmt.Errorf("watch: %q is not a cell", k)
+	}
+	ch := make(chan Notif, 1)
+	w.p.subscribe(w.pid, k, ch)
+	defer w.p.Unsubscribe(ch)
+	// get the done channel before checking the database, so a stop in between is not missed.
+	done := w.p.procDone(w.pid)
+	for {
+		var alive bool
+		current, err := dbutil.DoTx1(ctx, w.p.env.DB, func(tx *sqlx.Tx) (Value, error) {
+			if alive = w.p.checkProcAlive(tx, w.pid) == nil; !alive {
+				return nil, nil
+			}
+			return w.p.nsGet(tx, k)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !alive {
+			return nil, ErrProcStopped
+		}
+		if !myc.Equal(current, prev) {
+			return current, nil
+		}
+		for changed := false; !changed; {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-done:
+				return nil, ErrProcStopped
+			case n := <-ch:
+				changed = n.Key == k
+			}
+		}
+	}
+}
+
+func (w *watchDev) PortType() *myc.PortType {
+	return DEV_WATCH_Type
+}
+
+func (w *watchDev) Port() mvm1.PortBackend {
+	return mvm1.PortBackend{
+		Interact: func(ctx context.Context, s cadata.Store, buf []mvm1.Word) error {
+			req := DEV_WATCH_Req.Zero().(myc.Product)
+			load := func(ref myc.Ref) (myc.Value, error) {
+				return myc.Load(ctx, s, ref)
+			}
+			if err := req.Decode(bitbuf.FromBytes(wordsToBytes(buf)).Slice(0, DEV_WATCH_Req.SizeOf()), load); err != nil {
+				return err
+			}
+			k := myccanon.AsString(req[0])
+			prev := req[1].(*myc.AnyValue).Unwrap()
+			val, err := w.Watch(ctx, k, prev)
+			if err != nil {
+				return err
+			}
+			av := myc.NewAnyValue(val)
+			if err := av.PullInto(ctx, s, w.p.newStore()); err != nil {
+				return err
+			}
+			return bytesToWords(myc.MarshalAppend(nil, av), buf[:spec.AnyValueBits/mvm1.WordBits])
+		},
+	}
+}
@@ -1,6 +1,11 @@
 package mycss
 
-import "fmt"
+import (
+	"fmt"
+
+	"myceliumweb.org/mycelium/mvm1"
+	"myceliumweb.org/mycelium/mycss/internal/sqlstores"
+)
 
 type ErrPodNotFound struct {
 	PodID
@@ -9,3 +14,65 @@ type ErrPodNotFound struct {
 func (e ErrPodNotFound) Error() string {
 	return fmt.Sprintf("pod %d not found", e.PodID)
 }
+
+// ErrWrongKey is returned when a System's MasterKey cannot decrypt its pod secrets.
+type ErrWrongKey struct{}
+
+func (e ErrWrongKey) Error() string {
+	return "wrong master key"
+}
+
+// ErrArchiveKey is returned by Import when a pod's secret in an archive was sealed with a different MasterKey.
+// Secrets are exported sealed, so an archive can only be imported by a System with the exporting System's key.
+type ErrArchiveKey struct {
+	// PodID is the pod's ID in the archive.
+	PodID PodID
+}
+
+func (e ErrArchiveKey) Error() string {
+	return fmt.Sprintf("pod %d in the archive is sealed with a different master key. it must be imported with the key of the database it was exported from", e.PodID)
+}
+
+// ErrNoKey is returned when a System which requires a MasterKey has the zero key,
+// and its database does not already have pods sealed with the zero key.
+// It is also returned when Rekey is given the zero key.
+type ErrNoKey struct {
+	// Op is what the key is required for.
+	Op string
+}
+
+func (e ErrNoKey) Error() string {
+	return fmt.Sprintf("a master key is required to %s", e.Op)
+}
+
+// ErrStorageQuota is returned when a write would cause a Pod's store to contain
+// more than PodConfig.MaxStorageBytes
+type ErrStorageQuota = sqlstores.ErrQuotaExceeded
+
+// ErrMaxDepth is returned when a value written to a Pod has a greater ref depth
+// than PodConfig.MaxDepth
+type ErrMaxDepth struct {
+	PodID
+	Max int64
+}
+
+func (e ErrMaxDepth) Error() string {
+	return fmt.Sprintf("pod %d: value exceeds max depth of %d", e.PodID, e.Max)
+}
+
+// ErrProcLimit is returned when a process exceeds one of the limits in its Pod's config
+type ErrProcLimit struct {
+	PodID  PodID
+	ProcID ProcID
+	Limit  mvm1.Limit
+	// Steps is the number of steps the process had taken when it was stopped.
+	Steps uint64
+}
+
+func (e ErrProcLimit) Error() string {
+	return fmt.Sprintf("pod %d: process %d exceeded limit on %v after %d steps", e.PodID, e.ProcID, e.Limit, e.Steps)
+}
+
+func (e ErrProcLimit) Unwrap() error {
+	return mvm1.ErrLimitExceeded{Limit: e.Limit}
+}
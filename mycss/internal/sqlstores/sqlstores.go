@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"io"
 
 	"myceliumweb.org/mycelium/internal/cadata"
@@ -37,9 +38,29 @@ func Migration(x *migrations.State) *migrations.State {
 	) WITHOUT ROWID, STRICT;`)
 }
 
+// SizeMigration adds a size column to the stores table, which triggers keep equal to
+// the total number of bytes in the store's blobs, so StoreSize does not have to scan the store.
+func SizeMigration(x *migrations.State) *migrations.State {
+	return x.
+		ApplyStmt(`ALTER TABLE stores ADD COLUMN size INTEGER NOT NULL DEFAULT 0`).
+		ApplyStmt(`UPDATE stores SET size = (
+		SELECT coalesce(sum(length(blobs.data)), 0) FROM store_blobs
+		JOIN blobs ON blob_id = blobs.id
+		WHERE store_id = stores.id
+	)`).
+		ApplyStmt(`CREATE TRIGGER store_blobs_insert_size AFTER INSERT ON store_blobs BEGIN
+		UPDATE stores SET size = size + coalesce((SELECT length(data) FROM blobs WHERE id = NEW.blob_id), 0)
+		WHERE id = NEW.store_id;
+	END`).
+		ApplyStmt(`CREATE TRIGGER store_blobs_delete_size AFTER DELETE ON store_blobs BEGIN
+		UPDATE stores SET size = size - coalesce((SELECT length(data) FROM blobs WHERE id = OLD.blob_id), 0)
+		WHERE id = OLD.store_id;
+	END`)
+}
+
 // CreateStore allocates a new store ID which wil not be reused
 func CreateStore(tx *sqlx.Tx) (ret StoreID, err error) {
-	err = tx.Get(&ret, `INSERT INTO stores VALUES (NULL) RETURNING id`)
+	err = tx.Get(&ret, `INSERT INTO stores DEFAULT VALUES RETURNING id`)
 	return ret, err
 }
 
@@ -59,11 +80,25 @@ func DropStore(tx *sqlx.Tx, storeID StoreID) error {
 	return nil
 }
 
+// ErrQuotaExceeded is returned when adding a blob would cause a store to exceed its quota.
+type ErrQuotaExceeded struct {
+	Store StoreID
+	Quota int64
+	Size  int64
+}
+
+func (e ErrQuotaExceeded) Error() string {
+	return fmt.Sprintf("store %d would exceed quota of %d bytes (size=%d)", e.Store, e.Quota, e.Size)
+}
+
 type txStore struct {
 	tx      *sqlx.Tx
 	intID   StoreID
 	hf      cadata.HashFunc
 	maxSize int
+
+	// quota is the maximum number of bytes in the store. 0 means no quota.
+	quota int64
 }
 
 func NewTxStore(tx *sqlx.Tx, hf cadata.HashFunc, maxSize int, intID StoreID) *txStore {
@@ -75,11 +110,22 @@ func NewTxStore(tx *sqlx.Tx, hf cadata.HashFunc, maxSize int, intID StoreID) *tx
 	}
 }
 
+// WithQuota returns a copy of the store which will not allow more than quota bytes in the store.
+// A quota of 0 means no quota.
+func (s *txStore) WithQuota(quota int64) *txStore {
+	s2 := *s
+	s2.quota = quota
+	return &s2
+}
+
 func (s *txStore) Post(ctx context.Context, salt *cadata.ID, data []byte) (cadata.ID, error) {
 	if len(data) > s.MaxSize() {
 		return cadata.ID{}, cadata.ErrTooLarge
 	}
 	id := s.Hash(salt, data)
+	if err := s.checkQuota(ctx, &id, int64(len(data))); err != nil {
+		return cadata.ID{}, err
+	}
 	if _, err := s.tx.Exec(`INSERT INTO blobs (id, salt, data)
 		VALUES (?, ?, ?) ON CONFLICT DO NOTHING`, id[:], saltBytes(salt), data); err != nil {
 		return cadata.ID{}, err
@@ -90,6 +136,27 @@ func (s *txStore) Post(ctx context.Context, salt *cadata.ID, data []byte) (cadat
 	return id, nil
 }
 
+// checkQuota returns ErrQuotaExceeded if adding a blob of length n would put the store over quota.
+func (s *txStore) checkQuota(ctx context.Context, id *cadata.ID, n int64) error {
+	if s.quota <= 0 {
+		return nil
+	}
+	if yes, err := s.Exists(ctx, id); err != nil {
+		return err
+	} else if yes {
+		// blobs already in the store do not take up more space.
+		return nil
+	}
+	size, err := StoreSize(s.tx, s.intID)
+	if err != nil {
+		return err
+	}
+	if size+n > s.quota {
+		return ErrQuotaExceeded{Store: s.intID, Quota: s.quota, Size: size + n}
+	}
+	return nil
+}
+
 func (s *txStore) Get(ctx context.Context, id *cadata.ID, salt *cadata.ID, buf []byte) (int, error) {
 	var data []byte
 	if err := s.tx.Get(&data, `SELECT blobs.data FROM store_blobs JOIN blobs ON blob_id = blobs.id
@@ -112,6 +179,15 @@ func (s *txStore) Add(ctx context.Context, id *cadata.ID) error {
 		return err
 	}
 	if count > 0 {
+		if s.quota > 0 {
+			var n int64
+			if err := s.tx.Get(&n, `SELECT length(data) FROM blobs WHERE id = ?`, id[:]); err != nil {
+				return err
+			}
+			if err := s.checkQuota(ctx, id, n); err != nil {
+				return err
+			}
+		}
 		return s.add(*id)
 	} else {
 		return cadata.ErrNotFound{Key: id}
@@ -191,12 +267,21 @@ type store struct {
 	hf      cadata.HashFunc
 	maxSize int
 	intID   StoreID
+	quota   int64
 }
 
 func NewStore(db *sqlx.DB, hf cadata.HashFunc, maxSize int, intID StoreID) *store {
 	return &store{db: db, hf: hf, maxSize: maxSize, intID: intID}
 }
 
+// WithQuota returns a copy of the store which will not allow more than quota bytes in the store.
+// A quota of 0 means no quota.
+func (s *store) WithQuota(quota int64) *store {
+	s2 := *s
+	s2.quota = quota
+	return &s2
+}
+
 func (s *store) Post(ctx context.Context, salt *cadata.ID, data []byte) (cadata.ID, error) {
 	return dbutil.DoTx1(ctx, s.db, func(tx *sqlx.Tx) (cadata.ID, error) {
 		s2 := s.txStore(tx)
@@ -250,7 +335,30 @@ func (s *store) Hash(salt *cadata.ID, x []byte) cadata.ID {
 }
 
 func (s *store) txStore(tx *sqlx.Tx) txStore {
-	return txStore{tx: tx, hf: s.hf, maxSize: s.maxSize, intID: s.intID}
+	return txStore{tx: tx, hf: s.hf, maxSize: s.maxSize, intID: s.intID, quota: s.quota}
+}
+
+// RemoveBlobs removes ids from a store, and deletes any blobs which are no longer
+// included in any store.
+// It returns the number of bytes freed from the blobs table.
+func RemoveBlobs(tx *sqlx.Tx, sid StoreID, ids []cadata.ID) (int64, error) {
+	var reclaimed int64
+	for _, id := range ids {
+		if _, err := tx.Exec(`DELETE FROM store_blobs WHERE store_id = ? AND blob_id = ?`, sid, id[:]); err != nil {
+			return 0, err
+		}
+		var size int64
+		if err := tx.Get(&size, `DELETE FROM blobs WHERE id = ? AND NOT EXISTS (
+			SELECT 1 FROM store_blobs WHERE blob_id = ?
+		) RETURNING length(data)`, id[:], id[:]); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return 0, err
+		}
+		reclaimed += size
+	}
+	return reclaimed, nil
 }
 
 // Counts the number of blobs in a store
@@ -260,6 +368,13 @@ func CountBlobs(tx *sqlx.Tx, sid StoreID) (int64, error) {
 	return ret, err
 }
 
+// StoreSize returns the total number of bytes in all the blobs in a store
+func StoreSize(tx *sqlx.Tx, sid StoreID) (int64, error) {
+	var ret int64
+	err := tx.Get(&ret, `SELECT coalesce((SELECT size FROM stores WHERE id = ?), 0)`, sid)
+	return ret, err
+}
+
 func saltBytes(salt *cadata.ID) []byte {
 	if salt == nil {
 		return nil
@@ -4,8 +4,11 @@ import (
 	"context"
 	"testing"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/require"
+	"lukechampine.com/blake3"
 
+	"myceliumweb.org/mycelium/internal/cadata"
 	"myceliumweb.org/mycelium/mycss/internal/dbutil"
 	"myceliumweb.org/mycelium/mycss/internal/migrations"
 )
@@ -22,3 +25,53 @@ func TestStore(t *testing.T) {
 	// 	return s
 	// })
 }
+
+func TestStoreSize(t *testing.T) {
+	ctx := context.TODO()
+	db := dbutil.NewTestDB(t)
+	require.NoError(t, migrations.Migrate(ctx, db, SizeMigration(Migration(migrations.InitialState()))))
+	hf := func(salt *cadata.ID, data []byte) cadata.ID {
+		return cadata.ID(blake3.Sum256(data))
+	}
+
+	require.NoError(t, dbutil.DoTx(ctx, db, func(tx *sqlx.Tx) error {
+		sid1, err := CreateStore(tx)
+		require.NoError(t, err)
+		sid2, err := CreateStore(tx)
+		require.NoError(t, err)
+		s1 := NewTxStore(tx, hf, 1<<10, sid1).WithQuota(20)
+		s2 := NewTxStore(tx, hf, 1<<10, sid2)
+		checkSize := func(sid StoreID) {
+			size, err := StoreSize(tx, sid)
+			require.NoError(t, err)
+			var sum int64
+			require.NoError(t, tx.Get(&sum, `SELECT coalesce(sum(length(blobs.data)), 0) FROM store_blobs
+				JOIN blobs ON blob_id = blobs.id
+				WHERE store_id = ?`, sid))
+			require.Equal(t, sum, size)
+		}
+
+		id1, err := s1.Post(ctx, nil, make([]byte, 10))
+		require.NoError(t, err)
+		// posting the same blob again does not count twice.
+		_, err = s1.Post(ctx, nil, make([]byte, 10))
+		require.NoError(t, err)
+		checkSize(sid1)
+		_, err = s1.Post(ctx, nil, make([]byte, 11))
+		require.ErrorAs(t, err, &ErrQuotaExceeded{})
+
+		require.NoError(t, s2.Add(ctx, &id1))
+		_, err = s2.Post(ctx, nil, make([]byte, 100))
+		require.NoError(t, err)
+		checkSize(sid2)
+
+		_, err = RemoveBlobs(tx, sid1, []cadata.ID{id1})
+		require.NoError(t, err)
+		checkSize(sid1)
+		checkSize(sid2)
+		_, err = s1.Post(ctx, nil, make([]byte, 20))
+		require.NoError(t, err)
+		checkSize(sid1)
+		return nil
+	}))
+}
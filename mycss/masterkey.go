@@ -0,0 +1,212 @@
+package mycss
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/argon2"
+
+	"myceliumweb.org/mycelium/mycss/internal/dbutil"
+)
+
+// keyCheckText is sealed with the master key, so that a wrong key can be detected
+// before it is used to open any pods.
+const keyCheckText = "mycss master key"
+
+// MasterKey is the key used to encrypt the secrets of all the pods in a System.
+// A pod's secret derives its network identity.
+//
+// The zero value is the all-zero key used by Systems created before master keys were introduced.
+type MasterKey struct {
+	passphrase []byte
+	key        [32]byte
+}
+
+// PassphraseKey returns a MasterKey derived from a passphrase using Argon2id.
+// The salt is generated when the key is first used with a System, and stored in the database.
+func PassphraseKey(passphrase []byte) MasterKey {
+	return MasterKey{passphrase: bytes.Clone(passphrase)}
+}
+
+// RawKey returns a MasterKey which uses k directly.
+func RawKey(k [32]byte) MasterKey {
+	return MasterKey{key: k}
+}
+
+// ReadKeyFile reads a MasterKey from a file containing either 32 bytes, or 64 hex characters.
+func ReadKeyFile(p string) (MasterKey, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return MasterKey{}, err
+	}
+	var k [32]byte
+	switch trimmed := bytes.TrimSpace(data); {
+	case len(data) == 32:
+		copy(k[:], data)
+	case len(trimmed) == 64:
+		if _, err := hex.Decode(k[:], trimmed); err != nil {
+			return MasterKey{}, fmt.Errorf("key file %s: %w", p, err)
+		}
+	default:
+		return MasterKey{}, fmt.Errorf("key file %s must contain 32 bytes or 64 hex characters", p)
+	}
+	return RawKey(k), nil
+}
+
+// newSalt returns a salt for deriving the key, or nil if the key does not need one.
+func (mk MasterKey) newSalt() []byte {
+	if mk.passphrase == nil {
+		return nil
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		panic(err)
+	}
+	return salt
+}
+
+func (mk MasterKey) derive(salt []byte) *[32]byte {
+	if mk.passphrase == nil {
+		k := mk.key
+		return &k
+	}
+	out := argon2.IDKey(mk.passphrase, salt, 1, 64*1024, 4, 32)
+	return (*[32]byte)(out)
+}
+
+func (mk MasterKey) isZero() bool {
+	return mk.passphrase == nil && mk.key == [32]byte{}
+}
+
+// unlock derives the master key and checks it against the database.
+// The key is cached after the first successful call.
+// It does not take a lock.
+func (s *System) unlock(ctx context.Context) (*[32]byte, error) {
+	if s.key != nil {
+		return s.key, nil
+	}
+	key, err := dbutil.DoTx1(ctx, s.db, func(tx *sqlx.Tx) (*[32]byte, error) {
+		var row struct {
+			Salt     []byte `db:"salt"`
+			KeyCheck []byte `db:"key_check"`
+		}
+		if err := tx.GetContext(ctx, &row, `SELECT salt, key_check FROM master_key`); err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+			// the System has never had a master key.
+			// pods created until now are sealed with the zero key.
+			var count int
+			if err := tx.GetContext(ctx, &count, `SELECT count(*) FROM pods`); err != nil {
+				return nil, err
+			}
+			if s.mk.isZero() {
+				if count == 0 && s.requireKey {
+					return nil, ErrNoKey{Op: "create pods in a new database"}
+				}
+				return new([32]byte), nil
+			}
+			if count > 0 {
+				return nil, ErrWrongKey{}
+			}
+			// there are no pods, so adopt the key.
+			salt := s.mk.newSalt()
+			key := s.mk.derive(salt)
+			return key, putKeyCheck(tx, key, salt)
+		}
+		key := s.mk.derive(row.Salt)
+		if err := checkKey(key, row.KeyCheck); err != nil {
+			return nil, err
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.key = key
+	return key, nil
+}
+
+// Rekey changes the System's master key to next, and re-seals the secret of every pod with it.
+// The pods keep their secrets, so their network identities do not change.
+// next must not be the zero key; Rekey returns ErrNoKey if it is.
+func (s *System) Rekey(ctx context.Context, next MasterKey) error {
+	if next.isZero() {
+		return ErrNoKey{Op: "rekey"}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prevKey, err := s.unlock(ctx)
+	if err != nil {
+		return err
+	}
+	salt := next.newSalt()
+	nextKey := next.derive(salt)
+	if err := dbutil.DoTx(ctx, s.db, func(tx *sqlx.Tx) error {
+		var rows []struct {
+			ID     PodID  `db:"id"`
+			Secret []byte `db:"secret"`
+		}
+		if err := tx.SelectContext(ctx, &rows, `SELECT id, secret FROM pods`); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			secret, err := openSecret(prevKey, row.Secret)
+			if err != nil {
+				return fmt.Errorf("pod %d: %w", row.ID, err)
+			}
+			ctext := seal(nextKey, randomNonce(), nil, secret[:])
+			if _, err := tx.ExecContext(ctx, `UPDATE pods SET secret = ? WHERE id = ?`, ctext, row.ID); err != nil {
+				return err
+			}
+		}
+		return putKeyCheck(tx, nextKey, salt)
+	}); err != nil {
+		return err
+	}
+	s.mk = next
+	s.key = nextKey
+	return nil
+}
+
+func putKeyCheck(tx *sqlx.Tx, key *[32]byte, salt []byte) error {
+	check := seal(key, randomNonce(), nil, []byte(keyCheckText))
+	_, err := tx.Exec(`INSERT INTO master_key (id, salt, key_check) VALUES (0, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			salt = excluded.salt,
+			key_check = excluded.key_check`, salt, check)
+	return err
+}
+
+func checkKey(key *[32]byte, check []byte) error {
+	if len(check) < 24 {
+		return fmt.Errorf("master key check is too short")
+	}
+	ptext, err := open(key, (*[24]byte)(check[:24]), nil, check[24:])
+	if err != nil || string(ptext) != keyCheckText {
+		return ErrWrongKey{}
+	}
+	return nil
+}
+
+// openSecret decrypts a pod secret, as stored in the database.
+func openSecret(key *[32]byte, ctext []byte) (*[32]byte, error) {
+	if len(ctext) < 24 {
+		return nil, fmt.Errorf("sealed secret is too short")
+	}
+	secret, err := open(key, (*[24]byte)(ctext[:24]), nil, ctext[24:])
+	if err != nil {
+		return nil, ErrWrongKey{}
+	}
+	if n := len(secret); n != 32 {
+		return nil, fmt.Errorf("secret is incorrect size %d", n)
+	}
+	return (*[32]byte)(secret), nil
+}
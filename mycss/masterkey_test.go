@@ -0,0 +1,107 @@
+package mycss
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium/internal/testutil"
+)
+
+func TestMasterKeyPassphrase(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys1 := newTestSys(t)
+	sys1.mk = PassphraseKey([]byte("correct horse"))
+	p1, err := sys1.Create(ctx)
+	require.NoError(t, err)
+
+	sys2 := NewSystemWithKey(sys1.db, PassphraseKey([]byte("correct horse")))
+	p2, err := sys2.Get(ctx, p1.ID())
+	require.NoError(t, err)
+	require.Equal(t, *p1.secret, *p2.secret)
+
+	for _, mk := range []MasterKey{{}, PassphraseKey([]byte("battery staple"))} {
+		sys3 := NewSystemWithKey(sys1.db, mk)
+		_, err = sys3.Get(ctx, p1.ID())
+		require.ErrorAs(t, err, &ErrWrongKey{})
+		_, err = sys3.Create(ctx)
+		require.ErrorAs(t, err, &ErrWrongKey{})
+	}
+}
+
+func TestRequireKey(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys1 := newTestSys(t)
+	sys1.SetRequireKey(true)
+	_, err := sys1.Create(ctx)
+	require.ErrorAs(t, err, &ErrNoKey{})
+
+	// a database which already uses the zero key can still be used.
+	p1, err := NewSystem(sys1.db).Create(ctx)
+	require.NoError(t, err)
+	sys2 := NewSystem(sys1.db)
+	sys2.SetRequireKey(true)
+	_, err = sys2.Get(ctx, p1.ID())
+	require.NoError(t, err)
+	_, err = sys2.Create(ctx)
+	require.NoError(t, err)
+}
+
+func TestRekey(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys1 := newTestSys(t)
+	p1, err := sys1.Create(ctx)
+	require.NoError(t, err)
+	// a non-zero key cannot open pods sealed with the zero key.
+	_, err = NewSystemWithKey(sys1.db, RawKey([32]byte{1})).Get(ctx, p1.ID())
+	require.ErrorAs(t, err, &ErrWrongKey{})
+
+	require.NoError(t, sys1.Rekey(ctx, RawKey([32]byte{1})))
+	_, err = NewSystem(sys1.db).Get(ctx, p1.ID())
+	require.ErrorAs(t, err, &ErrWrongKey{})
+	p2, err := NewSystemWithKey(sys1.db, RawKey([32]byte{1})).Get(ctx, p1.ID())
+	require.NoError(t, err)
+	require.Equal(t, *p1.secret, *p2.secret)
+
+	require.NoError(t, sys1.Rekey(ctx, PassphraseKey([]byte("hunter2"))))
+	p3, err := NewSystemWithKey(sys1.db, PassphraseKey([]byte("hunter2"))).Get(ctx, p1.ID())
+	require.NoError(t, err)
+	require.Equal(t, *p1.secret, *p3.secret)
+}
+
+func TestRekeyZero(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := NewSystemWithKey(newTestSys(t).db, RawKey([32]byte{1}))
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	var before []byte
+	require.NoError(t, sys.db.GetContext(ctx, &before, `SELECT key_check FROM master_key`))
+
+	require.ErrorAs(t, sys.Rekey(ctx, MasterKey{}), &ErrNoKey{})
+	var after []byte
+	require.NoError(t, sys.db.GetContext(ctx, &after, `SELECT key_check FROM master_key`))
+	require.Equal(t, before, after)
+	_, err = NewSystemWithKey(sys.db, RawKey([32]byte{1})).Get(ctx, p.ID())
+	require.NoError(t, err)
+}
+
+func TestReadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	k := [32]byte{1, 2, 3}
+	rawPath := filepath.Join(dir, "raw")
+	require.NoError(t, os.WriteFile(rawPath, k[:], 0o600))
+	hexPath := filepath.Join(dir, "hex")
+	require.NoError(t, os.WriteFile(hexPath, []byte(hex.EncodeToString(k[:])+"\n"), 0o600))
+	for _, p := range []string{rawPath, hexPath} {
+		mk, err := ReadKeyFile(p)
+		require.NoError(t, err)
+		require.Equal(t, RawKey(k), mk)
+	}
+	badPath := filepath.Join(dir, "bad")
+	require.NoError(t, os.WriteFile(badPath, []byte("too short"), 0o600))
+	_, err := ReadKeyFile(badPath)
+	require.Error(t, err)
+}
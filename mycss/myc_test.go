@@ -1,14 +1,17 @@
 package mycss
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"myceliumweb.org/mycelium/internal/cadata"
 	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/mvm1"
 	"myceliumweb.org/mycelium/myccanon"
 	"myceliumweb.org/mycelium/myccanon/mycjson"
 	"myceliumweb.org/mycelium/mycexpr"
@@ -33,6 +36,17 @@ func TestCreate(t *testing.T) {
 	t.Logf("created pod %v", p)
 }
 
+func TestDrop(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := newTestSys(t)
+	p, err := s.Create(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.Drop(ctx, p.ID()))
+	pods, err := s.List(ctx)
+	require.NoError(t, err)
+	require.Empty(t, pods)
+}
+
 func TestPutGet1(t *testing.T) {
 	ctx := testutil.Context(t)
 	sys := newTestSys(t)
@@ -145,3 +159,118 @@ func mkLambda(in, out myc.Type, bodyFn func(eb EB) *Expr) *myc.Lambda {
 	}
 	return la
 }
+
+func TestMainLimit(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	reset(t, p, s, myccanon.Namespace{
+		"": mkLambda(myccanon.NS_Type, myc.ProductType{}, func(eb EB) *Expr {
+			return eb.Apply(eb.Self(), eb.P(0))
+		}),
+	}, PodConfig{MaxSteps: 1000})
+
+	err = Main(ctx, p)
+	var limErr ErrProcLimit
+	require.ErrorAs(t, err, &limErr)
+	require.Equal(t, mvm1.LimitSteps, limErr.Limit)
+	require.Equal(t, p.ID(), limErr.PodID)
+}
+
+func TestMainResume(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	reset(t, p, s, myccanon.Namespace{
+		"": mkLambda(myccanon.NS_Type, myc.ProductType{}, func(eb EB) *Expr {
+			return eb.Apply(eb.Self(), eb.P(0))
+		}),
+	}, PodConfig{})
+
+	ctx1, cf := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cf()
+	require.ErrorIs(t, Main(ctx1, p), context.DeadlineExceeded)
+	procIDs, err := p.Interrupted(ctx)
+	require.NoError(t, err)
+	require.Len(t, procIDs, 1)
+
+	ctx2, cf := context.WithTimeout(ctx, time.Second)
+	defer cf()
+	require.ErrorIs(t, Resume(ctx2, p, procIDs[0]), context.DeadlineExceeded)
+	procIDs2, err := p.Interrupted(ctx)
+	require.NoError(t, err)
+	require.Len(t, procIDs2, 1)
+	require.Greater(t, procIDs2[0], procIDs[0])
+}
+
+func TestDropInterrupted(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	reset(t, p, s, myccanon.Namespace{
+		"": mkLambda(myccanon.NS_Type, myc.ProductType{}, func(eb EB) *Expr {
+			return eb.Apply(eb.Self(), eb.P(0))
+		}),
+	}, PodConfig{})
+
+	ctx1, cf := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cf()
+	require.ErrorIs(t, Main(ctx1, p), context.DeadlineExceeded)
+	procIDs, err := p.Interrupted(ctx)
+	require.NoError(t, err)
+	require.Len(t, procIDs, 1)
+
+	require.NoError(t, sys.Drop(ctx, p.ID()))
+	var n int
+	require.NoError(t, sys.db.GetContext(ctx, &n, `SELECT count(*) FROM pod_procs`))
+	require.Zero(t, n)
+	require.NoError(t, sys.db.GetContext(ctx, &n, `SELECT count(*) FROM stores`))
+	require.Zero(t, n)
+}
+
+func TestCancelBeforeSnapshot(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+
+	ctx1, cf := context.WithCancel(ctx)
+	defer cf()
+	require.ErrorIs(t, p.doInProcess(ctx1, true, nil, func(pc ProcCtx) error {
+		cf()
+		return ctx1.Err()
+	}), context.Canceled)
+	var n int
+	require.NoError(t, sys.db.GetContext(ctx, &n, `SELECT count(*) FROM pod_procs`))
+	require.Zero(t, n)
+}
+
+func TestMainTrace(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	entry := mkLambda(myccanon.NS_Type, myc.ProductType{}, func(eb EB) *Expr {
+		return eb.Product()
+	})
+	reset(t, p, s, myccanon.Namespace{"": entry}, PodConfig{})
+
+	var calls []mvm1.Fingerprint
+	require.NoError(t, MainTrace(ctx, p, tracerFunc(func(ev mvm1.TraceEvent) {
+		if ev.Kind == mvm1.EventCall {
+			calls = append(calls, ev.Fingerprint)
+		}
+	})))
+	require.Contains(t, calls, mvm1.FingerprintOf(entry))
+}
+
+type tracerFunc func(mvm1.TraceEvent)
+
+func (f tracerFunc) Trace(ev mvm1.TraceEvent) { f(ev) }
@@ -0,0 +1,244 @@
+package mychui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+
+	"myceliumweb.org/mycelium"
+	"myceliumweb.org/mycelium/internal/stores"
+	"myceliumweb.org/mycelium/myccanon"
+	"myceliumweb.org/mycelium/myccanon/mycjson"
+	"myceliumweb.org/mycelium/mycexpr"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/mycss"
+)
+
+// DefaultMaxConcurrentRequests is the number of HTTP requests which can be handled by a single pod at once.
+const DefaultMaxConcurrentRequests = 16
+
+// HTTPHeadersType is a list of header name, value pairs.
+func HTTPHeadersType() myc.Type {
+	return myc.ListOf(myc.ProductType{myc.StringType(), myc.StringType()})
+}
+
+// HTTPBodyType is either raw bytes, or a JSON value.
+// Request bodies are JSON when the request's Content-Type is application/json.
+func HTTPBodyType() myc.SumType {
+	return myc.SumType{
+		myc.ListOf(myc.ByteType()),
+		mycjson.JSONType(),
+	}
+}
+
+// HTTPRequestType is the type of the request passed to a handler.
+func HTTPRequestType() myc.ProductType {
+	return myc.ProductType{
+		// Method
+		myc.StringType(),
+		// Path, relative to the handler
+		myc.StringType(),
+		HTTPHeadersType(),
+		HTTPBodyType(),
+	}
+}
+
+// HTTPResponseType is the type of the response returned by a handler.
+func HTTPResponseType() myc.ProductType {
+	return myc.ProductType{
+		// Status
+		myc.B32Type(),
+		HTTPHeadersType(),
+		HTTPBodyType(),
+	}
+}
+
+// HTTPHandlerType is the type of the namespace entries which can be called over HTTP.
+// The handler is called with the pod's namespace and the request.
+func HTTPHandlerType() *myc.LambdaType {
+	return myc.NewLambdaType(
+		myc.ProductType{myccanon.NS_Type, HTTPRequestType()},
+		HTTPResponseType(),
+	)
+}
+
+// SetMaxConcurrentRequests sets the number of HTTP requests which can be handled by a single pod at once.
+// Requests beyond the limit are rejected with 503 Service Unavailable.
+// It must be called before Serve.
+func (s *Server) SetMaxConcurrentRequests(n int) {
+	s.gw.limit = n
+}
+
+// gateway limits the number of requests being handled by each pod.
+type gateway struct {
+	limit int
+
+	mu       sync.Mutex
+	inflight map[mycss.PodID]int
+}
+
+func (gw *gateway) acquire(pid mycss.PodID) bool {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	if gw.inflight == nil {
+		gw.inflight = make(map[mycss.PodID]int)
+	}
+	if gw.inflight[pid] >= gw.limit {
+		return false
+	}
+	gw.inflight[pid]++
+	return true
+}
+
+func (gw *gateway) release(pid mycss.PodID) {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	gw.inflight[pid]--
+	if gw.inflight[pid] == 0 {
+		delete(gw.inflight, pid)
+	}
+}
+
+// handleHTTP calls the handler in the pod's namespace named in the URL, in its own process.
+func (s *Server) handleHTTP(c *fiber.Ctx) error {
+	ctx := c.Context()
+	pod, err := s.getPod(c)
+	if err != nil {
+		return err
+	}
+	key := c.Params("handler")
+	ns, err := pod.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	if h, exists := ns[key]; !exists || !myc.TypeContains(HTTPHandlerType(), h) {
+		return fiber.NewError(http.StatusNotFound, fmt.Sprintf("pod %d has no HTTP handler %q", pod.ID(), key))
+	}
+	if len(c.Body()) > mycelium.MaxSizeBytes {
+		return fiber.NewError(http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is larger than %d bytes", mycelium.MaxSizeBytes))
+	}
+	req, err := makeHTTPRequest(c)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	if !s.gw.acquire(pod.ID()) {
+		return fiber.NewError(http.StatusServiceUnavailable, "too many concurrent requests")
+	}
+	defer s.gw.release(pod.ID())
+
+	var resp httpResponse
+	if err := pod.DoInProcess(ctx, func(pc mycss.ProcCtx) error {
+		laz, err := mycexpr.BuildLazy(HTTPResponseType(), func(eb mycexpr.EB) *mycexpr.Expr {
+			return eb.LetVal(pc.NS().ToMycelium(), func(eb mycexpr.EB) *mycexpr.Expr {
+				return eb.Apply(
+					eb.AnyValueTo(myccanon.NSGetExpr(eb.P(0), key), HTTPHandlerType()),
+					eb.Product(eb.P(0), eb.Lit(req)),
+				)
+			})
+		})
+		if err != nil {
+			return err
+		}
+		if err := laz.PullInto(ctx, pc.Store(), stores.Union{}); err != nil {
+			return err
+		}
+		out, err := pc.Eval(ctx, laz)
+		if err != nil {
+			return err
+		}
+		resp, err = parseHTTPResponse(ctx, pc, out)
+		return err
+	}); err != nil {
+		return err
+	}
+	for _, h := range resp.Headers {
+		c.Set(h[0], h[1])
+	}
+	c.Status(resp.Status)
+	_, err = c.Write(resp.Body)
+	return err
+}
+
+func makeHTTPRequest(c *fiber.Ctx) (myc.Product, error) {
+	var headers []myc.Value
+	c.Request().Header.VisitAll(func(k, v []byte) {
+		headers = append(headers, myc.Product{myc.NewString(string(k)), myc.NewString(string(v))})
+	})
+	body, err := makeHTTPBody(c.Get(fiber.HeaderContentType), c.Body())
+	if err != nil {
+		return nil, err
+	}
+	return myc.Product{
+		myc.NewString(c.Method()),
+		myc.NewString("/" + c.Params("*")),
+		myc.NewList(myc.ProductType{myc.StringType(), myc.StringType()}, headers...),
+		body,
+	}, nil
+}
+
+func makeHTTPBody(contentType string, data []byte) (myc.Value, error) {
+	if mt, _, _ := strings.Cut(contentType, ";"); strings.TrimSpace(mt) != fiber.MIMEApplicationJSON {
+		return HTTPBodyType().New(0, myc.NewString(string(data)))
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var x any
+	if err := dec.Decode(&x); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	jv, err := mycjson.EncodeJSON(mycjson.NewJSON(x))
+	if err != nil {
+		return nil, err
+	}
+	return HTTPBodyType().New(1, jv)
+}
+
+type httpResponse struct {
+	Status  int
+	Headers [][2]string
+	Body    []byte
+}
+
+func parseHTTPResponse(ctx context.Context, pc mycss.ProcCtx, x myc.Value) (httpResponse, error) {
+	if !myc.TypeContains(HTTPResponseType(), x) {
+		return httpResponse{}, fmt.Errorf("handler returned %v, which is not a response", x.Type())
+	}
+	pr := x.(myc.Product)
+	status := int(*pr[0].(*myc.B32))
+	if status < 100 || status > 999 {
+		return httpResponse{}, fmt.Errorf("handler returned invalid status %d", status)
+	}
+	var resp httpResponse
+	resp.Status = status
+	hs := pr[1].(*myc.List)
+	for i := 0; i < hs.Len(); i++ {
+		h := hs.Get(i).(myc.Product)
+		resp.Headers = append(resp.Headers, [2]string{myccanon.AsString(h[0]), myccanon.AsString(h[1])})
+	}
+	body := pr[2].(*myc.Sum)
+	switch body.Tag() {
+	case 0:
+		resp.Body = body.Unwrap().(*myc.List).Array().(myc.ByteArray).AsBytes()
+	case 1:
+		jv, err := mycjson.DecodeJSON(ctx, pc.Store(), body.Unwrap())
+		if err != nil {
+			return httpResponse{}, err
+		}
+		if resp.Body, err = json.Marshal(jv); err != nil {
+			return httpResponse{}, err
+		}
+		if !slices.ContainsFunc(resp.Headers, func(h [2]string) bool {
+			return strings.EqualFold(h[0], fiber.HeaderContentType)
+		}) {
+			resp.Headers = append(resp.Headers, [2]string{fiber.HeaderContentType, fiber.MIMEApplicationJSON})
+		}
+	}
+	return resp, nil
+}
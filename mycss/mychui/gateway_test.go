@@ -0,0 +1,95 @@
+package mychui
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/mycexpr"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/mycss"
+	"myceliumweb.org/mycelium/mycss/testmycss"
+)
+
+func TestGateway(t *testing.T) {
+	ctx := testutil.Context(t)
+	store := testutil.NewStore(t)
+	sys := testmycss.New(t)
+	pod := testmycss.Create(t, sys)
+	lAddr := startServing(t, sys)
+
+	// echo responds with the request's body
+	echo, err := mycexpr.BuildLambda(HTTPHandlerType().In(), HTTPResponseType(), func(eb mycexpr.EB) *mycexpr.Expr {
+		req := eb.Field(eb.P(0), 1)
+		return eb.Product(
+			eb.B32(201),
+			eb.Lit(myc.NewList(myc.ProductType{myc.StringType(), myc.StringType()},
+				myc.Product{myc.NewString("X-Echo"), myc.NewString("yes")},
+			)),
+			eb.Field(req, 3),
+		)
+	})
+	require.NoError(t, err)
+	require.NoError(t, pod.Put(ctx, store, "echo", echo))
+	require.NoError(t, pod.Put(ctx, store, "notAHandler", myc.NewB32(1)))
+
+	resp, body := doHTTP(t, "POST", mkGatewayURL(lAddr, pod, "echo/a/b"), "text/plain", "hello")
+	require.Equal(t, 201, resp.StatusCode)
+	require.Equal(t, "yes", resp.Header.Get("X-Echo"))
+	require.Equal(t, "hello", body)
+
+	resp, body = doHTTP(t, "PUT", mkGatewayURL(lAddr, pod, "echo/"), "application/json", `{"a": [true, null, "x", 100]}`)
+	require.Equal(t, 201, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.JSONEq(t, `{"a": [true, null, "x", 100]}`, body)
+
+	resp, _ = doHTTP(t, "GET", mkGatewayURL(lAddr, pod, "notAHandler/"), "", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = doHTTP(t, "GET", mkGatewayURL(lAddr, pod, "missing/"), "", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = doHTTP(t, "POST", mkGatewayURL(lAddr, pod, "echo/"), "application/json", `{`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestGatewayLimit(t *testing.T) {
+	ctx := testutil.Context(t)
+	store := testutil.NewStore(t)
+	sys := testmycss.New(t)
+	pod := testmycss.Create(t, sys)
+	srv := New(sys)
+	srv.SetMaxConcurrentRequests(0)
+	lis := testutil.Listen(t)
+	go srv.Serve(ctx, lis)
+
+	echo, err := mycexpr.BuildLambda(HTTPHandlerType().In(), HTTPResponseType(), func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.Product(eb.B32(200), eb.Lit(myc.NewList(myc.ProductType{myc.StringType(), myc.StringType()})), eb.Field(eb.Field(eb.P(0), 1), 3))
+	})
+	require.NoError(t, err)
+	require.NoError(t, pod.Put(ctx, store, "echo", echo))
+	resp, _ := doHTTP(t, "GET", mkGatewayURL(lis.Addr(), pod, "echo/"), "", "")
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func mkGatewayURL(addr net.Addr, pod *mycss.Pod, p string) string {
+	return fmt.Sprintf("http://%s/v1/pod/%d/http/%s", addr.String(), pod.ID(), p)
+}
+
+func doHTTP(t testing.TB, method, u, contentType, body string) (*http.Response, string) {
+	req, err := http.NewRequest(method, u, strings.NewReader(body))
+	require.NoError(t, err)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(data)
+}
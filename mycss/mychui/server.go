@@ -43,10 +43,11 @@ type Server struct {
 	sys   *mycss.System
 	app   *fiber.App
 	bgCtx context.Context
+	gw    gateway
 }
 
 func New(sys *mycss.System) *Server {
-	s := &Server{sys: sys}
+	s := &Server{sys: sys, gw: gateway{limit: DefaultMaxConcurrentRequests}}
 
 	var renderer *html.Engine
 	if devPath != "" {
@@ -72,6 +73,7 @@ func New(sys *mycss.System) *Server {
 	v1.Get("/pod/:podID/renderHTML", s.renderHTML)
 	v1.Get("/pod/:podID/blob/:ref", s.blob)
 	v1.Get("/pod/:podID/ws", websocket.New(s.handleWS))
+	v1.All("/pod/:podID/http/:handler/*", s.handleHTTP)
 	s.app = app
 	return s
 }
@@ -278,6 +280,7 @@ type podInfo struct {
 	ProcCount int64
 	Config    string
 	CreatedAt time.Time
+	Status    mycss.PodStatus
 }
 
 func makePodInfo(ctx context.Context, pod *mycss.Pod) (*podInfo, error) {
@@ -290,6 +293,10 @@ func makePodInfo(ctx context.Context, pod *mycss.Pod) (*podInfo, error) {
 	if err != nil {
 		return nil, err
 	}
+	status, err := pod.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return &podInfo{
 		ID:        pod.ID(),
 		ProcCount: pod.ProcCount(),
@@ -297,6 +304,7 @@ func makePodInfo(ctx context.Context, pod *mycss.Pod) (*podInfo, error) {
 		NS:        makeNSEntries(ns),
 		CreatedAt: pod.CreatedAt(),
 		Config:    string(cfgData),
+		Status:    status,
 	}, nil
 }
 
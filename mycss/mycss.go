@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/json"
+	"io"
 	"net/netip"
 	"os"
 	"slices"
@@ -29,19 +30,34 @@ type (
 // Systems contain pods.
 type System struct {
 	db *sqlx.DB
+	mk MasterKey
 
-	bgCtx context.Context
-	ab    AddressBook
+	bgCtx   context.Context
+	ab      AddressBook
+	clock   Clock
+	entropy io.Reader
 
-	mu    sync.Mutex
-	stale bool
-	pods  map[PodID]*Pod
+	mu         sync.Mutex
+	key        *[32]byte
+	requireKey bool
+	stale      bool
+	pods       map[PodID]*Pod
 }
 
+// NewSystem returns a System using the zero MasterKey.
 func NewSystem(db *sqlx.DB) *System {
+	return NewSystemWithKey(db, MasterKey{})
+}
+
+// NewSystemWithKey returns a System which uses mk to encrypt pod secrets.
+// If mk is not the System's key, then opening or creating pods will fail with ErrWrongKey.
+func NewSystemWithKey(db *sqlx.DB, mk MasterKey) *System {
 	s := &System{
-		bgCtx: context.Background(),
-		db:    db,
+		bgCtx:   context.Background(),
+		db:      db,
+		mk:      mk,
+		clock:   RealClock{},
+		entropy: rand.Reader,
 
 		stale: true,
 		pods:  make(map[PodID]*Pod),
@@ -49,16 +65,45 @@ func NewSystem(db *sqlx.DB) *System {
 	return s
 }
 
+// SetClock sets the Clock used by the devices in the System's pods.
+// It must be called before any pods are created or opened.
+func (s *System) SetClock(clk Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = clk
+}
+
+// SetEntropy sets the source of randomness for the System's pods.
+// It is used for pod secrets, port data, and the random device.
+// It must be called before any pods are created or opened.
+func (s *System) SetEntropy(r io.Reader) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entropy = r
+}
+
+// SetRequireKey sets whether the System refuses to use the zero MasterKey for a new database.
+// Databases which already have pods sealed with the zero key can still be used.
+// It must be called before any pods are created or opened.
+func (s *System) SetRequireKey(yes bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requireKey = yes
+}
+
 func (s *System) Create(ctx context.Context) (*Pod, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.stale = true
+	key, err := s.unlock(ctx)
+	if err != nil {
+		return nil, err
+	}
 	// create and encrypt secret
 	var secret [32]byte
-	if _, err := rand.Read(secret[:]); err != nil {
+	if _, err := io.ReadFull(s.entropy, secret[:]); err != nil {
 		return nil, err
 	}
-	key := new([32]byte)
 	nonce := randomNonce()
 	secretCtext := seal(key, nonce, nil, secret[:])
 
@@ -109,6 +154,21 @@ func (s *System) Drop(ctx context.Context, pid PodID) error {
 		if err := sqlstores.DropStore(tx, pod.storeID); err != nil {
 			return err
 		}
+		// interrupted processes are not in memory, so stopAllThreads leaves their records.
+		var procStores []sqlstores.StoreID
+		if err := tx.SelectContext(ctx, &procStores, `SELECT store_id FROM pod_procs WHERE pod_id = ?`, pod.id); err != nil {
+			return err
+		}
+		for _, sid := range procStores {
+			if err := sqlstores.DropStore(tx, sid); err != nil {
+				return err
+			}
+		}
+		for _, table := range []string{"pod_procs", "pod_ns", "pod_ns_history", "pod_status", "pod_recordings", "pod_mailbox"} {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE pod_id = ?`, pod.id); err != nil {
+				return err
+			}
+		}
 		if _, err := tx.ExecContext(ctx, `DELETE FROM pods WHERE id = ?`, pod.id); err != nil {
 			return err
 		}
@@ -153,12 +213,6 @@ func (s *System) List(ctx context.Context) (ret []*Pod, _ error) {
 	return ret, nil
 }
 
-func (s *System) Run(ctx context.Context) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return nil
-}
-
 func (s *System) AddLoc(peer mycnet.Peer, addr netip.AddrPort) {
 	s.ab.Add(peer.ID(), addr)
 }
@@ -203,6 +257,9 @@ func (s *System) podEnv() PodEnv {
 		Background: s.bgCtx,
 		Locator:    &s.ab,
 		ConsoleOut: os.Stdout,
+		Clock:      s.clock,
+		Entropy:    s.entropy,
+		Pods:       s.Get,
 	}
 }
 
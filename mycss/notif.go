@@ -0,0 +1,132 @@
+package mycss
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/maps"
+)
+
+// Notif is sent to subscribers when an entry in a Pod's namespace changes.
+type Notif struct {
+	Key string
+}
+
+// Subscribe causes a Notif to be sent on ch after each committed change to a key in the Pod's
+// namespace starting with prefix.
+// Sends to ch never block the writer; if the subscriber falls behind, notifications are coalesced
+// so that each changed key is delivered at least once after its most recent change.
+func (p *Pod) Subscribe(prefix string, ch chan Notif) {
+	p.subscribe(0, prefix, ch)
+}
+
+// Unsubscribe stops notifications to ch.
+func (p *Pod) Unsubscribe(ch chan Notif) {
+	p.subsMu.Lock()
+	defer p.subsMu.Unlock()
+	if sub, exists := p.subs[ch]; exists {
+		sub.close()
+		delete(p.subs, ch)
+	}
+}
+
+func (p *Pod) subscribe(procID ProcID, prefix string, ch chan Notif) {
+	p.subsMu.Lock()
+	defer p.subsMu.Unlock()
+	if p.subs == nil {
+		p.subs = make(map[chan Notif]*subscriber)
+	}
+	if sub, exists := p.subs[ch]; exists {
+		sub.close()
+	}
+	sub := newSubscriber(procID, prefix, ch)
+	p.subs[ch] = sub
+	go sub.run()
+}
+
+// unsubscribeProc removes all the subscriptions made by a process.
+func (p *Pod) unsubscribeProc(procID ProcID) {
+	p.subsMu.Lock()
+	defer p.subsMu.Unlock()
+	for ch, sub := range p.subs {
+		if sub.procID == procID {
+			sub.close()
+			delete(p.subs, ch)
+		}
+	}
+}
+
+// publish notifies subscribers that the keys have changed.
+// It should only be called after the change has been committed.
+func (p *Pod) publish(keys ...string) {
+	p.subsMu.Lock()
+	defer p.subsMu.Unlock()
+	for _, sub := range p.subs {
+		for _, k := range keys {
+			if strings.HasPrefix(k, sub.prefix) {
+				sub.add(k)
+			}
+		}
+	}
+}
+
+type subscriber struct {
+	procID ProcID
+	prefix string
+	ch     chan Notif
+
+	mu      sync.Mutex
+	pending map[string]struct{}
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+func newSubscriber(procID ProcID, prefix string, ch chan Notif) *subscriber {
+	return &subscriber{
+		procID:  procID,
+		prefix:  prefix,
+		ch:      ch,
+		pending: make(map[string]struct{}),
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// add marks k as pending, without blocking.
+func (s *subscriber) add(k string) {
+	s.mu.Lock()
+	s.pending[k] = struct{}{}
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers pending keys to ch until close is called.
+func (s *subscriber) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.wake:
+		}
+		s.mu.Lock()
+		keys := maps.Keys(s.pending)
+		clear(s.pending)
+		s.mu.Unlock()
+		slices.Sort(keys)
+		for _, k := range keys {
+			select {
+			case <-s.done:
+				return
+			case s.ch <- Notif{Key: k}:
+			}
+		}
+	}
+}
+
+func (s *subscriber) close() {
+	close(s.done)
+}
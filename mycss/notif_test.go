@@ -0,0 +1,186 @@
+package mycss
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/myccanon"
+	"myceliumweb.org/mycelium/mycexpr"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+func TestNotifPut(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+
+	ch := make(chan Notif, 10)
+	p.Subscribe("a", ch)
+	defer p.Unsubscribe(ch)
+	require.NoError(t, p.Put(ctx, s, "b", myc.NewB32(1)))
+	require.NoError(t, p.Put(ctx, s, "a1", myc.NewB32(2)))
+	require.Equal(t, Notif{Key: "a1"}, recvNotif(t, ch))
+	requireNoNotif(t, ch)
+}
+
+func TestNotifReset(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	reset(t, p, s, myccanon.Namespace{
+		"a": myc.NewB32(1),
+		"b": myc.NewB32(2),
+	}, PodConfig{})
+
+	ch := make(chan Notif, 10)
+	p.Subscribe("", ch)
+	defer p.Unsubscribe(ch)
+	reset(t, p, s, myccanon.Namespace{
+		"a": myc.NewB32(1),
+		"c": myc.NewB32(3),
+	}, PodConfig{})
+	// a did not change
+	require.Equal(t, Notif{Key: "b"}, recvNotif(t, ch))
+	require.Equal(t, Notif{Key: "c"}, recvNotif(t, ch))
+	requireNoNotif(t, ch)
+}
+
+func TestNotifCoalesce(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+
+	// nothing reads from ch while the puts happen.
+	ch := make(chan Notif)
+	p.Subscribe("", ch)
+	defer p.Unsubscribe(ch)
+	for i := 0; i < 20; i++ {
+		require.NoError(t, p.Put(ctx, s, "x", myc.NewB32(uint32(i))))
+	}
+	require.Equal(t, Notif{Key: "x"}, recvNotif(t, ch))
+	// at most one more notification can be pending.
+	var count int
+	for {
+		select {
+		case <-ch:
+			count++
+			continue
+		case <-time.After(100 * time.Millisecond):
+		}
+		break
+	}
+	require.LessOrEqual(t, count, 1)
+}
+
+func TestCellWatch(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	reset(t, p, s, myccanon.Namespace{
+		"cell0": myc.NewB32(13),
+	}, PodConfig{
+		Devices: map[string]DeviceSpec{
+			"cell0":  DevCell(),
+			"watch0": DevWatch(),
+		},
+	})
+
+	type result struct {
+		v   myc.Value
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := Eval(ctx, p, s, s, func(env myc.Value) *myc.Lazy {
+			laz, err := mycexpr.BuildLazy(myc.Bottom(), func(eb EB) *Expr {
+				return eb.LetVal(env, func(eb EB) *Expr {
+					return WatchExpr(GetWatch(eb.P(0), "watch0"), "cell0", eb.Lit(myc.NewAnyValue(myc.NewB32(13))))
+				})
+			})
+			if err != nil {
+				panic(err)
+			}
+			return laz
+		})
+		done <- result{out, err}
+	}()
+	select {
+	case res := <-done:
+		t.Fatalf("watch returned before the cell changed: %v %v", res.v, res.err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	require.Equal(t, myc.NewB32(22), cellCAS(t, p, s, "cell0", myc.NewB32(13), myc.NewB32(22)))
+	res := <-done
+	require.NoError(t, res.err)
+	require.Equal(t, myc.NewB32(22), res.v.(*myc.AnyValue).Unwrap())
+}
+
+func TestCellWatchStop(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	reset(t, p, s, myccanon.Namespace{
+		"cell0": myc.NewB32(13),
+	}, PodConfig{
+		Devices: map[string]DeviceSpec{
+			"cell0":  DevCell(),
+			"watch0": DevWatch(),
+		},
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := Eval(ctx, p, s, s, func(env myc.Value) *myc.Lazy {
+			laz, err := mycexpr.BuildLazy(myc.Bottom(), func(eb EB) *Expr {
+				return eb.LetVal(env, func(eb EB) *Expr {
+					return WatchExpr(GetWatch(eb.P(0), "watch0"), "cell0", eb.Lit(myc.NewAnyValue(myc.NewB32(13))))
+				})
+			})
+			if err != nil {
+				panic(err)
+			}
+			return laz
+		})
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return p.ProcCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+	// dropping the pod stops all its processes.
+	require.NoError(t, sys.Drop(ctx, p.ID()))
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, ErrProcStopped)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch was not cancelled by process stop")
+	}
+}
+
+func recvNotif(t testing.TB, ch chan Notif) Notif {
+	select {
+	case n := <-ch:
+		return n
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for notification")
+		return Notif{}
+	}
+}
+
+func requireNoNotif(t testing.TB, ch chan Notif) {
+	select {
+	case n := <-ch:
+		t.Fatalf("unexpected notification %v", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -8,7 +8,7 @@ import (
 	"fmt"
 	"io"
 	"maps"
-	"math"
+	"slices"
 	"sync"
 	"time"
 
@@ -32,15 +32,59 @@ type PodID int64
 type PodConfig struct {
 	MaxDepth        int64
 	MaxStorageBytes int64
+
+	// MaxSteps is the maximum number of VM steps a process can take during a single evaluation.
+	MaxSteps uint64 `json:",omitempty"`
+	// MaxStackWords is the maximum size of a process's VM stack.
+	MaxStackWords int `json:",omitempty"`
+	// MaxPostBytes is the maximum number of bytes a process can post during a single evaluation.
+	MaxPostBytes int64 `json:",omitempty"`
+	// MaxWallTime is the maximum duration of a single evaluation.
+	MaxWallTime time.Duration `json:",omitempty"`
+
+	// SnapshotInterval is how often the main process takes a snapshot, which it can be resumed from if it is interrupted.
+	// Zero means that snapshots are only taken when the process is cancelled.
+	SnapshotInterval time.Duration `json:",omitempty"`
+
+	// Autostart causes System.Run to run the pod's main process, and to restart it if it fails.
+	Autostart bool `json:",omitempty"`
+	// RestartBackoff is how long System.Run waits before the first restart of a failed main process.
+	// The wait doubles after each consecutive failure, up to MaxRestartBackoff.
+	// Zero means DefaultRestartBackoff.
+	RestartBackoff time.Duration `json:",omitempty"`
+	// MaxRestartBackoff is the longest System.Run will wait before restarting a failed main process.
+	// Zero means DefaultMaxRestartBackoff.
+	MaxRestartBackoff time.Duration `json:",omitempty"`
+
+	// Record causes every evaluation in the pod's processes to be recorded, so that it can be replayed with Pod.Replay.
+	Record bool `json:",omitempty"`
+
 	// Resources is a map from keys to resource specifications
 	Devices map[string]DeviceSpec
 }
 
 func (pc *PodConfig) Validate() error {
+	var mailboxes int
 	for k, spec := range pc.Devices {
 		if err := spec.Validate(); err != nil {
 			return fmt.Errorf("invalid spec for resource at %s: %w", k, err)
 		}
+		if spec.Mailbox != nil {
+			mailboxes++
+		}
+	}
+	if mailboxes > 1 {
+		return fmt.Errorf("a pod can only have one mailbox, have %d", mailboxes)
+	}
+	return nil
+}
+
+// getMailbox returns the spec of the pod's mailbox, or nil if it does not have one.
+func (pc *PodConfig) getMailbox() *MailboxSpec {
+	for _, v := range pc.Devices {
+		if v.Mailbox != nil {
+			return v.Mailbox
+		}
 	}
 	return nil
 }
@@ -60,10 +104,14 @@ type DeviceSpec struct {
 	Network   *NetworkSpec `json:",omitempty"`
 	WallClock *struct{}    `json:",omitempty"`
 	Random    *struct{}    `json:",omitempty"`
+	Watch     *struct{}    `json:",omitempty"`
+	Timer     *struct{}    `json:",omitempty"`
+	Mailbox   *MailboxSpec `json:",omitempty"`
+	FS        *FSSpec      `json:",omitempty"`
 }
 
 func DevNetwork(i uint32) DeviceSpec {
-	return DeviceSpec{Network: &NetworkSpec{i}}
+	return DeviceSpec{Network: &NetworkSpec{KeyIndex: i}}
 }
 
 func DevCell() DeviceSpec {
@@ -82,6 +130,22 @@ func DevRandom() DeviceSpec {
 	return DeviceSpec{Random: &struct{}{}}
 }
 
+func DevWatch() DeviceSpec {
+	return DeviceSpec{Watch: &struct{}{}}
+}
+
+func DevTimer() DeviceSpec {
+	return DeviceSpec{Timer: &struct{}{}}
+}
+
+func DevMailbox(spec MailboxSpec) DeviceSpec {
+	return DeviceSpec{Mailbox: &spec}
+}
+
+func DevFS(spec FSSpec) DeviceSpec {
+	return DeviceSpec{FS: &spec}
+}
+
 func (ds *DeviceSpec) Validate() error {
 	var count int
 	for _, yes := range []bool{
@@ -90,6 +154,10 @@ func (ds *DeviceSpec) Validate() error {
 		ds.Network != nil,
 		ds.WallClock != nil,
 		ds.Random != nil,
+		ds.Watch != nil,
+		ds.Timer != nil,
+		ds.Mailbox != nil,
+		ds.FS != nil,
 	} {
 		if yes {
 			count++
@@ -101,11 +169,41 @@ func (ds *DeviceSpec) Validate() error {
 	if count > 1 {
 		return fmt.Errorf("crowded resource spec")
 	}
+	if ds.FS != nil {
+		return ds.FS.Validate()
+	}
 	return nil
 }
 
 type NetworkSpec struct {
 	KeyIndex uint32
+	// Limits constrains the values received from other peers.
+	// Any zero fields are taken from mycnet.DefaultLimits.
+	Limits mycnet.Limits
+	// AskTimeout is how long to wait for the reply to an ask, in either direction.
+	// Zero means DefaultAskTimeout.
+	AskTimeout time.Duration `json:",omitempty"`
+}
+
+func (s *NetworkSpec) askTimeout() time.Duration {
+	if s.AskTimeout <= 0 {
+		return DefaultAskTimeout
+	}
+	return s.AskTimeout
+}
+
+func (s *NetworkSpec) limits() mycnet.Limits {
+	lim := s.Limits
+	if lim.MaxDepth == 0 {
+		lim.MaxDepth = mycnet.DefaultLimits.MaxDepth
+	}
+	if lim.MaxBlobs == 0 {
+		lim.MaxBlobs = mycnet.DefaultLimits.MaxBlobs
+	}
+	if lim.MaxBits == 0 {
+		lim.MaxBits = mycnet.DefaultLimits.MaxBits
+	}
+	return lim
 }
 
 // PodEnv contains all the dependenies that must be provided to run a pod.
@@ -114,21 +212,42 @@ type PodEnv struct {
 	Locator    *AddressBook
 	Background context.Context
 	ConsoleOut io.Writer
+	// Clock is the source of time for the Pod's devices.
+	Clock Clock
+	// Entropy is the source of randomness for the Pod's devices and ports.
+	Entropy io.Reader
+	// Pods looks up the other pods in the same System, for the mailbox device.
+	Pods func(ctx context.Context, pid PodID) (*Pod, error)
 }
 
 // A Pod is a root namespace and store
 type Pod struct {
 	id        PodID
 	env       PodEnv
-	cfg       PodConfig
 	createdAt time.Time
 
+	// cfgMu guards cfg, which is replaced by Reset.
+	cfgMu sync.RWMutex
+	cfg   PodConfig
+
 	storeID sqlstores.StoreID
 	secret  *[32]byte
 
 	procsMu sync.Mutex
 	procs   map[ProcID]*process
-	subs    map[chan Notif]ProcID
+
+	subsMu sync.Mutex
+	subs   map[chan Notif]*subscriber
+
+	gcMu     sync.Mutex
+	gcMarkMu sync.Mutex
+	gcMarks  *markSet
+	// gcPosts is the number of blobs being posted which were not marked, because no GC was running when they began.
+	gcPosts     int
+	gcPostsDone sync.Cond
+
+	inboxMu sync.Mutex
+	inboxCh chan struct{}
 
 	console      *consoleDev
 	wallClock    wallClockDev
@@ -162,16 +281,13 @@ func (s *System) openPod(ctx context.Context, pid PodID, env PodEnv) (*Pod, erro
 		return nil, err
 	}
 	// decrypt secret
-	// TODO: change key
-	key := new([32]byte)
-	nonce := row.Secret[:24]
-	ctext := row.Secret[24:]
-	secret, err := open(key, (*[24]byte)(nonce), nil, ctext)
+	key, err := s.unlock(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if n := len(secret); n != 32 {
-		return nil, fmt.Errorf("secret is incorrect size %d", n)
+	secret, err := openSecret(key, row.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("opening pod %d: %w", pid, err)
 	}
 	var cfg PodConfig
 	if err := json.Unmarshal(row.Config, &cfg); err != nil {
@@ -184,12 +300,15 @@ func (s *System) openPod(ctx context.Context, pid PodID, env PodEnv) (*Pod, erro
 		createdAt: row.CreatedAt,
 
 		storeID: row.StoreID,
-		secret:  (*[32]byte)(secret),
+		secret:  secret,
 
 		procs: make(map[ProcID]*process),
 
-		console: newConsoleSvc(env.ConsoleOut),
+		console:   newConsoleSvc(env.ConsoleOut),
+		wallClock: wallClockDev{clock: env.Clock},
+		random:    randomDev{entropy: env.Entropy},
 	}
+	p.gcPostsDone.L = &p.gcMarkMu
 	if err := p.resetNetwork(ctx); err != nil {
 		return nil, err
 	}
@@ -201,11 +320,19 @@ func (p *Pod) ID() PodID {
 }
 
 func (p *Pod) Config() PodConfig {
-	ret := p.cfg
+	ret := p.config()
 	ret.Devices = maps.Clone(ret.Devices)
 	return ret
 }
 
+// config returns the Pod's config without copying it.
+// The config must not be modified, Reset replaces it instead.
+func (p *Pod) config() PodConfig {
+	p.cfgMu.RLock()
+	defer p.cfgMu.RUnlock()
+	return p.cfg
+}
+
 func (p *Pod) CreatedAt() time.Time {
 	return p.createdAt
 }
@@ -219,16 +346,24 @@ func (p *Pod) BlobCount(ctx context.Context) (int64, error) {
 // Put sets the symbol k to the value v.
 // It will create a new variable if one does not exist.
 func (p *Pod) Put(ctx context.Context, src cadata.Getter, k string, val Value) error {
-	return dbutil.DoTx(ctx, p.env.DB, func(tx *sqlx.Tx) error {
+	if err := dbutil.DoTx(ctx, p.env.DB, func(tx *sqlx.Tx) error {
 		dst := p.newTxStore(tx)
 		if err := val.PullInto(ctx, dst, src); err != nil {
 			return err
 		}
-		if err := p.nsPut(ctx, tx, k, val); err != nil {
+		if err := p.checkDepth(ctx, dst, val, p.config().MaxDepth); err != nil {
 			return err
 		}
-		return nil
-	})
+		w, err := p.beginNSWrite(tx, 0)
+		if err != nil {
+			return err
+		}
+		return p.nsPut(ctx, tx, w, k, val)
+	}); err != nil {
+		return err
+	}
+	p.publish(k)
+	return nil
 }
 
 // Get retrieves the value at k in the pods namespace
@@ -240,42 +375,49 @@ func (p *Pod) Get(ctx context.Context, k string) (Value, error) {
 // with the contents of ns.
 // And cells declared in the config will be carried over from the pod.
 func (p *Pod) Reset(ctx context.Context, src cadata.Getter, ns myccanon.Namespace, cfg PodConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
 	p.procsMu.Lock()
 	defer p.procsMu.Unlock()
+	var changed []string
 	if err := dbutil.DoTx(ctx, p.env.DB, func(tx *sqlx.Tx) error {
 		// we protect any data that will be cells in the new config.
 		currentNS := myccanon.Namespace{}
 		if err := p.nsAll(tx, currentNS); err != nil {
 			return err
 		}
-		if _, err := tx.ExecContext(ctx, `DELETE FROM pod_ns WHERE pod_id = ?`, p.id); err != nil {
-			return err
-		}
-		s := p.newTxStore(tx)
-		for k, v := range ns {
+		s := sqlstores.NewTxStore(tx, mycelium.Hash, mycelium.MaxSizeBytes, p.storeID).WithQuota(cfg.MaxStorageBytes)
+		for _, v := range ns {
 			if err := v.PullInto(ctx, s, src); err != nil {
 				return err
 			}
-			if err := p.nsPut(ctx, tx, k, v); err != nil {
+			if err := p.checkDepth(ctx, s, v, cfg.MaxDepth); err != nil {
 				return err
 			}
 		}
-		// overwrite any cells with the previous data
+		// carry over any cells with the previous data
+		nextNS := maps.Clone(ns)
+		if nextNS == nil {
+			nextNS = myccanon.Namespace{}
+		}
 		for _, k := range cfg.getCells() {
 			if v, exists := currentNS[k]; exists {
-				if err := p.nsPut(ctx, tx, k, v); err != nil {
-					return err
-				}
+				nextNS[k] = v
 			}
 		}
-		if err := p.setPodConfig(tx, cfg); err != nil {
+		changed = nsChanges(currentNS, nextNS)
+		if err := p.nsApply(ctx, tx, 0, nextNS, changed); err != nil {
 			return err
 		}
-		return nil
+		return p.setPodConfig(tx, cfg)
 	}); err != nil {
 		return err
 	}
+	p.publish(changed...)
+	p.cfgMu.Lock()
 	p.cfg = cfg
+	p.cfgMu.Unlock()
 	if err := p.resetNetwork(ctx); err != nil {
 		return err
 	}
@@ -292,11 +434,11 @@ func (p *Pod) resetNetwork(ctx context.Context) error {
 	}
 	clear(p.networkNodes)
 	s := p.newStore()
-	for _, spec := range p.cfg.Devices {
+	for _, spec := range p.config().Devices {
 		if spec.Network != nil {
 			idx := spec.Network.KeyIndex
 			if _, exists := p.networkNodes[idx]; !exists {
-				node, err := newNetworkNode(p.env.Background, s, p.env.Locator, p.secret, idx)
+				node, err := newNetworkNode(p.env.Background, s, p.env.Locator, p.secret, idx, *spec.Network)
 				if err != nil {
 					return err
 				}
@@ -316,7 +458,7 @@ func (p *Pod) GetAll(ctx context.Context) (myccanon.Namespace, error) {
 	}); err != nil {
 		return nil, err
 	}
-	p.overlayNS(ns, 0, mvm1.New(0, nil, mvm1.DefaultAccels()))
+	p.overlayNS(ns, 0, mvm1.New(0, nil, mvm1.DefaultAccels()), make(map[string][32]byte))
 	return ns, nil
 }
 
@@ -367,9 +509,7 @@ func (pc ProcCtx) Eval(ctx context.Context, laz *myc.Lazy) (myc.Value, error) {
 		return nil, err
 	}
 	vm.SetEval()
-	steps := vm.Run(ctx, math.MaxUint64)
-	logctx.Infof(ctx, "vm ran for %d steps", steps)
-	if err := vm.Err(); err != nil {
+	if err := pc.run(ctx); err != nil {
 		return nil, err
 	}
 	av, err := vm.ExportAnyValue(ctx, s)
@@ -387,8 +527,15 @@ func (pc ProcCtx) Store() cadata.Store {
 	return pc.p.getStore()
 }
 
+// Subscribe causes a Notif to be sent on ch after each change to the Pod's namespace.
+// The subscription ends when the process does.
 func (pc ProcCtx) Subscribe(ch chan Notif) {
-	pc.p.p.subscribe(pc.p.id, ch)
+	pc.SubscribePrefix("", ch)
+}
+
+// SubscribePrefix is like Subscribe, but only for keys starting with prefix.
+func (pc ProcCtx) SubscribePrefix(prefix string, ch chan Notif) {
+	pc.p.p.subscribe(pc.p.id, prefix, ch)
 }
 
 func (pc ProcCtx) Unsubscribe(ch chan Notif) {
@@ -399,7 +546,15 @@ type ProcFunc = func(ProcCtx) error
 
 // DoInProcess calls fn in a process context
 func (p *Pod) DoInProcess(ctx context.Context, fn ProcFunc) error {
-	proc, err := p.newProcess(ctx)
+	return p.doInProcess(ctx, false, nil, fn)
+}
+
+// doInProcess calls fn in a new process context.
+// If durable is true, the process's state is kept in the database, and the process is not deleted
+// if ctx is cancelled after it has taken a snapshot, so that it can be resumed later.
+// If prev is not nil, the process takes over the resources of an interrupted process.
+func (p *Pod) doInProcess(ctx context.Context, durable bool, prev *procRecord, fn ProcFunc) error {
+	proc, err := p.newProcess(ctx, durable, prev)
 	if err != nil {
 		return err
 	}
@@ -411,34 +566,30 @@ func (p *Pod) DoInProcess(ctx context.Context, fn ProcFunc) error {
 		Ctx: ctx,
 		p:   proc,
 	})
-	if err := p.deleteProcess(ctx, proc); err != nil {
+	if durable && ctx.Err() != nil && proc.snapshotted {
+		// leave the process record in place, so it can be resumed.
+		proc.stop()
+	} else if err := p.deleteProcess(context.WithoutCancel(ctx), proc); err != nil {
 		return err
 	}
 
 	p.procsMu.Lock()
 	delete(p.procs, proc.id)
 	p.procsMu.Unlock()
+	p.unsubscribeProc(proc.id)
 
 	return err
 }
 
-type Notif struct {
-	Key string
-}
-
-func (p *Pod) subscribe(procID ProcID, ch chan Notif) {
+// procDone returns a channel which is closed when the process stops.
+// If the process is not running, procDone returns nil.
+func (p *Pod) procDone(procID ProcID) <-chan struct{} {
 	p.procsMu.Lock()
 	defer p.procsMu.Unlock()
-	if p.subs == nil {
-		p.subs = make(map[chan Notif]ProcID)
+	if proc, exists := p.procs[procID]; exists {
+		return proc.done
 	}
-	p.subs[ch] = procID
-}
-
-func (p *Pod) Unsubscribe(ch chan Notif) {
-	p.procsMu.Lock()
-	defer p.procsMu.Unlock()
-	delete(p.subs, ch)
+	return nil
 }
 
 // stopAllThreads sets dead_lteq to last_proc_id, signalling all threads to stop.
@@ -446,7 +597,7 @@ func (p *Pod) Unsubscribe(ch chan Notif) {
 func (p *Pod) stopAllThreads(ctx context.Context) error {
 	var deadLteq ProcID
 	if err := p.env.DB.GetContext(ctx, &deadLteq, `UPDATE pods 
-		SET dead_lteq = last_thread_id
+		SET dead_lteq = last_proc_id
 		WHERE id = ?
 		RETURNING dead_lteq
 		`, p.id); err != nil && !errors.Is(err, sql.ErrNoRows) {
@@ -466,11 +617,11 @@ func (p *Pod) stopAllThreads(ctx context.Context) error {
 }
 
 func (p *Pod) newTxStore(tx *sqlx.Tx) cadata.Store {
-	return sqlstores.NewTxStore(tx, mycelium.Hash, mycelium.MaxSizeBytes, p.storeID)
+	return gcStore{p: p, Store: sqlstores.NewTxStore(tx, mycelium.Hash, mycelium.MaxSizeBytes, p.storeID).WithQuota(p.config().MaxStorageBytes)}
 }
 
 func (p *Pod) newStore() cadata.Store {
-	return sqlstores.NewStore(p.env.DB, mycelium.Hash, mycelium.MaxSizeBytes, p.storeID)
+	return gcStore{p: p, Store: sqlstores.NewStore(p.env.DB, mycelium.Hash, mycelium.MaxSizeBytes, p.storeID).WithQuota(p.config().MaxStorageBytes)}
 }
 
 func (p *Pod) getStore(r dbutil.Reader) cadata.Store {
@@ -481,14 +632,17 @@ func (p *Pod) getStore(r dbutil.Reader) cadata.Store {
 	}
 }
 
-// nsPut does a put in a pod's namespace.
+// nsPut does a put in a pod's namespace, and records it in the history as part of w.
 // it should only be called after the value has been pulled.
-func (p *Pod) nsPut(ctx context.Context, tx *sqlx.Tx, k string, v Value) error {
+func (p *Pod) nsPut(ctx context.Context, tx *sqlx.Tx, w nsWrite, k string, v Value) error {
 	dst := p.newTxStore(tx)
 	data, err := myc.SaveRoot(ctx, dst, myc.NewAnyValue(v))
 	if err != nil {
 		return err
 	}
+	if err := p.gcBarrier(ctx, dst, v); err != nil {
+		return err
+	}
 	if _, err := tx.Exec(`
 		INSERT INTO pod_ns (pod_id, k, v)
 		VALUES (?, ?, ?)
@@ -497,24 +651,48 @@ func (p *Pod) nsPut(ctx context.Context, tx *sqlx.Tx, k string, v Value) error {
 		`, p.id, string(k), data); err != nil {
 		return err
 	}
-	return nil
+	return p.nsRecord(tx, w, k, data)
 }
 
-func (p *Pod) nsCAS(ctx context.Context, tx *sqlx.Tx, k string, prev, next Value) (Value, error) {
+// nsCAS sets k to next if it is currently prev.
+// It returns the value at k after the operation, and whether the swap happened.
+func (p *Pod) nsCAS(ctx context.Context, tx *sqlx.Tx, procID ProcID, k string, prev, next Value) (Value, bool, error) {
 	current, err := p.nsGet(tx, k)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	if myc.Equal(current, prev) {
-		if err := p.nsPut(ctx, tx, k, next); err != nil {
-			return nil, err
+		if err := p.checkDepth(ctx, p.newTxStore(tx), next, p.config().MaxDepth); err != nil {
+			return nil, false, err
+		}
+		w, err := p.beginNSWrite(tx, procID)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := p.nsPut(ctx, tx, w, k, next); err != nil {
+			return nil, false, err
 		}
-		return next, nil
+		return next, true, nil
 	} else {
-		return current, nil
+		return current, false, nil
 	}
 }
 
+// nsChanges returns the keys which are different between prev and next.
+func nsChanges(prev, next myccanon.Namespace) (ret []string) {
+	for k, v := range prev {
+		if v2, exists := next[k]; !exists || !myc.Equal(v, v2) {
+			ret = append(ret, k)
+		}
+	}
+	for k := range next {
+		if _, exists := prev[k]; !exists {
+			ret = append(ret, k)
+		}
+	}
+	return ret
+}
+
 // nsGet does a get on a pod namespace
 func (p *Pod) nsGet(tx dbutil.Reader, k string) (Value, error) {
 	ctx := context.TODO()
@@ -557,37 +735,87 @@ func (p *Pod) nsAll(tx dbutil.Reader, ns myccanon.Namespace) error {
 	return nil
 }
 
-func (p *Pod) overlayNS(dst myccanon.Namespace, procID ProcID, vm *mvm1.VM) {
-	for k, spec := range p.cfg.Devices {
+// overlayNS adds a port for each device to dst, and connects it to vm.
+// If ports contains data for a key, then that port data is reused, otherwise random port data is generated and added to ports.
+func (p *Pod) overlayNS(dst myccanon.Namespace, procID ProcID, vm *mvm1.VM, ports map[string][32]byte) {
+	newPort := func(k string, ty *myc.PortType) *myc.Port {
+		if data, exists := ports[k]; exists {
+			return myc.NewPort(ty, data)
+		}
+		var data [32]byte
+		if _, err := io.ReadFull(p.env.Entropy, data[:]); err != nil {
+			panic(err)
+		}
+		ports[k] = data
+		return myc.NewPort(ty, data)
+	}
+	// devices are visited in order, so that ports are generated deterministically.
+	devices := p.config().Devices
+	for _, k := range slices.Sorted(maps.Keys(devices)) {
+		spec := devices[k]
 		switch {
 		case spec.Console != nil:
-			port := myc.NewRandPort(p.console.PortType())
+			port := newPort(k, p.console.PortType())
 			vm.PutPort(mvm1.PortFromBytes(port.Data()), p.console.Port())
 			dst[k] = port
 		case spec.Cell != nil:
 			c := newCell(p, procID, k)
-			port := myc.NewRandPort(c.PortType())
+			port := newPort(k, c.PortType())
 			vm.PutPort(mvm1.PortFromBytes(port.Data()), c.Port())
 			dst[k] = port
 		case spec.Network != nil:
 			nn := p.networkNodes[spec.Network.KeyIndex]
-			port := myc.NewRandPort(nn.PortType())
+			port := newPort(k, nn.PortType())
 			vm.PutPort(mvm1.PortFromBytes(port.Data()), nn.Port())
 			dst[k] = port
 		case spec.WallClock != nil:
 			clk := p.wallClock
-			port := myc.NewRandPort(clk.PortType())
+			port := newPort(k, clk.PortType())
 			vm.PutPort(mvm1.PortFromBytes(port.Data()), clk.Port())
 			dst[k] = port
 		case spec.Random != nil:
 			rs := p.random
-			port := myc.NewRandPort(rs.PortType())
+			port := newPort(k, rs.PortType())
 			vm.PutPort(mvm1.PortFromBytes(port.Data()), rs.Port())
 			dst[k] = port
+		case spec.Watch != nil:
+			w := newWatch(p, procID)
+			port := newPort(k, w.PortType())
+			vm.PutPort(mvm1.PortFromBytes(port.Data()), w.Port())
+			dst[k] = port
+		case spec.Timer != nil:
+			td := newTimer(p, procID)
+			port := newPort(k, td.PortType())
+			vm.PutPort(mvm1.PortFromBytes(port.Data()), td.Port())
+			dst[k] = port
+		case spec.Mailbox != nil:
+			mb := newMailbox(p, procID, *spec.Mailbox)
+			port := newPort(k, mb.PortType())
+			vm.PutPort(mvm1.PortFromBytes(port.Data()), mb.Port())
+			dst[k] = port
+		case spec.FS != nil:
+			fd := newFS(*spec.FS)
+			port := newPort(k, fd.PortType())
+			vm.PutPort(mvm1.PortFromBytes(port.Data()), fd.Port())
+			dst[k] = port
 		}
 	}
 }
 
+// vmLimits returns the limits for a single evaluation in a process, starting now.
+func (p *Pod) vmLimits() mvm1.Limits {
+	cfg := p.config()
+	lim := mvm1.Limits{
+		MaxSteps:      cfg.MaxSteps,
+		MaxStackWords: cfg.MaxStackWords,
+		MaxPostBytes:  cfg.MaxPostBytes,
+	}
+	if cfg.MaxWallTime > 0 {
+		lim.Deadline = time.Now().Add(cfg.MaxWallTime)
+	}
+	return lim
+}
+
 func (p *Pod) setPodConfig(tx *sqlx.Tx, cfg PodConfig) error {
 	data, err := json.Marshal(cfg)
 	if err != nil {
@@ -0,0 +1,262 @@
+package mycss
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"go.brendoncarroll.net/stdctx/logctx"
+	"go.uber.org/zap"
+
+	"myceliumweb.org/mycelium"
+	"myceliumweb.org/mycelium/internal/cadata"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/mycss/internal/dbutil"
+	"myceliumweb.org/mycelium/mycss/internal/sqlstores"
+)
+
+// gcBatchSize is the number of blobs considered by each sweep transaction.
+const gcBatchSize = 256
+
+// GCStats summarizes the work done by a call to Pod.GC
+type GCStats struct {
+	// Reachable is the number of blobs reachable from the namespace and its history.
+	Reachable int
+	// Removed is the number of blobs removed from the Pod's store.
+	Removed int
+	// BytesReclaimed is the number of bytes freed from the database.
+	BytesReclaimed int64
+}
+
+// GC removes all the blobs from the Pod's store which are not reachable from the namespace,
+// from any version of the namespace retained in its history, from any recording,
+// or from any message waiting in its mailbox.
+//
+// GC does not hold a lock on the database for its whole duration.
+// The namespace is marked one entry at a time, and the store is swept in small batches,
+// so processes are able to continue reading and writing the namespace while GC is running.
+// Any value written to the namespace while GC is running is marked as it is written,
+// and so is any blob posted to the Pod's store, in case it is written to the namespace later.
+func (p *Pod) GC(ctx context.Context) (GCStats, error) {
+	p.gcMu.Lock()
+	defer p.gcMu.Unlock()
+
+	ms := newMarkSet()
+	p.setMarkSet(ms)
+	defer p.setMarkSet(nil)
+	p.awaitPosts()
+	if err := p.gcMark(ctx, ms); err != nil {
+		return GCStats{}, err
+	}
+	stats, err := p.gcSweep(ctx, ms)
+	if err != nil {
+		return GCStats{}, err
+	}
+	stats.Reachable = ms.len()
+	logctx.Info(ctx, "gc complete",
+		zap.Uint64("pod", uint64(p.id)),
+		zap.Int("reachable", stats.Reachable),
+		zap.Int("removed", stats.Removed),
+		zap.Int64("bytes_reclaimed", stats.BytesReclaimed),
+	)
+	return stats, nil
+}
+
+// gcMark marks everything reachable from the namespace, its history, the recordings and the mailbox.
+func (p *Pod) gcMark(ctx context.Context, ms *markSet) error {
+	var keys []string
+	if err := p.env.DB.SelectContext(ctx, &keys, `SELECT k FROM pod_ns WHERE pod_id = ?`, p.id); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := dbutil.DoTx(ctx, p.env.DB, func(tx *sqlx.Tx) error {
+			v, err := p.nsGet(tx, k)
+			if err != nil {
+				return err
+			}
+			if v == nil {
+				return nil
+			}
+			return ms.mark(ctx, p.newTxStore(tx), v)
+		}); err != nil {
+			return err
+		}
+	}
+
+	// everything which can still be reached through the history is also kept.
+	roots, err := p.historyRoots(ctx, p.env.DB)
+	if err != nil {
+		return err
+	}
+	// and everything needed to replay a recording.
+	recRoots, err := p.recordingRoots(ctx, p.env.DB)
+	if err != nil {
+		return err
+	}
+	roots = append(roots, recRoots...)
+	// and the messages waiting in the mailbox.
+	inboxRoots, err := p.inboxRoots(ctx, p.env.DB)
+	if err != nil {
+		return err
+	}
+	roots = append(roots, inboxRoots...)
+	for _, root := range roots {
+		if err := dbutil.DoTx(ctx, p.env.DB, func(tx *sqlx.Tx) error {
+			s := p.newTxStore(tx)
+			av, err := myc.LoadRoot(ctx, s, root)
+			if err != nil {
+				return err
+			}
+			return ms.mark(ctx, s, av.Unwrap())
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gcSweep removes every blob which is not marked from the Pod's store.
+func (p *Pod) gcSweep(ctx context.Context, ms *markSet) (GCStats, error) {
+	stats := GCStats{}
+	var begin cadata.ID
+	for {
+		done, err := dbutil.DoTx1(ctx, p.env.DB, func(tx *sqlx.Tx) (bool, error) {
+			s := sqlstores.NewTxStore(tx, mycelium.Hash, mycelium.MaxSizeBytes, p.storeID)
+			ids := make([]cadata.ID, gcBatchSize)
+			n, err := s.List(ctx, cadata.Span{}.WithLowerIncl(begin), ids)
+			if err != nil {
+				return false, err
+			}
+			ids = ids[:n]
+			var garbage []cadata.ID
+			for _, id := range ids {
+				if !ms.contains(id) {
+					garbage = append(garbage, id)
+				}
+			}
+			reclaimed, err := sqlstores.RemoveBlobs(tx, p.storeID, garbage)
+			if err != nil {
+				return false, err
+			}
+			stats.Removed += len(garbage)
+			stats.BytesReclaimed += reclaimed
+			if n < gcBatchSize {
+				return true, nil
+			}
+			begin = ids[n-1].Successor()
+			return false, nil
+		})
+		if err != nil {
+			return GCStats{}, err
+		}
+		if done {
+			break
+		}
+	}
+	return stats, nil
+}
+
+func (p *Pod) setMarkSet(ms *markSet) {
+	p.gcMarkMu.Lock()
+	defer p.gcMarkMu.Unlock()
+	p.gcMarks = ms
+}
+
+// awaitPosts waits for the blobs which began being posted before the mark set was installed.
+// Any blob posted after that is marked by the allocation barrier in gcStore.
+func (p *Pod) awaitPosts() {
+	p.gcMarkMu.Lock()
+	defer p.gcMarkMu.Unlock()
+	for p.gcPosts > 0 {
+		p.gcPostsDone.Wait()
+	}
+}
+
+// gcBarrier marks v as reachable if there is a garbage collection in progress.
+// It must be called whenever a value is written to the namespace.
+func (p *Pod) gcBarrier(ctx context.Context, src cadata.Getter, v Value) error {
+	p.gcMarkMu.Lock()
+	ms := p.gcMarks
+	p.gcMarkMu.Unlock()
+	if ms == nil {
+		return nil
+	}
+	return ms.mark(ctx, src, v)
+}
+
+// gcStore is the Pod's store, with an allocation barrier.
+// Blobs posted while a garbage collection is in progress are marked,
+// so that they are not swept before a value referring to them is written to the namespace.
+type gcStore struct {
+	cadata.Store
+	p *Pod
+}
+
+func (s gcStore) Post(ctx context.Context, salt *cadata.ID, data []byte) (cadata.ID, error) {
+	p := s.p
+	p.gcMarkMu.Lock()
+	ms := p.gcMarks
+	if ms != nil {
+		ms.add(mycelium.Hash(salt, data))
+	} else {
+		p.gcPosts++
+	}
+	p.gcMarkMu.Unlock()
+	if ms == nil {
+		defer func() {
+			p.gcMarkMu.Lock()
+			defer p.gcMarkMu.Unlock()
+			p.gcPosts--
+			if p.gcPosts == 0 {
+				p.gcPostsDone.Broadcast()
+			}
+		}()
+	}
+	return s.Store.Post(ctx, salt, data)
+}
+
+var _ cadata.PostExister = &markSet{}
+
+// markSet is a PostExister which only remembers the IDs of the blobs posted to it.
+// Pulling a Value into a markSet marks every blob reachable from that Value.
+type markSet struct {
+	mu  sync.Mutex
+	ids map[cadata.ID]struct{}
+}
+
+func newMarkSet() *markSet {
+	return &markSet{ids: make(map[cadata.ID]struct{})}
+}
+
+func (ms *markSet) mark(ctx context.Context, src cadata.Getter, v Value) error {
+	return myc.NewAnyValue(v).PullInto(ctx, ms, src)
+}
+
+func (ms *markSet) Post(ctx context.Context, salt *cadata.ID, data []byte) (cadata.ID, error) {
+	id := mycelium.Hash(salt, data)
+	ms.add(id)
+	return id, nil
+}
+
+func (ms *markSet) add(id cadata.ID) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.ids[id] = struct{}{}
+}
+
+func (ms *markSet) Exists(ctx context.Context, id *cadata.ID) (bool, error) {
+	return ms.contains(*id), nil
+}
+
+func (ms *markSet) contains(id cadata.ID) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	_, exists := ms.ids[id]
+	return exists
+}
+
+func (ms *markSet) len() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return len(ms.ids)
+}
@@ -0,0 +1,287 @@
+package mycss
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"myceliumweb.org/mycelium/mvm1"
+	"myceliumweb.org/mycelium/myccanon"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/mycss/internal/dbutil"
+)
+
+// NSVersion identifies a state of a Pod's namespace.
+// Each transaction which changes the namespace creates a new version.
+// Version 0 is the namespace before any changes were recorded.
+type NSVersion uint64
+
+// NSEntry is a single change to a key in a Pod's namespace.
+type NSEntry struct {
+	Version NSVersion
+	Key     string
+	// Value is nil if the key was deleted.
+	Value Value
+	// ProcID is the process which made the change, or 0 if it was made from outside a process.
+	ProcID    ProcID
+	CreatedAt time.Time
+}
+
+// ErrHistoryPruned is returned when reading a version of a namespace older than the retained history.
+type ErrHistoryPruned struct {
+	PodID   PodID
+	Version NSVersion
+	Start   NSVersion
+}
+
+func (e ErrHistoryPruned) Error() string {
+	return fmt.Sprintf("pod %d: version %d has been pruned. oldest version is %d", e.PodID, e.Version, e.Start)
+}
+
+// NSVersion returns the current version of the Pod's namespace.
+func (p *Pod) NSVersion(ctx context.Context) (NSVersion, error) {
+	var ver NSVersion
+	err := p.env.DB.GetContext(ctx, &ver, `SELECT ns_version FROM pods WHERE id = ?`, p.id)
+	return ver, err
+}
+
+// GetAllAt returns the Pod's namespace as it was at version ver.
+// Like GetAll, it includes a port for each device.
+func (p *Pod) GetAllAt(ctx context.Context, ver NSVersion) (myccanon.Namespace, error) {
+	ns := myccanon.Namespace{}
+	if err := dbutil.DoTx(ctx, p.env.DB, func(tx *sqlx.Tx) error {
+		clear(ns)
+		return p.nsAllAt(ctx, tx, ver, ns)
+	}); err != nil {
+		return nil, err
+	}
+	p.overlayNS(ns, 0, mvm1.New(0, nil, mvm1.DefaultAccels()), make(map[string][32]byte))
+	return ns, nil
+}
+
+// History returns every recorded change to k, oldest first.
+func (p *Pod) History(ctx context.Context, k string) ([]NSEntry, error) {
+	return dbutil.DoTx1(ctx, p.env.DB, func(tx *sqlx.Tx) ([]NSEntry, error) {
+		var rows []struct {
+			Version   NSVersion `db:"version"`
+			V         []byte    `db:"v"`
+			ProcID    ProcID    `db:"proc_id"`
+			CreatedAt time.Time `db:"created_at"`
+		}
+		if err := tx.SelectContext(ctx, &rows, `SELECT version, v, proc_id, created_at FROM pod_ns_history
+			WHERE pod_id = ? AND k = ?
+			ORDER BY version`, p.id, k); err != nil {
+			return nil, err
+		}
+		s := p.newTxStore(tx)
+		ret := make([]NSEntry, len(rows))
+		for i, row := range rows {
+			ret[i] = NSEntry{
+				Version:   row.Version,
+				Key:       k,
+				ProcID:    row.ProcID,
+				CreatedAt: row.CreatedAt,
+			}
+			if row.V != nil {
+				av, err := myc.LoadRoot(ctx, s, row.V)
+				if err != nil {
+					return nil, err
+				}
+				ret[i].Value = av.Unwrap()
+			}
+		}
+		return ret, nil
+	})
+}
+
+// Rollback sets the Pod's namespace to what it was at version ver.
+// The rollback is itself recorded as a new version, so it can be undone.
+func (p *Pod) Rollback(ctx context.Context, ver NSVersion) error {
+	p.procsMu.Lock()
+	defer p.procsMu.Unlock()
+	var changed []string
+	if err := dbutil.DoTx(ctx, p.env.DB, func(tx *sqlx.Tx) error {
+		prev := myccanon.Namespace{}
+		if err := p.nsAll(tx, prev); err != nil {
+			return err
+		}
+		next := myccanon.Namespace{}
+		if err := p.nsAllAt(ctx, tx, ver, next); err != nil {
+			return err
+		}
+		changed = nsChanges(prev, next)
+		return p.nsApply(ctx, tx, 0, next, changed)
+	}); err != nil {
+		return err
+	}
+	p.publish(changed...)
+	return nil
+}
+
+// PruneHistory forgets all the versions of the namespace before ver.
+// The namespace can still be read at ver and any version after it.
+// Blobs which are only reachable from the forgotten versions are removed by the next GC.
+func (p *Pod) PruneHistory(ctx context.Context, ver NSVersion) error {
+	return dbutil.DoTx(ctx, p.env.DB, func(tx *sqlx.Tx) error {
+		start, current, err := p.historyBounds(tx)
+		if err != nil {
+			return err
+		}
+		if ver <= start {
+			return nil
+		}
+		if ver > current {
+			return fmt.Errorf("cannot prune to version %d, current version is %d", ver, current)
+		}
+		// delete every entry which is shadowed by another entry at or before ver.
+		if _, err := tx.ExecContext(ctx, `DELETE FROM pod_ns_history AS h
+			WHERE h.pod_id = ? AND h.version < ? AND EXISTS (
+				SELECT 1 FROM pod_ns_history AS h2
+				WHERE h2.pod_id = h.pod_id AND h2.k = h.k AND h2.version > h.version AND h2.version <= ?
+			)`, p.id, ver, ver); err != nil {
+			return err
+		}
+		// a deletion at or before ver is now the only entry for its key at or before ver,
+		// so the key reads as absent without it.
+		if _, err := tx.ExecContext(ctx, `DELETE FROM pod_ns_history
+			WHERE pod_id = ? AND version <= ? AND v IS NULL`, p.id, ver); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE pods SET ns_history_start = ? WHERE id = ?`, ver, p.id)
+		return err
+	})
+}
+
+// PruneHistoryBefore forgets all the versions of the namespace which were replaced before t.
+// The namespace can still be read as it was at t.
+func (p *Pod) PruneHistoryBefore(ctx context.Context, t time.Time) error {
+	var ver NSVersion
+	if err := p.env.DB.GetContext(ctx, &ver, `SELECT COALESCE(MAX(version), 0) FROM pod_ns_history
+		WHERE pod_id = ? AND created_at < ?`, p.id, t.UTC().Format(time.DateTime)); err != nil {
+		return err
+	}
+	return p.PruneHistory(ctx, ver)
+}
+
+// historyBounds returns the oldest and newest versions of the namespace which can be read.
+func (p *Pod) historyBounds(tx *sqlx.Tx) (start, current NSVersion, _ error) {
+	var row struct {
+		Start   NSVersion `db:"ns_history_start"`
+		Current NSVersion `db:"ns_version"`
+	}
+	if err := tx.Get(&row, `SELECT ns_history_start, ns_version FROM pods WHERE id = ?`, p.id); err != nil {
+		return 0, 0, err
+	}
+	return row.Start, row.Current, nil
+}
+
+// nsAllAt writes the namespace as it was at version ver to ns.
+func (p *Pod) nsAllAt(ctx context.Context, tx *sqlx.Tx, ver NSVersion, ns myccanon.Namespace) error {
+	start, current, err := p.historyBounds(tx)
+	if err != nil {
+		return err
+	}
+	if ver < start {
+		return ErrHistoryPruned{PodID: p.id, Version: ver, Start: start}
+	}
+	if ver > current {
+		return fmt.Errorf("pod %d: version %d does not exist yet", p.id, ver)
+	}
+	var rows []struct {
+		K string `db:"k"`
+		V []byte `db:"v"`
+	}
+	if err := tx.SelectContext(ctx, &rows, `SELECT h.k, h.v FROM pod_ns_history AS h
+		WHERE h.pod_id = ? AND h.version = (
+			SELECT MAX(h2.version) FROM pod_ns_history AS h2
+			WHERE h2.pod_id = h.pod_id AND h2.k = h.k AND h2.version <= ?
+		)`, p.id, ver); err != nil {
+		return err
+	}
+	s := p.newTxStore(tx)
+	for _, row := range rows {
+		if row.V == nil {
+			continue
+		}
+		av, err := myc.LoadRoot(ctx, s, row.V)
+		if err != nil {
+			return err
+		}
+		ns[row.K] = av.Unwrap()
+	}
+	return nil
+}
+
+// nsApply writes the keys in changed to the namespace as a single new version.
+// The values for keys in changed are taken from next, and keys missing from next are deleted.
+// The values must already have been pulled into the Pod's store.
+func (p *Pod) nsApply(ctx context.Context, tx *sqlx.Tx, procID ProcID, next myccanon.Namespace, changed []string) error {
+	if len(changed) == 0 {
+		return nil
+	}
+	w, err := p.beginNSWrite(tx, procID)
+	if err != nil {
+		return err
+	}
+	for _, k := range changed {
+		if v, exists := next[k]; exists {
+			if err := p.nsPut(ctx, tx, w, k, v); err != nil {
+				return err
+			}
+		} else {
+			if err := p.nsDelete(ctx, tx, w, k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nsWrite is a new version of the namespace, being written in a transaction.
+type nsWrite struct {
+	version NSVersion
+	procID  ProcID
+}
+
+// beginNSWrite allocates a new version of the namespace.
+// All the changes in the version should be made in tx.
+func (p *Pod) beginNSWrite(tx *sqlx.Tx, procID ProcID) (nsWrite, error) {
+	var ver NSVersion
+	if err := tx.Get(&ver, `UPDATE pods SET ns_version = ns_version + 1
+		WHERE id = ?
+		RETURNING ns_version`, p.id); err != nil {
+		return nsWrite{}, err
+	}
+	return nsWrite{version: ver, procID: procID}, nil
+}
+
+// nsRecord adds an entry to the namespace history.
+// data is the root of the new value, or nil if the key was deleted.
+func (p *Pod) nsRecord(tx *sqlx.Tx, w nsWrite, k string, data []byte) error {
+	_, err := tx.Exec(`INSERT INTO pod_ns_history (pod_id, version, k, v, proc_id)
+		VALUES (?, ?, ?, ?, ?)`, p.id, w.version, k, data, w.procID)
+	return err
+}
+
+// nsDelete removes k from the namespace.
+func (p *Pod) nsDelete(ctx context.Context, tx *sqlx.Tx, w nsWrite, k string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM pod_ns WHERE pod_id = ? AND k = ?`, p.id, k); err != nil {
+		return err
+	}
+	return p.nsRecord(tx, w, k, nil)
+}
+
+// historyRoots returns the distinct roots in the Pod's namespace history.
+func (p *Pod) historyRoots(ctx context.Context, r dbutil.Reader) ([][]byte, error) {
+	var roots [][]byte
+	if err := r.Select(&roots, `SELECT DISTINCT v FROM pod_ns_history WHERE pod_id = ? AND v IS NOT NULL`, p.id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return roots, nil
+}
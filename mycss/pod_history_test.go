@@ -0,0 +1,115 @@
+package mycss
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/myccanon"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+func TestHistory(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+
+	require.NoError(t, p.Put(ctx, s, "x", myc.NewB32(1)))
+	v1, err := p.NSVersion(ctx)
+	require.NoError(t, err)
+	require.NoError(t, p.Put(ctx, s, "x", myc.NewB32(2)))
+	reset(t, p, s, myccanon.Namespace{"y": myc.NewB32(3)}, PodConfig{})
+	v3, err := p.NSVersion(ctx)
+	require.NoError(t, err)
+
+	hist, err := p.History(ctx, "x")
+	require.NoError(t, err)
+	require.Len(t, hist, 3)
+	require.Equal(t, myc.NewB32(1), hist[0].Value)
+	require.Equal(t, myc.NewB32(2), hist[1].Value)
+	require.Nil(t, hist[2].Value)
+	require.Equal(t, v3, hist[2].Version)
+
+	ns, err := p.GetAllAt(ctx, v1)
+	require.NoError(t, err)
+	require.Equal(t, myccanon.Namespace{"x": myc.NewB32(1)}, ns)
+
+	require.NoError(t, p.Rollback(ctx, v1))
+	x, err := p.Get(ctx, "x")
+	require.NoError(t, err)
+	require.Equal(t, myc.NewB32(1), x)
+	y, err := p.Get(ctx, "y")
+	require.NoError(t, err)
+	require.Nil(t, y)
+
+	// the rollback can itself be undone.
+	require.NoError(t, p.Rollback(ctx, v3))
+	y, err = p.Get(ctx, "y")
+	require.NoError(t, err)
+	require.Equal(t, myc.NewB32(3), y)
+
+	require.NoError(t, p.PruneHistory(ctx, v3))
+	_, err = p.GetAllAt(ctx, v1)
+	require.ErrorAs(t, err, &ErrHistoryPruned{})
+	ns, err = p.GetAllAt(ctx, v3)
+	require.NoError(t, err)
+	require.Equal(t, myccanon.Namespace{"y": myc.NewB32(3)}, ns)
+}
+
+func TestHistoryCell(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	reset(t, p, s, myccanon.Namespace{
+		"cell0": myc.NewB32(13),
+	}, PodConfig{
+		Devices: map[string]DeviceSpec{"cell0": DevCell()},
+	})
+	cellCAS(t, p, s, "cell0", myc.NewB32(13), myc.NewB32(22))
+
+	hist, err := p.History(ctx, "cell0")
+	require.NoError(t, err)
+	require.Len(t, hist, 2)
+	require.Equal(t, ProcID(0), hist[0].ProcID)
+	require.NotEqual(t, ProcID(0), hist[1].ProcID)
+	require.Equal(t, myc.NewB32(22), hist[1].Value)
+}
+
+func TestPruneHistoryDeleted(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+
+	require.NoError(t, p.Put(ctx, s, "x", myc.NewString("hello world")))
+	v1, err := p.NSVersion(ctx)
+	require.NoError(t, err)
+	reset(t, p, s, myccanon.Namespace{"y": myc.NewB32(3)}, PodConfig{})
+	// nothing has been replaced before the first version was written.
+	require.NoError(t, p.PruneHistoryBefore(ctx, time.Now().Add(-time.Hour)))
+	ns, err := p.GetAllAt(ctx, v1)
+	require.NoError(t, err)
+	require.Equal(t, myccanon.Namespace{"x": myc.NewString("hello world")}, ns)
+
+	require.NoError(t, p.PruneHistoryBefore(ctx, time.Now().Add(time.Hour)))
+	_, err = p.GetAllAt(ctx, v1)
+	require.ErrorAs(t, err, &ErrHistoryPruned{})
+	hist, err := p.History(ctx, "x")
+	require.NoError(t, err)
+	require.Empty(t, hist)
+	stats, err := p.GC(ctx)
+	require.NoError(t, err)
+	require.Greater(t, stats.Removed, 0)
+
+	ns, err = p.GetAll(ctx)
+	require.NoError(t, err)
+	require.Equal(t, myc.NewB32(3), ns["y"])
+	require.NotContains(t, ns, "x")
+}
@@ -0,0 +1,58 @@
+package mycss
+
+import (
+	"context"
+
+	"myceliumweb.org/mycelium/internal/cadata"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+// checkDepth returns ErrMaxDepth if following Refs from v can reach a depth greater than maxDepth.
+// All of the Refs in v must be loadable from src.
+// If maxDepth is 0, then there is no limit.
+func (p *Pod) checkDepth(ctx context.Context, src cadata.Getter, v Value, maxDepth int64) error {
+	if maxDepth <= 0 {
+		return nil
+	}
+	memo := make(map[cadata.ID]int64)
+	var depthOf func(x Value, d int64) (int64, error)
+	depthOf = func(x Value, d int64) (int64, error) {
+		if d > maxDepth {
+			return 0, ErrMaxDepth{PodID: p.id, Max: maxDepth}
+		}
+		ref, ok := x.(*myc.Ref)
+		if !ok {
+			var ret int64
+			for c := range x.Components() {
+				if c == nil {
+					continue
+				}
+				cd, err := depthOf(c, d)
+				if err != nil {
+					return 0, err
+				}
+				ret = max(ret, cd)
+			}
+			return ret, nil
+		}
+		cid := cadata.ID(ref.Data())
+		if below, exists := memo[cid]; exists {
+			if d+1+below > maxDepth {
+				return 0, ErrMaxDepth{PodID: p.id, Max: maxDepth}
+			}
+			return 1 + below, nil
+		}
+		y, err := myc.Load(ctx, src, *ref)
+		if err != nil {
+			return 0, err
+		}
+		below, err := depthOf(y, d+1)
+		if err != nil {
+			return 0, err
+		}
+		memo[cid] = below
+		return 1 + below, nil
+	}
+	_, err := depthOf(v, 0)
+	return err
+}
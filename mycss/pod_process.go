@@ -2,8 +2,10 @@ package mycss
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 
@@ -22,10 +24,19 @@ type process struct {
 	p       *Pod
 	id      ProcID
 	storeID sqlstores.StoreID
+	// durable processes keep their store in the database, and can be resumed from a snapshot.
+	durable bool
 
 	store cadata.Store
 	vm    *mvm1.VM
 	ns    myccanon.Namespace
+	ports map[string][32]byte
+
+	lastSnapshot time.Time
+	// snapshotted is true once the process has a snapshot in its record, which it can be resumed from.
+	snapshotted bool
+	// runs is the number of times the VM has been run, it numbers the process's recordings.
+	runs int
 
 	stopOnce sync.Once
 	done     chan struct{}
@@ -34,21 +45,33 @@ type process struct {
 // newProcess allocates resources for a new process and returns it.
 // - it allocates a new ProcID from the Pod's counter.
 // - it does not add the process to the process table.
-func (pod *Pod) newProcess(ctx context.Context) (*process, error) {
+// If prev is not nil, then the new process takes over the store and ports of the interrupted process.
+func (pod *Pod) newProcess(ctx context.Context, durable bool, prev *procRecord) (*process, error) {
 	ns := myccanon.Namespace{}
 	var storeID sqlstores.StoreID
 	var procID ProcID
+	ports := make(map[string][32]byte)
+	if prev != nil {
+		if err := json.Unmarshal(prev.Ports, &ports); err != nil {
+			return nil, err
+		}
+	}
 	if err := dbutil.DoTx(ctx, pod.env.DB, func(tx *sqlx.Tx) error {
 		clear(ns)
 		if err := pod.nsAll(tx, ns); err != nil {
 			return err
 		}
 		var err error
-		storeID, err = sqlstores.CreateStore(tx)
+		procID, err = pod.nextProcID(tx)
 		if err != nil {
 			return err
 		}
-		procID, err = pod.nextProcID(tx)
+		if prev != nil {
+			storeID = prev.StoreID
+			_, err := tx.Exec(`UPDATE pod_procs SET proc_id = ? WHERE pod_id = ? AND proc_id = ?`, procID, pod.id, prev.ProcID)
+			return err
+		}
+		storeID, err = sqlstores.CreateStore(tx)
 		if err != nil {
 			return err
 		}
@@ -60,20 +83,39 @@ func (pod *Pod) newProcess(ctx context.Context) (*process, error) {
 		p:       pod,
 		id:      procID,
 		storeID: storeID,
+		durable: durable,
+		ns:      ns,
+		ports:   ports,
+		done:    make(chan struct{}),
+
+		lastSnapshot: time.Now(),
+		snapshotted:  prev != nil,
+	}
+	if durable {
+		proc.store = stores.WriteThrough{
+			Cache: stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes),
+			Store: sqlstores.NewStore(pod.env.DB, mycelium.Hash, mycelium.MaxSizeBytes, storeID),
+		}
+	} else {
 		// TODO: cache
-		// stores: sqlstores.NewStore(pr.p.env.DB, mycelium.Hash, mycelium.MaxSizeBytes, pr.storeID)
-		store: stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes),
-		ns:    ns,
-		done:  make(chan struct{}),
+		proc.store = stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes)
 	}
 	proc.vm = mvm1.New(0, proc.getStore(), mvm1.DefaultAccels())
-	pod.overlayNS(ns, procID, proc.vm)
+	pod.overlayNS(ns, procID, proc.vm, proc.ports)
+	if durable && prev == nil {
+		if err := proc.saveRecord(ctx, nil); err != nil {
+			return nil, err
+		}
+	}
 	return proc, nil
 }
 
 func (p *Pod) deleteProcess(ctx context.Context, proc *process) error {
 	proc.stop()
 	return dbutil.DoTx(ctx, p.env.DB, func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM pod_procs WHERE pod_id = ? AND proc_id = ?`, p.id, proc.id); err != nil {
+			return err
+		}
 		if err := sqlstores.DropStore(tx, proc.storeID); err != nil {
 			return err
 		}
@@ -0,0 +1,175 @@
+package mycss
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"myceliumweb.org/mycelium"
+	"myceliumweb.org/mycelium/internal/stores"
+	"myceliumweb.org/mycelium/mvm1"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/mycss/internal/dbutil"
+)
+
+// RecordingInfo describes a recorded run of a process's VM.
+// A process has one recording for each evaluation, numbered by Run.
+type RecordingInfo struct {
+	ProcID ProcID `db:"proc_id"`
+	Run    int    `db:"run"`
+	// Steps is the number of steps the VM had taken when the run ended.
+	Steps uint64 `db:"steps"`
+	// Halted is false if the run was interrupted before the VM halted.
+	Halted bool `db:"halted"`
+	// Err is the error the VM faulted with, if any.
+	Err       string    `db:"err"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Recordings returns information about every recording of the process procID.
+// If procID is 0, the recordings of every process are returned.
+func (p *Pod) Recordings(ctx context.Context, procID ProcID) ([]RecordingInfo, error) {
+	var ret []RecordingInfo
+	if err := p.env.DB.SelectContext(ctx, &ret, `SELECT proc_id, run, steps, halted, err, created_at
+		FROM pod_recordings
+		WHERE pod_id = ? AND (? = 0 OR proc_id = ?)
+		ORDER BY proc_id, run`, p.id, procID, procID); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Replay re-executes a recorded run, with all of its port calls served from the recording.
+// If the VM does not make exactly the same port calls, or does not end in the same state,
+// Replay returns an mvm1.ErrDivergence.
+func (p *Pod) Replay(ctx context.Context, procID ProcID, run int) error {
+	var row struct {
+		Root []byte `db:"root"`
+		RecordingInfo
+	}
+	if err := p.env.DB.GetContext(ctx, &row, `SELECT proc_id, run, root, steps, halted, err, created_at
+		FROM pod_recordings
+		WHERE pod_id = ? AND proc_id = ? AND run = ?`, p.id, procID, run); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("pod %d has no recording of process %d run %d", p.id, procID, run)
+		}
+		return err
+	}
+	src := p.newStore()
+	av, err := myc.LoadRoot(ctx, src, row.Root)
+	if err != nil {
+		return err
+	}
+	rec, err := mvm1.LoadRecording(ctx, src, *av.Unwrap().(*myc.Ref))
+	if err != nil {
+		return err
+	}
+
+	// the VM only has the blobs which were read during the recording.
+	s := stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes)
+	for _, blob := range rec.Blobs {
+		if _, err := s.Post(ctx, blob.Salt, blob.Data); err != nil {
+			return err
+		}
+	}
+	vm := mvm1.New(0, s, mvm1.DefaultAccels())
+	if err := vm.Restore(ctx, src, rec.Start); err != nil {
+		return err
+	}
+	rp := mvm1.NewReplayer(rec.Calls)
+	rp.Install(vm)
+	lim := p.vmLimits()
+	lim.Deadline = time.Time{}
+	vm.SetLimits(lim)
+	if vm.Steps() <= row.Steps {
+		vm.Run(ctx, row.Steps-vm.Steps())
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := rp.Err(); err != nil {
+		return err
+	}
+
+	diverge := func(format string, args ...any) error {
+		return mvm1.ErrDivergence{Index: len(rec.Calls) - rp.Remaining(), Reason: fmt.Sprintf(format, args...)}
+	}
+	var vmErr string
+	if err := vm.Err(); err != nil {
+		vmErr = err.Error()
+	}
+	// the wall clock is not replayed, so a deadline fault shows up as the VM stopping at the same step.
+	deadline := row.Err == (mvm1.ErrLimitExceeded{Limit: mvm1.LimitDeadline}).Error()
+	switch {
+	case vm.Steps() != row.Steps:
+		return diverge("stopped after %d steps, recorded %d", vm.Steps(), row.Steps)
+	case deadline && vmErr == "":
+	case vmErr != row.Err:
+		return diverge("error %q, recorded %q", vmErr, row.Err)
+	case vm.Halted() != row.Halted:
+		return diverge("halted=%v, recorded %v", vm.Halted(), row.Halted)
+	case rp.Remaining() > 0:
+		return diverge("%d recorded port calls were not made", rp.Remaining())
+	}
+	return nil
+}
+
+// saveRecording saves a recording of the process's last run to the Pod's store.
+// start is a snapshot of the VM when the recording began, in the process's store.
+func (pr *process) saveRecording(ctx context.Context, start myc.Ref, r *mvm1.Recorder) error {
+	var vmErr string
+	if err := pr.vm.Err(); err != nil {
+		vmErr = err.Error()
+	}
+	rec := mvm1.Recording{
+		Start: start,
+		Blobs: r.Blobs(),
+		Calls: r.Calls(),
+	}
+	run := pr.runs
+	pr.runs++
+	p := pr.p
+	return dbutil.DoTx(ctx, p.env.DB, func(tx *sqlx.Tx) error {
+		dst := p.newTxStore(tx)
+		if err := start.PullInto(ctx, dst, pr.getStore()); err != nil {
+			return err
+		}
+		ref, err := rec.Post(ctx, dst)
+		if err != nil {
+			return err
+		}
+		root, err := myc.SaveRoot(ctx, dst, myc.NewAnyValue(&ref))
+		if err != nil {
+			return err
+		}
+		if err := p.gcBarrier(ctx, dst, &ref); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO pod_recordings (pod_id, proc_id, run, root, steps, halted, err)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`, p.id, pr.id, run, root, pr.vm.Steps(), pr.vm.Halted(), vmErr)
+		return err
+	})
+}
+
+// DropRecordings deletes all of the Pod's recordings.
+// The blobs they used are removed by the next GC.
+func (p *Pod) DropRecordings(ctx context.Context) error {
+	_, err := p.env.DB.ExecContext(ctx, `DELETE FROM pod_recordings WHERE pod_id = ?`, p.id)
+	return err
+}
+
+// recordingRoots returns the roots of the Pod's recordings.
+func (p *Pod) recordingRoots(ctx context.Context, r dbutil.Reader) ([][]byte, error) {
+	var roots [][]byte
+	if err := r.Select(&roots, `SELECT root FROM pod_recordings WHERE pod_id = ?`, p.id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return roots, nil
+}
@@ -0,0 +1,57 @@
+package mycss
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/mvm1"
+	"myceliumweb.org/mycelium/myccanon"
+)
+
+func TestRecordReplay(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	reset(t, p, s, myccanon.Namespace{}, PodConfig{
+		Record: true,
+		Devices: map[string]DeviceSpec{
+			"clock0":  DevWallClock(),
+			"random0": DevRandom(),
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		eval(t, p, s, func(eb EB) *Expr {
+			return eb.Product(
+				CurrentTimeExpr(GetWallClock(eb.P(0), "clock0")),
+				GenRandomExpr(GetRandom(eb.P(0), "random0"), 32),
+			)
+		})
+	}
+	recs, err := p.Recordings(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, recs, 2)
+	// recordings are kept by GC.
+	_, err = p.GC(ctx)
+	require.NoError(t, err)
+	for _, rec := range recs {
+		require.True(t, rec.Halted)
+		require.Empty(t, rec.Err)
+		require.NoError(t, p.Replay(ctx, rec.ProcID, rec.Run))
+	}
+
+	// a recording which does not match the execution is reported.
+	_, err = sys.db.ExecContext(ctx, `UPDATE pod_recordings SET steps = steps + 1 WHERE pod_id = ?`, p.ID())
+	require.NoError(t, err)
+	err = p.Replay(ctx, recs[0].ProcID, recs[0].Run)
+	require.ErrorAs(t, err, &mvm1.ErrDivergence{})
+
+	require.NoError(t, p.DropRecordings(ctx))
+	recs, err = p.Recordings(ctx, 0)
+	require.NoError(t, err)
+	require.Empty(t, recs)
+}
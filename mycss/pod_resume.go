@@ -0,0 +1,210 @@
+package mycss
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.brendoncarroll.net/stdctx/logctx"
+	"go.uber.org/zap"
+
+	"myceliumweb.org/mycelium"
+	"myceliumweb.org/mycelium/internal/cadata"
+	"myceliumweb.org/mycelium/mvm1"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/mycss/internal/dbutil"
+	"myceliumweb.org/mycelium/mycss/internal/sqlstores"
+)
+
+// runChunkSteps is the number of steps a process runs between checking for cancellation,
+// and taking snapshots.
+const runChunkSteps = 1 << 16
+
+// procRecord is a row in the pod_procs table.
+// Each row is a durable process, which can be resumed if it is interrupted.
+type procRecord struct {
+	ProcID  ProcID            `db:"proc_id"`
+	StoreID sqlstores.StoreID `db:"store_id"`
+	// Ports is a JSON object mapping namespace keys to port data
+	Ports []byte `db:"ports"`
+	// Snapshot is the CID of the last snapshot of the process's VM, or nil if there has not been one.
+	Snapshot []byte `db:"snapshot"`
+}
+
+// Interrupted returns the IDs of durable processes which were running in a previous instance
+// of the Pod, and have a snapshot they can be resumed from.
+func (p *Pod) Interrupted(ctx context.Context) ([]ProcID, error) {
+	p.procsMu.Lock()
+	defer p.procsMu.Unlock()
+	var rows []ProcID
+	if err := p.env.DB.SelectContext(ctx, &rows, `SELECT proc_id FROM pod_procs
+		WHERE pod_id = ? AND snapshot IS NOT NULL
+		ORDER BY proc_id`, p.id); err != nil {
+		return nil, err
+	}
+	var ret []ProcID
+	for _, procID := range rows {
+		if _, running := p.procs[procID]; !running {
+			ret = append(ret, procID)
+		}
+	}
+	return ret, nil
+}
+
+// Resume resumes an interrupted process from its last snapshot, and blocks until it completes.
+// The resumed process is given a new ProcID.
+func Resume(ctx context.Context, p *Pod, procID ProcID) error {
+	var rec procRecord
+	if err := p.env.DB.GetContext(ctx, &rec, `SELECT proc_id, store_id, ports, snapshot FROM pod_procs
+		WHERE pod_id = ? AND proc_id = ?`, p.id, procID); err != nil {
+		return err
+	}
+	if rec.Snapshot == nil {
+		return fmt.Errorf("process %d has no snapshot", procID)
+	}
+	return p.doInProcess(ctx, true, &rec, func(pc ProcCtx) error {
+		vm := pc.VM()
+		ref := myc.NewRef(mvm1.SnapshotType, cadata.IDFromBytes(rec.Snapshot))
+		if err := vm.Restore(ctx, pc.Store(), *ref); err != nil {
+			return err
+		}
+		logctx.Info(ctx, "resumed process", zap.Int64("pod", int64(p.id)), zap.Int64("prev", int64(procID)), zap.Int64("proc", int64(pc.p.id)))
+		return pc.run(ctx)
+	})
+}
+
+// run runs the process's VM until it halts.
+// The process takes a snapshot if it is durable, and the Pod is configured to take snapshots.
+// If the Pod is configured to record, the run is recorded.
+func (pc ProcCtx) run(ctx context.Context) error {
+	if !pc.p.p.config().Record {
+		return pc.runVM(ctx)
+	}
+	vm := pc.VM()
+	start, err := vm.Snapshot(ctx, pc.Store())
+	if err != nil {
+		return err
+	}
+	rec := mvm1.NewRecorder()
+	vm.SetRecorder(rec)
+	err = pc.runVM(ctx)
+	vm.SetRecorder(nil)
+	if err2 := pc.p.saveRecording(context.WithoutCancel(ctx), start, rec); err2 != nil {
+		logctx.Error(ctx, "saving recording", zap.Error(err2))
+	}
+	return err
+}
+
+func (pc ProcCtx) runVM(ctx context.Context) error {
+	vm := pc.VM()
+	vm.SetLimits(pc.p.p.vmLimits())
+	start := vm.Steps()
+	defer func() { logctx.Infof(ctx, "vm ran for %d steps", vm.Steps()-start) }()
+	for vm.Run(ctx, runChunkSteps) == runChunkSteps {
+		if err := ctx.Err(); err != nil {
+			if err2 := pc.p.checkpoint(context.WithoutCancel(ctx)); err2 != nil {
+				logctx.Error(ctx, "taking snapshot", zap.Error(err2))
+			}
+			return err
+		}
+		if interval := pc.p.p.config().SnapshotInterval; interval > 0 && time.Since(pc.p.lastSnapshot) >= interval {
+			if err := pc.p.checkpoint(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	if err := vm.Err(); err != nil {
+		if l, ok := vm.ExceededLimit(); ok {
+			return ErrProcLimit{PodID: pc.p.p.id, ProcID: pc.p.id, Limit: l, Steps: vm.Steps() - start}
+		}
+		return err
+	}
+	return nil
+}
+
+// checkpoint snapshots the process's VM, if it is durable.
+// The snapshot is written to memory first, and then copied to the process's store in a single transaction.
+func (pr *process) checkpoint(ctx context.Context) error {
+	if !pr.durable {
+		return nil
+	}
+	scratch := &snapshotStore{base: pr.store}
+	ref, err := pr.vm.Snapshot(ctx, scratch)
+	if err != nil {
+		return err
+	}
+	if err := dbutil.DoTx(ctx, pr.p.env.DB, func(tx *sqlx.Tx) error {
+		dst := sqlstores.NewTxStore(tx, mycelium.Hash, mycelium.MaxSizeBytes, pr.storeID)
+		for _, blob := range scratch.blobs {
+			if _, err := dst.Post(ctx, blob.salt, blob.data); err != nil {
+				return err
+			}
+		}
+		cid := ref.Data()
+		return pr.putRecord(tx, cid[:])
+	}); err != nil {
+		return err
+	}
+	pr.lastSnapshot = time.Now()
+	pr.snapshotted = true
+	return nil
+}
+
+func (pr *process) saveRecord(ctx context.Context, snapshot []byte) error {
+	return dbutil.DoTx(ctx, pr.p.env.DB, func(tx *sqlx.Tx) error {
+		return pr.putRecord(tx, snapshot)
+	})
+}
+
+func (pr *process) putRecord(tx *sqlx.Tx, snapshot []byte) error {
+	ports, err := json.Marshal(pr.ports)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO pod_procs (pod_id, proc_id, store_id, ports, snapshot)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(pod_id, proc_id) DO UPDATE SET
+			ports = excluded.ports,
+			snapshot = excluded.snapshot
+	`, pr.p.id, pr.id, pr.storeID, ports, snapshot)
+	return err
+}
+
+// snapshotStore holds the blobs of a snapshot while it is being written.
+// Blobs which exist in base are not written again.
+type snapshotStore struct {
+	base  cadata.Exister
+	ids   map[cadata.ID]struct{}
+	blobs []snapshotBlob
+}
+
+type snapshotBlob struct {
+	salt *cadata.ID
+	data []byte
+}
+
+func (s *snapshotStore) Post(ctx context.Context, salt *cadata.ID, data []byte) (cadata.ID, error) {
+	id := mycelium.Hash(salt, data)
+	if s.ids == nil {
+		s.ids = make(map[cadata.ID]struct{})
+	}
+	if _, exists := s.ids[id]; !exists {
+		s.ids[id] = struct{}{}
+		blob := snapshotBlob{data: append([]byte{}, data...)}
+		if salt != nil {
+			salt := *salt
+			blob.salt = &salt
+		}
+		s.blobs = append(s.blobs, blob)
+	}
+	return id, nil
+}
+
+func (s *snapshotStore) Exists(ctx context.Context, id *cadata.ID) (bool, error) {
+	if _, exists := s.ids[*id]; exists {
+		return true, nil
+	}
+	return s.base.Exists(ctx, id)
+}
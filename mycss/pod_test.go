@@ -2,6 +2,7 @@ package mycss
 
 import (
 	"fmt"
+	"runtime"
 	"testing"
 	"time"
 
@@ -137,3 +138,149 @@ func TestRandom(t *testing.T) {
 	t.Log(out)
 	require.True(t, myc.Equal(out.Type(), myc.ListOf(myc.BitType{})))
 }
+
+func TestGC(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+
+	require.NoError(t, p.Put(ctx, s, "x", myc.NewString("hello world")))
+	require.NoError(t, p.Put(ctx, s, "y", myc.NewString("keep me")))
+	before, err := p.BlobCount(ctx)
+	require.NoError(t, err)
+	// overwrite x, the old string is only reachable from the history.
+	require.NoError(t, p.Put(ctx, s, "x", myc.NewString("goodbye world")))
+	stats, err := p.GC(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, stats.Removed)
+
+	// after pruning the history, the old string is garbage.
+	ver, err := p.NSVersion(ctx)
+	require.NoError(t, err)
+	require.NoError(t, p.PruneHistory(ctx, ver))
+	stats, err = p.GC(ctx)
+	require.NoError(t, err)
+	require.Greater(t, stats.Removed, 0)
+	require.Greater(t, stats.BytesReclaimed, int64(0))
+	after, err := p.BlobCount(ctx)
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+
+	// everything in the namespace should still be loadable
+	x, err := p.Get(ctx, "x")
+	require.NoError(t, err)
+	require.Equal(t, myc.NewString("goodbye world"), x)
+	y, err := p.Get(ctx, "y")
+	require.NoError(t, err)
+	require.Equal(t, myc.NewString("keep me"), y)
+	dst := testutil.NewStore(t)
+	require.NoError(t, myc.NewAnyValue(x).PullInto(ctx, dst, p.Store()))
+
+	// a second collection should find nothing to remove
+	stats, err = p.GC(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, stats.Removed)
+}
+
+func TestGCPostDuringGC(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+
+	ms := newMarkSet()
+	p.setMarkSet(ms)
+	p.awaitPosts()
+	require.NoError(t, p.gcMark(ctx, ms))
+	// the value is posted to the pod's store after marking, but before it is written to the namespace,
+	// as it would be if it was pulled from the network.
+	ref, err := myc.Post(ctx, p.newStore(), myc.NewString("hello world"))
+	require.NoError(t, err)
+	stats, err := p.gcSweep(ctx, ms)
+	require.NoError(t, err)
+	require.Equal(t, 0, stats.Removed)
+	p.setMarkSet(nil)
+	// the pod's store must still have the value, because nothing else does.
+	require.NoError(t, p.Put(ctx, testutil.NewStore(t), "x", &ref))
+}
+
+func TestGCConcurrentPost(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	// give the GC something to do, so that it is running for a while.
+	s := testutil.NewStore(t)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, p.Put(ctx, s, fmt.Sprintf("k%d", i), myc.NewString(fmt.Sprintf("keep %d", i))))
+	}
+
+	gcDone := make(chan error, 1)
+	go func() {
+		_, err := p.GC(ctx)
+		gcDone <- err
+	}()
+	// values are posted while the GC is running, and only written to the namespace after it has finished.
+	var refs []myc.Ref
+	for done := false; !done; {
+		select {
+		case err := <-gcDone:
+			require.NoError(t, err)
+			done = true
+		default:
+			if !gcRunning(p) {
+				runtime.Gosched()
+				continue
+			}
+			ref, err := myc.Post(ctx, p.newStore(), myc.NewString(fmt.Sprintf("value %d", len(refs))))
+			require.NoError(t, err)
+			refs = append(refs, ref)
+		}
+	}
+	t.Logf("posted %d values during GC", len(refs))
+	for i := range refs {
+		require.NoError(t, p.Put(ctx, testutil.NewStore(t), "x", &refs[i]))
+	}
+}
+
+func gcRunning(p *Pod) bool {
+	p.gcMarkMu.Lock()
+	defer p.gcMarkMu.Unlock()
+	return p.gcMarks != nil
+}
+
+func TestStorageQuota(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	reset(t, p, s, nil, PodConfig{MaxStorageBytes: 1024})
+
+	require.NoError(t, p.Put(ctx, s, "small", myc.NewString("hello world")))
+	big := myc.NewString(string(make([]byte, 2048)))
+	err = p.Put(ctx, s, "big", big)
+	require.ErrorAs(t, err, &ErrStorageQuota{})
+	// the failed put should not have changed the namespace
+	v, err := p.Get(ctx, "big")
+	require.NoError(t, err)
+	require.Nil(t, v)
+}
+
+func TestMaxDepth(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	p, err := sys.Create(ctx)
+	require.NoError(t, err)
+	s := testutil.NewStore(t)
+	reset(t, p, s, nil, PodConfig{MaxDepth: 1})
+
+	str := myc.NewString("hello world")
+	require.NoError(t, p.Put(ctx, s, "shallow", str))
+	ref, err := myc.Post(ctx, s, str)
+	require.NoError(t, err)
+	err = p.Put(ctx, s, "deep", &ref)
+	require.ErrorAs(t, err, &ErrMaxDepth{})
+}
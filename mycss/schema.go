@@ -40,5 +40,69 @@ var currentSchema = func() *migrations.State {
 		FOREIGN KEY(pod_id) REFERENCES pods(id),
 		PRIMARY KEY(pod_id, k)
 	)`)
+	x = x.ApplyStmt(`CREATE TABLE pod_procs (
+		pod_id INTEGER NOT NULL,
+		proc_id INTEGER NOT NULL,
+		store_id INTEGER NOT NULL,
+		ports BLOB NOT NULL,
+		snapshot BLOB,
+
+		FOREIGN KEY(pod_id) REFERENCES pods(id),
+		PRIMARY KEY(pod_id, proc_id)
+	)`)
+	x = x.ApplyStmt(`ALTER TABLE pods ADD COLUMN ns_version INTEGER NOT NULL DEFAULT 0`)
+	x = x.ApplyStmt(`ALTER TABLE pods ADD COLUMN ns_history_start INTEGER NOT NULL DEFAULT 0`)
+	x = x.ApplyStmt(`CREATE TABLE pod_ns_history (
+		pod_id INTEGER NOT NULL,
+		version INTEGER NOT NULL,
+		k TEXT NOT NULL,
+		v BLOB,
+		proc_id INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+		FOREIGN KEY(pod_id) REFERENCES pods(id),
+		PRIMARY KEY(pod_id, k, version)
+	)`)
+	// existing entries become version 0
+	x = x.ApplyStmt(`INSERT INTO pod_ns_history (pod_id, version, k, v)
+		SELECT pod_id, 0, k, v FROM pod_ns`)
+	x = x.ApplyStmt(`CREATE TABLE master_key (
+		id INTEGER PRIMARY KEY CHECK (id = 0),
+		salt BLOB,
+		key_check BLOB NOT NULL
+	)`)
+	x = x.ApplyStmt(`CREATE TABLE pod_status (
+		pod_id INTEGER PRIMARY KEY,
+		state TEXT NOT NULL,
+		restarts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		started_at TIMESTAMP,
+		exited_at TIMESTAMP,
+
+		FOREIGN KEY(pod_id) REFERENCES pods(id)
+	)`)
+	x = x.ApplyStmt(`CREATE TABLE pod_recordings (
+		pod_id INTEGER NOT NULL,
+		proc_id INTEGER NOT NULL,
+		run INTEGER NOT NULL,
+		root BLOB NOT NULL,
+		steps INTEGER NOT NULL,
+		halted BOOLEAN NOT NULL,
+		err TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+		FOREIGN KEY(pod_id) REFERENCES pods(id),
+		PRIMARY KEY(pod_id, proc_id, run)
+	)`)
+	x = x.ApplyStmt(`CREATE TABLE pod_mailbox (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		pod_id INTEGER NOT NULL,
+		from_pod INTEGER NOT NULL,
+		root BLOB NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+		FOREIGN KEY(pod_id) REFERENCES pods(id)
+	)`)
+	x = sqlstores.SizeMigration(x)
 	return x
 }()
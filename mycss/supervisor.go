@@ -0,0 +1,248 @@
+package mycss
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"go.brendoncarroll.net/stdctx/logctx"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultRestartBackoff    = time.Second
+	DefaultMaxRestartBackoff = time.Minute
+)
+
+// supervisorScanInterval is how often System.Run looks for new autostart pods.
+var supervisorScanInterval = 5 * time.Second
+
+// RunState is the state of a Pod's main process, as managed by System.Run
+type RunState string
+
+const (
+	// RunStateStopped means the main process is not running, and will not be restarted.
+	RunStateStopped = RunState("stopped")
+	// RunStateRunning means the main process is running.
+	RunStateRunning = RunState("running")
+	// RunStateBackoff means the main process failed, and is waiting to be restarted.
+	RunStateBackoff = RunState("backoff")
+	// RunStateExited means the main process completed without error.
+	RunStateExited = RunState("exited")
+)
+
+// PodStatus is the run status of a Pod's main process.
+type PodStatus struct {
+	State RunState
+	// Restarts is the number of times the main process has been restarted after failing.
+	Restarts int64
+	// LastError is the error from the last time the main process failed.
+	LastError string
+	StartedAt time.Time
+	ExitedAt  time.Time
+}
+
+// Status returns the run status of the Pod's main process.
+// Pods which have never been run by System.Run are stopped.
+func (p *Pod) Status(ctx context.Context) (PodStatus, error) {
+	var row struct {
+		State     RunState     `db:"state"`
+		Restarts  int64        `db:"restarts"`
+		LastError string       `db:"last_error"`
+		StartedAt sql.NullTime `db:"started_at"`
+		ExitedAt  sql.NullTime `db:"exited_at"`
+	}
+	if err := p.env.DB.GetContext(ctx, &row, `SELECT state, restarts, last_error, started_at, exited_at
+		FROM pod_status WHERE pod_id = ?`, p.id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return PodStatus{State: RunStateStopped}, nil
+		}
+		return PodStatus{}, err
+	}
+	return PodStatus{
+		State:     row.State,
+		Restarts:  row.Restarts,
+		LastError: row.LastError,
+		StartedAt: row.StartedAt.Time,
+		ExitedAt:  row.ExitedAt.Time,
+	}, nil
+}
+
+// Run supervises all the pods in the System with Autostart set in their config, until ctx is cancelled.
+// Each pod's main process is started, or resumed if it was interrupted, and restarted with
+// exponential backoff if it fails.
+// Pods which are created or configured to autostart while Run is running are picked up periodically.
+// A pod whose main process exits without error is not started again until it stops being autostart.
+func (s *System) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	var mu sync.Mutex
+	// supervised holds the pods which are being run by a call to supervise.
+	supervised := make(map[PodID]struct{})
+	// exited holds the autostart pods whose main process has exited without error.
+	exited := make(map[PodID]struct{})
+	for {
+		pods, err := s.List(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		listed := make(map[PodID]struct{}, len(pods))
+		mu.Lock()
+		for _, pod := range pods {
+			pid := pod.ID()
+			listed[pid] = struct{}{}
+			if !pod.Config().Autostart {
+				delete(exited, pid)
+				continue
+			}
+			if _, exists := supervised[pid]; exists {
+				continue
+			}
+			if _, exists := exited[pid]; exists {
+				continue
+			}
+			supervised[pid] = struct{}{}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok := s.supervise(ctx, pod)
+				mu.Lock()
+				defer mu.Unlock()
+				delete(supervised, pid)
+				if ok {
+					exited[pid] = struct{}{}
+				}
+			}()
+		}
+		// forget the pods which have been dropped.
+		for pid := range exited {
+			if _, exists := listed[pid]; !exists {
+				delete(exited, pid)
+			}
+		}
+		mu.Unlock()
+
+		wait, stop := s.clock.After(supervisorScanInterval)
+		select {
+		case <-ctx.Done():
+			stop()
+			return nil
+		case <-wait:
+		}
+	}
+}
+
+// supervise runs the pod's main process until it exits successfully, the pod is no longer autostart,
+// the pod is dropped, or ctx is cancelled.
+// It returns true if the main process exited successfully.
+func (s *System) supervise(ctx context.Context, pod *Pod) bool {
+	var backoff time.Duration
+	for {
+		cfg := pod.Config()
+		if !cfg.Autostart {
+			pod.setRunState(ctx, RunStateStopped)
+			return false
+		}
+		minBackoff, maxBackoff := cfg.restartBackoff()
+		if err := pod.recordStart(ctx); err != nil {
+			logctx.Error(ctx, "recording pod status", zap.Uint64("pod", uint64(pod.ID())), zap.Error(err))
+		}
+		start := s.clock.Now()
+		err := runMain(ctx, pod)
+		if ctx.Err() != nil {
+			pod.setRunState(context.WithoutCancel(ctx), RunStateStopped)
+			return false
+		}
+		if err == nil {
+			if err := pod.recordExit(ctx, RunStateExited, nil); err != nil {
+				logctx.Error(ctx, "recording pod status", zap.Uint64("pod", uint64(pod.ID())), zap.Error(err))
+			}
+			return true
+		}
+		logctx.Error(ctx, "pod main failed", zap.Uint64("pod", uint64(pod.ID())), zap.Error(err))
+		if err := pod.recordExit(ctx, RunStateBackoff, err); err != nil {
+			logctx.Error(ctx, "recording pod status", zap.Uint64("pod", uint64(pod.ID())), zap.Error(err))
+		}
+		// a process which ran for a long time resets the backoff.
+		if backoff == 0 || s.clock.Now().Sub(start) >= maxBackoff {
+			backoff = minBackoff
+		} else {
+			backoff = min(2*backoff, maxBackoff)
+		}
+		wait, stop := s.clock.After(backoff)
+		select {
+		case <-ctx.Done():
+			stop()
+			pod.setRunState(context.WithoutCancel(ctx), RunStateStopped)
+			return false
+		case <-wait:
+		}
+		if _, err := s.Get(ctx, pod.ID()); err != nil {
+			// the pod has been dropped
+			return false
+		}
+	}
+}
+
+// runMain resumes the pod's interrupted processes if there are any, otherwise it starts Main.
+func runMain(ctx context.Context, pod *Pod) error {
+	procIDs, err := pod.Interrupted(ctx)
+	if err != nil {
+		return err
+	}
+	if len(procIDs) == 0 {
+		return Main(ctx, pod)
+	}
+	for _, procID := range procIDs {
+		if err := Resume(ctx, pod, procID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pc *PodConfig) restartBackoff() (minBackoff, maxBackoff time.Duration) {
+	minBackoff, maxBackoff = pc.RestartBackoff, pc.MaxRestartBackoff
+	if minBackoff <= 0 {
+		minBackoff = DefaultRestartBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxRestartBackoff
+	}
+	return minBackoff, max(minBackoff, maxBackoff)
+}
+
+func (p *Pod) recordStart(ctx context.Context) error {
+	_, err := p.env.DB.ExecContext(ctx, `INSERT INTO pod_status (pod_id, state, started_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (pod_id) DO UPDATE SET
+			state = excluded.state,
+			started_at = excluded.started_at`, p.id, RunStateRunning, p.env.Clock.Now())
+	return err
+}
+
+// recordExit records the end of the main process.
+// If err is not nil, the restart count is incremented, since the process will be restarted.
+func (p *Pod) recordExit(ctx context.Context, state RunState, err error) error {
+	var restarts int64
+	var errStr string
+	if err != nil {
+		restarts = 1
+		errStr = err.Error()
+	}
+	_, err = p.env.DB.ExecContext(ctx, `UPDATE pod_status
+		SET state = ?, restarts = restarts + ?, last_error = ?, exited_at = ?
+		WHERE pod_id = ?`, state, restarts, errStr, p.env.Clock.Now(), p.id)
+	return err
+}
+
+func (p *Pod) setRunState(ctx context.Context, state RunState) {
+	if _, err := p.env.DB.ExecContext(ctx, `UPDATE pod_status SET state = ? WHERE pod_id = ?`, state, p.id); err != nil {
+		logctx.Error(ctx, "recording pod status", zap.Uint64("pod", uint64(p.id)), zap.Error(err))
+	}
+}
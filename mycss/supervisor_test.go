@@ -0,0 +1,122 @@
+package mycss
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/myccanon"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+func TestSupervisor(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	s := testutil.NewStore(t)
+
+	// faulty loops forever, and always exceeds its step limit.
+	faulty, err := sys.Create(ctx)
+	require.NoError(t, err)
+	reset(t, faulty, s, myccanon.Namespace{
+		"": mkLambda(myccanon.NS_Type, myc.ProductType{}, func(eb EB) *Expr {
+			return eb.Apply(eb.Self(), eb.P(0))
+		}),
+	}, PodConfig{
+		MaxSteps:          1000,
+		Autostart:         true,
+		RestartBackoff:    time.Millisecond,
+		MaxRestartBackoff: 10 * time.Millisecond,
+	})
+	// ok returns immediately.
+	ok, err := sys.Create(ctx)
+	require.NoError(t, err)
+	reset(t, ok, s, myccanon.Namespace{
+		"": mkLambda(myccanon.NS_Type, myc.ProductType{}, func(eb EB) *Expr {
+			return eb.Product()
+		}),
+	}, PodConfig{Autostart: true})
+	// manual is not started.
+	manual, err := sys.Create(ctx)
+	require.NoError(t, err)
+
+	ctx2, cf := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- sys.Run(ctx2) }()
+
+	require.Eventually(t, func() bool {
+		st, err := faulty.Status(ctx)
+		require.NoError(t, err)
+		return st.Restarts >= 3
+	}, 10*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		st, err := ok.Status(ctx)
+		require.NoError(t, err)
+		return st.State == RunStateExited
+	}, 10*time.Second, 10*time.Millisecond)
+
+	cf()
+	require.NoError(t, <-done)
+	st, err := faulty.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, RunStateStopped, st.State)
+	require.Contains(t, st.LastError, "limit")
+	st, err = manual.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, PodStatus{State: RunStateStopped}, st)
+}
+
+func TestSupervisorRescan(t *testing.T) {
+	ctx := testutil.Context(t)
+	sys := newTestSys(t)
+	clk := NewManualClock(time.Unix(0, 0))
+	sys.SetClock(clk)
+	s := testutil.NewStore(t)
+	ns := myccanon.Namespace{
+		"": mkLambda(myccanon.NS_Type, myc.ProductType{}, func(eb EB) *Expr {
+			return eb.Product()
+		}),
+	}
+	pod, err := sys.Create(ctx)
+	require.NoError(t, err)
+	reset(t, pod, s, ns, PodConfig{Autostart: true})
+
+	ctx2, cf := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- sys.Run(ctx2) }()
+	defer func() {
+		cf()
+		require.NoError(t, <-done)
+	}()
+	// rescan waits for the next scan of the pods to finish.
+	rescan := func() {
+		require.Eventually(t, func() bool { return clk.Waiters() == 1 }, 10*time.Second, time.Millisecond)
+		clk.Advance(supervisorScanInterval)
+		require.Eventually(t, func() bool { return clk.Waiters() == 1 }, 10*time.Second, time.Millisecond)
+	}
+	awaitExit := func(startedAt time.Time) {
+		require.Eventually(t, func() bool {
+			st, err := pod.Status(ctx)
+			require.NoError(t, err)
+			return st.State == RunStateExited && st.StartedAt.Equal(startedAt)
+		}, 10*time.Second, time.Millisecond)
+	}
+	awaitExit(clk.Now())
+	first := clk.Now()
+
+	// the pod has exited, so it is not started again.
+	rescan()
+	st, err := pod.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, RunStateExited, st.State)
+	require.True(t, st.StartedAt.Equal(first))
+
+	// until it stops being autostart, and then starts again.
+	reset(t, pod, s, ns, PodConfig{})
+	rescan()
+	reset(t, pod, s, ns, PodConfig{Autostart: true})
+	rescan()
+	awaitExit(clk.Now())
+}
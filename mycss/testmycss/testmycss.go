@@ -1,7 +1,10 @@
 package testmycss
 
 import (
+	"encoding/binary"
+	"io"
 	"testing"
+	"time"
 
 	"myceliumweb.org/mycelium/internal/testutil"
 	"myceliumweb.org/mycelium/mycss"
@@ -19,3 +22,42 @@ func Create(t testing.TB, sys *mycss.System) *mycss.Pod {
 	require.NoError(t, err)
 	return pod
 }
+
+// Epoch is the time that clocks returned by NewClock start at.
+var Epoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// NewClock returns a ManualClock stopped at Epoch.
+func NewClock() *mycss.ManualClock {
+	return mycss.NewManualClock(Epoch)
+}
+
+// NewEntropy returns a deterministic source of randomness derived from seed.
+func NewEntropy(seed uint64) io.Reader {
+	var seed32 [32]byte
+	binary.LittleEndian.PutUint64(seed32[:], seed)
+	return mycss.SeededEntropy(seed32)
+}
+
+// NewDeterministic returns a System whose pods use a ManualClock, and entropy derived from seed.
+// Two Systems created with the same seed, given the same inputs, will produce the same outputs.
+func NewDeterministic(t testing.TB, seed uint64) (*mycss.System, *mycss.ManualClock) {
+	sys := mycss.NewTestSys(t)
+	clk := NewClock()
+	sys.SetClock(clk)
+	sys.SetEntropy(NewEntropy(seed))
+	return sys, clk
+}
+
+// AwaitWaiters blocks until n callers are waiting for clk to advance.
+// It is used to make sure a process is sleeping before advancing the clock.
+func AwaitWaiters(t testing.TB, clk *mycss.ManualClock, n int) {
+	require.Eventually(t, func() bool {
+		return clk.Waiters() >= n
+	}, 5*time.Second, time.Millisecond)
+}
+
+// Advance waits for n callers to be waiting on clk, and then advances it by d.
+func Advance(t testing.TB, clk *mycss.ManualClock, n int, d time.Duration) {
+	AwaitWaiters(t, clk, n)
+	clk.Advance(d)
+}
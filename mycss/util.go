@@ -13,6 +13,7 @@ import (
 	"myceliumweb.org/mycelium/internal/cadata"
 	"myceliumweb.org/mycelium/internal/stores"
 	"myceliumweb.org/mycelium/internal/testutil"
+	"myceliumweb.org/mycelium/mvm1"
 	"myceliumweb.org/mycelium/myccanon"
 	"myceliumweb.org/mycelium/myccanon/mycjson"
 	"myceliumweb.org/mycelium/mycexpr"
@@ -75,8 +76,17 @@ func Eval(ctx context.Context, p *Pod, dst cadata.PostExister, src cadata.GetExi
 }
 
 // Main spawns a process to evaluate the symbol "" in the namespace, and blocks until it completes
+// The process is durable; if it is interrupted, it can be continued with Resume.
 func Main(ctx context.Context, p *Pod) error {
-	return p.DoInProcess(ctx, func(pc ProcCtx) error {
+	return MainTrace(ctx, p, nil)
+}
+
+// MainTrace is like Main, but events from the process's VM are sent to t.
+func MainTrace(ctx context.Context, p *Pod, t mvm1.Tracer) error {
+	return p.doInProcess(ctx, true, nil, func(pc ProcCtx) error {
+		if t != nil {
+			pc.VM().SetTracer(t)
+		}
 		entry, exists := pc.NS()[""]
 		if !exists {
 			return fmt.Errorf("start failed: pod namespace is not executable")
@@ -150,6 +150,20 @@ func lambdaEval(out []EvalVec, s cadata.PostExister) []EvalVec {
 		})
 	}
 
+	// the body of a lambda can share nodes
+	shared, err := mycexpr.BuildLambda(myc.B32Type(), myc.ProductType{myc.B32Type(), myc.B32Type()}, func(eb EB) *Expr {
+		x := eb.P(0)
+		return eb.Product(x, x)
+	})
+	if err != nil {
+		panic(err)
+	}
+	out = append(out, EvalVec{
+		Name: "LambdaShared",
+		I:    eb.Apply(eb.Lit(shared), eb.B32(7)),
+		O:    Product{b32(7), b32(7)},
+	})
+
 	// make sure everything can be returned from a lambda
 	return append(out, []EvalVec{
 		{
@@ -367,6 +381,28 @@ func listEval(out []EvalVec, s cadata.PostExister) []EvalVec {
 			),
 			O: myc.NewString("second"),
 		},
+		{
+			Name: "SlotArray",
+			I:    eb.Slot(eb.Array(eb.Lit(myc.B32Type()), eb.B32(10), eb.B32(20), eb.B32(30)), eb.B32(2)),
+			O:    b32(30),
+		},
+		{
+			Name: "SlotArrayField",
+			I: eb.Field(
+				eb.Slot(eb.Array(eb.Lit(myc.ProductType{myc.B8Type(), myc.B32Type()}),
+					eb.Product(eb.B8(1), eb.B32(10)),
+					eb.Product(eb.B8(2), eb.B32(20)),
+				), eb.B32(1)),
+				1,
+			),
+			O: b32(20),
+		},
+		{
+			// the elements after the one selected must not be left in the output
+			Name: "SlotListB8",
+			I:    eb.Equal(eb.Slot(eb.List(eb.B8(1), eb.B8(2), eb.B8(3)), eb.B32(1)), eb.B8(2)),
+			O:    myc.NewBit(1),
+		},
 		{
 			I: eb.Load(eb.ListTo(
 				eb.List(eb.String("a1"), eb.String("b2"), eb.String("c3")),
@@ -400,6 +436,17 @@ func miscEval(out []EvalVec, s cadata.PostExister) []EvalVec {
 			I:    eb.If(eb.Bit(0), eb.B32(111), eb.B32(222)),
 			O:    b32(222),
 		},
+		{
+			// a branch which always faults can take the type of the other branch
+			Name: "If(true, 111, fault)",
+			I:    eb.If(eb.Bit(1), eb.B32(111), eb.Fault(eb.String("unreachable"))),
+			O:    b32(111),
+		},
+		{
+			Name: "If(false, fault, 222)",
+			I:    eb.If(eb.Bit(0), eb.Fault(eb.String("unreachable")), eb.B32(222)),
+			O:    b32(222),
+		},
 		{
 			Name: "Let Nested Order",
 			I: eb.LetVal(Product{b32(2)}, func(eb EB) *Expr {
@@ -0,0 +1,328 @@
+package myctests
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand/v2"
+
+	"myceliumweb.org/mycelium/myccanon"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+// FloatVecs returns test vectors for the floating point lambdas in myccanon.
+// The expected outputs are computed with Go's math package, with NaN results replaced by the canonical NaN.
+// The inputs are special values, and pseudo-random values from a fixed seed.
+func FloatVecs() (out []EvalVec) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	out = float32Vecs(out, rng)
+	out = float64Vecs(out, rng)
+	out = float16Vecs(out, rng)
+	return out
+}
+
+func float32Vecs(out []EvalVec, rng *rand.Rand) []EvalVec {
+	eb := EB{}
+	val := func(x float32) myc.Value { return float32Val(x) }
+	unary := func(name string, la *Lambda, x myc.Value, o myc.Value) {
+		out = append(out, EvalVec{Name: name, I: eb.Apply(eb.Lit(la), lit(x)), O: o})
+	}
+	binary := func(name string, la *Lambda, a, b float32, o myc.Value) {
+		out = append(out, EvalVec{
+			Name: fmt.Sprintf("%s(%v, %v)", name, a, b),
+			I:    eb.Apply(eb.Lit(la), eb.Product(lit(val(a)), lit(val(b)))),
+			O:    o,
+		})
+	}
+	specials := []float32{
+		0, negZero32(), 1, -1.5, 3, 0.1,
+		math.MaxFloat32, -math.SmallestNonzeroFloat32, math.Float32frombits(0x0080_0000),
+		float32(math.Inf(1)), float32(math.Inf(-1)), float32(math.NaN()),
+	}
+	var pairs [][2]float32
+	for _, a := range specials {
+		for _, b := range specials {
+			pairs = append(pairs, [2]float32{a, b})
+		}
+	}
+	for range 24 {
+		a := randFloat32(rng)
+		pairs = append(pairs, [2]float32{a, randFloat32(rng)})
+		// nearby values exercise cancellation and rounding
+		pairs = append(pairs, [2]float32{a, -a * (1 + float32(rng.NormFloat64())*1e-5)})
+	}
+	for _, p := range pairs {
+		a, b := p[0], p[1]
+		binary("Float32_Add", myccanon.Float32_Add, a, b, val(a+b))
+		binary("Float32_Sub", myccanon.Float32_Sub, a, b, val(a-b))
+		binary("Float32_Mul", myccanon.Float32_Mul, a, b, val(a*b))
+		binary("Float32_Div", myccanon.Float32_Div, a, b, val(a/b))
+		binary("Float32_Eq", myccanon.Float32_Eq, a, b, boolBit(a == b))
+		binary("Float32_Lt", myccanon.Float32_Lt, a, b, boolBit(a < b))
+		binary("Float32_Le", myccanon.Float32_Le, a, b, boolBit(a <= b))
+		binary("Float32_Gt", myccanon.Float32_Gt, a, b, boolBit(a > b))
+		binary("Float32_Ge", myccanon.Float32_Ge, a, b, boolBit(a >= b))
+	}
+
+	xs := append([]float32{
+		2.5, -2.5, 0.999, 1 << 31, -1 << 31, 1 << 63, -1 << 63, 1e30, -1e30,
+		math.Float32frombits(0x0000_0001), math.Float32frombits(0x007f_ffff),
+	}, specials...)
+	for range 16 {
+		xs = append(xs, randFloat32(rng))
+	}
+	for _, x := range xs {
+		name := func(op string) string { return fmt.Sprintf("%s(%v)", op, x) }
+		unary(name("Float32_Neg"), myccanon.Float32_Neg, val(x), float32Bits(canonicalBits32(x)^(1<<31)))
+		unary(name("Float32_Recip"), myccanon.Float32_Recip, val(x), val(1/x))
+		unary(name("Float32_ToB32S"), myccanon.Float32_ToB32S, val(x), myc.NewB32(uint32(toIntSat(float64(x), 32))))
+		unary(name("Float32_ToB64S"), myccanon.Float32_ToB64S, val(x), myc.NewB64(uint64(toIntSat(float64(x), 64))))
+		unary(name("Float64_FromFloat32"), myccanon.Float64_FromFloat32, val(x), float64Val(float64(x)))
+	}
+	for _, x := range intInputs(rng) {
+		name := func(op string) string { return fmt.Sprintf("%s(%d)", op, x) }
+		unary(name("Float32_FromB32S"), myccanon.Float32_FromB32S, myc.NewB32(uint32(int32(x))), val(float32(int32(x))))
+		unary(name("Float32_FromB64S"), myccanon.Float32_FromB64S, myc.NewB64(uint64(x)), val(float32(x)))
+	}
+	return out
+}
+
+func float64Vecs(out []EvalVec, rng *rand.Rand) []EvalVec {
+	eb := EB{}
+	val := func(x float64) myc.Value { return float64Val(x) }
+	unary := func(name string, la *Lambda, x myc.Value, o myc.Value) {
+		out = append(out, EvalVec{Name: name, I: eb.Apply(eb.Lit(la), lit(x)), O: o})
+	}
+	binary := func(name string, la *Lambda, a, b float64, o myc.Value) {
+		out = append(out, EvalVec{
+			Name: fmt.Sprintf("%s(%v, %v)", name, a, b),
+			I:    eb.Apply(eb.Lit(la), eb.Product(lit(val(a)), lit(val(b)))),
+			O:    o,
+		})
+	}
+	specials := []float64{
+		0, math.Copysign(0, -1), 1, -1.5, 3, 0.1,
+		math.MaxFloat64, -math.SmallestNonzeroFloat64, math.Float64frombits(0x0010_0000_0000_0000),
+		math.Inf(1), math.Inf(-1), math.NaN(),
+	}
+	var pairs [][2]float64
+	for _, a := range specials {
+		for _, b := range specials {
+			pairs = append(pairs, [2]float64{a, b})
+		}
+	}
+	for range 24 {
+		a := randFloat64(rng)
+		pairs = append(pairs, [2]float64{a, randFloat64(rng)})
+		pairs = append(pairs, [2]float64{a, -a * (1 + rng.NormFloat64()*1e-12)})
+	}
+	for _, p := range pairs {
+		a, b := p[0], p[1]
+		binary("Float64_Add", myccanon.Float64_Add, a, b, val(a+b))
+		binary("Float64_Sub", myccanon.Float64_Sub, a, b, val(a-b))
+		binary("Float64_Mul", myccanon.Float64_Mul, a, b, val(a*b))
+		binary("Float64_Div", myccanon.Float64_Div, a, b, val(a/b))
+		binary("Float64_Eq", myccanon.Float64_Eq, a, b, boolBit(a == b))
+		binary("Float64_Lt", myccanon.Float64_Lt, a, b, boolBit(a < b))
+		binary("Float64_Le", myccanon.Float64_Le, a, b, boolBit(a <= b))
+		binary("Float64_Gt", myccanon.Float64_Gt, a, b, boolBit(a > b))
+		binary("Float64_Ge", myccanon.Float64_Ge, a, b, boolBit(a >= b))
+	}
+
+	xs := append([]float64{
+		2.5, -2.5, 0.999, 1 << 31, -1 << 31, 1<<31 - 0.5, 1 << 63, -1 << 63, 1e300, -1e300,
+		// these round differently to Float32 and Float16
+		1 + 0x1p-24, 1 + 0x1p-23 + 0x1p-24, 0x1p-149, 0x1p-150, 0x1.8p-150, 65519.99, 0x1p-25, 0x1.8p-24,
+		math.Float64frombits(0x0000_0000_0000_0001), math.Float64frombits(0x000f_ffff_ffff_ffff),
+	}, specials...)
+	for range 16 {
+		xs = append(xs, randFloat64(rng))
+	}
+	for _, x := range xs {
+		name := func(op string) string { return fmt.Sprintf("%s(%v)", op, x) }
+		unary(name("Float64_Neg"), myccanon.Float64_Neg, val(x), float64Bits(canonicalBits64(x)^(1<<63)))
+		unary(name("Float64_Recip"), myccanon.Float64_Recip, val(x), val(1/x))
+		unary(name("Float64_ToB32S"), myccanon.Float64_ToB32S, val(x), myc.NewB32(uint32(toIntSat(x, 32))))
+		unary(name("Float64_ToB64S"), myccanon.Float64_ToB64S, val(x), myc.NewB64(uint64(toIntSat(x, 64))))
+		unary(name("Float32_FromFloat64"), myccanon.Float32_FromFloat64, val(x), float32Val(float32(x)))
+		unary(name("Float16_FromFloat64"), myccanon.Float16_FromFloat64, val(x), float16Bits(toFloat16(x)))
+	}
+	for _, x := range intInputs(rng) {
+		name := func(op string) string { return fmt.Sprintf("%s(%d)", op, x) }
+		unary(name("Float64_FromB32S"), myccanon.Float64_FromB32S, myc.NewB32(uint32(int32(x))), val(float64(int32(x))))
+		unary(name("Float64_FromB64S"), myccanon.Float64_FromB64S, myc.NewB64(uint64(x)), val(float64(x)))
+	}
+	return out
+}
+
+func float16Vecs(out []EvalVec, rng *rand.Rand) []EvalVec {
+	eb := EB{}
+	unary := func(name string, la *Lambda, x myc.Value, o myc.Value) {
+		out = append(out, EvalVec{Name: name, I: eb.Apply(eb.Lit(la), lit(x)), O: o})
+	}
+	hs := []uint16{
+		0x0000, 0x8000, 0x0001, 0x03ff, 0x0400, 0x3c00, 0xc000, 0x7bff, 0xfbff,
+		0x7c00, 0xfc00, 0x7e00, 0x7c01, 0xffff,
+	}
+	for range 16 {
+		hs = append(hs, uint16(rng.Uint32()))
+	}
+	for _, h := range hs {
+		x := fromFloat16(h)
+		unary(fmt.Sprintf("Float32_FromFloat16(%#04x)", h), myccanon.Float32_FromFloat16, float16Bits(h), float32Val(float32(x)))
+		unary(fmt.Sprintf("Float64_FromFloat16(%#04x)", h), myccanon.Float64_FromFloat16, float16Bits(h), float64Val(x))
+	}
+	xs := []float32{
+		0, negZero32(), 1, 65504, 65519, 65520, -65536, 0x1p-14, 0x1p-24, 0x1p-25, 0x1.8p-25, 0x1.8p-24, 0x1p-26,
+		1 + 0x1p-11, 1 + 0x3p-11, 0x1.ffcp-15, 0x1.ffep-15, 0.1, -3.14159,
+		float32(math.Inf(1)), float32(math.NaN()),
+	}
+	for range 16 {
+		xs = append(xs, float32(rng.NormFloat64()*1000))
+	}
+	for _, x := range xs {
+		unary(fmt.Sprintf("Float16_FromFloat32(%v)", x), myccanon.Float16_FromFloat32, float32Val(x), float16Bits(toFloat16(float64(x))))
+	}
+	return out
+}
+
+func float16Bits(x uint16) myc.Value {
+	return floatVal(10, 5, uint64(x))
+}
+
+func float32Bits(x uint32) myc.Value {
+	return floatVal(23, 8, uint64(x))
+}
+
+func float64Bits(x uint64) myc.Value {
+	return floatVal(52, 11, x)
+}
+
+func float32Val(x float32) myc.Value {
+	return float32Bits(canonicalBits32(x))
+}
+
+func float64Val(x float64) myc.Value {
+	return float64Bits(canonicalBits64(x))
+}
+
+// canonicalBits32 returns the bits of x, or of the canonical NaN if x is a NaN
+func canonicalBits32(x float32) uint32 {
+	if x != x {
+		return 0x7fc0_0000
+	}
+	return math.Float32bits(x)
+}
+
+func canonicalBits64(x float64) uint64 {
+	if x != x {
+		return 0x7ff8_0000_0000_0000
+	}
+	return math.Float64bits(x)
+}
+
+// floatVal splits the bit pattern of a float into the mantissa, exponent and sign
+func floatVal(mant, exp int, x uint64) myc.Value {
+	return myc.Product{
+		bitsVal(mant, new(big.Int).SetUint64(x&(1<<mant-1))),
+		bitsVal(exp, new(big.Int).SetUint64(x>>mant&(1<<exp-1))),
+		bit(int(x >> (mant + exp) & 1)),
+	}
+}
+
+func negZero32() float32 {
+	return float32(math.Copysign(0, -1))
+}
+
+// randFloat32 returns a float with random bits, which is usually not a NaN
+func randFloat32(rng *rand.Rand) float32 {
+	if rng.IntN(2) == 0 {
+		return float32(rng.NormFloat64() * 100)
+	}
+	return math.Float32frombits(rng.Uint32())
+}
+
+func randFloat64(rng *rand.Rand) float64 {
+	if rng.IntN(2) == 0 {
+		return rng.NormFloat64() * 100
+	}
+	return math.Float64frombits(rng.Uint64())
+}
+
+func intInputs(rng *rand.Rand) []int64 {
+	xs := []int64{0, 1, -1, 3, 1<<24 + 1, 1<<53 + 1, math.MaxInt32, math.MinInt32, math.MaxInt64, math.MinInt64}
+	for range 8 {
+		xs = append(xs, int64(rng.Uint64()))
+	}
+	return xs
+}
+
+// toIntSat truncates x toward zero, saturating to the range of a bits sized integer.
+func toIntSat(x float64, bits int) int64 {
+	lo, hi := -math.Ldexp(1, bits-1), math.Ldexp(1, bits-1)
+	switch {
+	case math.IsNaN(x):
+		return 0
+	case x < lo:
+		return math.MinInt64 >> (64 - bits)
+	case x >= hi:
+		return math.MaxInt64 >> (64 - bits)
+	}
+	return int64(math.Trunc(x))
+}
+
+func fromFloat16(h uint16) float64 {
+	sign := 1.0
+	if h>>15 == 1 {
+		sign = -1
+	}
+	exp, mant := int(h>>10&0x1f), float64(h&0x3ff)
+	switch exp {
+	case 0x1f:
+		if mant != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	case 0:
+		return sign * mant * 0x1p-24
+	default:
+		return sign * (1 + mant/1024) * math.Ldexp(1, exp-15)
+	}
+}
+
+// toFloat16 returns the bits of the half precision float nearest to x
+func toFloat16(x float64) uint16 {
+	var sign uint16
+	if math.Signbit(x) {
+		sign = 0x8000
+	}
+	a := math.Abs(x)
+	switch {
+	case math.IsNaN(x):
+		return 0x7e00
+	case a == 0:
+		return sign
+	}
+	// the number of significant bits available at the magnitude of x
+	prec := 11
+	if e := math.Ilogb(a); e < -14 {
+		prec -= -14 - e
+	}
+	var r float64
+	switch {
+	case prec > 0:
+		r, _ = new(big.Float).SetMode(big.ToNearestEven).SetPrec(uint(prec)).SetFloat64(a).Float64()
+	case prec == 0 && a > 0x1p-25:
+		// between half the smallest subnormal and the smallest subnormal.
+		r = 0x1p-24
+	}
+	switch {
+	case r >= 65536:
+		return sign | 0x7c00
+	case r < 0x1p-14:
+		return sign | uint16(r*0x1p24)
+	default:
+		e := math.Ilogb(r)
+		return sign | uint16(e+15)<<10 | uint16(math.Ldexp(r, 10-e)-1024)
+	}
+}
@@ -0,0 +1,172 @@
+package myctests
+
+import (
+	"fmt"
+	"math/big"
+
+	"myceliumweb.org/mycelium/myccanon"
+	myc "myceliumweb.org/mycelium/mycmem"
+)
+
+// IntVecs returns test vectors for the canonical integer lambdas in myccanon.
+// The expected outputs are computed with math/big, so the vectors can be used to check
+// that the pure definitions and the accelerated implementations agree.
+// Evaluating these without accelerators takes a large number of steps.
+func IntVecs() (out []EvalVec) {
+	for _, il := range allIntLambdas() {
+		out = intVecs(out, il)
+	}
+	return out
+}
+
+type intLambdas struct {
+	bits int
+
+	shl, shr, rotl, rotr *Lambda
+	lt, lts, gt, gts     *Lambda
+	min, max             *Lambda
+	mod, mulWide         *Lambda
+}
+
+func allIntLambdas() []intLambdas {
+	return []intLambdas{
+		{
+			bits: 8,
+			shl:  myccanon.B8_Shl, shr: myccanon.B8_Shr, rotl: myccanon.B8_RotL, rotr: myccanon.B8_RotR,
+			lt: myccanon.B8_Lt, lts: myccanon.B8_LtS, gt: myccanon.B8_Gt, gts: myccanon.B8_GtS,
+			min: myccanon.B8_Min, max: myccanon.B8_Max,
+			mod: myccanon.B8_Mod, mulWide: myccanon.B8_MulWide,
+		},
+		{
+			bits: 16,
+			shl:  myccanon.B16_Shl, shr: myccanon.B16_Shr, rotl: myccanon.B16_RotL, rotr: myccanon.B16_RotR,
+			lt: myccanon.B16_Lt, lts: myccanon.B16_LtS, gt: myccanon.B16_Gt, gts: myccanon.B16_GtS,
+			min: myccanon.B16_Min, max: myccanon.B16_Max,
+			mod: myccanon.B16_Mod, mulWide: myccanon.B16_MulWide,
+		},
+		{
+			bits: 32,
+			shl:  myccanon.B32_Shl, shr: myccanon.B32_Shr, rotl: myccanon.B32_RotL, rotr: myccanon.B32_RotR,
+			lt: myccanon.B32_Lt, lts: myccanon.B32_LtS, gt: myccanon.B32_Gt, gts: myccanon.B32_GtS,
+			min: myccanon.B32_Min, max: myccanon.B32_Max,
+			mod: myccanon.B32_Mod, mulWide: myccanon.B32_MulWide,
+		},
+		{
+			bits: 64,
+			shl:  myccanon.B64_Shl, shr: myccanon.B64_Shr, rotl: myccanon.B64_RotL, rotr: myccanon.B64_RotR,
+			lt: myccanon.B64_Lt, lts: myccanon.B64_LtS, gt: myccanon.B64_Gt, gts: myccanon.B64_GtS,
+			min: myccanon.B64_Min, max: myccanon.B64_Max,
+			mod: myccanon.B64_Mod, mulWide: myccanon.B64_MulWide,
+		},
+		{
+			bits: 128,
+			shl:  myccanon.B128_Shl, shr: myccanon.B128_Shr, rotl: myccanon.B128_RotL, rotr: myccanon.B128_RotR,
+			lt: myccanon.B128_Lt, lts: myccanon.B128_LtS, gt: myccanon.B128_Gt, gts: myccanon.B128_GtS,
+			min: myccanon.B128_Min, max: myccanon.B128_Max,
+			mod: myccanon.B128_Mod, mulWide: myccanon.B128_MulWide,
+		},
+		{
+			bits: 256,
+			shl:  myccanon.B256_Shl, shr: myccanon.B256_Shr, rotl: myccanon.B256_RotL, rotr: myccanon.B256_RotR,
+			lt: myccanon.B256_Lt, lts: myccanon.B256_LtS, gt: myccanon.B256_Gt, gts: myccanon.B256_GtS,
+			min: myccanon.B256_Min, max: myccanon.B256_Max,
+			mod: myccanon.B256_Mod, mulWide: myccanon.B256_MulWide,
+		},
+	}
+}
+
+func intVecs(out []EvalVec, il intLambdas) []EvalVec {
+	n := il.bits
+	modulus := new(big.Int).Lsh(big.NewInt(1), uint(n))
+	maxU := new(big.Int).Sub(modulus, big.NewInt(1))
+	minS := new(big.Int).Rsh(modulus, 1)
+	// an arbitrary value with a mix of bits set
+	mixed := new(big.Int).Div(maxU, big.NewInt(3))
+	mixed.Add(mixed, big.NewInt(1<<5+1))
+	pairs := [][2]*big.Int{
+		{big.NewInt(0), big.NewInt(1)},
+		{big.NewInt(7), big.NewInt(3)},
+		{big.NewInt(3), big.NewInt(7)},
+		{maxU, big.NewInt(2)},
+		{minS, maxU},
+		{mixed, minS},
+		{mixed, mixed},
+	}
+	shifts := []uint32{0, 1, 3, uint32(n) - 1, uint32(n), uint32(n) + 3, 1 << 31}
+
+	eb := EB{}
+	apply := func(la *Lambda, args ...myc.Value) *Expr {
+		es := make([]*Expr, len(args))
+		for i := range args {
+			es[i] = lit(args[i])
+		}
+		return eb.Apply(eb.Lit(la), eb.Product(es...))
+	}
+	add := func(name string, la *Lambda, o myc.Value, args ...myc.Value) {
+		out = append(out, EvalVec{
+			Name: fmt.Sprintf("B%d_%s", n, name),
+			I:    apply(la, args...),
+			O:    o,
+		})
+	}
+	x := bitsVal(n, mixed)
+	for _, amt := range shifts {
+		a := b32(int(amt))
+		var shl, shr big.Int
+		if amt < uint32(n) {
+			shl.Lsh(mixed, uint(amt)).Mod(&shl, modulus)
+			shr.Rsh(mixed, uint(amt))
+		}
+		r := uint(amt % uint32(n))
+		var rotl, rotr big.Int
+		rotl.Lsh(mixed, r).Or(&rotl, new(big.Int).Rsh(mixed, uint(n)-r)).Mod(&rotl, modulus)
+		rotr.Rsh(mixed, r).Or(&rotr, new(big.Int).Lsh(mixed, uint(n)-r)).Mod(&rotr, modulus)
+
+		add("Shl", il.shl, bitsVal(n, &shl), x, a)
+		add("Shr", il.shr, bitsVal(n, &shr), x, a)
+		add("RotL", il.rotl, bitsVal(n, &rotl), x, a)
+		add("RotR", il.rotr, bitsVal(n, &rotr), x, a)
+	}
+	signed := func(x *big.Int) *big.Int {
+		if x.Cmp(minS) < 0 {
+			return x
+		}
+		return new(big.Int).Sub(x, modulus)
+	}
+	for _, pair := range pairs {
+		a, b := pair[0], pair[1]
+		av, bv := bitsVal(n, a), bitsVal(n, b)
+		c, cs := a.Cmp(b), signed(a).Cmp(signed(b))
+
+		add("Lt", il.lt, boolBit(c < 0), av, bv)
+		add("Gt", il.gt, boolBit(c > 0), av, bv)
+		add("LtS", il.lts, boolBit(cs < 0), av, bv)
+		add("GtS", il.gts, boolBit(cs > 0), av, bv)
+		if c < 0 {
+			add("Min", il.min, av, av, bv)
+			add("Max", il.max, bv, av, bv)
+		} else {
+			add("Min", il.min, bv, av, bv)
+			add("Max", il.max, av, av, bv)
+		}
+		add("Mod", il.mod, bitsVal(n, new(big.Int).Mod(a, b)), av, bv)
+		add("MulWide", il.mulWide, bitsVal(2*n, new(big.Int).Mul(a, b)), av, bv)
+	}
+	return out
+}
+
+// bitsVal returns the low n bits of x as a bit array
+func bitsVal(n int, x *big.Int) myc.Value {
+	bs := make([]myc.Bit, n)
+	for i := range bs {
+		bs[i] = myc.Bit(x.Bit(i))
+	}
+	return myc.NewBitArray(bs...)
+}
+
+func boolBit(x bool) myc.Value {
+	if x {
+		return bit(1)
+	}
+	return bit(0)
+}
@@ -40,7 +40,7 @@ func (c *Context) Build(ctx context.Context, dst cadata.PostExister, name string
 				}
 			}
 		}
-		return c.compile(ctx, dst, base, sd)
+		return c.compile(ctx, dst, name, base, sd)
 	}()
 	if err != nil {
 		return nil, err
@@ -1,10 +1,14 @@
 package build
 
 import (
+	"archive/zip"
+	"bytes"
 	"testing"
+	"testing/fstest"
 
 	"myceliumweb.org/mycelium/internal/testutil"
 	"myceliumweb.org/mycelium/myccanon"
+	"myceliumweb.org/mycelium/spore/test"
 
 	"github.com/stretchr/testify/require"
 )
@@ -30,3 +34,48 @@ func TestStdLib(t *testing.T) {
 		})
 	}
 }
+
+func TestSourceMap(t *testing.T) {
+	t.Parallel()
+	ctx := testutil.Context(t)
+	s := testutil.NewStore(t)
+	fsx := fstest.MapFS{
+		"failing/failing_test.sp": &fstest.MapFile{Data: []byte(`(import "testing")
+
+(defl assertEq {expected: (Array Bit 8), actual: (Array Bit 8)} ()
+    (if (!equal expected actual)
+        {}
+        (do (!panic actual) {})
+    )
+)
+
+(defl TestFail (testing.T) ()
+    (assertEq (b8 1) (b8 1))
+    (assertEq (b8 1) (b8 2))
+)
+
+(pub assertEq TestFail)
+`)},
+	}
+	c := NewContext([]Source{{Prefix: "", FS: fsx}, StdLib()})
+	c.EnableSourceMaps()
+	pkg, err := c.Build(ctx, s, "failing")
+	require.NoError(t, err)
+	require.NotEmpty(t, pkg.SourceMap)
+
+	tests, err := test.List(*pkg)
+	require.NoError(t, err)
+	require.Len(t, tests, 1)
+	res, err := test.Run(ctx, s, tests[0])
+	require.NoError(t, err)
+	require.False(t, res.Pass)
+	require.Equal(t, []string{"failing/failing_test.sp:6", "failing/failing_test.sp:12"}, res.Trace)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.WriteZip(ctx, "failing", false, &buf))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	sm, err := LoadSourceMap(zr)
+	require.NoError(t, err)
+	require.Equal(t, pkg.SourceMap, sm)
+}
@@ -3,10 +3,13 @@ package build
 import (
 	"archive/zip"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
+	"path"
 	"strings"
 
 	"myceliumweb.org/mycelium"
@@ -36,7 +39,8 @@ const (
 // Context is a build context, it has a list of sources,
 // and an import cache for packages.
 type Context struct {
-	sources []Source
+	sources    []Source
+	sourceMaps bool
 
 	cache map[string]compile.Package
 }
@@ -56,6 +60,12 @@ func NewContext(sources []Source) *Context {
 	}
 }
 
+// EnableSourceMaps causes packages built after the call to include a SourceMap.
+// The SourceMap for a package also covers all of its dependencies.
+func (c *Context) EnableSourceMaps() {
+	c.sourceMaps = true
+}
+
 // List produces a list of package names with the prefix
 func (c *Context) List(prefix string) ([]string, error) {
 	fsx, relPath, _, err := c.find(prefix)
@@ -133,22 +143,75 @@ func (c *Context) WriteZip(ctx context.Context, pkgName string, isExec bool, w i
 		pkg.NS[""] = lam
 	}
 	mval := pkg.NS.ToMycelium()
-	return myczip.WriteTo(ctx, s, mval, w)
+	zw := zip.NewWriter(w)
+	if err := myczip.Save(ctx, s, mval, zw); err != nil {
+		return err
+	}
+	if pkg.SourceMap != nil {
+		data, err := json.Marshal(pkg.SourceMap)
+		if err != nil {
+			return err
+		}
+		fw, err := zw.Create(SourceMapFilename)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
 }
 
 // compile invokes the compiler on the files in a source directory
-func (c *Context) compile(ctx context.Context, _ cadata.PostExister, base Namespace, sd *SourceDir) (*compile.Package, error) {
+func (c *Context) compile(ctx context.Context, _ cadata.PostExister, name string, base Namespace, sd *SourceDir) (*compile.Package, error) {
 	s2 := stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes)
 	comp := compile.New(s2, spore.Preamble())
+	if c.sourceMaps {
+		comp.EnableSourceMap()
+	}
 	pkg, err := comp.Compile(ctx, base, c.cache, slices2.Map(sd.Files, func(x *SourceFile) compile.SourceFile {
 		return x.SourceFile
 	}))
 	if err != nil {
 		return nil, fmt.Errorf("[compile %q] %w", sd.Path, err)
 	}
+	if pkg.SourceMap != nil {
+		// filenames are relative to the package, make them relative to the build context.
+		for site, pos := range pkg.SourceMap {
+			pos.Filename = path.Join(name, pos.Filename)
+			pkg.SourceMap[site] = pos
+		}
+		for _, sf := range sd.Files {
+			for _, istmt := range sf.DirectDeps {
+				maps.Copy(pkg.SourceMap, c.cache[istmt.Target].SourceMap)
+			}
+		}
+	}
 	return pkg, nil
 }
 
+// SourceMapFilename is the name of the file in a package zip which holds the package's SourceMap.
+const SourceMapFilename = "sourcemap.json"
+
+// LoadSourceMap reads the SourceMap from a package zip.
+// It returns nil if the package was built without a SourceMap.
+func LoadSourceMap(zr *zip.Reader) (compile.SourceMap, error) {
+	f, err := zr.Open(SourceMapFilename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var sm compile.SourceMap
+	if err := json.NewDecoder(f).Decode(&sm); err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
+
 func LoadPkg(zr *zip.Reader) (*compile.Package, cadata.Getter, error) {
 	val, src, err := myczip.Load(zr)
 	if err != nil {
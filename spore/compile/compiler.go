@@ -40,6 +40,7 @@ type Compiler struct {
 	rootScope Scope
 
 	vm       *mvm1.VM
+	sm       *sourceMapper
 	macros   map[ast.Symbol]MacroFunc
 	builtIns map[ast.Op]BuiltInFunc
 	prims    map[ast.Op]spec.Op
@@ -52,6 +53,7 @@ func New(s cadata.Store, preamble map[string]*Expr) Compiler {
 			NS: preamble,
 		},
 		vm: mvm1.New(0, s, mvm1.DefaultAccels()),
+		sm: &sourceMapper{},
 	}
 	c.macros = map[ast.Symbol]MacroFunc{
 		"b8":       makeB8,
@@ -98,6 +100,13 @@ func New(s cadata.Store, preamble map[string]*Expr) Compiler {
 	return c
 }
 
+// EnableSourceMap causes the Compiler to produce a SourceMap for each Package it compiles.
+func (sc *Compiler) EnableSourceMap() {
+	if sc.sm.out == nil {
+		sc.sm.out = SourceMap{}
+	}
+}
+
 // Package is the output of a compilation.
 // SourceFiles => | Compiler | => Package
 type Package struct {
@@ -105,6 +114,9 @@ type Package struct {
 	NS Namespace
 	// Internals is the internal namespace for the package
 	Internals map[string]*Expr
+	// SourceMap maps nodes in the package's Lambdas to positions in its source files.
+	// It is nil unless the Compiler has source maps enabled.
+	SourceMap SourceMap
 }
 
 // MakePackageName makes a package name from an import path
@@ -125,6 +137,10 @@ func (sc *Compiler) Compile(ctx context.Context, base Namespace, deps map[string
 		}
 	}
 
+	if sc.sm.enabled() {
+		sc.sm.out = SourceMap{}
+		sc.sm.exprs = nil
+	}
 	// new definitions will go to localNS as we evaluate the file contents
 	localNS := make(map[string]*Expr)
 	for k, v := range base {
@@ -158,6 +174,7 @@ func (sc *Compiler) Compile(ctx context.Context, base Namespace, deps map[string
 	return &Package{
 		NS:        pubNS,
 		Internals: pkgCtx.localNS,
+		SourceMap: sc.sm.out,
 	}, nil
 }
 
@@ -170,6 +187,7 @@ type pkgContext struct {
 
 func (sc *Compiler) compileFile(ctx context.Context, pkgCtx *pkgContext, file *SourceFile) error {
 	importNS := Namespace{}
+	sc.sm.setFile(file)
 	for i, node := range file.Nodes {
 		loc := Loc{uint32(i)}
 		fctx := fileContext{
@@ -316,7 +334,12 @@ func (sc *Compiler) compileAST(ctx context.Context, eb EB, loc Loc, scope *Scope
 	case ast.Tuple:
 		return sc.compileTuple(ctx, eb, loc, scope, e)
 	case ast.SExpr:
-		return sc.compileSExpr(ctx, eb, loc, scope, e)
+		expr, err := sc.compileSExpr(ctx, eb, loc, scope, e)
+		if err != nil {
+			return nil, err
+		}
+		sc.sm.noteExpr(e, expr)
+		return expr, nil
 	case ast.Symbol:
 		if fn, ok := sc.macros[e]; ok {
 			e2, err := fn(nil)
@@ -544,6 +567,9 @@ func (sc *Compiler) comptime(ctx context.Context, eb mycexpr.EB, loc Loc, scope
 	if err != nil {
 		return nil, err
 	}
+	if lam, ok := val.(*myc.Lambda); ok && expr.IsCode(spec.Lambda) {
+		sc.sm.addLambda(lam, expr.Arg(2))
+	}
 	return mycexpr.Literal(val), nil
 }
 
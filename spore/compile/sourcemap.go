@@ -0,0 +1,208 @@
+package compile
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"myceliumweb.org/mycelium/internal/cadata"
+	"myceliumweb.org/mycelium/mvm1"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/spec"
+	"myceliumweb.org/mycelium/spore/ast"
+	"myceliumweb.org/mycelium/spore/parser"
+)
+
+// SourcePos is the position of a node in a SourceFile
+type SourcePos struct {
+	Filename string
+	Loc      Loc
+	// Begin and End are byte offsets into the file
+	Begin, End uint32
+	// Line is the line that the node begins on, starting at 1
+	Line uint32
+}
+
+func (p SourcePos) String() string {
+	return fmt.Sprintf("%s:%d", p.Filename, p.Line)
+}
+
+// SourceMap maps Apply and Panic nodes in the bodies of compiled Lambdas to positions in the source.
+// It is a side table; it does not change the compiled code.
+type SourceMap map[mvm1.Site]SourcePos
+
+var _ mvm1.SourceMap = SourceMap{}
+
+func (sm SourceMap) Lookup(site mvm1.Site) (string, bool) {
+	pos, ok := sm[site]
+	if !ok {
+		return "", false
+	}
+	return pos.String(), true
+}
+
+type sourceMapEntry struct {
+	Lambda   string `json:"lambda"`
+	Node     uint32 `json:"node"`
+	Filename string `json:"file"`
+	Loc      Loc    `json:"loc"`
+	Begin    uint32 `json:"begin"`
+	End      uint32 `json:"end"`
+	Line     uint32 `json:"line"`
+}
+
+func (sm SourceMap) MarshalJSON() ([]byte, error) {
+	ents := make([]sourceMapEntry, 0, len(sm))
+	for site, pos := range sm {
+		ents = append(ents, sourceMapEntry{
+			Lambda:   site.Fingerprint.String(),
+			Node:     site.Node,
+			Filename: pos.Filename,
+			Loc:      pos.Loc,
+			Begin:    pos.Begin,
+			End:      pos.End,
+			Line:     pos.Line,
+		})
+	}
+	sort.Slice(ents, func(i, j int) bool {
+		if ents[i].Lambda != ents[j].Lambda {
+			return ents[i].Lambda < ents[j].Lambda
+		}
+		return ents[i].Node < ents[j].Node
+	})
+	return json.Marshal(ents)
+}
+
+func (sm *SourceMap) UnmarshalJSON(data []byte) error {
+	var ents []sourceMapEntry
+	if err := json.Unmarshal(data, &ents); err != nil {
+		return err
+	}
+	*sm = make(SourceMap, len(ents))
+	for _, ent := range ents {
+		var id cadata.ID
+		if err := id.UnmarshalBase64([]byte(ent.Lambda)); err != nil {
+			return err
+		}
+		site := mvm1.Site{Fingerprint: mvm1.Fingerprint(mvm1.RefFromCID(id)), Node: ent.Node}
+		(*sm)[site] = SourcePos{
+			Filename: ent.Filename,
+			Loc:      ent.Loc,
+			Begin:    ent.Begin,
+			End:      ent.End,
+			Line:     ent.Line,
+		}
+	}
+	return nil
+}
+
+// sourceMapper collects a SourceMap during compilation.
+// It is shared by all copies of a Compiler.
+type sourceMapper struct {
+	// out is nil if source maps are disabled
+	out SourceMap
+	// nodes holds the position of every non-empty SExpr in the current file.
+	// SExprs are identified by their first element, which is not copied by macro expansion.
+	nodes map[*ast.Node]SourcePos
+	// exprs holds the position of the SExpr that each Expr was compiled from.
+	exprs map[*Expr]SourcePos
+}
+
+func (sm *sourceMapper) enabled() bool {
+	return sm.out != nil
+}
+
+// setFile indexes the nodes in sf
+func (sm *sourceMapper) setFile(sf *SourceFile) {
+	if !sm.enabled() {
+		return
+	}
+	sm.nodes = make(map[*ast.Node]SourcePos)
+	sm.indexNodes(sf, nil, sf.Nodes, sf.Span.Children)
+}
+
+func (sm *sourceMapper) indexNodes(sf *SourceFile, loc Loc, nodes []ast.Node, spans []parser.Span) {
+	for i, node := range nodes {
+		if i >= len(spans) {
+			return
+		}
+		loc2 := append(loc[:len(loc):len(loc)], uint32(i))
+		switch x := node.(type) {
+		case ast.SExpr:
+			if len(x) == 0 {
+				continue
+			}
+			bound := spans[i].Bound
+			sm.nodes[&x[0]] = SourcePos{
+				Filename: sf.Filename,
+				Loc:      loc2,
+				Begin:    uint32(bound.Begin),
+				End:      uint32(bound.End),
+				Line:     lineOf(sf.Newlines, uint32(bound.Begin)),
+			}
+			sm.indexNodes(sf, loc2, x, spans[i].Children)
+		case ast.Array:
+			sm.indexNodes(sf, loc2, x, spans[i].Children)
+		case ast.Tuple:
+			sm.indexNodes(sf, loc2, x, spans[i].Children)
+		}
+	}
+}
+
+// noteExpr records that expr was compiled from e.
+// If expr was already compiled from an inner SExpr, the inner position is kept.
+func (sm *sourceMapper) noteExpr(e ast.SExpr, expr *Expr) {
+	if !sm.enabled() || len(e) == 0 || expr == nil {
+		return
+	}
+	pos, ok := sm.nodes[&e[0]]
+	if !ok {
+		return
+	}
+	if sm.exprs == nil {
+		sm.exprs = make(map[*Expr]SourcePos)
+	}
+	if _, exists := sm.exprs[expr]; !exists {
+		sm.exprs[expr] = pos
+	}
+}
+
+// addLambda adds the Apply and Panic nodes in body to the SourceMap.
+// body must be the Expr which was evaluated to produce lam.
+// The VM may encode the Lambda's body differently from body, depending on which nodes it shares,
+// so the position of each node is found by walking body alongside the encoded body of lam.
+func (sm *sourceMapper) addLambda(lam *myc.Lambda, body *Expr) {
+	if !sm.enabled() {
+		return
+	}
+	fp := mvm1.FingerprintOf(lam)
+	seen := make(map[uint32]struct{})
+	var walk func(e *Expr, x myc.Prog)
+	walk = func(e *Expr, x myc.Prog) {
+		n := uint32(len(x) - 1)
+		if _, exists := seen[n]; exists {
+			return
+		}
+		seen[n] = struct{}{}
+		if x.Code() != e.OpCode() {
+			// Params and Self are replaced with values when the body is closed over.
+			return
+		}
+		for i, arg := range e.ArgExprs() {
+			walk(arg, x.Input(i))
+		}
+		if e.IsCode(spec.Apply, spec.Panic) {
+			if pos, exists := sm.exprs[e]; exists {
+				sm.out[mvm1.Site{Fingerprint: fp, Node: n}] = pos
+			}
+		}
+	}
+	walk(body, lam.Body().Prog())
+}
+
+// lineOf returns the line number containing offset, starting at 1
+func lineOf(newlines []uint32, offset uint32) uint32 {
+	return uint32(sort.Search(len(newlines), func(i int) bool {
+		return newlines[i] >= offset
+	})) + 1
+}
@@ -0,0 +1,39 @@
+package compile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"myceliumweb.org/mycelium/mvm1"
+	"myceliumweb.org/mycelium/mycexpr"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/spec"
+)
+
+func TestAddLambdaShared(t *testing.T) {
+	var body, fault *Expr
+	lam, err := mycexpr.BuildLambda(myc.ProductType{}, myc.ProductType{}, func(eb EB) *Expr {
+		// shared is encoded once, so the fault is not at its position in a traversal of the tree.
+		shared := eb.Product(eb.B32(1), eb.B32(2))
+		fault = eb.Fault(eb.String("fault"))
+		body = eb.Product(eb.Product(shared, shared), fault)
+		return body
+	})
+	require.NoError(t, err)
+	pos := SourcePos{Filename: "a.sp", Line: 3}
+	sm := sourceMapper{
+		out:   SourceMap{},
+		exprs: map[*Expr]SourcePos{fault: pos},
+	}
+	sm.addLambda(lam, body)
+
+	var faultNode uint32
+	for i, node := range lam.Body().Prog() {
+		if node.IsCode(spec.Panic) {
+			faultNode = uint32(i)
+		}
+	}
+	site := mvm1.Site{Fingerprint: mvm1.FingerprintOf(lam), Node: faultNode}
+	require.Equal(t, SourceMap{site: pos}, sm.out)
+}
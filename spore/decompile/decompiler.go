@@ -46,6 +46,11 @@ func (dc *Decompiler) Decompile(x mycmem.Value) ast.Node {
 	return dc.comptime(dc.decompile(x))
 }
 
+// DecompileExpr returns the spore syntax for the expression x.
+func (dc *Decompiler) DecompileExpr(x *Expr) ast.Node {
+	return dc.astFromExpr(x)
+}
+
 func (dc *Decompiler) decompile(x mycmem.Value) (ret ast.Node) {
 	fp := mycmem.Fingerprint(x)
 	if node, exists := dc.dict[fp]; exists {
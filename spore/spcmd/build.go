@@ -3,6 +3,7 @@ package spcmd
 import (
 	"context"
 	"io"
+	"math"
 	"os"
 	"path"
 	"strings"
@@ -11,7 +12,9 @@ import (
 	"go.brendoncarroll.net/stdctx/logctx"
 
 	"myceliumweb.org/mycelium"
+	"myceliumweb.org/mycelium/internal/cadata"
 	"myceliumweb.org/mycelium/internal/stores"
+	"myceliumweb.org/mycelium/mvm1"
 	"myceliumweb.org/mycelium/spore/build"
 	"myceliumweb.org/mycelium/spore/test"
 )
@@ -45,6 +48,7 @@ func buildZipFile(ctx context.Context, pkgPath string, setEntry bool, out io.Wri
 		{Prefix: "", FS: os.DirFS(dir)},
 		build.StdLib(),
 	})
+	bc.EnableSourceMaps()
 	return bc.WriteZip(ctx, pkgPath, setEntry, out)
 }
 
@@ -62,11 +66,12 @@ var spTest = star.Command{
 	Metadata: star.Metadata{
 		Short: "build and run the tests for a package",
 	},
-	Flags: []star.IParam{},
+	Flags: []star.IParam{profileParam},
 	Pos:   []star.IParam{pkgParam},
 	F: func(c star.Context) error {
 		ctx := c.Context
 		pkgPath := pkgParam.Load(c)
+		profPath, _ := profileParam.LoadOpt(c)
 		pkgPath, shouldList := strings.CutSuffix(pkgPath, "/...")
 
 		bc := build.NewContext([]build.Source{
@@ -75,6 +80,7 @@ var spTest = star.Command{
 				FS:     os.DirFS(pkgPath)},
 			build.StdLib(),
 		})
+		bc.EnableSourceMaps()
 		var pkgPaths []string
 		if shouldList {
 			var err error
@@ -85,6 +91,12 @@ var spTest = star.Command{
 			pkgPaths = []string{pkgPath}
 		}
 
+		var prof *mvm1.Profiler
+		var names profileNames
+		if profPath != "" {
+			prof = mvm1.NewProfiler()
+			names = newProfileNames()
+		}
 		s := stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes)
 		for _, pkgPath := range pkgPaths {
 			c.Printf("%s", pkgPath)
@@ -96,6 +108,9 @@ var spTest = star.Command{
 			if err != nil {
 				return err
 			}
+			if names != nil {
+				names.addNS(pkgPath, pkg.NS)
+			}
 			if len(tests) == 0 {
 				c.Printf(" (no tests)\n")
 				continue
@@ -105,7 +120,7 @@ var spTest = star.Command{
 			for _, t := range tests {
 				indent := "  "
 				c.Printf("%s%s\n", indent, t.Name)
-				res, err := test.Run(ctx, s, t)
+				res, err := runTest(ctx, s, t, prof)
 				if err != nil {
 					return err
 				}
@@ -113,9 +128,34 @@ var spTest = star.Command{
 					c.Printf("   PASS\n")
 				} else {
 					c.Printf("   FAIL: %v\n", res.Fault)
+					printTrace(c, res.Trace)
 				}
 			}
 		}
+		if prof != nil {
+			return writeProfile(profPath, prof, names)
+		}
 		return nil
 	},
 }
+
+// runTest runs a test, sending events to prof if it is not nil.
+func runTest(ctx context.Context, s cadata.Store, t test.Test, prof *mvm1.Profiler) (test.Result, error) {
+	if prof == nil {
+		return test.Run(ctx, s, t)
+	}
+	vm, err := test.Load(ctx, s, t)
+	if err != nil {
+		return test.Result{}, err
+	}
+	vm.SetTracer(prof)
+	vm.Run(ctx, math.MaxUint64)
+	return test.Finish(ctx, t, vm), nil
+}
+
+// printTrace prints the source positions from a failed test
+func printTrace(c star.Context, trace []string) {
+	for _, pos := range trace {
+		c.Printf("      at %s\n", pos)
+	}
+}
@@ -0,0 +1,349 @@
+package spcmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.brendoncarroll.net/star"
+
+	"myceliumweb.org/mycelium"
+	"myceliumweb.org/mycelium/internal/cadata"
+	"myceliumweb.org/mycelium/internal/stores"
+	"myceliumweb.org/mycelium/mvm1"
+	"myceliumweb.org/mycelium/myccanon"
+	"myceliumweb.org/mycelium/mycexpr"
+	"myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/spore"
+	"myceliumweb.org/mycelium/spore/ast"
+	"myceliumweb.org/mycelium/spore/build"
+	"myceliumweb.org/mycelium/spore/compile"
+	"myceliumweb.org/mycelium/spore/decompile"
+	"myceliumweb.org/mycelium/spore/printer"
+	"myceliumweb.org/mycelium/spore/test"
+)
+
+var spDebug = star.Command{
+	Metadata: star.Metadata{
+		Short: "run a test or an entry point from a package in an interactive debugger",
+	},
+	Flags: []star.IParam{testNameParam, entryParam},
+	Pos:   []star.IParam{pkgParam},
+	F: func(c star.Context) error {
+		ctx := c.Context
+		pkgPath := pkgParam.Load(c)
+		testName, hasTest := testNameParam.LoadOpt(c)
+		entry, hasEntry := entryParam.LoadOpt(c)
+		if hasTest && hasEntry {
+			return fmt.Errorf("only one of --%s or --%s can be given", testNameParam.Name, entryParam.Name)
+		}
+		dir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		bc := build.NewContext([]build.Source{
+			{Prefix: "", FS: os.DirFS(dir)},
+			build.StdLib(),
+		})
+		bc.EnableSourceMaps()
+		s := stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes)
+		pkg, err := bc.Build(ctx, s, pkgPath)
+		if err != nil {
+			return err
+		}
+		if hasEntry {
+			return debugEntry(c, s, pkgPath, pkg, entry)
+		}
+		return debugTest(c, s, pkgPath, pkg, testName)
+	},
+}
+
+var testNameParam = star.Param[string]{
+	Name:     "test",
+	Repeated: true,
+	Parse:    star.ParseString,
+}
+
+var entryParam = star.Param[string]{
+	Name:     "entry",
+	Repeated: true,
+	Parse:    star.ParseString,
+}
+
+// debugTest debugs the test called name in pkg, or the first test if name is empty.
+func debugTest(c star.Context, s cadata.Store, pkgPath string, pkg *compile.Package, name string) error {
+	ctx := c.Context
+	tests, err := test.List(*pkg)
+	if err != nil {
+		return err
+	}
+	if len(tests) == 0 {
+		return fmt.Errorf("package %s has no tests", pkgPath)
+	}
+	t := tests[0]
+	if name != "" {
+		found := false
+		for _, t2 := range tests {
+			if t2.Name == name {
+				t, found = t2, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("package %s has no test %q", pkgPath, name)
+		}
+	}
+	vm, err := test.Load(ctx, s, t)
+	if err != nil {
+		return err
+	}
+	dbg := newDebugger(vm, pkg)
+	c.Printf("debugging %s.%s\n", pkgPath, t.Name)
+	if err := dbg.repl(ctx, c.StdIn, c.StdOut); err != nil {
+		return err
+	}
+	if vm.Halted() {
+		res := test.Finish(ctx, t, vm)
+		if res.Pass {
+			c.Printf("PASS\n")
+		} else {
+			c.Printf("FAIL: %v\n", res.Fault)
+			printTrace(c, res.Trace)
+		}
+	}
+	return nil
+}
+
+// debugEntry debugs a call to the Lambda called name in pkg, and prints its result if it returns.
+// The Lambda must take either the empty Product, or a namespace, which is the package's namespace.
+func debugEntry(c star.Context, s cadata.Store, pkgPath string, pkg *compile.Package, name string) error {
+	ctx := c.Context
+	la, ok := pkg.NS[name].(*mycmem.Lambda)
+	if !ok {
+		return fmt.Errorf("package %s has no lambda %q", pkgPath, name)
+	}
+	var arg mycmem.Value
+	switch in := la.LambdaType().In(); {
+	case mycmem.Equal(in, mycmem.ProductType{}):
+		arg = mycmem.Product{}
+	case mycmem.Equal(in, myccanon.NS_Type):
+		arg = pkg.NS.ToMycelium()
+	default:
+		return fmt.Errorf("%s takes %v. only lambdas taking the empty Product or a namespace can be debugged", name, in)
+	}
+	laz, err := mycexpr.BuildLazy(mycmem.AnyValueType{}, func(eb mycexpr.EB) *mycexpr.Expr {
+		return eb.AnyValueFrom(eb.Apply(eb.Lit(la), eb.Lit(arg)))
+	})
+	if err != nil {
+		return err
+	}
+	vm := mvm1.New(0, s, mvm1.DefaultAccels())
+	if err := vm.ImportLazy(ctx, s, laz); err != nil {
+		return err
+	}
+	vm.SetEval()
+	dbg := newDebugger(vm, pkg)
+	c.Printf("debugging %s.%s\n", pkgPath, name)
+	if err := dbg.repl(ctx, c.StdIn, c.StdOut); err != nil {
+		return err
+	}
+	if vm.Halted() && vm.Err() == nil {
+		av, err := vm.ExportAnyValue(ctx, s)
+		if err != nil {
+			return err
+		}
+		out, err := mycmem.LoadRoot(ctx, s, av.AsBytes())
+		if err != nil {
+			return err
+		}
+		c.Printf("%s\n", printer.Printer{}.PrintString(dbg.dc.Decompile(out.Unwrap())))
+	}
+	return nil
+}
+
+// debugger drives a VM from a line based command interface.
+type debugger struct {
+	vm    *mvm1.VM
+	ns    spore.Namespace
+	sm    mvm1.SourceMap
+	names map[mvm1.Fingerprint]string
+	dc    *decompile.Decompiler
+}
+
+func newDebugger(vm *mvm1.VM, pkg *compile.Package) *debugger {
+	dict := spore.Dictionary()
+	names := make(map[mvm1.Fingerprint]string)
+	for k, v := range pkg.NS {
+		names[mvm1.FingerprintOf(v)] = k
+		dict[cadata.ID(mycmem.Fingerprint(v))] = ast.Symbol(k)
+	}
+	var sm mvm1.SourceMap
+	if pkg.SourceMap != nil {
+		sm = pkg.SourceMap
+	}
+	return &debugger{
+		vm:    vm,
+		ns:    pkg.NS,
+		sm:    sm,
+		names: names,
+		dc:    decompile.New(dict),
+	}
+}
+
+func (d *debugger) repl(ctx context.Context, in *bufio.Reader, out *bufio.Writer) error {
+	const help = `commands:
+  step [n]       execute n instructions (default 1)
+  continue       run until a breakpoint is reached or the test halts
+  break <name>   break when the lambda is called. name is a symbol in the package, or a fingerprint
+  clear <name>   remove a breakpoint
+  frames         print the call stack
+  show           print the next call in the current function
+  args           print the input to the current function
+  stack          print the size of the stack
+  quit           exit the debugger
+`
+	for {
+		if d.vm.Halted() {
+			if err := d.vm.Err(); err != nil {
+				fmt.Fprintf(out, "halted: %v\n", err)
+			} else {
+				fmt.Fprintf(out, "halted\n")
+			}
+			return out.Flush()
+		}
+		fmt.Fprintf(out, "(sp debug) ")
+		if err := out.Flush(); err != nil {
+			return err
+		}
+		line, err := in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		cmd, args := fields[0], fields[1:]
+		switch cmd {
+		case "s", "step":
+			n := uint64(1)
+			if len(args) > 0 {
+				if n, err = strconv.ParseUint(args[0], 10, 64); err != nil {
+					fmt.Fprintf(out, "%v\n", err)
+					continue
+				}
+			}
+			d.vm.Run(ctx, n)
+			d.printFrame(ctx, out)
+		case "c", "continue":
+			d.vm.Run(ctx, math.MaxUint64)
+			if d.vm.AtBreakpoint() {
+				fmt.Fprintf(out, "breakpoint\n")
+				d.printFrame(ctx, out)
+			}
+		case "b", "break", "clear":
+			if len(args) != 1 {
+				fmt.Fprintf(out, "usage: %s <name>\n", cmd)
+				continue
+			}
+			fp, err := d.resolve(args[0])
+			if err != nil {
+				fmt.Fprintf(out, "%v\n", err)
+				continue
+			}
+			if cmd == "clear" {
+				d.vm.ClearBreakpoint(fp)
+			} else {
+				d.vm.SetBreakpoint(fp)
+			}
+		case "f", "frames":
+			for i, fr := range d.vm.Frames(ctx) {
+				fmt.Fprintf(out, "#%d %s pc=%d\n", i, d.frameName(fr), fr.PC)
+			}
+		case "show":
+			d.show(ctx, out)
+		case "args":
+			fr := d.vm.Frames(ctx)[0]
+			lam, ok := fr.Value.(*mycmem.Lambda)
+			if !ok || fr.PC != 0 {
+				fmt.Fprintf(out, "args are only available at the start of a lambda\n")
+				continue
+			}
+			arg, err := d.vm.PeekValue(ctx, lam.LambdaType().In(), 0)
+			if err != nil {
+				fmt.Fprintf(out, "%v\n", err)
+				continue
+			}
+			fmt.Fprintf(out, "%s\n", printer.Printer{}.PrintString(d.dc.Decompile(arg)))
+		case "stack":
+			fmt.Fprintf(out, "%d words\n", d.vm.StackLen())
+		case "q", "quit":
+			return out.Flush()
+		case "h", "help":
+			fmt.Fprint(out, help)
+		default:
+			fmt.Fprintf(out, "unknown command %q. try help\n", cmd)
+		}
+	}
+}
+
+func (d *debugger) printFrame(ctx context.Context, out io.Writer) {
+	fr := d.vm.Frames(ctx)[0]
+	fmt.Fprintf(out, "%s pc=%d\n", d.frameName(fr), fr.PC)
+}
+
+// show prints the expression for the next call or fault in the current frame, and its source position if it is known.
+func (d *debugger) show(ctx context.Context, out io.Writer) {
+	fr := d.vm.Frames(ctx)[0]
+	node, ok := d.vm.NextNode()
+	la, isLambda := fr.Value.(*mycmem.Lambda)
+	if !ok || !isLambda {
+		fmt.Fprintf(out, "%s pc=%d: no calls left to show\n", d.frameName(fr), fr.PC)
+		return
+	}
+	body := la.Body().Prog()
+	if int(node) >= len(body) {
+		fmt.Fprintf(out, "%s pc=%d: node %d is not in the body\n", d.frameName(fr), fr.PC, node)
+		return
+	}
+	pos := fmt.Sprintf("%s#%d", d.frameName(fr), node)
+	if d.sm != nil {
+		if p, ok := d.sm.Lookup(mvm1.Site{Fingerprint: fr.Fingerprint, Node: node}); ok {
+			pos = p
+		}
+	}
+	x := mycexpr.FromMycelium(mycmem.NewAnyProg(body[:node+1]))
+	fmt.Fprintf(out, "%s: %s\n", pos, printer.Printer{}.PrintString(d.dc.DecompileExpr(x)))
+}
+
+func (d *debugger) frameName(fr mvm1.Frame) string {
+	if name, exists := d.names[fr.Fingerprint]; exists {
+		return name
+	}
+	if fr.Value == nil && fr.Fingerprint == (mvm1.Fingerprint{}) {
+		return "<eval>"
+	}
+	return fr.Fingerprint.String()
+}
+
+// resolve returns the fingerprint for a symbol in the package namespace, or a fingerprint string.
+func (d *debugger) resolve(x string) (mvm1.Fingerprint, error) {
+	if v, exists := d.ns[x]; exists {
+		if _, ok := v.(*mycmem.Lambda); !ok {
+			return mvm1.Fingerprint{}, fmt.Errorf("%s is not a lambda", x)
+		}
+		return mvm1.FingerprintOf(v), nil
+	}
+	var id cadata.ID
+	if err := id.UnmarshalBase64([]byte(x)); err != nil {
+		return mvm1.Fingerprint{}, fmt.Errorf("%q is not a symbol in the package or a fingerprint", x)
+	}
+	return mvm1.Fingerprint(mvm1.RefFromCID(id)), nil
+}
@@ -0,0 +1,67 @@
+package spcmd
+
+import (
+	"os"
+
+	"go.brendoncarroll.net/star"
+
+	"myceliumweb.org/mycelium/mvm1"
+	"myceliumweb.org/mycelium/myccanon"
+	myc "myceliumweb.org/mycelium/mycmem"
+	"myceliumweb.org/mycelium/spore"
+	"myceliumweb.org/mycelium/spore/printer"
+)
+
+// profileParam is the path to write a CPU profile to.
+// No profile is written if it is not given.
+var profileParam = star.Param[string]{
+	Name:     "profile",
+	Repeated: true,
+	Parse:    star.ParseString,
+}
+
+// profileNames resolves fingerprints to names for profiles.
+type profileNames map[mvm1.Fingerprint]string
+
+func newProfileNames() profileNames {
+	names := make(profileNames)
+	for id, node := range spore.Dictionary() {
+		names[mvm1.Fingerprint(mvm1.RefFromCID(id))] = printer.Printer{}.PrintString(node)
+	}
+	return names
+}
+
+// addNS adds the lambdas in ns, and in any namespaces nested in ns, prefixing their names with prefix.
+func (pn profileNames) addNS(prefix string, ns myccanon.Namespace) {
+	for k, v := range ns {
+		name := k
+		if prefix != "" {
+			name = prefix + "." + k
+		}
+		if myc.Equal(v.Type(), myccanon.NS_Type) {
+			sub := myccanon.Namespace{}
+			if err := sub.FromMycelium(v); err == nil {
+				pn.addNS(name, sub)
+			}
+			continue
+		}
+		pn[mvm1.FingerprintOf(v)] = name
+	}
+}
+
+func (pn profileNames) lookup(fp mvm1.Fingerprint) string {
+	return pn[fp]
+}
+
+// writeProfile writes the profile to the file at p.
+func writeProfile(p string, prof *mvm1.Profiler, names profileNames) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := prof.WriteProfile(f, names.lookup); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -3,8 +3,11 @@ package spcmd
 import (
 	"archive/zip"
 	"bytes"
+	"errors"
 
 	"go.brendoncarroll.net/star"
+
+	"myceliumweb.org/mycelium/mvm1"
 	"myceliumweb.org/mycelium/myccmd"
 	"myceliumweb.org/mycelium/mycss"
 	"myceliumweb.org/mycelium/mycss/mycgui"
@@ -14,17 +17,17 @@ var spRun = star.Command{
 	Metadata: star.Metadata{
 		Short: "create a new pod to run an executable package",
 	},
-	Flags: []star.IParam{dbParam, cellParam, netParam, consoleParam},
+	Flags: []star.IParam{dbParam, keyParam, cellParam, netParam, consoleParam, watchParam, timerParam, mailboxParam, fsParam, autostartParam, recordParam, profileParam},
 	Pos:   []star.IParam{pkgParam},
 	F: func(c star.Context) error {
 		ctx := c.Context
 		buf := bytes.Buffer{}
 		pkgPath := pkgParam.Load(c)
+		profPath, _ := profileParam.LoadOpt(c)
 		if err := buildZipFile(ctx, pkgPath, true, &buf); err != nil {
 			return err
 		}
-		db := dbParam.Load(c)
-		sys := mycss.NewSystem(db)
+		sys := myccmd.NewSystem(c)
 		pod, err := sys.Create(ctx)
 		if err != nil {
 			return err
@@ -38,7 +41,21 @@ var spRun = star.Command{
 		if err := mycss.ResetZipReader(ctx, pod, zr, pcfg); err != nil {
 			return err
 		}
-		return mycss.Main(ctx, pod)
+		if profPath == "" {
+			return mycss.Main(ctx, pod)
+		}
+		ns, err := pod.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+		names := newProfileNames()
+		names.addNS("", ns)
+		prof := mvm1.NewProfiler()
+		err = mycss.MainTrace(ctx, pod, prof)
+		if err2 := writeProfile(profPath, prof, names); err2 != nil {
+			return errors.Join(err, err2)
+		}
+		return err
 	},
 }
 
@@ -46,7 +63,7 @@ var spRunGui = star.Command{
 	Metadata: star.Metadata{
 		Short: "run a package with a Graphical User Interface",
 	},
-	Flags: []star.IParam{dbParam, cellParam, netParam, consoleParam},
+	Flags: []star.IParam{dbParam, keyParam, cellParam, netParam, consoleParam, watchParam, timerParam, mailboxParam, fsParam, autostartParam, recordParam},
 	Pos:   []star.IParam{pkgParam},
 	F: func(c star.Context) error {
 		ctx := c.Context
@@ -55,8 +72,7 @@ var spRunGui = star.Command{
 		if err := buildZipFile(ctx, pkgPath, false, &buf); err != nil {
 			return err
 		}
-		db := dbParam.Load(c)
-		sys := mycss.NewSystem(db)
+		sys := myccmd.NewSystem(c)
 		pod, err := sys.Create(ctx)
 		if err != nil {
 			return err
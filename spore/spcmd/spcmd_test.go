@@ -0,0 +1,80 @@
+package spcmd
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.brendoncarroll.net/star"
+
+	"myceliumweb.org/mycelium/internal/testutil"
+)
+
+func TestRun(t *testing.T) {
+	t.Chdir("../../examples/helloworld")
+	runCmd(t, Root(), "run", "--console", "myconsole", ".")
+}
+
+func TestDebugEntry(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dbg.sp"), []byte(`
+(import "bits")
+
+(defl double {x: bits.B32} bits.B32 (bits.b32_add x x))
+(defl run {} bits.B32 (double (b32 21)))
+(pub double)
+(pub run)
+`), 0o644))
+	t.Chdir(dir)
+	out := runCmdIn(t, Root(), "break run\ncontinue\nshow\ncontinue\n", "debug", "--entry", "run", ".")
+	// show prints the call to double, not the whole of run.
+	require.Contains(t, out, "dbg.sp:5: (!apply double ")
+	require.Contains(t, out, "(b32 42)")
+}
+
+// TestOptionalFlags checks that each command can be run with only its required arguments.
+func TestOptionalFlags(t *testing.T) {
+	tcs := []struct {
+		Name string
+		Cmd  star.Command
+		Args []string
+	}{
+		{Name: "run", Cmd: spRun, Args: []string{"."}},
+		{Name: "run-gui", Cmd: spRunGui, Args: []string{"."}},
+		{Name: "test", Cmd: spTest, Args: []string{"."}},
+		{Name: "debug", Cmd: spDebug, Args: []string{"."}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			var called bool
+			cmd := tc.Cmd
+			cmd.F = func(star.Context) error {
+				called = true
+				return nil
+			}
+			runCmd(t, cmd, tc.Args...)
+			require.True(t, called)
+		})
+	}
+}
+
+// runCmd runs cmd with args, and returns what it printed.
+func runCmd(t testing.TB, cmd star.Command, args ...string) string {
+	return runCmdIn(t, cmd, "", args...)
+}
+
+// runCmdIn runs cmd with args and stdin, and returns what it printed.
+func runCmdIn(t testing.TB, cmd star.Command, stdin string, args ...string) string {
+	ctx := testutil.Context(t)
+	var stdout, stderr bytes.Buffer
+	outw, errw := bufio.NewWriter(&stdout), bufio.NewWriter(&stderr)
+	err := star.Run(ctx, cmd, map[string]string{}, "sp", args, bufio.NewReader(strings.NewReader(stdin)), outw, errw)
+	outw.Flush()
+	errw.Flush()
+	require.NoError(t, err, stderr.String())
+	return stdout.String()
+}
@@ -27,17 +27,17 @@ var rootCmd = star.NewDir(star.Metadata{
 
 	"run":     spRun,
 	"run-gui": spRunGui,
+	"debug":   spDebug,
 })
 
 var spEval = star.Command{
 	Metadata: star.Metadata{
 		Short: "evaluate a spore expression in the context of a pod",
 	},
-	Flags: []star.IParam{dbParam, podIDParam},
+	Flags: []star.IParam{dbParam, keyParam, podIDParam},
 	Pos:   []star.IParam{exprParam},
 	F: func(c star.Context) error {
-		db := dbParam.Load(c)
-		sys := mycss.NewSystem(db)
+		sys := myccmd.NewSystem(c)
 		pod, err := sys.Get(c, myccmd.PodIDParam.Load(c))
 		if err != nil {
 			return err
@@ -71,10 +71,17 @@ var exprParam = star.Param[string]{Name: "expr", Parse: star.ParseString}
 var (
 	dbParam    = myccmd.DBParam
 	podIDParam = myccmd.PodIDParam
+	keyParam   = myccmd.KeyParam
 
-	cellParam    = myccmd.CellParam
-	netParam     = myccmd.NetNodeParam
-	consoleParam = myccmd.ConsoleParam
+	cellParam      = myccmd.CellParam
+	netParam       = myccmd.NetNodeParam
+	consoleParam   = myccmd.ConsoleParam
+	watchParam     = myccmd.WatchParam
+	timerParam     = myccmd.TimerParam
+	mailboxParam   = myccmd.MailboxParam
+	fsParam        = myccmd.FSParam
+	autostartParam = myccmd.AutostartParam
+	recordParam    = myccmd.RecordParam
 )
 
 func newMemStore() cadata.Store {
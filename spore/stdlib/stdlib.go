@@ -15,6 +15,7 @@ var Base = map[string]myccanon.Namespace{
 	"floats":        floatsPkg,
 	"substrate":     substratePkg,
 	"substrate/net": netPkg,
+	"substrate/fs":  fsPkg,
 }
 
 var bitsPkg = myccanon.Namespace{
@@ -45,28 +46,71 @@ var bitsPkg = myccanon.Namespace{
 }
 
 var floatsPkg = myccanon.Namespace{
-	"Float16": myccanon.Float16,
+	"Float16":      myccanon.Float16,
+	"f16_from_f32": myccanon.Float16_FromFloat32,
+	"f16_from_f64": myccanon.Float16_FromFloat64,
 
-	"Float32": myccanon.Float32,
-	"f32_add": myccanon.Float32_Add,
-	"f32_sub": myccanon.Float32_Sub,
-	"f32_mul": myccanon.Float32_Mul,
-	"f32_div": myccanon.Float32_Div,
+	"Float32":       myccanon.Float32,
+	"f32_neg":       myccanon.Float32_Neg,
+	"f32_recip":     myccanon.Float32_Recip,
+	"f32_add":       myccanon.Float32_Add,
+	"f32_sub":       myccanon.Float32_Sub,
+	"f32_mul":       myccanon.Float32_Mul,
+	"f32_div":       myccanon.Float32_Div,
+	"f32_eq":        myccanon.Float32_Eq,
+	"f32_lt":        myccanon.Float32_Lt,
+	"f32_le":        myccanon.Float32_Le,
+	"f32_gt":        myccanon.Float32_Gt,
+	"f32_ge":        myccanon.Float32_Ge,
+	"f32_from_b32s": myccanon.Float32_FromB32S,
+	"f32_to_b32s":   myccanon.Float32_ToB32S,
+	"f32_from_b64s": myccanon.Float32_FromB64S,
+	"f32_to_b64s":   myccanon.Float32_ToB64S,
+	"f32_from_f16":  myccanon.Float32_FromFloat16,
+	"f32_from_f64":  myccanon.Float32_FromFloat64,
 
-	"Float64": myccanon.Float64,
-	"f64_add": myccanon.Float64_Add,
-	"f64_sub": myccanon.Float64_Sub,
-	"f64_mul": myccanon.Float64_Mul,
-	"f64_div": myccanon.Float64_Div,
+	"Float64":       myccanon.Float64,
+	"f64_neg":       myccanon.Float64_Neg,
+	"f64_recip":     myccanon.Float64_Recip,
+	"f64_add":       myccanon.Float64_Add,
+	"f64_sub":       myccanon.Float64_Sub,
+	"f64_mul":       myccanon.Float64_Mul,
+	"f64_div":       myccanon.Float64_Div,
+	"f64_eq":        myccanon.Float64_Eq,
+	"f64_lt":        myccanon.Float64_Lt,
+	"f64_le":        myccanon.Float64_Le,
+	"f64_gt":        myccanon.Float64_Gt,
+	"f64_ge":        myccanon.Float64_Ge,
+	"f64_from_b32s": myccanon.Float64_FromB32S,
+	"f64_to_b32s":   myccanon.Float64_ToB32S,
+	"f64_from_b64s": myccanon.Float64_FromB64S,
+	"f64_to_b64s":   myccanon.Float64_ToB64S,
+	"f64_from_f16":  myccanon.Float64_FromFloat16,
+	"f64_from_f32":  myccanon.Float64_FromFloat32,
 }
 
 var netPkg = myccanon.Namespace{
-	"NodeInfo": mycss.DEV_NET_NodeInfo,
-	"Message":  mycss.DEV_NET_Message,
-	"Addr":     mycss.DEV_NET_Addr,
+	"NetDev":      mycss.DEV_NET_Node,
+	"NodeReq":     mycss.DEV_NET_NodeReq,
+	"NodeInfo":    mycss.DEV_NET_NodeInfo,
+	"Message":     mycss.DEV_NET_Message,
+	"IncomingAsk": mycss.DEV_NET_IncomingAsk,
+	"Addr":        mycss.DEV_NET_Addr,
+}
+
+var fsPkg = myccanon.Namespace{
+	"FSDev": mycss.DEV_FS_Type,
+	"Info":  mycss.DEV_FS_Info,
+	"Req":   mycss.DEV_FS_Req,
 }
 
 var substratePkg = myccanon.Namespace{
-	"CellDev": mycss.DEV_CELL_Type,
-	"NetDev":  mycss.DEV_NET_Node,
+	"CellDev":    mycss.DEV_CELL_Type,
+	"NetDev":     mycss.DEV_NET_Node,
+	"WatchDev":   mycss.DEV_WATCH_Type,
+	"TimerDev":   mycss.DEV_TIMER_Type,
+	"TimerReq":   mycss.DEV_TIMER_Req,
+	"Time":       mycss.TAI64N,
+	"MailboxDev": mycss.DEV_MAILBOX_Type,
+	"MailboxMsg": mycss.DEV_MAILBOX_Msg,
 }
@@ -38,6 +38,10 @@ type Result struct {
 
 	Err   error
 	Fault myc.Value
+	// Trace holds the source positions of the call stack when the test faulted, starting with the fault,
+	// and ending with the test.
+	// Positions are only known if the package was built with a SourceMap.
+	Trace []string
 }
 
 // List lists the tests in a package
@@ -66,6 +70,17 @@ func List(pkg compile.Package) ([]Test, error) {
 
 // Run runs the Test x and returns a result
 func Run(ctx context.Context, src cadata.Getter, x Test) (Result, error) {
+	vm, err := Load(ctx, src, x)
+	if err != nil {
+		return Result{}, err
+	}
+	vm.Run(ctx, math.MaxUint64)
+	return Finish(ctx, x, vm), nil
+}
+
+// Load returns a VM which is ready to run the Test x.
+// The VM can be driven with Run or Step, and then passed to Finish.
+func Load(ctx context.Context, src cadata.Getter, x Test) (*mvm1.VM, error) {
 	s := stores.NewMem(mycelium.Hash, mycelium.MaxSizeBytes)
 	vm := mvm1.New(0, s, mvm1.DefaultAccels())
 	port := myc.NewRandPort(TestEnvType)
@@ -74,18 +89,29 @@ func Run(ctx context.Context, src cadata.Getter, x Test) (Result, error) {
 		return eb.Apply(eb.Lit(x.Lambda), eb.Lit(port))
 	})
 	if err != nil {
-		return Result{}, err
+		return nil, err
 	}
 	if err := vm.ImportLazy(ctx, src, laz); err != nil {
-		return Result{}, err
+		return nil, err
 	}
 	vm.SetEval()
-	vm.Run(ctx, math.MaxUint64)
+	return vm, nil
+}
+
+// Finish returns the Result of the Test x, which was run on vm until it halted.
+func Finish(ctx context.Context, x Test, vm *mvm1.VM) Result {
 	if err := vm.Err(); err != nil {
-		return Result{Test: x, Pass: false, Err: err, Fault: vm.GetFault()}, nil
+		var sm mvm1.SourceMap
+		if x.Pkg != nil && x.Pkg.SourceMap != nil {
+			sm = x.Pkg.SourceMap
+		}
+		trace := vm.StackTrace(ctx, sm)
+		// the last frame is the Lazy from Load which calls the test.
+		trace = trace[:len(trace)-1]
+		return Result{Test: x, Pass: false, Err: err, Fault: vm.GetFault(), Trace: trace}
 	}
 	return Result{
 		Test: x,
 		Pass: true,
-	}, nil
+	}
 }